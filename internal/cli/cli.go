@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/config"
+	"github.com/Dima-salang/proompt-vault-tui/internal/gitsync"
+	"github.com/Dima-salang/proompt-vault-tui/internal/tokenizer"
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+)
+
+// exit codes returned by Run
+const (
+	ExitOK    = 0
+	ExitError = 1
+	ExitUsage = 2
+)
+
+// App runs the pvt subcommands against a prompt service.
+type App struct {
+	Service vault.PromptService
+	Stdout  io.Writer
+	Stderr  io.Writer
+
+	// default token budget, used by the commands that report prompt sizes
+	Budget tokenizer.Budget
+
+	// syncs the vault with a git repository, nil if sync is not available
+	Sync *gitsync.Syncer
+
+	// storage the vault is kept in and its backend, for pvt convert and pvt reindex
+	Repository vault.PromptRepository
+	Backend    string
+
+	// opens the storage of a backend, at its default path when path is "".
+	// nil if the vault cannot be converted.
+	OpenStorage func(backend, path string) (vault.PromptRepository, func() error, error)
+
+	// the config file, the dir keys.toml and themes.toml are in, and how
+	// the settings in effect are loaded: the file, then the environment
+	// and the flags. LoadConfig is nil if there is no config.
+	ConfigPath string
+	ConfigDir  string
+	LoadConfig func() (config.Config, []string, error)
+}
+
+type command struct {
+	name    string
+	summary string
+	run     func(app *App, ctx context.Context, args []string) error
+}
+
+// usageError is returned by a command when it was called the wrong way
+type usageError struct {
+	err error
+}
+
+func (e usageError) Error() string {
+	return e.err.Error()
+}
+
+// errIssuesFound makes a command exit with ExitError without printing anything more,
+// for commands whose output already explains what is wrong
+var errIssuesFound = errors.New("issues found")
+
+// every subcommand, keyed by name
+var commands = map[string]command{}
+
+func register(c command) {
+	commands[c.name] = c
+}
+
+// Runs the subcommand named by args[0] and returns the process exit code.
+func (app *App) Run(ctx context.Context, args []string) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		app.usage()
+		return ExitOK
+	}
+
+	c, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(app.Stderr, "pvt: unknown command %q\n\n", args[0])
+		app.usage()
+		return ExitUsage
+	}
+
+	err := c.run(app, ctx, args[1:])
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, flag.ErrHelp):
+		return ExitOK
+	case errors.Is(err, errIssuesFound):
+		return ExitError
+	}
+
+	fmt.Fprintf(app.Stderr, "pvt %s: %v\n", c.name, err)
+	var uerr usageError
+	if errors.As(err, &uerr) {
+		return ExitUsage
+	}
+	return ExitError
+}
+
+func (app *App) usage() {
+	fmt.Fprintln(app.Stderr, "usage: pvt [flags] [command] [flags]")
+	fmt.Fprintln(app.Stderr)
+	fmt.Fprintln(app.Stderr, "Without a command the interactive vault is opened. The flags before the")
+	fmt.Fprintln(app.Stderr, "command override the settings, see pvt -h.")
+	fmt.Fprintln(app.Stderr)
+	fmt.Fprintln(app.Stderr, "commands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(app.Stderr, "  %-10s %s\n", name, commands[name].summary)
+	}
+}
+
+// creates the flag set of a subcommand, writing its usage to stderr
+func (app *App) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("pvt "+name, flag.ContinueOnError)
+	fs.SetOutput(app.Stderr)
+	return fs
+}
+
+// parses the flags of a subcommand, turning parse failures into usage errors
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return usageError{err}
+	}
+	return nil
+}
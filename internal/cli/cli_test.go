@@ -0,0 +1,664 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/config"
+	"github.com/Dima-salang/proompt-vault-tui/internal/gitsync"
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+	"github.com/boltdb/bolt"
+)
+
+// creates an app backed by a temporary bolt vault holding the given prompts
+func newTestApp(t *testing.T, prompts ...vault.Prompt) (*App, *bytes.Buffer, *bytes.Buffer) {
+	t.Helper()
+
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "test.db"), 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := vault.NewPromptRepository(db, logger)
+	service := vault.NewPromptService(repo)
+	for i := range prompts {
+		if _, err := service.CreateOrUpdatePrompt(context.Background(), &prompts[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	app := &App{Service: service, Stdout: stdout, Stderr: stderr, Repository: repo, Backend: vault.BackendBolt}
+	return app, stdout, stderr
+}
+
+func TestRun_UnknownCommand(t *testing.T) {
+	app, _, stderr := newTestApp(t)
+
+	if code := app.Run(context.Background(), []string{"nope"}); code != ExitUsage {
+		t.Errorf("Run() = %d, want %d", code, ExitUsage)
+	}
+	if !strings.Contains(stderr.String(), `unknown command "nope"`) {
+		t.Errorf("stderr = %q, want it to name the unknown command", stderr.String())
+	}
+}
+
+func TestRun_Lint(t *testing.T) {
+	tests := []struct {
+		name       string // description of this test case
+		prompts    []vault.Prompt
+		args       []string
+		wantCode   int
+		wantOutput string
+	}{
+		{
+			name:       "Clean vault",
+			prompts:    []vault.Prompt{{Title: "clean", PromptContent: "content"}},
+			args:       []string{"lint"},
+			wantCode:   ExitOK,
+			wantOutput: "no issues found",
+		},
+		{
+			name:       "Warnings do not fail by default",
+			prompts:    []vault.Prompt{{Title: "spaces", PromptContent: "content  "}},
+			args:       []string{"lint"},
+			wantCode:   ExitOK,
+			wantOutput: "[trailing-whitespace]",
+		},
+		{
+			name:       "Errors fail",
+			prompts:    []vault.Prompt{{Title: "braces", PromptContent: "{{oops"}},
+			args:       []string{"lint"},
+			wantCode:   ExitError,
+			wantOutput: "[unbalanced-braces]",
+		},
+		{
+			name:       "Minimum severity filters issues",
+			prompts:    []vault.Prompt{{Title: "spaces", PromptContent: "content  "}},
+			args:       []string{"lint", "--min-severity", "warning"},
+			wantCode:   ExitOK,
+			wantOutput: "no issues found",
+		},
+		{
+			name:     "Unknown severity is a usage error",
+			args:     []string{"lint", "--fail-on", "loud"},
+			wantCode: ExitUsage,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, stdout, _ := newTestApp(t, tt.prompts...)
+
+			if code := app.Run(context.Background(), tt.args); code != tt.wantCode {
+				t.Errorf("Run() = %d, want %d", code, tt.wantCode)
+			}
+			if !strings.Contains(stdout.String(), tt.wantOutput) {
+				t.Errorf("stdout = %q, want it to contain %q", stdout.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRun_Scan(t *testing.T) {
+	secret := vault.Prompt{Title: "leaky", PromptContent: "key sk-ant-REDACTED"}
+
+	app, stdout, _ := newTestApp(t, secret)
+	if code := app.Run(context.Background(), []string{"scan"}); code != ExitError {
+		t.Errorf("Run(scan) = %d, want %d", code, ExitError)
+	}
+	if !strings.Contains(stdout.String(), "content:1: Anthropic API key") {
+		t.Errorf("stdout = %q, want the finding", stdout.String())
+	}
+
+	// redacting fixes the vault, so a second scan is clean
+	if code := app.Run(context.Background(), []string{"scan", "--redact"}); code != ExitOK {
+		t.Errorf("Run(scan --redact) = %d, want %d", code, ExitOK)
+	}
+	stdout.Reset()
+	if code := app.Run(context.Background(), []string{"scan"}); code != ExitOK {
+		t.Errorf("Run(scan) after redacting = %d, want %d: %s", code, ExitOK, stdout.String())
+	}
+}
+
+func TestRun_Export(t *testing.T) {
+	secret := vault.Prompt{Title: "leaky", PromptContent: "key sk-ant-REDACTED"}
+
+	app, stdout, stderr := newTestApp(t, secret)
+	if code := app.Run(context.Background(), []string{"export"}); code != ExitOK {
+		t.Fatalf("Run(export) = %d, want %d", code, ExitOK)
+	}
+	if strings.Contains(stdout.String(), "sk-ant-") || !strings.Contains(stdout.String(), "[REDACTED]") {
+		t.Errorf("export was not redacted: %s", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "1 secret(s) redacted") {
+		t.Errorf("stderr = %q, want the redaction summary", stderr.String())
+	}
+
+	stdout.Reset()
+	if code := app.Run(context.Background(), []string{"export", "--no-redact"}); code != ExitOK {
+		t.Fatalf("Run(export --no-redact) = %d, want %d", code, ExitOK)
+	}
+	if !strings.Contains(stdout.String(), "sk-ant-") {
+		t.Errorf("--no-redact export lost the content: %s", stdout.String())
+	}
+}
+
+func TestRun_Get(t *testing.T) {
+	prompt := vault.Prompt{Title: "reviewer", PromptContent: "Review this code carefully."}
+
+	tests := []struct {
+		name       string // description of this test case
+		args       []string
+		wantCode   int
+		wantOutput []string
+	}{
+		{
+			name:       "Prints the content",
+			args:       []string{"get", "1"},
+			wantCode:   ExitOK,
+			wantOutput: []string{"Review this code carefully."},
+		},
+		{
+			name:       "Prints the stats",
+			args:       []string{"get", "--stats", "1"},
+			wantCode:   ExitOK,
+			wantOutput: []string{"#1 reviewer", "words      4", "gpt ~", "claude ~"},
+		},
+		{
+			name:       "Flags prompts over budget",
+			args:       []string{"get", "--stats", "--model", "claude", "--budget", "2", "1"},
+			wantCode:   ExitError,
+			wantOutput: []string{"(claude), over budget"},
+		},
+		{
+			name:     "Unknown prompt",
+			args:     []string{"get", "42"},
+			wantCode: ExitError,
+		},
+		{
+			name:     "Missing id",
+			args:     []string{"get"},
+			wantCode: ExitUsage,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, stdout, _ := newTestApp(t, prompt)
+
+			if code := app.Run(context.Background(), tt.args); code != tt.wantCode {
+				t.Errorf("Run() = %d, want %d", code, tt.wantCode)
+			}
+			for _, want := range tt.wantOutput {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("stdout = %q, want it to contain %q", stdout.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestRun_GetExpandsIncludes(t *testing.T) {
+	app, stdout, _ := newTestApp(t,
+		vault.Prompt{Title: "House Style", PromptContent: "Be concise."},
+		vault.Prompt{Title: "Reviewer", PromptContent: "{{> house-style}}\nReview the code."},
+	)
+
+	if code := app.Run(context.Background(), []string{"get", "2"}); code != ExitOK {
+		t.Fatalf("Run(get) = %d, want %d", code, ExitOK)
+	}
+	if want := "Be concise.\nReview the code.\n"; stdout.String() != want {
+		t.Errorf("stdout = %q, want %q", stdout.String(), want)
+	}
+
+	stdout.Reset()
+	if code := app.Run(context.Background(), []string{"get", "--raw", "2"}); code != ExitOK {
+		t.Fatalf("Run(get --raw) = %d, want %d", code, ExitOK)
+	}
+	if !strings.Contains(stdout.String(), "{{> house-style}}") {
+		t.Errorf("stdout = %q, want the include directive", stdout.String())
+	}
+}
+
+func TestRun_GetBySlug(t *testing.T) {
+	app, stdout, stderr := newTestApp(t,
+		vault.Prompt{Title: "House Style", PromptContent: "Be concise."},
+		vault.Prompt{Title: "Reviewer", Slug: "code-review", PromptContent: "Review the code."},
+	)
+
+	for ref, want := range map[string]string{"house-style": "Be concise.\n", "code-review": "Review the code.\n"} {
+		stdout.Reset()
+		if code := app.Run(context.Background(), []string{"get", ref}); code != ExitOK {
+			t.Fatalf("Run(get %s) = %d, want %d: %s", ref, code, ExitOK, stderr.String())
+		}
+		if stdout.String() != want {
+			t.Errorf("get %s = %q, want %q", ref, stdout.String(), want)
+		}
+	}
+
+	if code := app.Run(context.Background(), []string{"get", "reviewer"}); code != ExitError {
+		t.Errorf("Run(get reviewer) = %d, want %d", code, ExitError)
+	}
+}
+
+func TestRun_Import(t *testing.T) {
+	source, exported, _ := newTestApp(t,
+		vault.Prompt{Title: "House Style", PromptContent: "Be concise."},
+	)
+	if code := source.Run(context.Background(), []string{"export"}); code != ExitOK {
+		t.Fatalf("Run(export) = %d, want %d", code, ExitOK)
+	}
+
+	path := filepath.Join(t.TempDir(), "export.json")
+	if err := os.WriteFile(path, exported.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	app, _, stderr := newTestApp(t, vault.Prompt{Title: "Mine", PromptContent: "Mine."})
+	for _, want := range []string{"1 created", "1 unchanged"} {
+		stderr.Reset()
+		if code := app.Run(context.Background(), []string{"import", path}); code != ExitOK {
+			t.Fatalf("Run(import) = %d, want %d: %s", code, ExitOK, stderr.String())
+		}
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("stderr = %q, want %q", stderr.String(), want)
+		}
+	}
+}
+
+func TestRun_Sync(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	if out, err := exec.Command("git", "init", "--quiet", "--bare", remote).CombinedOutput(); err != nil {
+		t.Fatalf("git init --bare: %v: %s", err, out)
+	}
+
+	app, _, stderr := newTestApp(t, vault.Prompt{Title: "House Style", PromptContent: "Be concise."})
+	app.Sync = gitsync.New(filepath.Join(t.TempDir(), "sync"), app.Service)
+
+	if code := app.Run(context.Background(), []string{"sync"}); code != ExitError {
+		t.Errorf("Run(sync) before init = %d, want %d", code, ExitError)
+	}
+	if code := app.Run(context.Background(), []string{"sync", "init", remote}); code != ExitOK {
+		t.Fatalf("Run(sync init) = %d, want %d: %s", code, ExitOK, stderr.String())
+	}
+
+	stderr.Reset()
+	if code := app.Run(context.Background(), []string{"sync"}); code != ExitOK {
+		t.Fatalf("Run(sync) = %d, want %d: %s", code, ExitOK, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "1 pushed") {
+		t.Errorf("stderr = %q, want the pushed prompt reported", stderr.String())
+	}
+}
+
+func TestRun_Convert(t *testing.T) {
+	app, _, stderr := newTestApp(t,
+		vault.Prompt{Title: "House Style", PromptContent: "Be concise."},
+		vault.Prompt{Title: "Review", PromptContent: "Review it."},
+	)
+	dir := filepath.Join(t.TempDir(), "prompts")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	app.OpenStorage = func(backend, path string) (vault.PromptRepository, func() error, error) {
+		if path == "" {
+			path = dir
+		}
+		return vault.OpenRepository(backend, path, logger)
+	}
+
+	if code := app.Run(context.Background(), []string{"convert", "--to", "mysql"}); code != ExitUsage {
+		t.Errorf("Run(convert --to mysql) = %d, want %d", code, ExitUsage)
+	}
+	if code := app.Run(context.Background(), []string{"convert", "--to", "bolt"}); code != ExitUsage {
+		t.Errorf("Run(convert --to bolt) = %d, want %d", code, ExitUsage)
+	}
+
+	stderr.Reset()
+	if code := app.Run(context.Background(), []string{"convert", "--to", "markdown"}); code != ExitOK {
+		t.Fatalf("Run(convert) = %d, want %d: %s", code, ExitOK, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "converted 2 prompt(s)") {
+		t.Errorf("stderr = %q, want the number of prompts converted", stderr.String())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "house-style.md")); err != nil {
+		t.Errorf("converted vault is missing house-style.md: %v", err)
+	}
+
+	// converting again would mix two vaults
+	if code := app.Run(context.Background(), []string{"convert", "--to", "markdown"}); code != ExitError {
+		t.Errorf("Run(convert) into a non empty vault = %d, want %d", code, ExitError)
+	}
+}
+
+func TestRun_Reindex(t *testing.T) {
+	app, stdout, _ := newTestApp(t, vault.Prompt{Title: "House Style", PromptContent: "Be concise.", Tags: []string{"style"}})
+
+	if code := app.Run(context.Background(), []string{"reindex", "--check"}); code != ExitOK {
+		t.Fatalf("Run(reindex --check) = %d, want %d: %s", code, ExitOK, stdout.String())
+	}
+	if !strings.Contains(stdout.String(), "the indexes match") {
+		t.Errorf("stdout = %q, want the indexes reported sound", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := app.Run(context.Background(), []string{"reindex"}); code != ExitOK {
+		t.Fatalf("Run(reindex) = %d, want %d: %s", code, ExitOK, stdout.String())
+	}
+	if !strings.Contains(stdout.String(), "rebuilt the indexes, 0 entries") {
+		t.Errorf("stdout = %q, want the indexes rebuilt", stdout.String())
+	}
+
+	// backends without indexes have nothing to rebuild
+	app.Repository, app.Backend = vault.NewMemoryRepository(), "memory"
+	stdout.Reset()
+	if code := app.Run(context.Background(), []string{"reindex"}); code != ExitOK || !strings.Contains(stdout.String(), "keeps no indexes") {
+		t.Errorf("Run(reindex) on the memory backend = %d, %q, want nothing to do", code, stdout.String())
+	}
+}
+
+// opens a bolt vault holding two prompts, the second of which the last
+// crash left half written
+func newDamagedRepository(t *testing.T) vault.PromptRepository {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.db")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo, closeRepo, err := vault.OpenRepository(vault.BackendBolt, path, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { closeRepo() })
+	if _, err := repo.CreateOrUpdatePrompts(context.Background(), []vault.Prompt{
+		{Title: "Review", PromptContent: "c"},
+		{Title: "Summary", PromptContent: "c"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := closeRepo(); err != nil {
+		t.Fatal(err)
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("prompts")).Put([]byte{0, 0, 0, 0, 0, 0, 0, 2}, []byte(`{"ID": 2, "Tit`))
+	})
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo, closeRepo, err = vault.OpenRepository(vault.BackendBolt, path, logger); err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func TestRun_Doctor(t *testing.T) {
+	repo := newDamagedRepository(t)
+	stdout := &bytes.Buffer{}
+	app := &App{Service: vault.NewPromptService(repo), Stdout: stdout, Stderr: io.Discard, Repository: repo, Backend: vault.BackendBolt}
+
+	tests := []struct {
+		name       string // description of this test case
+		args       []string
+		wantCode   int
+		wantOutput string
+	}{
+		{"reports the bad record", []string{"doctor"}, ExitError, "record 2 cannot be decoded"},
+		{"hints at the repair", []string{"doctor"}, ExitError, "run pvt doctor --repair"},
+		{"repairs", []string{"doctor", "--repair"}, ExitOK, "repaired: quarantined 1 record(s)"},
+		{"is healthy afterwards", []string{"doctor"}, ExitOK, "1 record(s) quarantined by earlier repairs"},
+		{"compacts", []string{"doctor", "--compact"}, ExitOK, "compacted the database"},
+		{"still works after compacting", []string{"doctor"}, ExitOK, "no problems found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout.Reset()
+			if code := app.Run(context.Background(), tt.args); code != tt.wantCode {
+				t.Errorf("Run(%v) = %d, want %d: %s", tt.args, code, tt.wantCode, stdout.String())
+			}
+			if !strings.Contains(stdout.String(), tt.wantOutput) {
+				t.Errorf("stdout = %q, want it to contain %q", stdout.String(), tt.wantOutput)
+			}
+		})
+	}
+
+	// backends without a database file have nothing to check
+	app.Repository, app.Backend = vault.NewMemoryRepository(), "memory"
+	stdout.Reset()
+	if code := app.Run(context.Background(), []string{"doctor"}); code != ExitOK || !strings.Contains(stdout.String(), "nothing to do") {
+		t.Errorf("Run(doctor) on the memory backend = %d, %q, want nothing to do", code, stdout.String())
+	}
+}
+
+func TestRun_UnreadableRecord(t *testing.T) {
+	source, exported, _ := newTestApp(t, vault.Prompt{Title: "House Style", PromptContent: "Be concise."})
+	if code := source.Run(context.Background(), []string{"export"}); code != ExitOK {
+		t.Fatalf("Run(export) = %d, want %d", code, ExitOK)
+	}
+	path := filepath.Join(t.TempDir(), "export.json")
+	if err := os.WriteFile(path, exported.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := newDamagedRepository(t)
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	app := &App{Service: vault.NewPromptService(repo), Stdout: stdout, Stderr: stderr, Repository: repo, Backend: vault.BackendBolt}
+
+	// nothing may pass for the whole vault while a record cannot be read
+	tests := []struct {
+		name       string // description of this test case
+		args       []string
+		wantOutput string
+	}{
+		{"export refuses", []string{"export"}, "cannot export: 1 prompt record(s) cannot be read, run pvt doctor --repair"},
+		{"scan warns", []string{"scan"}, "warning: 1 prompt record(s) cannot be read"},
+		{"import refuses", []string{"import", path}, "cannot import: 1 prompt record(s) cannot be read"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout.Reset()
+			stderr.Reset()
+			if code := app.Run(context.Background(), tt.args); code != ExitError {
+				t.Errorf("Run(%v) = %d, want %d", tt.args, code, ExitError)
+			}
+			if !strings.Contains(stderr.String(), tt.wantOutput) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr.String(), tt.wantOutput)
+			}
+		})
+	}
+	prompts, err := repo.GetAllPrompts(context.Background())
+	if len(prompts) != 1 || !errors.Is(err, vault.ErrUnreadable) {
+		t.Errorf("GetAllPrompts() = %d prompts, %v, want the import to have written nothing", len(prompts), err)
+	}
+}
+
+func TestRun_Dupes(t *testing.T) {
+	content := "Review the following code for bugs and style problems, then suggest a fix for each issue you find."
+	tests := []struct {
+		name       string // description of this test case
+		prompts    []vault.Prompt
+		args       []string
+		wantCode   int
+		wantOutput string
+	}{
+		{
+			name: "Lists the copies",
+			prompts: []vault.Prompt{
+				{Title: "Review", PromptContent: content},
+				{Title: "Review copy", PromptContent: content},
+				{Title: "Summary", PromptContent: "Summarize the article."},
+			},
+			args:     []string{"dupes"},
+			wantCode: ExitOK,
+			// the most recently updated copy is the one to keep
+			wantOutput: "100% similar\n  #2 review-copy  Review copy\n  #1 review  Review\n",
+		},
+		{
+			name:       "Nothing similar",
+			prompts:    []vault.Prompt{{Title: "Review", PromptContent: content}, {Title: "Summary", PromptContent: "Summarize the article."}},
+			args:       []string{"dupes"},
+			wantCode:   ExitOK,
+			wantOutput: "no duplicates found",
+		},
+		{
+			name:     "Threshold out of range",
+			args:     []string{"dupes", "--threshold", "1.5"},
+			wantCode: ExitUsage,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, stdout, _ := newTestApp(t, tt.prompts...)
+			if code := app.Run(context.Background(), tt.args); code != tt.wantCode {
+				t.Errorf("Run(%v) = %d, want %d", tt.args, code, tt.wantCode)
+			}
+			if !strings.Contains(stdout.String(), tt.wantOutput) {
+				t.Errorf("stdout = %q, want it to contain %q", stdout.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRun_Trash(t *testing.T) {
+	content := "Review the following code for bugs and style problems, then suggest a fix for each issue you find."
+	app, stdout, stderr := newTestApp(t,
+		vault.Prompt{Title: "Review", PromptContent: content},
+		vault.Prompt{Title: "Review copy", PromptContent: content},
+	)
+	if code := app.Run(context.Background(), []string{"trash"}); code != ExitOK || !strings.Contains(stdout.String(), "the trash is empty") {
+		t.Errorf("Run(trash) = %d, %q, want the trash empty", code, stdout.String())
+	}
+	if _, err := app.Service.MergePrompts(context.Background(), 1, []int{2}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string // description of this test case
+		args       []string
+		wantCode   int
+		wantOutput string
+	}{
+		{"lists the merged copy", []string{"trash"}, ExitOK, "#2 review-copy  Review copy  trashed "},
+		{"not an id", []string{"trash", "restore", "review-copy"}, ExitUsage, `"review-copy" is not a prompt id`},
+		{"restores it", []string{"trash", "restore", "2"}, ExitOK, "restored #2 review-copy-2"},
+		{"once", []string{"trash", "restore", "2"}, ExitError, "not in the trash"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout.Reset()
+			stderr.Reset()
+			if code := app.Run(context.Background(), tt.args); code != tt.wantCode {
+				t.Errorf("Run(%v) = %d, want %d: %s", tt.args, code, tt.wantCode, stderr.String())
+			}
+			if output := stdout.String() + stderr.String(); !strings.Contains(output, tt.wantOutput) {
+				t.Errorf("output = %q, want it to contain %q", output, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRun_Search(t *testing.T) {
+	prompts := []vault.Prompt{
+		{Title: "Schema changes", PromptContent: "Review this SQL migration before it ships."},
+		{Title: "Code review", PromptContent: "Review the code for bugs."},
+		{Title: "Summary", PromptContent: "Summarize the article."},
+	}
+	tests := []struct {
+		name       string // description of this test case
+		args       []string
+		wantCode   int
+		wantOutput []string
+	}{
+		{"Best match first", []string{"search", "reviewing", "sql", "migrations"}, ExitOK, []string{"#1 schema-changes  Schema changes\n", "#2 code-review  Code review\n"}},
+		{"Limit", []string{"search", "--limit", "1", "review"}, ExitOK, []string{"#"}},
+		{"Nothing found", []string{"search", "kubernetes"}, ExitOK, []string{"no prompts match\n"}},
+		{"No words", []string{"search"}, ExitUsage, nil},
+		{"Query", []string{"search", "review", "-sql"}, ExitOK, []string{"#2 code-review  Code review\n"}},
+		{"Fields alone", []string{"search", "title:summary"}, ExitOK, []string{"     -  #3 summary  Summary\n"}},
+		{"Unquoted phrase", []string{"search", "title:Code review"}, ExitOK, []string{"#2 code-review"}},
+		{"Malformed query", []string{"search", "pinned:maybe"}, ExitUsage, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, stdout, _ := newTestApp(t, prompts...)
+			if code := app.Run(context.Background(), tt.args); code != tt.wantCode {
+				t.Errorf("Run(%v) = %d, want %d", tt.args, code, tt.wantCode)
+			}
+			lines := strings.SplitAfter(stdout.String(), "\n")
+			if tt.wantOutput != nil && len(lines)-1 != len(tt.wantOutput) {
+				t.Fatalf("stdout = %q, want %d line(s)", stdout.String(), len(tt.wantOutput))
+			}
+			for i, want := range tt.wantOutput {
+				if !strings.Contains(lines[i], want) {
+					t.Errorf("line %d = %q, want %q", i, lines[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestRun_Config(t *testing.T) {
+	dir := t.TempDir()
+	app, stdout, stderr := newTestApp(t)
+	app.ConfigDir = dir
+	app.ConfigPath = filepath.Join(dir, "config.toml")
+	app.LoadConfig = func() (config.Config, []string, error) { return config.Load(app.ConfigPath) }
+
+	run := func(args ...string) int {
+		stdout.Reset()
+		stderr.Reset()
+		return app.Run(context.Background(), append([]string{"config"}, args...))
+	}
+
+	if code := run("path"); code != ExitOK || stdout.String() != app.ConfigPath+"\n" {
+		t.Errorf("Run(config path) = %d, %q, want the path", code, stdout.String())
+	}
+	if code := run("set", "editor.height", "20"); code != ExitOK {
+		t.Fatalf("Run(config set) = %d, want %d: %s", code, ExitOK, stderr.String())
+	}
+	if code := run("get", "editor.height"); code != ExitOK || stdout.String() != "20\n" {
+		t.Errorf("Run(config get) = %d, %q, want 20", code, stdout.String())
+	}
+	if code := run("get"); code != ExitOK || !strings.Contains(stdout.String(), "editor.height = 20\n") || !strings.Contains(stdout.String(), "storage.backend = \"bolt\"\n") {
+		t.Errorf("Run(config get) = %d, %q, want every setting", code, stdout.String())
+	}
+	if code := run("validate"); code != ExitOK || stdout.String() != app.ConfigPath+": ok\n" {
+		t.Errorf("Run(config validate) = %d, %q, want ok", code, stdout.String())
+	}
+
+	for _, args := range [][]string{{}, {"edit"}, {"set", "editor.height"}, {"set", "editor.colour", "red"}, {"get", "editor.colour"}, {"path", "x"}} {
+		if code := run(args...); code != ExitUsage {
+			t.Errorf("Run(config %v) = %d, want %d", args, code, ExitUsage)
+		}
+	}
+	if code := run("set", "editor.height", "tall"); code != ExitError {
+		t.Errorf("Run(config set) of a bad value = %d, want %d", code, ExitError)
+	}
+
+	// unknown keys are warned about, bad values and keymaps are errors
+	if err := os.WriteFile(app.ConfigPath, []byte("[editor]\nheigth = 20\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if code := run("validate"); code != ExitOK || !strings.Contains(stderr.String(), "warning: "+app.ConfigPath+": unknown key editor.heigth") {
+		t.Errorf("Run(config validate) = %d, %q, want a warning", code, stderr.String())
+	}
+	if err := os.WriteFile(filepath.Join(dir, "keys.toml"), []byte("[list]\nnew = \"d\"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if code := run("validate"); code != ExitError || !strings.Contains(stderr.String(), "keys.toml") {
+		t.Errorf("Run(config validate) = %d, %q, want the keymap conflict", code, stderr.String())
+	}
+}
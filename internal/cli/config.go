@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/config"
+	"github.com/Dima-salang/proompt-vault-tui/internal/keymap"
+	"github.com/Dima-salang/proompt-vault-tui/internal/theme"
+)
+
+func init() {
+	register(command{
+		name:    "config",
+		summary: "show and change the settings: config get|set|path|validate",
+		run:     (*App).config,
+	})
+}
+
+// pvt config get [key]
+// pvt config set <key> <value>
+// pvt config path
+// pvt config validate
+func (app *App) config(ctx context.Context, args []string) error {
+	if app.LoadConfig == nil {
+		return errors.New("the config is not available")
+	}
+	if len(args) == 0 {
+		return usageError{errors.New("expected get, set, path or validate")}
+	}
+
+	fs := app.flags("config " + args[0])
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+	switch args[0] {
+	case "get":
+		return app.configGet(fs.Args())
+	case "set":
+		if fs.NArg() != 2 {
+			return usageError{errors.New("expected a key and a value")}
+		}
+		return app.configSet(fs.Arg(0), fs.Arg(1))
+	case "path":
+		if fs.NArg() != 0 {
+			return usageError{fmt.Errorf("unexpected argument %q", fs.Arg(0))}
+		}
+		fmt.Fprintln(app.Stdout, app.ConfigPath)
+		return nil
+	case "validate":
+		if fs.NArg() != 0 {
+			return usageError{fmt.Errorf("unexpected argument %q", fs.Arg(0))}
+		}
+		return app.configValidate()
+	}
+	return usageError{fmt.Errorf("unknown config command %q, expected get, set, path or validate", args[0])}
+}
+
+// prints the settings in effect, from the file, the environment and the
+// flags, as a config file would have them
+func (app *App) configGet(keys []string) error {
+	c, warnings, err := app.LoadConfig()
+	app.warn(warnings)
+	if err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		for _, s := range c.Settings() {
+			fmt.Fprintf(app.Stdout, "%s = %s\n", s.Key, s.TOML())
+		}
+		return nil
+	}
+	for _, key := range keys {
+		s, err := c.Setting(key)
+		if err != nil {
+			return usageError{err}
+		}
+		fmt.Fprintln(app.Stdout, s.Get())
+	}
+	return nil
+}
+
+func (app *App) configSet(key, value string) error {
+	c := config.Default()
+	s, err := c.Setting(key)
+	if err != nil {
+		return usageError{err}
+	}
+	if err := config.SetFile(app.ConfigPath, key, value); err != nil {
+		return err
+	}
+	fmt.Fprintf(app.Stderr, "set %s in %s\n", key, app.ConfigPath)
+	if os.Getenv(s.EnvName()) != "" {
+		fmt.Fprintf(app.Stderr, "warning: %s is set and overrides it\n", s.EnvName())
+	}
+	return nil
+}
+
+// checks the config file and, next to it in the config dir, the keys
+// and the themes of the tui
+func (app *App) configValidate() error {
+	c, warnings, err := app.LoadConfig()
+	app.warn(warnings)
+	errs := []error{err}
+	if err == nil {
+		_, err = theme.Load(filepath.Join(app.ConfigDir, "themes.toml"), c.TUI.Theme)
+		errs = append(errs, err)
+	}
+	_, err = keymap.Load(filepath.Join(app.ConfigDir, "keys.toml"))
+	errs = append(errs, err)
+
+	if err := errors.Join(errs...); err != nil {
+		fmt.Fprintln(app.Stderr, err)
+		return errIssuesFound
+	}
+	fmt.Fprintf(app.Stdout, "%s: ok\n", app.ConfigPath)
+	return nil
+}
+
+// prints the warnings about the config
+func (app *App) warn(warnings []string) {
+	for _, w := range warnings {
+		fmt.Fprintln(app.Stderr, "warning:", w)
+	}
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+)
+
+func init() {
+	register(command{
+		name:    "convert",
+		summary: "copy the vault to another storage backend",
+		run:     (*App).convert,
+	})
+}
+
+// pvt convert --to <backend> [--path <path>]
+func (app *App) convert(ctx context.Context, args []string) error {
+	fs := app.flags("convert")
+	to := fs.String("to", "", fmt.Sprintf("backend to convert to, one of %v", vault.Backends))
+	path := fs.String("path", "", "where the new backend keeps the vault, its default location if empty")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usageError{errors.New("unexpected arguments")}
+	}
+	if !slices.Contains(vault.Backends, *to) {
+		return usageError{fmt.Errorf("--to must be one of %v", vault.Backends)}
+	}
+	if *to == app.Backend && *path == "" {
+		return usageError{fmt.Errorf("the vault is already kept in %s, pass --path to copy it elsewhere", *to)}
+	}
+	if app.Repository == nil || app.OpenStorage == nil {
+		return errors.New("converting is not available")
+	}
+
+	target, closeTarget, err := app.OpenStorage(*to, *path)
+	if err != nil {
+		return err
+	}
+	defer closeTarget()
+
+	n, err := vault.ConvertRepository(ctx, app.Repository, target)
+	if err != nil {
+		return err
+	}
+	if err := closeTarget(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(app.Stderr, "converted %d prompt(s) to %s\n", n, *to)
+	fmt.Fprintf(app.Stderr, "run pvt config set storage.backend %s", *to)
+	if *path != "" {
+		fmt.Fprintf(app.Stderr, " and pvt config set storage.path %s", *path)
+	}
+	fmt.Fprintln(app.Stderr, " to use it, the old storage is left as it was")
+	return nil
+}
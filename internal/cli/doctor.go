@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+)
+
+func init() {
+	register(command{
+		name:    "doctor",
+		summary: "check the vault for unreadable records and broken indexes, fix them with --repair",
+		run:     (*App).doctor,
+	})
+}
+
+// pvt doctor [--repair] [--compact]
+func (app *App) doctor(ctx context.Context, args []string) error {
+	fs := app.flags("doctor")
+	repair := fs.Bool("repair", false, "quarantine unreadable records, fix the id sequence and rebuild the indexes")
+	compact := fs.Bool("compact", false, "rewrite the database into a fresh file, reclaiming the space of deleted prompts")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usageError{errors.New("unexpected arguments")}
+	}
+	if app.Repository == nil {
+		return errors.New("the doctor is not available")
+	}
+
+	doctor, ok := app.Repository.(vault.Doctor)
+	if !ok {
+		fmt.Fprintf(app.Stdout, "the %s backend has no database file to check, nothing to do\n", app.Backend)
+		return nil
+	}
+
+	var d *vault.Diagnosis
+	var err error
+	if *repair {
+		d, err = doctor.Repair(ctx)
+	} else {
+		d, err = doctor.Diagnose(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	app.printDiagnosis(d)
+
+	switch {
+	case *repair && d.Healthy():
+		fmt.Fprintln(app.Stdout, "nothing to repair")
+	case *repair:
+		fmt.Fprintf(app.Stdout, "\nrepaired: quarantined %d record(s), rebuilt %d index entries", len(d.BadRecords), len(d.Drift))
+		if d.SequenceBehind() {
+			fmt.Fprintf(app.Stdout, ", moved the id sequence to %d", d.MaxID)
+		}
+		fmt.Fprintln(app.Stdout)
+	case d.Healthy():
+		fmt.Fprintln(app.Stdout, "no problems found")
+	case !*compact:
+		fmt.Fprintln(app.Stdout, "\nrun pvt doctor --repair to fix them")
+		return errIssuesFound
+	}
+
+	if *compact {
+		before, after, err := doctor.Compact(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(app.Stdout, "compacted the database from %d to %d bytes\n", before, after)
+		// compacting copies the problems along, they still need a repair
+		if !*repair && !d.Healthy() {
+			fmt.Fprintln(app.Stdout, "\nrun pvt doctor --repair to fix the problems found")
+			return errIssuesFound
+		}
+	}
+	return nil
+}
+
+func (app *App) printDiagnosis(d *vault.Diagnosis) {
+	fmt.Fprintf(app.Stdout, "%d readable prompt(s), %d bytes\n", d.Prompts, d.Size)
+	if d.Quarantined > 0 {
+		fmt.Fprintf(app.Stdout, "%d record(s) quarantined by earlier repairs\n", d.Quarantined)
+	}
+	for _, r := range d.BadRecords {
+		fmt.Fprintln(app.Stdout, r)
+	}
+	for _, drift := range d.Drift {
+		fmt.Fprintln(app.Stdout, drift)
+	}
+	if d.SequenceBehind() {
+		fmt.Fprintf(app.Stdout, "the id sequence is at %d but ids go up to %d, new prompts would overwrite existing ones\n", d.Sequence, d.MaxID)
+	}
+}
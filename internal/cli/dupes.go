@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+)
+
+func init() {
+	register(command{
+		name:    "dupes",
+		summary: "list groups of prompts with near identical content",
+		run:     (*App).dupes,
+	})
+}
+
+// pvt dupes [--threshold similarity]
+func (app *App) dupes(ctx context.Context, args []string) error {
+	fs := app.flags("dupes")
+	threshold := fs.Float64("threshold", vault.DefaultSimilarity, "how similar prompts must be to be listed, between 0 and 1")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usageError{errors.New("unexpected arguments")}
+	}
+	if *threshold <= 0 || *threshold > 1 {
+		return usageError{fmt.Errorf("threshold %v is not between 0 and 1", *threshold)}
+	}
+
+	clusters, err := app.Service.FindDuplicates(ctx, *threshold)
+	if err != nil {
+		return err
+	}
+	if len(clusters) == 0 {
+		fmt.Fprintln(app.Stdout, "no duplicates found")
+		return nil
+	}
+
+	prompts := 0
+	for i, c := range clusters {
+		if i > 0 {
+			fmt.Fprintln(app.Stdout)
+		}
+		fmt.Fprintf(app.Stdout, "%.0f%% similar\n", c.Similarity*100)
+		for _, p := range c.Prompts {
+			fmt.Fprintf(app.Stdout, "  #%d %s  %s\n", p.ID, p.Slug, p.Title)
+		}
+		prompts += len(c.Prompts)
+	}
+	fmt.Fprintf(app.Stdout, "\n%d group(s) of %d prompt(s), merge them from the tui with D\n", len(clusters), prompts)
+	return nil
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+)
+
+func init() {
+	register(command{
+		name:    "export",
+		summary: "write every prompt to a JSON file, with secrets redacted",
+		run:     (*App).export,
+	})
+}
+
+// pvt export [-o file] [--no-redact]
+func (app *App) export(ctx context.Context, args []string) error {
+	fs := app.flags("export")
+	output := fs.String("o", "-", "file to write the export to, - for stdout")
+	noRedact := fs.Bool("no-redact", false, "keep secrets found in the prompt content as they are")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	// an export missing prompts is no backup
+	prompts, err := app.Service.GetAllPrompts(ctx)
+	if errors.Is(err, vault.ErrUnreadable) {
+		return fmt.Errorf("cannot export: %w", err)
+	}
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = app.Stdout
+	if *output != "-" {
+		f, err := os.OpenFile(*output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	opts := vault.DefaultExportOptions()
+	opts.Redact = !*noRedact
+
+	redacted, err := vault.ExportPrompts(w, prompts, opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(app.Stderr, "exported %d prompt(s)", len(prompts))
+	if redacted > 0 {
+		fmt.Fprintf(app.Stderr, ", %d secret(s) redacted", redacted)
+	}
+	fmt.Fprintln(app.Stderr)
+	return nil
+}
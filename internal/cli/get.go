@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/tokenizer"
+)
+
+func init() {
+	register(command{
+		name:    "get",
+		summary: "print a prompt, or its size with --stats",
+		run:     (*App).get,
+	})
+}
+
+// pvt get [--raw] [--stats] [--model family] [--budget tokens] <id or slug>
+func (app *App) get(ctx context.Context, args []string) error {
+	defaults := app.Budget
+	if defaults.Family.Name == "" {
+		defaults = tokenizer.DefaultBudget()
+	}
+
+	fs := app.flags("get")
+	stats := fs.Bool("stats", false, "print the size of the prompt instead of its content")
+	raw := fs.Bool("raw", false, "print the content without expanding its includes")
+	model := fs.String("model", defaults.Family.Name, "model family used for the budget, one of "+strings.Join(tokenizer.Names(), ", "))
+	limit := fs.Int("budget", defaults.Limit, "warn if the prompt takes up more tokens than this, 0 to disable")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError{errors.New("expected exactly one prompt id or slug")}
+	}
+
+	prompt, err := app.Service.GetPromptByRef(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	// sizes are those of the text that actually gets sent, includes and all
+	content := prompt.PromptContent
+	if !*raw {
+		content, err = app.Service.RenderPrompt(ctx, prompt.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !*stats {
+		fmt.Fprintln(app.Stdout, content)
+		return nil
+	}
+
+	family, err := tokenizer.Lookup(*model)
+	if err != nil {
+		return usageError{err}
+	}
+	budget := tokenizer.Budget{Family: family, Limit: *limit}
+	estimate := budget.Estimate(content)
+
+	fmt.Fprintf(app.Stdout, "#%d %s\n", prompt.ID, prompt.Title)
+	fmt.Fprintf(app.Stdout, "  %-10s %d\n", "characters", len([]rune(content)))
+	fmt.Fprintf(app.Stdout, "  %-10s %d\n", "words", len(strings.Fields(content)))
+	fmt.Fprintf(app.Stdout, "  %-10s %d\n", "lines", strings.Count(content, "\n")+1)
+
+	counts := []string{}
+	for _, name := range tokenizer.Names() {
+		counts = append(counts, fmt.Sprintf("%s ~%d", name, tokenizer.Families[name].Count(content)))
+	}
+	fmt.Fprintf(app.Stdout, "  %-10s %s\n", "tokens", strings.Join(counts, "  "))
+
+	fmt.Fprintf(app.Stdout, "  %-10s %.2f%% of %s's %s token window\n", "context",
+		estimate.ContextShare()*100, family.Name, tokenizer.FormatCount(family.ContextWindow))
+
+	if budget.Limit > 0 {
+		status := "ok"
+		if estimate.OverBudget() {
+			status = "over budget"
+		}
+		fmt.Fprintf(app.Stdout, "  %-10s ~%d / %d tokens (%s), %s\n", "budget", estimate.Tokens, budget.Limit, family.Name, status)
+		if estimate.OverBudget() {
+			return errIssuesFound
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+)
+
+func init() {
+	register(command{
+		name:    "import",
+		summary: "merge prompts from a JSON export, matching them by UUID",
+		run:     (*App).importPrompts,
+	})
+}
+
+// pvt import <file>
+func (app *App) importPrompts(ctx context.Context, args []string) error {
+	fs := app.flags("import")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError{errors.New("expected exactly one file, - for stdin")}
+	}
+
+	var r io.Reader = os.Stdin
+	if name := fs.Arg(0); name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	doc, err := vault.ReadExport(r)
+	if err != nil {
+		return err
+	}
+
+	result, err := app.Service.ImportPrompts(ctx, doc.Prompts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(app.Stderr, "imported %d prompt(s): %d created, %d updated, %d unchanged\n",
+		len(doc.Prompts), result.Created, result.Updated, result.Unchanged)
+	if result.Kept > 0 {
+		fmt.Fprintf(app.Stderr, "%d prompt(s) skipped, the copy in the vault is newer\n", result.Kept)
+	}
+	if result.Duplicates > 0 {
+		fmt.Fprintf(app.Stderr, "%d duplicate(s) in the export ignored\n", result.Duplicates)
+	}
+	if result.Renumbered > 0 {
+		fmt.Fprintf(app.Stderr, "%d new prompt(s) got a new ID, theirs was taken\n", result.Renumbered)
+	}
+	if result.Dangling > 0 {
+		fmt.Fprintf(app.Stderr, "warning: %d numeric include(s) point to prompts missing from the export and now include the prompt with that ID here\n", result.Dangling)
+	}
+	return nil
+}
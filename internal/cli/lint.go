@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+)
+
+func init() {
+	register(command{
+		name:    "lint",
+		summary: "check every prompt in the vault for problems",
+		run:     (*App).lint,
+	})
+}
+
+// pvt lint [--min-severity level] [--fail-on level]
+func (app *App) lint(ctx context.Context, args []string) error {
+	fs := app.flags("lint")
+	minSeverity := fs.String("min-severity", "info", "only report issues at least this severe (info, warning, error)")
+	failOn := fs.String("fail-on", "error", "exit with status 1 if an issue at least this severe is found")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	min, err := vault.ParseSeverity(*minSeverity)
+	if err != nil {
+		return usageError{err}
+	}
+	fail, err := vault.ParseSeverity(*failOn)
+	if err != nil {
+		return usageError{err}
+	}
+
+	reports, err := app.Service.LintVault(ctx)
+	if err != nil {
+		return err
+	}
+
+	issues, prompts, failed := 0, 0, false
+	for _, report := range reports {
+		reported := false
+		for _, issue := range report.Issues {
+			if issue.Severity < min {
+				continue
+			}
+			if !reported {
+				fmt.Fprintf(app.Stdout, "#%d %s\n", report.Prompt.ID, report.Prompt.Title)
+				reported = true
+				prompts++
+			}
+			fmt.Fprintf(app.Stdout, "  %s\n", issue)
+			issues++
+			if issue.Severity >= fail {
+				failed = true
+			}
+		}
+	}
+
+	if issues == 0 {
+		fmt.Fprintln(app.Stdout, "no issues found")
+		return nil
+	}
+
+	fmt.Fprintf(app.Stdout, "\n%d issue(s) in %d prompt(s)\n", issues, prompts)
+	if failed {
+		return errIssuesFound
+	}
+	return nil
+}
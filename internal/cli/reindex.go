@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+)
+
+func init() {
+	register(command{
+		name:    "reindex",
+		summary: "rebuild the indexes of the vault, or check them with --check",
+		run:     (*App).reindex,
+	})
+}
+
+// pvt reindex [--check]
+func (app *App) reindex(ctx context.Context, args []string) error {
+	fs := app.flags("reindex")
+	check := fs.Bool("check", false, "only report entries out of sync with the prompts, exit with status 1 if there are any")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usageError{errors.New("unexpected arguments")}
+	}
+	if app.Repository == nil {
+		return errors.New("reindexing is not available")
+	}
+
+	indexer, ok := app.Repository.(vault.Indexer)
+	if !ok {
+		fmt.Fprintf(app.Stdout, "the %s backend keeps no indexes, nothing to do\n", app.Backend)
+		return nil
+	}
+
+	drift, err := indexer.CheckIndexes(ctx)
+	if err != nil {
+		return err
+	}
+	for _, d := range drift {
+		fmt.Fprintln(app.Stdout, d)
+	}
+
+	if *check {
+		if len(drift) == 0 {
+			fmt.Fprintln(app.Stdout, "the indexes match the prompts")
+			return nil
+		}
+		fmt.Fprintf(app.Stdout, "\n%d index entries out of sync, run pvt reindex to rebuild them\n", len(drift))
+		return errIssuesFound
+	}
+
+	if err := indexer.Reindex(ctx); err != nil {
+		return err
+	}
+	fmt.Fprintf(app.Stdout, "rebuilt the indexes, %d entries were out of sync\n", len(drift))
+	return nil
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+)
+
+func init() {
+	register(command{
+		name:    "scan",
+		summary: "audit the vault for API keys and other secrets",
+		run:     (*App).scan,
+	})
+}
+
+// pvt scan [--redact]
+func (app *App) scan(ctx context.Context, args []string) error {
+	fs := app.flags("scan")
+	redact := fs.Bool("redact", false, "replace the secrets found with [REDACTED] and save the prompts")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	// the records that cannot be read are not scanned, so the scan fails
+	// once the rest is
+	var unreadable error
+	prompts, err := app.Service.GetAllPrompts(ctx)
+	if errors.Is(err, vault.ErrUnreadable) {
+		unreadable = err
+		fmt.Fprintf(app.Stderr, "warning: %v, they are not scanned\n", err)
+	} else if err != nil {
+		return err
+	}
+
+	findings := 0
+	affected := []vault.Prompt{}
+	for _, prompt := range prompts {
+		found := vault.ScanSecrets(prompt.PromptContent)
+		if len(found) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(app.Stdout, "#%d %s\n", prompt.ID, prompt.Title)
+		for _, f := range found {
+			fmt.Fprintf(app.Stdout, "  content:%d: %s\n", f.Line, f.Kind)
+		}
+		findings += len(found)
+
+		redacted, _ := vault.RedactPrompt(prompt)
+		affected = append(affected, redacted)
+	}
+
+	if findings == 0 {
+		fmt.Fprintln(app.Stdout, "no secrets found")
+		return unreadable
+	}
+
+	fmt.Fprintf(app.Stdout, "\n%d possible secret(s) in %d prompt(s)\n", findings, len(affected))
+	if !*redact {
+		return errIssuesFound
+	}
+
+	// redact everything in one transaction, so an interrupted run changes nothing
+	if _, err := app.Service.CreateOrUpdatePrompts(ctx, affected); err != nil {
+		return err
+	}
+	fmt.Fprintf(app.Stdout, "redacted %d prompt(s)\n", len(affected))
+	return unreadable
+}
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+)
+
+func init() {
+	register(command{
+		name:    "search",
+		summary: "list the prompts matching a query, the most relevant first",
+		run:     (*App).search,
+	})
+}
+
+// pvt search [--limit n] <query...>
+//
+// the arguments are joined into one query, see vault.ParseSearch.
+// arguments the shell unquoted are quoted again, so that
+// pvt search tag:review "exact phrase" searches for the phrase.
+func (app *App) search(ctx context.Context, args []string) error {
+	fs := app.flags("search")
+	limit := fs.Int("limit", 20, "list at most this many prompts, 0 for every match")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usageError{errors.New("expected a query, as in: pvt search tag:review -draft")}
+	}
+	if *limit < 0 {
+		return usageError{fmt.Errorf("limit %d is negative", *limit)}
+	}
+
+	results, err := app.Service.Search(ctx, searchQuery(fs.Args()), *limit)
+	var queryErr *vault.QueryError
+	if errors.As(err, &queryErr) {
+		return usageError{err}
+	}
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		fmt.Fprintln(app.Stdout, "no prompts match")
+		return nil
+	}
+	for _, r := range results {
+		// queries of fields alone match without a score
+		score := "     -"
+		if r.Score > 0 {
+			score = fmt.Sprintf("%6.2f", r.Score)
+		}
+		fmt.Fprintf(app.Stdout, "%s  #%d %s  %s\n", score, r.Prompt.ID, r.Prompt.Slug, r.Prompt.Title)
+	}
+	return nil
+}
+
+// joins the arguments into a query, quoting those with spaces in them
+func searchQuery(args []string) string {
+	terms := make([]string, len(args))
+	for i, arg := range args {
+		terms[i] = arg
+		if !strings.ContainsFunc(arg, unicode.IsSpace) || strings.ContainsAny(arg, `"()`) {
+			continue
+		}
+		// keep a field outside of the quotes, tag:"code review"
+		field, value, ok := strings.Cut(arg, ":")
+		if !ok || strings.ContainsFunc(field, unicode.IsSpace) {
+			field, value = "", arg
+		} else {
+			field += ":"
+		}
+		terms[i] = field + `"` + value + `"`
+	}
+	return strings.Join(terms, " ")
+}
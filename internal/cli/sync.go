@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/gitsync"
+)
+
+func init() {
+	register(command{
+		name:    "sync",
+		summary: "sync the vault with a git repository, see pvt sync init",
+		run:     (*App).sync,
+	})
+}
+
+// pvt sync init <remote>
+// pvt sync [--prefer local|remote]
+func (app *App) sync(ctx context.Context, args []string) error {
+	if app.Sync == nil {
+		return errors.New("sync is not available")
+	}
+	if len(args) > 0 && args[0] == "init" {
+		return app.syncInit(ctx, args[1:])
+	}
+
+	fs := app.flags("sync")
+	prefer := fs.String("prefer", "", "resolve conflicts by keeping the local or the remote side")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usageError{fmt.Errorf("unexpected argument %q", fs.Arg(0))}
+	}
+
+	side := gitsync.Unresolved
+	switch *prefer {
+	case "":
+	case "local":
+		side = gitsync.KeepLocal
+	case "remote":
+		side = gitsync.KeepRemote
+	default:
+		return usageError{fmt.Errorf("--prefer must be local or remote, not %q", *prefer)}
+	}
+
+	plan, err := app.Sync.Plan(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(plan.Conflicts) > 0 && side == gitsync.Unresolved {
+		for _, c := range plan.Conflicts {
+			fmt.Fprintf(app.Stdout, "conflict: %s: %s\n", c.Title(), c.Summary())
+		}
+		fmt.Fprintf(app.Stderr, "%d conflict(s), open pvt and press S to resolve them, or run again with --prefer local|remote\n", len(plan.Conflicts))
+		return errIssuesFound
+	}
+	plan.ResolveAll(side)
+
+	result, err := app.Sync.Apply(ctx, plan)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(app.Stderr, "synced: %s\n", result)
+	return nil
+}
+
+func (app *App) syncInit(ctx context.Context, args []string) error {
+	fs := app.flags("sync init")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError{errors.New("expected exactly one remote, a url or a path")}
+	}
+
+	if err := app.Sync.Init(ctx, fs.Arg(0)); err != nil {
+		return err
+	}
+	fmt.Fprintf(app.Stderr, "syncing with %s through %s\n", fs.Arg(0), app.Sync.Dir)
+	return nil
+}
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+func init() {
+	register(command{
+		name:    "trash",
+		summary: "list the prompts merges moved to the trash, or restore them",
+		run:     (*App).trash,
+	})
+}
+
+// pvt trash
+// pvt trash restore <id>...
+func (app *App) trash(ctx context.Context, args []string) error {
+	if len(args) > 0 && args[0] == "restore" {
+		return app.trashRestore(ctx, args[1:])
+	}
+
+	fs := app.flags("trash")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usageError{fmt.Errorf("unexpected argument %q", fs.Arg(0))}
+	}
+
+	trash, err := app.Service.GetTrash(ctx)
+	if err != nil {
+		return err
+	}
+	if len(trash) == 0 {
+		fmt.Fprintln(app.Stdout, "the trash is empty")
+		return nil
+	}
+	for _, t := range trash {
+		fmt.Fprintf(app.Stdout, "#%d %s  %s  trashed %s\n", t.Prompt.ID, t.Prompt.Slug, t.Prompt.Title, t.TrashedAt.Local().Format("2006-01-02 15:04"))
+	}
+	fmt.Fprintf(app.Stdout, "\n%d prompt(s), put one back with pvt trash restore <id>\n", len(trash))
+	return nil
+}
+
+func (app *App) trashRestore(ctx context.Context, args []string) error {
+	fs := app.flags("trash restore")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usageError{errors.New("expected the ids of the prompts to restore")}
+	}
+	ids := make([]int, fs.NArg())
+	for i, arg := range fs.Args() {
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			return usageError{fmt.Errorf("%q is not a prompt id", arg)}
+		}
+		ids[i] = id
+	}
+
+	for _, id := range ids {
+		prompt, err := app.Service.RestoreTrashed(ctx, id)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(app.Stderr, "restored #%d %s\n", prompt.ID, prompt.Slug)
+	}
+	return nil
+}
@@ -0,0 +1,367 @@
+// Package config holds the settings of pvt.
+// They come from a TOML file in the user config dir, which environment
+// variables and then command line flags override:
+//
+//	[storage]
+//	backend = "sqlite"
+//
+//	[editor]
+//	title_limit = 80
+//	height = 20
+//
+// Every setting has a key, "editor.height" above, that names it in
+// pvt config, an environment variable, PVT_EDITOR_HEIGHT, and a flag,
+// -editor.height. Unknown keys are warned about rather than rejected, so
+// that a config written for a newer pvt still works with an older one.
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/tokenizer"
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+)
+
+// Config is every setting of pvt.
+type Config struct {
+	Storage Storage
+	Log     Log
+	Tokens  Tokens
+	Editor  Editor
+	TUI     TUI
+}
+
+// where the vault is kept
+type Storage struct {
+	Backend string // bolt, markdown or sqlite
+	Path    string // "" for the default path of the backend
+}
+
+// where the log goes and how much of it is kept
+type Log struct {
+	File     string // "" for pvt.log in the state dir
+	Level    string // debug, info, warn or error
+	Format   string // text or json
+	MaxSize  int    // megabytes a file grows to before it is rotated, 0 for never
+	MaxFiles int    // rotated files kept
+}
+
+// the token budget prompts are checked against
+type Tokens struct {
+	Model  string // tokenizer family, "" for the default one
+	Budget int    // 0 for no limit
+}
+
+// sizes of the editor of the tui and how long its fields can be
+type Editor struct {
+	Width            int // of the content
+	Height           int // of the content
+	FieldWidth       int // of the one line fields
+	TitleLimit       int
+	SlugLimit        int
+	DescriptionLimit int
+	VariablesLimit   int
+	TagsLimit        int
+	CollectionLimit  int
+}
+
+type TUI struct {
+	Theme       string // "" to let themes.toml pick
+	PageSize    int    // prompts loaded at a time
+	Timeout     time.Duration
+	SyncTimeout time.Duration
+}
+
+// The settings when nothing is configured.
+func Default() Config {
+	return Config{
+		Storage: Storage{Backend: vault.BackendBolt},
+		Log:     Log{Level: "info", Format: "text", MaxSize: 5, MaxFiles: 3},
+		Editor: Editor{
+			Width:            70,
+			Height:           12,
+			FieldWidth:       60,
+			TitleLimit:       50,
+			SlugLimit:        60,
+			DescriptionLimit: 100,
+			VariablesLimit:   200,
+			TagsLimit:        200,
+			CollectionLimit:  60,
+		},
+		TUI: TUI{
+			PageSize:    100,
+			Timeout:     10 * time.Second,
+			SyncTimeout: 2 * time.Minute,
+		},
+	}
+}
+
+// Returns the directory of the config file and, by default, of the vault:
+// proompt-vault in the user config dir, $XDG_CONFIG_HOME on Linux.
+func Dir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "proompt-vault"), nil
+}
+
+// Returns the directory of the log: proompt-vault in $XDG_STATE_HOME, or
+// in ~/.local/state without it. Windows has no such dir, the log goes in
+// the local app data dir there.
+func StateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "proompt-vault"), nil
+	}
+	if runtime.GOOS == "windows" {
+		dir, err := os.UserCacheDir() // %LocalAppData%
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, "proompt-vault"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state", "proompt-vault"), nil
+}
+
+// Returns the path of the config file, $PVT_CONFIG if it is set.
+func Path() (string, error) {
+	if path := os.Getenv("PVT_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.toml"), nil
+}
+
+// Returns the token budget of the config.
+func (c *Config) Budget() tokenizer.Budget {
+	budget := tokenizer.DefaultBudget()
+	if family, err := tokenizer.Lookup(c.Tokens.Model); err == nil {
+		budget.Family = family
+	}
+	budget.Limit = c.Tokens.Budget
+	return budget
+}
+
+// Returns where the log is written: the configured file, or pvt.log in
+// the state dir.
+func (c *Config) LogPath() (string, error) {
+	if c.Log.File != "" {
+		return c.Log.File, nil
+	}
+	dir, err := StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "pvt.log"), nil
+}
+
+// Returns where the vault is kept: the configured path, or the default one
+// of the backend in dir.
+func (c *Config) StoragePath(dir string) string {
+	if c.Storage.Path != "" {
+		return c.Storage.Path
+	}
+	return DefaultStoragePath(dir, c.Storage.Backend)
+}
+
+// Returns where a backend keeps the vault in dir unless told otherwise.
+func DefaultStoragePath(dir, backend string) string {
+	switch backend {
+	case vault.BackendMarkdown:
+		return filepath.Join(dir, "prompts")
+	case vault.BackendSQLite:
+		return filepath.Join(dir, "prompts.sqlite")
+	}
+	return filepath.Join(dir, "prompts.db")
+}
+
+// kinds of values a setting can have, which is how it is written in TOML
+const (
+	kindString   = "string"
+	kindInt      = "int"
+	kindDuration = "duration"
+)
+
+// Setting is a single setting of the config, with the names it goes by.
+type Setting struct {
+	Key  string // as in the file, section.name
+	Env  string
+	Doc  string
+	kind string
+
+	get func() string
+	set func(string) error
+}
+
+// Returns every setting of the config, section by section.
+func (c *Config) Settings() []Setting {
+	return []Setting{
+		stringSetting("storage.backend", "PVT_STORAGE", "storage backend: "+strings.Join(vault.Backends, ", "), &c.Storage.Backend, oneOf(vault.Backends)),
+		stringSetting("storage.path", "PVT_STORAGE_PATH", "where the vault is kept, the config dir by default", &c.Storage.Path, nil),
+		stringSetting("log.file", "", "file the log is written to, pvt.log in the state dir by default", &c.Log.File, nil),
+		stringSetting("log.level", "", "least level logged: debug, info, warn or error", &c.Log.Level, oneOf([]string{"debug", "info", "warn", "error"})),
+		stringSetting("log.format", "", "format of the log file: text or json", &c.Log.Format, oneOf([]string{"text", "json"})),
+		intSetting("log.max_size", "", "megabytes the log grows to before it is rotated, 0 for never", &c.Log.MaxSize, 0),
+		intSetting("log.max_files", "", "rotated log files kept", &c.Log.MaxFiles, 0),
+		stringSetting("tokens.model", "PVT_TOKEN_MODEL", "model family prompts are counted for: "+strings.Join(tokenizer.Names(), ", "), &c.Tokens.Model, tokenModel),
+		intSetting("tokens.budget", "PVT_TOKEN_BUDGET", "tokens a prompt may use, 0 for no limit", &c.Tokens.Budget, 0),
+		intSetting("editor.width", "", "width of the content editor", &c.Editor.Width, 20),
+		intSetting("editor.height", "", "height of the content editor", &c.Editor.Height, 3),
+		intSetting("editor.field_width", "", "width of the one line fields", &c.Editor.FieldWidth, 20),
+		intSetting("editor.title_limit", "", "characters a title can have", &c.Editor.TitleLimit, 1),
+		intSetting("editor.slug_limit", "", "characters a slug can have", &c.Editor.SlugLimit, 1),
+		intSetting("editor.description_limit", "", "characters a description can have", &c.Editor.DescriptionLimit, 1),
+		intSetting("editor.variables_limit", "", "characters the variables can have", &c.Editor.VariablesLimit, 1),
+		intSetting("editor.tags_limit", "", "characters the tags can have", &c.Editor.TagsLimit, 1),
+		intSetting("editor.collection_limit", "", "characters a collection can have", &c.Editor.CollectionLimit, 1),
+		stringSetting("tui.theme", "PVT_THEME", "theme of the tui, see themes.toml", &c.TUI.Theme, nil),
+		intSetting("tui.page_size", "", "prompts loaded at a time", &c.TUI.PageSize, 1),
+		durationSetting("tui.timeout", "", "how long a storage call may take", &c.TUI.Timeout),
+		durationSetting("tui.sync_timeout", "", "how long a sync may take", &c.TUI.SyncTimeout),
+	}
+}
+
+// Looks up a setting by its key.
+func (c *Config) Setting(key string) (Setting, error) {
+	for _, s := range c.Settings() {
+		if s.Key == key {
+			return s, nil
+		}
+	}
+	return Setting{}, fmt.Errorf("unknown key %q, see pvt config get for the keys", key)
+}
+
+// Returns the value of the setting as it is written on the command line.
+func (s Setting) Get() string {
+	return s.get()
+}
+
+// Sets the setting from its value as written on the command line.
+func (s Setting) Set(value string) error {
+	return s.set(value)
+}
+
+// Returns the setting as a TOML value, quoted if it is a string.
+func (s Setting) TOML() string {
+	if s.kind == kindInt {
+		return s.get()
+	}
+	return strconv.Quote(s.get())
+}
+
+// Sets the setting called key, see Setting.Set.
+func (c *Config) Set(key, value string) error {
+	s, err := c.Setting(key)
+	if err != nil {
+		return err
+	}
+	if err := s.Set(value); err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	return nil
+}
+
+// Overrides the settings with the environment variables that are set.
+// Settings without a variable of their own use PVT_ and their key, as in
+// PVT_EDITOR_HEIGHT.
+func (c *Config) ApplyEnv(getenv func(string) string) error {
+	for _, s := range c.Settings() {
+		name := s.EnvName()
+		value := getenv(name)
+		if value == "" {
+			continue
+		}
+		if err := s.Set(value); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Returns the environment variable overriding the setting.
+func (s Setting) EnvName() string {
+	if s.Env != "" {
+		return s.Env
+	}
+	return "PVT_" + strings.ToUpper(strings.NewReplacer(".", "_").Replace(s.Key))
+}
+
+func stringSetting(key, env, doc string, p *string, check func(string) error) Setting {
+	return Setting{
+		Key: key, Env: env, Doc: doc, kind: kindString,
+		get: func() string { return *p },
+		set: func(value string) error {
+			if check != nil {
+				if err := check(value); err != nil {
+					return err
+				}
+			}
+			*p = value
+			return nil
+		},
+	}
+}
+
+func intSetting(key, env, doc string, p *int, least int) Setting {
+	return Setting{
+		Key: key, Env: env, Doc: doc, kind: kindInt,
+		get: func() string { return strconv.Itoa(*p) },
+		set: func(value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%q is not a number", value)
+			}
+			if n < least {
+				return fmt.Errorf("%d is too small, the least is %d", n, least)
+			}
+			*p = n
+			return nil
+		},
+	}
+}
+
+func durationSetting(key, env, doc string, p *time.Duration) Setting {
+	return Setting{
+		Key: key, Env: env, Doc: doc, kind: kindDuration,
+		get: func() string { return p.String() },
+		set: func(value string) error {
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				return fmt.Errorf("%q is not a duration, as in 30s or 2m", value)
+			}
+			*p = d
+			return nil
+		},
+	}
+}
+
+func oneOf(values []string) func(string) error {
+	return func(value string) error {
+		if !slices.Contains(values, value) {
+			return fmt.Errorf("unknown value %q, expected one of %s", value, strings.Join(values, ", "))
+		}
+		return nil
+	}
+}
+
+func tokenModel(value string) error {
+	if value == "" {
+		return nil
+	}
+	_, err := tokenizer.Lookup(value)
+	return err
+}
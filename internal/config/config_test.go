@@ -0,0 +1,254 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/config"
+	"github.com/Dima-salang/proompt-vault-tui/internal/tokenizer"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name         string // description of this test case
+		config       string
+		check        func(t *testing.T, c config.Config)
+		wantWarnings []string
+		wantErr      string
+	}{
+		{
+			name:   "Empty",
+			config: "",
+			check: func(t *testing.T, c config.Config) {
+				if c != config.Default() {
+					t.Errorf("Parse() = %+v, want the defaults", c)
+				}
+			},
+		},
+		{
+			name:   "Settings",
+			config: "[storage]\nbackend = \"sqlite\"\n\n[editor]\ntitle_limit = 80\nheight = 20\n\n[tui]\ntimeout = \"30s\"",
+			check: func(t *testing.T, c config.Config) {
+				if c.Storage.Backend != "sqlite" || c.Editor.TitleLimit != 80 || c.Editor.Height != 20 || c.TUI.Timeout != 30*time.Second {
+					t.Errorf("Parse() = %+v", c)
+				}
+				if c.Editor.Width != config.Default().Editor.Width {
+					t.Errorf("Parse() editor.width = %d, want the default", c.Editor.Width)
+				}
+			},
+		},
+		{
+			name:   "Unknown keys warn",
+			config: "colour = \"red\"\n[editor]\nwidht = 80\nheight = 20\n[plugins]\nfoo = 1",
+			check: func(t *testing.T, c config.Config) {
+				if c.Editor.Height != 20 {
+					t.Errorf("Parse() editor.height = %d, want the known keys applied", c.Editor.Height)
+				}
+			},
+			wantWarnings: []string{
+				"unknown key colour, settings belong in one of the sections storage, log",
+				"unknown key editor.widht, expected one of width, height",
+				"unknown section [plugins], expected one of storage",
+			},
+		},
+		{name: "Bad backend", config: "[storage]\nbackend = \"csv\"", wantErr: `storage.backend: unknown value "csv", expected one of bolt`},
+		{name: "Bad model", config: "[tokens]\nmodel = \"gpt9\"", wantErr: `tokens.model: unknown model family "gpt9"`},
+		{name: "Number in quotes", config: "[editor]\nwidth = \"80\"", wantErr: "editor.width: must be a number"},
+		{name: "Too small", config: "[editor]\nheight = 1", wantErr: "editor.height: 1 is too small, the least is 3"},
+		{name: "Duration without quotes", config: "[tui]\ntimeout = 30", wantErr: `tui.timeout: must be a duration in quotes`},
+		{name: "Bad duration", config: "[tui]\ntimeout = \"soon\"", wantErr: `tui.timeout: "soon" is not a duration`},
+		{name: "String as a number", config: "[log]\nfile = 1", wantErr: "log.file: must be a string"},
+		{name: "Malformed", config: "[editor\n", wantErr: "toml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, warnings, err := config.Parse([]byte(tt.config))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse() = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(warnings) != len(tt.wantWarnings) {
+				t.Fatalf("Parse() warnings = %q, want %d", warnings, len(tt.wantWarnings))
+			}
+			for i, w := range tt.wantWarnings {
+				if !strings.HasPrefix(warnings[i], w) {
+					t.Errorf("Parse() warning %d = %q, want it to start with %q", i, warnings[i], w)
+				}
+			}
+			tt.check(t, c)
+		})
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	env := map[string]string{
+		"PVT_STORAGE":       "markdown",
+		"PVT_TOKEN_MODEL":   "claude",
+		"PVT_TOKEN_BUDGET":  "2000",
+		"PVT_EDITOR_HEIGHT": "30",
+		"PVT_TUI_PAGE_SIZE": "",
+	}
+	c := config.Default()
+	if err := c.ApplyEnv(func(name string) string { return env[name] }); err != nil {
+		t.Fatal(err)
+	}
+	if c.Storage.Backend != "markdown" || c.Editor.Height != 30 || c.TUI.PageSize != config.Default().TUI.PageSize {
+		t.Errorf("ApplyEnv() = %+v", c)
+	}
+	if b := c.Budget(); b.Family.Name != "claude" || b.Limit != 2000 {
+		t.Errorf("Budget() = %s, %d, want claude with 2000 tokens", b.Family.Name, b.Limit)
+	}
+
+	env = map[string]string{"PVT_TOKEN_BUDGET": "lots"}
+	if err := c.ApplyEnv(func(name string) string { return env[name] }); err == nil || !strings.HasPrefix(err.Error(), `PVT_TOKEN_BUDGET: "lots" is not a number`) {
+		t.Errorf("ApplyEnv() = %v, want an error naming the variable", err)
+	}
+}
+
+func TestDefault_Budget(t *testing.T) {
+	c := config.Default()
+	if b := c.Budget(); b != tokenizer.DefaultBudget() {
+		t.Errorf("Budget() = %+v, want the default budget", b)
+	}
+}
+
+func TestLogPath(t *testing.T) {
+	state := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", state)
+
+	c := config.Default()
+	path, err := c.LogPath()
+	if err != nil || path != filepath.Join(state, "proompt-vault", "pvt.log") {
+		t.Errorf("LogPath() = %q, %v, want pvt.log in the state dir", path, err)
+	}
+	c.Log.File = "/var/log/pvt.log"
+	if path, _ := c.LogPath(); path != "/var/log/pvt.log" {
+		t.Errorf("LogPath() = %q, want the configured file", path)
+	}
+	if err := c.Set("log.level", "trace"); err == nil {
+		t.Error("Set(log.level, trace) succeeded, want an error")
+	}
+}
+
+func TestGetSet(t *testing.T) {
+	c := config.Default()
+	if err := c.Set("tui.sync_timeout", "5m"); err != nil {
+		t.Fatal(err)
+	}
+	s, err := c.Setting("tui.sync_timeout")
+	if err != nil || s.Get() != "5m0s" || s.TOML() != `"5m0s"` {
+		t.Errorf("Setting() = %q, %v, want 5m0s", s.Get(), err)
+	}
+	if err := c.Set("editor.width", "-1"); err == nil || !strings.HasPrefix(err.Error(), "editor.width: ") {
+		t.Errorf("Set(editor.width, -1) = %v, want an error naming the key", err)
+	}
+	if err := c.Set("editor.colour", "red"); err == nil {
+		t.Error("Set() of an unknown key succeeded, want an error")
+	}
+	if s := c.Settings()[0]; s.EnvName() != "PVT_STORAGE" {
+		t.Errorf("EnvName() = %s, want PVT_STORAGE", s.EnvName())
+	}
+	if s, _ := c.Setting("editor.title_limit"); s.EnvName() != "PVT_EDITOR_TITLE_LIMIT" {
+		t.Errorf("EnvName() = %s, want PVT_EDITOR_TITLE_LIMIT", s.EnvName())
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	c, warnings, err := config.Load(filepath.Join(dir, "missing.toml"))
+	if err != nil || len(warnings) != 0 || c != config.Default() {
+		t.Errorf("Load() of a missing file = %v, %v, want the defaults", warnings, err)
+	}
+
+	path := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(path, []byte("[editor]\nwidht = 1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, warnings, err := config.Load(path); err != nil || len(warnings) != 1 || !strings.HasPrefix(warnings[0], path+": ") {
+		t.Errorf("Load() = %q, %v, want a warning naming the file", warnings, err)
+	}
+	if err := os.WriteFile(path, []byte("[editor]\nwidth = 1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := config.Load(path); err == nil || !strings.HasPrefix(err.Error(), path+": ") {
+		t.Errorf("Load() = %v, want an error naming the file", err)
+	}
+}
+
+func TestSetFile(t *testing.T) {
+	tests := []struct {
+		name    string // description of this test case
+		file    string
+		key     string
+		value   string
+		want    string
+		wantErr string
+	}{
+		{name: "New file", key: "editor.height", value: "20", want: "[editor]\nheight = 20\n"},
+		{
+			name:  "Replaces the value",
+			file:  "# my settings\n[editor]\nheight = 12 # tall\nwidth = 70\n",
+			key:   "editor.height",
+			value: "20",
+			want:  "# my settings\n[editor]\nheight = 20\nwidth = 70\n",
+		},
+		{
+			name:  "Adds to the section",
+			file:  "[editor]\nwidth = 70\n\n[tui]\ntheme = \"dark\"\n",
+			key:   "editor.height",
+			value: "20",
+			want:  "[editor]\nwidth = 70\nheight = 20\n\n[tui]\ntheme = \"dark\"\n",
+		},
+		{
+			name:  "Adds the section",
+			file:  "[editor]\nwidth = 70",
+			key:   "storage.path",
+			value: `C:\vault`,
+			want:  "[editor]\nwidth = 70\n\n[storage]\npath = \"C:\\\\vault\"\n",
+		},
+		{name: "Bad value", key: "editor.height", value: "tall", wantErr: `editor.height: "tall" is not a number`},
+		{name: "Unknown key", key: "editor.colour", value: "red", wantErr: `unknown key "editor.colour"`},
+		{name: "Broken file", file: "[editor]\nwidth = 1\n", key: "editor.height", value: "20", wantErr: "editor.width: 1 is too small"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "pvt", "config.toml")
+			if tt.file != "" {
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(tt.file), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := config.SetFile(path, tt.key, tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("SetFile() = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("SetFile() wrote %q, want %q", data, tt.want)
+			}
+			if _, _, err := config.Load(path); err != nil {
+				t.Errorf("Load() after SetFile() = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Loads the config file at path over the defaults, which are all there is
+// when the file does not exist. Unknown keys are returned as warnings, bad
+// values as an error.
+func Load(path string) (Config, []string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Default(), nil, nil
+	}
+	if err != nil {
+		return Config{}, nil, err
+	}
+	c, warnings, err := Parse(data)
+	for i, w := range warnings {
+		warnings[i] = path + ": " + w
+	}
+	if err != nil {
+		return Config{}, warnings, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, warnings, nil
+}
+
+// Parses a config file over the defaults, see Load.
+func Parse(data []byte) (Config, []string, error) {
+	var file map[string]any
+	if _, err := toml.Decode(string(data), &file); err != nil {
+		return Config{}, nil, err
+	}
+
+	c := Default()
+	warnings := []string{}
+	errs := []error{}
+	for _, section := range slices.Sorted(maps.Keys(file)) {
+		names := c.names(section)
+		values, ok := file[section].(map[string]any)
+		switch {
+		case !ok:
+			warnings = append(warnings, fmt.Sprintf("unknown key %s, settings belong in one of the sections %s", section, strings.Join(c.sections(), ", ")))
+			continue
+		case len(names) == 0:
+			warnings = append(warnings, fmt.Sprintf("unknown section [%s], expected one of %s", section, strings.Join(c.sections(), ", ")))
+			continue
+		}
+		for _, name := range slices.Sorted(maps.Keys(values)) {
+			s, err := c.Setting(section + "." + name)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("unknown key %s.%s, expected one of %s", section, name, strings.Join(names, ", ")))
+				continue
+			}
+			if err := s.decode(values[name]); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.Key, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return Config{}, warnings, errors.Join(errs...)
+	}
+	return c, warnings, nil
+}
+
+// sets the setting from its value in the file
+func (s Setting) decode(value any) error {
+	switch v := value.(type) {
+	case string:
+		if s.kind != kindInt {
+			return s.Set(v)
+		}
+	case int64:
+		if s.kind == kindInt {
+			return s.Set(fmt.Sprint(v))
+		}
+	}
+	switch s.kind {
+	case kindInt:
+		return errors.New("must be a number")
+	case kindDuration:
+		return errors.New(`must be a duration in quotes, as in "30s"`)
+	}
+	return errors.New("must be a string")
+}
+
+// Sets a setting in the config file at path, creating the file if need be.
+// The rest of the file, comments included, is left as it is. The value is
+// checked, and so is the file once changed, before it is written.
+func SetFile(path, key, value string) error {
+	c := Default()
+	s, err := c.Setting(key)
+	if err != nil {
+		return err
+	}
+	if err := s.Set(value); err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	section, name, _ := strings.Cut(key, ".")
+	data = setLine(data, section, name, s.TOML())
+	if _, _, err := Parse(data); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// replaces the line of name in section with name = value, adding it at
+// the end of the section, or adding the section, if there is none
+func setLine(data []byte, section, name, value string) []byte {
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(data) == 0 {
+		lines = nil
+	}
+	line := name + " = " + value
+
+	current := ""
+	last := -1 // last line of the section with something on it
+	for i, l := range lines {
+		trimmed := strings.TrimSpace(l)
+		if strings.HasPrefix(trimmed, "[") {
+			current = strings.Trim(strings.TrimSpace(strings.SplitN(trimmed, "#", 2)[0]), "[] ")
+			if current == section {
+				last = i
+			}
+			continue
+		}
+		if current != section {
+			continue
+		}
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			last = i
+		}
+		if k, _, ok := strings.Cut(trimmed, "="); ok && strings.TrimSpace(k) == name {
+			lines[i] = line
+			return []byte(strings.Join(lines, "\n") + "\n")
+		}
+	}
+
+	if last >= 0 {
+		lines = slices.Insert(lines, last+1, line)
+	} else {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "["+section+"]", line)
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// the sections of the config, in order
+func (c *Config) sections() []string {
+	sections := []string{}
+	for _, s := range c.Settings() {
+		section, _, _ := strings.Cut(s.Key, ".")
+		if !slices.Contains(sections, section) {
+			sections = append(sections, section)
+		}
+	}
+	return sections
+}
+
+// the names of the settings of a section, in order
+func (c *Config) names(section string) []string {
+	names := []string{}
+	for _, s := range c.Settings() {
+		if sec, name, _ := strings.Cut(s.Key, "."); sec == section {
+			names = append(names, name)
+		}
+	}
+	return names
+}
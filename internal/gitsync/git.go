@@ -0,0 +1,139 @@
+package gitsync
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// runs git commands inside a working copy
+type git struct {
+	dir string
+}
+
+// runs git with args and returns its trimmed standard output
+func (g git) run(ctx context.Context, args ...string) (string, error) {
+	return g.runInput(ctx, nil, args...)
+}
+
+// runs git with args, feeding it stdin
+func (g git) runInput(ctx context.Context, stdin io.Reader, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", g.dir}, args...)...)
+	// never wait for credentials, sync runs from the tui too
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "LC_ALL=C")
+	cmd.Stdin = stdin
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", ctxErr
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// resolves rev to a commit, "" if it does not exist (e.g. an unborn branch)
+func (g git) revParse(ctx context.Context, rev string) string {
+	out, err := g.run(ctx, "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil {
+		return ""
+	}
+	return out
+}
+
+// reads every file below dir in the tree of rev, keyed by path
+func (g git) readTree(ctx context.Context, rev, dir string) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	if rev == "" {
+		return files, nil
+	}
+
+	out, err := g.run(ctx, "ls-tree", "-r", "--full-tree", rev, "--", dir)
+	if err != nil {
+		return nil, err
+	}
+	if out == "" {
+		return files, nil
+	}
+
+	// <mode> SP <type> SP <object> TAB <path>
+	paths := []string{}
+	var objects strings.Builder
+	for _, line := range strings.Split(out, "\n") {
+		meta, path, ok := strings.Cut(line, "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) != 3 || fields[1] != "blob" {
+			continue
+		}
+		paths = append(paths, path)
+		objects.WriteString(fields[2] + "\n")
+	}
+
+	// read every blob in one go instead of a process per file
+	contents, err := g.catFiles(ctx, objects.String(), len(paths))
+	if err != nil {
+		return nil, err
+	}
+	for i, path := range paths {
+		files[path] = contents[i]
+	}
+	return files, nil
+}
+
+// reads the objects listed one per line in objects with git cat-file --batch
+func (g git) catFiles(ctx context.Context, objects string, n int) ([][]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", "-C", g.dir, "cat-file", "--batch")
+	cmd.Stdin = strings.NewReader(objects)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git cat-file: %w", err)
+	}
+
+	r := bufio.NewReader(bytes.NewReader(out))
+	contents := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		// <object> SP <type> SP <size> LF <contents> LF
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("git cat-file: %w", err)
+		}
+		fields := strings.Fields(header)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("git cat-file: unexpected header %q", header)
+		}
+		size, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("git cat-file: unexpected header %q", header)
+		}
+
+		content := make([]byte, size+1)
+		if _, err := io.ReadFull(r, content); err != nil {
+			return nil, fmt.Errorf("git cat-file: %w", err)
+		}
+		contents = append(contents, content[:size])
+	}
+	return contents, nil
+}
+
+// lists the paths staged for the next commit
+func (g git) stagedFiles(ctx context.Context) ([]string, error) {
+	out, err := g.run(ctx, "diff", "--cached", "--name-only")
+	if err != nil || out == "" {
+		return nil, err
+	}
+	return strings.Split(out, "\n"), nil
+}
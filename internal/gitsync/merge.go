@@ -0,0 +1,231 @@
+package gitsync
+
+import (
+	"strings"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+)
+
+// a prompt field that takes part in the merge
+type field struct {
+	name string
+	get  func(p *vault.Prompt) string
+	set  func(p *vault.Prompt, value string)
+}
+
+// the fields compared and merged one by one. timestamps, ids and aliases are
+// bookkeeping of each vault and never conflict.
+var fields = []field{
+	{
+		name: vault.FieldTitle,
+		get:  func(p *vault.Prompt) string { return p.Title },
+		set:  func(p *vault.Prompt, v string) { p.Title = v },
+	},
+	{
+		name: vault.FieldSlug,
+		get:  func(p *vault.Prompt) string { return p.Slug },
+		set:  func(p *vault.Prompt, v string) { p.Slug = v },
+	},
+	{
+		name: vault.FieldDescription,
+		get:  func(p *vault.Prompt) string { return p.Description },
+		set:  func(p *vault.Prompt, v string) { p.Description = v },
+	},
+	{
+		name: vault.FieldVariables,
+		get:  func(p *vault.Prompt) string { return strings.Join(p.Variables, ", ") },
+		set: func(p *vault.Prompt, v string) {
+			p.Variables = nil
+			if v != "" {
+				p.Variables = strings.Split(v, ", ")
+			}
+		},
+	},
+	{
+		name: vault.FieldTags,
+		get:  func(p *vault.Prompt) string { return strings.Join(p.Tags, ", ") },
+		set: func(p *vault.Prompt, v string) {
+			p.Tags = nil
+			if v != "" {
+				p.Tags = strings.Split(v, ", ")
+			}
+		},
+	},
+	{
+		name: vault.FieldCollection,
+		get:  func(p *vault.Prompt) string { return p.Collection },
+		set:  func(p *vault.Prompt, v string) { p.Collection = v },
+	},
+	{
+		name: vault.FieldPinned,
+		get: func(p *vault.Prompt) string {
+			if p.Pinned {
+				return "pinned"
+			}
+			return ""
+		},
+		set: func(p *vault.Prompt, v string) { p.Pinned = v != "" },
+	},
+	{
+		name: vault.FieldContent,
+		get:  func(p *vault.Prompt) string { return p.PromptContent },
+		set:  func(p *vault.Prompt, v string) { p.PromptContent = v },
+	},
+}
+
+// Side of a conflict to keep.
+type Side int
+
+const (
+	Unresolved Side = iota
+	KeepLocal
+	KeepRemote
+)
+
+// Conflict is a prompt edited differently in the vault and in the repo since
+// the last sync. Local or Remote is nil when that side deleted the prompt,
+// Base is nil when both sides created it.
+type Conflict struct {
+	UUID   string
+	Base   *vault.Prompt
+	Local  *vault.Prompt
+	Remote *vault.Prompt
+
+	// names of the fields changed on both sides, empty for delete/edit conflicts
+	Fields []string
+
+	// both sides with the non conflicting changes of the other one applied
+	mergedLocal  *vault.Prompt
+	mergedRemote *vault.Prompt
+
+	Resolution Side
+}
+
+// Resolves the conflict by keeping one side.
+// Changes of the other side to fields that do not conflict are kept as well.
+func (c *Conflict) Resolve(side Side) {
+	c.Resolution = side
+}
+
+// Returns the prompt chosen by the resolution, nil if it deletes the prompt.
+func (c *Conflict) Result() *vault.Prompt {
+	switch c.Resolution {
+	case KeepLocal:
+		return c.mergedLocal
+	case KeepRemote:
+		return c.mergedRemote
+	}
+	return nil
+}
+
+// Title to show for the conflict, from whichever side still has the prompt.
+func (c *Conflict) Title() string {
+	for _, p := range []*vault.Prompt{c.Local, c.Remote, c.Base} {
+		if p != nil {
+			return p.Title
+		}
+	}
+	return c.UUID
+}
+
+// Describes what both sides did to the prompt.
+func (c *Conflict) Summary() string {
+	switch {
+	case c.Local == nil:
+		return "deleted here, edited in the remote"
+	case c.Remote == nil:
+		return "edited here, deleted in the remote"
+	case c.Base == nil:
+		return "added on both sides, " + strings.Join(c.Fields, ", ") + " differ"
+	}
+	return "both changed " + strings.Join(c.Fields, ", ")
+}
+
+// outcome of merging a single prompt
+type outcome int
+
+const (
+	unchanged   outcome = iota // nothing to do on either side
+	pushed                     // only the vault changed, the repo follows it
+	pulled                     // the repo changed, merged is written to the vault
+	deleted                    // deleted in the repo, the vault follows
+	conflicting                // changed on both sides in incompatible ways
+)
+
+// Three-way merges one prompt. base, local and remote are nil where the
+// prompt does not exist. The returned prompt is what the vault should hold
+// when the outcome is pulled.
+func merge(base, local, remote *vault.Prompt) (outcome, *vault.Prompt, *Conflict) {
+	conflict := &Conflict{Base: base, Local: local, Remote: remote}
+	for _, p := range []*vault.Prompt{base, local, remote} {
+		if p != nil {
+			conflict.UUID = p.UUID
+		}
+	}
+
+	switch {
+	case remote == nil && local == nil:
+		return unchanged, nil, nil
+	case remote == nil && base == nil:
+		return pushed, nil, nil
+	case remote == nil:
+		if same(base, local) {
+			return deleted, nil, nil
+		}
+		conflict.mergedLocal = local
+		return conflicting, nil, conflict
+	case local == nil && base == nil:
+		return pulled, remote, nil
+	case local == nil:
+		if same(base, remote) {
+			// deleted locally, the repo follows once it is written
+			return pushed, nil, nil
+		}
+		conflict.mergedRemote = remote
+		return conflicting, nil, conflict
+	}
+
+	if same(local, remote) {
+		return unchanged, nil, nil
+	}
+	if base == nil {
+		// created on both sides, typically by a previous sync that never got pushed
+		base = &vault.Prompt{}
+	}
+
+	merged := *local
+	fromRemote := *remote
+	fromRemote.ID, fromRemote.CreatedAt = local.ID, local.CreatedAt
+	for _, f := range fields {
+		b, l, r := f.get(base), f.get(local), f.get(remote)
+		switch {
+		case l == r:
+		case l == b:
+			f.set(&merged, r)
+		case r == b:
+			f.set(&fromRemote, l)
+		default:
+			conflict.Fields = append(conflict.Fields, f.name)
+		}
+	}
+
+	if len(conflict.Fields) > 0 {
+		conflict.mergedLocal = &merged
+		conflict.mergedRemote = &fromRemote
+		return conflicting, nil, conflict
+	}
+	if same(&merged, local) {
+		return pushed, nil, nil
+	}
+	return pulled, &merged, nil
+}
+
+// reports whether two copies of a prompt have the same merged fields
+func same(a, b *vault.Prompt) bool {
+	for _, f := range fields {
+		if f.get(a) != f.get(b) {
+			return false
+		}
+	}
+	return true
+}
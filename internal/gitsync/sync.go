@@ -0,0 +1,394 @@
+// Package gitsync shares a vault through a git repository.
+//
+// The vault is mirrored to a working copy as one markdown file per prompt,
+// with the metadata in front matter (see vault.MarshalMarkdown). Syncing
+// fetches the remote, three-way merges every prompt between the last synced
+// commit (the base), the vault and the remote, writes the result to the vault
+// and pushes the mirrored files back. Prompts are matched by UUID, so renames
+// and retitles on either side are followed.
+//
+// git itself does the transport, any remote it understands works, including
+// a bare repository on the local disk.
+package gitsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+)
+
+// directory of the repository holding the prompt files
+const promptsDir = "prompts"
+
+var (
+	// sync was never set up for the vault
+	ErrNotConfigured = errors.New("sync is not set up, run pvt sync init <remote>")
+
+	// Apply was called while some conflicts are still unresolved
+	ErrUnresolved = errors.New("conflicts are not resolved")
+)
+
+// Syncer syncs a vault with the remote of a git working copy.
+type Syncer struct {
+	// working copy the vault is mirrored to
+	Dir string
+
+	// branch of the remote that is synced
+	Branch string
+
+	Service vault.PromptService
+
+	git git
+}
+
+// Creates a syncer for the working copy at dir.
+func New(dir string, service vault.PromptService) *Syncer {
+	return &Syncer{Dir: dir, Branch: "main", Service: service, git: git{dir: dir}}
+}
+
+// Reports whether the working copy has been set up with Init.
+func (s *Syncer) Configured() bool {
+	_, err := os.Stat(filepath.Join(s.Dir, ".git"))
+	return err == nil
+}
+
+// Sets up the working copy to sync with remote, any url or path git accepts.
+// Setting it up again only changes the remote.
+func (s *Syncer) Init(ctx context.Context, remote string) error {
+	if s.Configured() {
+		_, err := s.git.run(ctx, "remote", "set-url", "origin", remote)
+		return err
+	}
+
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return err
+	}
+	steps := [][]string{
+		{"init", "--quiet", "--initial-branch", s.Branch},
+		{"remote", "add", "origin", remote},
+	}
+	for _, args := range steps {
+		if _, err := s.git.run(ctx, args...); err != nil {
+			return err
+		}
+	}
+
+	// commits need an author, fall back to a local one if git has none configured
+	if email, _ := s.git.run(ctx, "config", "user.email"); email == "" {
+		if _, err := s.git.run(ctx, "config", "user.email", "pvt@localhost"); err != nil {
+			return err
+		}
+		if _, err := s.git.run(ctx, "config", "user.name", "pvt"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Plan is the outcome of merging the vault with the remote, ready to be applied.
+type Plan struct {
+	// prompts of the remote to write to the vault, new or merged
+	Pull []vault.Prompt
+
+	// vault prompts deleted in the remote
+	Delete []vault.Prompt
+
+	// prompts changed on both sides, they must be resolved before applying
+	Conflicts []*Conflict
+
+	// commit of the remote branch that was merged, "" if it has none yet
+	remote string
+}
+
+// Reports whether every conflict of the plan has been resolved.
+func (p *Plan) Resolved() bool {
+	for _, c := range p.Conflicts {
+		if c.Resolution == Unresolved {
+			return false
+		}
+	}
+	return true
+}
+
+// Resolves every conflict of the plan by keeping the same side.
+func (p *Plan) ResolveAll(side Side) {
+	for _, c := range p.Conflicts {
+		c.Resolve(side)
+	}
+}
+
+// Result summarises an applied plan.
+type Result struct {
+	// prompts written to or deleted from the vault
+	Pulled  int
+	Deleted int
+
+	// prompt files changed in the remote
+	Pushed int
+}
+
+func (r Result) String() string {
+	return fmt.Sprintf("%d pulled, %d deleted, %d pushed", r.Pulled, r.Deleted, r.Pushed)
+}
+
+// Fetches the remote and merges it with the vault, without changing either.
+func (s *Syncer) Plan(ctx context.Context) (*Plan, error) {
+	if !s.Configured() {
+		return nil, ErrNotConfigured
+	}
+
+	remoteRef := "refs/remotes/origin/" + s.Branch
+	if _, err := s.git.run(ctx, "fetch", "--quiet", "origin", fmt.Sprintf("+refs/heads/%s:%s", s.Branch, remoteRef)); err != nil {
+		// a remote without any commit has nothing to fetch yet
+		if !strings.Contains(err.Error(), "couldn't find remote ref") {
+			return nil, err
+		}
+	}
+
+	plan := &Plan{remote: s.git.revParse(ctx, remoteRef)}
+
+	base, err := s.readPrompts(ctx, s.git.revParse(ctx, "HEAD"))
+	if err != nil {
+		return nil, fmt.Errorf("read last synced prompts: %w", err)
+	}
+	remote, err := s.readPrompts(ctx, plan.remote)
+	if err != nil {
+		return nil, fmt.Errorf("read remote prompts: %w", err)
+	}
+
+	prompts, err := s.localPrompts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	local := map[string]*vault.Prompt{}
+	for i := range prompts {
+		local[prompts[i].UUID] = &prompts[i]
+	}
+
+	// every uuid known to any side, sorted to keep the plan stable
+	seen := map[string]bool{}
+	uuids := []string{}
+	for _, side := range []map[string]*vault.Prompt{base, local, remote} {
+		for uuid := range side {
+			if !seen[uuid] {
+				seen[uuid] = true
+				uuids = append(uuids, uuid)
+			}
+		}
+	}
+	sort.Strings(uuids)
+
+	for _, uuid := range uuids {
+		outcome, merged, conflict := merge(base[uuid], local[uuid], remote[uuid])
+		switch outcome {
+		case pulled:
+			plan.Pull = append(plan.Pull, *merged)
+		case deleted:
+			plan.Delete = append(plan.Delete, *local[uuid])
+		case conflicting:
+			plan.Conflicts = append(plan.Conflicts, conflict)
+		}
+	}
+
+	return plan, nil
+}
+
+// Writes the merge to the vault, then mirrors the vault to the working copy
+// and pushes it. Every conflict must be resolved first.
+//
+// The vault is written first: if pushing fails, e.g. because someone pushed
+// in the meantime, the next sync merges again from the same base and nothing
+// is lost.
+func (s *Syncer) Apply(ctx context.Context, plan *Plan) (Result, error) {
+	result := Result{}
+	if !plan.Resolved() {
+		return result, ErrUnresolved
+	}
+	// checked before anything is written, the vault may have changed since the plan
+	if _, err := s.localPrompts(ctx); err != nil {
+		return result, err
+	}
+
+	writes := append([]vault.Prompt{}, plan.Pull...)
+	deletes := append([]vault.Prompt{}, plan.Delete...)
+	for _, c := range plan.Conflicts {
+		switch p := c.Result(); {
+		case p != nil:
+			writes = append(writes, *p)
+		case c.Local != nil:
+			deletes = append(deletes, *c.Local)
+		}
+	}
+
+	if err := s.writeVault(ctx, writes); err != nil {
+		return result, err
+	}
+	result.Pulled = len(writes)
+
+	for _, p := range deletes {
+		if err := s.Service.DeletePrompt(ctx, p.ID); err != nil && !errors.Is(err, vault.ErrNotFound) {
+			return result, err
+		}
+		result.Deleted++
+	}
+
+	pushed, err := s.push(ctx, plan.remote)
+	result.Pushed = pushed
+	return result, err
+}
+
+// the prompts of the vault. the prompt of a record that cannot be read
+// would look deleted and be deleted from every other vault, so there is
+// no syncing until the vault is repaired.
+func (s *Syncer) localPrompts(ctx context.Context) ([]vault.Prompt, error) {
+	prompts, err := s.Service.GetAllPrompts(ctx)
+	if errors.Is(err, vault.ErrUnreadable) {
+		return nil, fmt.Errorf("cannot sync: %w", err)
+	}
+	return prompts, err
+}
+
+// writes the merged prompts to the vault in a single transaction
+func (s *Syncer) writeVault(ctx context.Context, writes []vault.Prompt) error {
+	if len(writes) == 0 {
+		return nil
+	}
+
+	prompts, err := s.localPrompts(ctx)
+	if err != nil {
+		return err
+	}
+	slugOwners := map[string]string{}
+	for _, p := range prompts {
+		for _, slug := range append([]string{p.Slug}, p.Aliases...) {
+			slugOwners[slug] = p.UUID
+		}
+	}
+
+	for i := range writes {
+		p := &writes[i]
+		// a slug another prompt has here is derived again from the title
+		if owner, ok := slugOwners[p.Slug]; ok && owner != p.UUID {
+			p.Slug = ""
+		}
+	}
+
+	_, err = s.Service.CreateOrUpdatePrompts(ctx, writes)
+	return err
+}
+
+// mirrors the vault on top of the remote commit and pushes it.
+// returns the number of prompt files that changed.
+func (s *Syncer) push(ctx context.Context, remote string) (int, error) {
+	head := s.git.revParse(ctx, "HEAD")
+
+	// start from the remote so that the push is a fast forward
+	if remote != "" {
+		if _, err := s.git.run(ctx, "reset", "--quiet", "--hard", remote); err != nil {
+			return 0, err
+		}
+	}
+
+	prompts, err := s.localPrompts(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.writeFiles(prompts); err != nil {
+		return 0, err
+	}
+
+	if _, err := s.git.run(ctx, "add", "--all", "--", promptsDir); err != nil {
+		return 0, err
+	}
+	changed, err := s.git.stagedFiles(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(changed) == 0 && remote != "" {
+		return 0, nil
+	}
+
+	message := fmt.Sprintf("Sync %d prompt(s)", len(changed))
+	if _, err := s.git.run(ctx, "commit", "--quiet", "--allow-empty", "--message", message); err != nil {
+		return 0, err
+	}
+
+	if _, err := s.git.run(ctx, "push", "--quiet", "origin", "HEAD:refs/heads/"+s.Branch); err != nil {
+		// go back to the last synced commit, it stays the base of the next merge
+		s.restore(head)
+		return 0, err
+	}
+	return len(changed), nil
+}
+
+// moves the working copy back to commit, or to an unborn branch if it is empty
+func (s *Syncer) restore(commit string) {
+	ctx := context.Background()
+	if commit == "" {
+		s.git.run(ctx, "update-ref", "-d", "HEAD")
+		s.git.run(ctx, "rm", "-r", "--quiet", "--cached", "--ignore-unmatch", "--", promptsDir)
+		return
+	}
+	s.git.run(ctx, "reset", "--quiet", "--hard", commit)
+}
+
+// replaces the prompt files of the working copy with the prompts
+func (s *Syncer) writeFiles(prompts []vault.Prompt) error {
+	dir := filepath.Join(s.Dir, promptsDir)
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	for _, p := range prompts {
+		name := filepath.Join(dir, fileName(p))
+		if err := os.WriteFile(name, vault.MarshalMarkdown(p), 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// name of the file of a prompt, slugs are unique within a vault
+func fileName(p vault.Prompt) string {
+	if p.Slug == "" {
+		return p.UUID + ".md"
+	}
+	return p.Slug + ".md"
+}
+
+// reads the prompt files of a commit, keyed by uuid
+func (s *Syncer) readPrompts(ctx context.Context, commit string) (map[string]*vault.Prompt, error) {
+	files, err := s.git.readTree(ctx, commit, promptsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	prompts := map[string]*vault.Prompt{}
+	for name, data := range files {
+		if path.Ext(name) != ".md" {
+			continue
+		}
+		p, err := vault.UnmarshalMarkdown(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if !vault.ValidUUID(p.UUID) {
+			return nil, fmt.Errorf("%s: missing or invalid uuid %q", name, p.UUID)
+		}
+		if other, ok := prompts[p.UUID]; ok {
+			return nil, fmt.Errorf("%s: uuid %s is also used by %q", name, p.UUID, other.Title)
+		}
+		prompts[p.UUID] = &p
+	}
+	return prompts, nil
+}
@@ -0,0 +1,238 @@
+package gitsync_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/gitsync"
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+	"github.com/boltdb/bolt"
+)
+
+// a vault with a syncer, set up against remote
+type testVault struct {
+	db      *bolt.DB
+	service vault.PromptService
+	syncer  *gitsync.Syncer
+}
+
+func newTestVault(t *testing.T, remote string) *testVault {
+	t.Helper()
+
+	dir := t.TempDir()
+	db, err := bolt.Open(filepath.Join(dir, "test.db"), 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	service := vault.NewPromptService(vault.NewPromptRepository(db, logger))
+	syncer := gitsync.New(filepath.Join(dir, "sync"), service)
+	if err := syncer.Init(context.Background(), remote); err != nil {
+		t.Fatalf("Init() failed: %v", err)
+	}
+	return &testVault{db: db, service: service, syncer: syncer}
+}
+
+// creates a bare repository to sync through, no network involved
+func newRemote(t *testing.T) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	if out, err := exec.Command("git", "init", "--quiet", "--bare", remote).CombinedOutput(); err != nil {
+		t.Fatalf("git init --bare: %v: %s", err, out)
+	}
+	return remote
+}
+
+// syncs, failing the test on conflicts
+func (v *testVault) sync(t *testing.T) gitsync.Result {
+	t.Helper()
+
+	plan, err := v.syncer.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan() failed: %v", err)
+	}
+	if len(plan.Conflicts) > 0 {
+		t.Fatalf("Plan() found %d unexpected conflict(s)", len(plan.Conflicts))
+	}
+	result, err := v.syncer.Apply(context.Background(), plan)
+	if err != nil {
+		t.Fatalf("Apply() failed: %v", err)
+	}
+	return result
+}
+
+func (v *testVault) save(t *testing.T, p *vault.Prompt) {
+	t.Helper()
+	if _, err := v.service.CreateOrUpdatePrompt(context.Background(), p); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (v *testVault) get(t *testing.T, ref string) *vault.Prompt {
+	t.Helper()
+	p, err := v.service.GetPromptByRef(context.Background(), ref)
+	if err != nil {
+		t.Fatalf("GetPromptByRef(%q) failed: %v", ref, err)
+	}
+	return p
+}
+
+func TestSync(t *testing.T) {
+	remote := newRemote(t)
+	alice := newTestVault(t, remote)
+	bob := newTestVault(t, remote)
+
+	style := &vault.Prompt{Title: "House Style", PromptContent: "Be concise."}
+	review := &vault.Prompt{Title: "Reviewer", Variables: []string{"language"}, PromptContent: "Review this {{language}} code."}
+	alice.save(t, style)
+	alice.save(t, review)
+
+	if got := alice.sync(t); got.Pushed != 2 {
+		t.Errorf("first sync pushed %d file(s), want 2", got.Pushed)
+	}
+	if got := bob.sync(t); got.Pulled != 2 {
+		t.Errorf("bob pulled %d prompt(s), want 2", got.Pulled)
+	}
+	if got := bob.get(t, review.UUID); got.PromptContent != review.PromptContent || len(got.Variables) != 1 {
+		t.Errorf("bob has %+v, want alice's reviewer", got)
+	}
+
+	// edits to different fields of the same prompt are merged
+	style.Title = "Style Guide"
+	alice.save(t, style)
+	bobStyle := bob.get(t, style.UUID)
+	bobStyle.PromptContent = "Be concise and friendly."
+	bob.save(t, bobStyle)
+
+	alice.sync(t)
+	bob.sync(t)
+	alice.sync(t)
+
+	for name, v := range map[string]*testVault{"alice": alice, "bob": bob} {
+		got := v.get(t, style.UUID)
+		if got.Title != "Style Guide" || got.PromptContent != "Be concise and friendly." {
+			t.Errorf("%s has %q: %q, want both edits", name, got.Title, got.PromptContent)
+		}
+	}
+
+	// deletions travel too
+	if err := bob.service.DeletePrompt(context.Background(), bob.get(t, review.UUID).ID); err != nil {
+		t.Fatal(err)
+	}
+	bob.sync(t)
+	if got := alice.sync(t); got.Deleted != 1 {
+		t.Errorf("alice deleted %d prompt(s), want 1", got.Deleted)
+	}
+	prompts, err := alice.service.GetAllPrompts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prompts) != 1 {
+		t.Errorf("alice has %d prompt(s), want 1", len(prompts))
+	}
+}
+
+func TestSync_UnreadableRecord(t *testing.T) {
+	ctx := context.Background()
+	remote := newRemote(t)
+	alice := newTestVault(t, remote)
+	bob := newTestVault(t, remote)
+
+	style := &vault.Prompt{Title: "House Style", PromptContent: "Be concise."}
+	alice.save(t, style)
+	alice.save(t, &vault.Prompt{Title: "Reviewer", PromptContent: "Review this code."})
+	alice.sync(t)
+	bob.sync(t)
+
+	// a plan made while the vault was sound is not applied either
+	plan, err := alice.syncer.Plan(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = alice.db.Update(func(tx *bolt.Tx) error {
+		key := binary.BigEndian.AppendUint64(nil, uint64(style.ID))
+		return tx.Bucket([]byte("prompts")).Put(key, []byte("{not json"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the prompt that cannot be read must not look deleted
+	if _, err := alice.syncer.Plan(ctx); !errors.Is(err, vault.ErrUnreadable) {
+		t.Errorf("Plan() with an unreadable record error = %v, want vault.ErrUnreadable", err)
+	}
+	if _, err := alice.syncer.Apply(ctx, plan); !errors.Is(err, vault.ErrUnreadable) {
+		t.Errorf("Apply() with an unreadable record error = %v, want vault.ErrUnreadable", err)
+	}
+
+	bob.sync(t)
+	if got := bob.get(t, style.UUID); got.PromptContent != style.PromptContent {
+		t.Errorf("bob has %+v, want the house style kept", got)
+	}
+	if prompts, err := bob.service.GetAllPrompts(ctx); err != nil || len(prompts) != 2 {
+		t.Errorf("bob has %d prompt(s), %v, want 2", len(prompts), err)
+	}
+}
+
+func TestSync_Conflict(t *testing.T) {
+	ctx := context.Background()
+	remote := newRemote(t)
+	alice := newTestVault(t, remote)
+	bob := newTestVault(t, remote)
+
+	prompt := &vault.Prompt{Title: "Reviewer", PromptContent: "Review the code."}
+	alice.save(t, prompt)
+	alice.sync(t)
+	bob.sync(t)
+
+	prompt.PromptContent = "Review the code carefully."
+	alice.save(t, prompt)
+	alice.sync(t)
+
+	bobs := bob.get(t, prompt.UUID)
+	bobs.PromptContent = "Review the code quickly."
+	bobs.Description = "fast reviews"
+	bob.save(t, bobs)
+
+	plan, err := bob.syncer.Plan(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Conflicts) != 1 {
+		t.Fatalf("Plan() found %d conflict(s), want 1", len(plan.Conflicts))
+	}
+	conflict := plan.Conflicts[0]
+	if len(conflict.Fields) != 1 || conflict.Fields[0] != vault.FieldContent {
+		t.Errorf("conflicting fields = %v, want [content]", conflict.Fields)
+	}
+	if _, err := bob.syncer.Apply(ctx, plan); err != gitsync.ErrUnresolved {
+		t.Fatalf("Apply() error = %v, want ErrUnresolved", err)
+	}
+
+	// keeping the remote content keeps bob's description, nobody else touched it
+	conflict.Resolve(gitsync.KeepRemote)
+	if _, err := bob.syncer.Apply(ctx, plan); err != nil {
+		t.Fatalf("Apply() failed: %v", err)
+	}
+	alice.sync(t)
+
+	for name, v := range map[string]*testVault{"alice": alice, "bob": bob} {
+		got := v.get(t, prompt.UUID)
+		if got.PromptContent != "Review the code carefully." || got.Description != "fast reviews" {
+			t.Errorf("%s has %q (%q), want alice's content and bob's description", name, got.PromptContent, got.Description)
+		}
+	}
+}
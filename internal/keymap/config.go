@@ -0,0 +1,170 @@
+package keymap
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Loads the keymap from a config file, the default keymap if there is no
+// such file. The file names the preset to start from and rebinds actions
+// by section:
+//
+//	preset = "vim"
+//
+//	[list]
+//	new = "n"
+//	delete = ["x", "delete"]
+//	pin = []  # unbound
+//
+// The keymap is validated, so that the file can be fixed before the tui
+// starts rather than when a key does not do what it should.
+func Load(path string) (KeyMap, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Default(), nil
+	}
+	if err != nil {
+		return KeyMap{}, err
+	}
+	k, err := Parse(data)
+	if err != nil {
+		return KeyMap{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return k, nil
+}
+
+// Parses a keymap config, see Load, and validates it.
+func Parse(data []byte) (KeyMap, error) {
+	var config map[string]any
+	if _, err := toml.Decode(string(data), &config); err != nil {
+		return KeyMap{}, err
+	}
+
+	name := "default"
+	if preset, ok := config["preset"]; ok {
+		if name, ok = preset.(string); !ok {
+			return KeyMap{}, errors.New("preset must be a string, default or vim")
+		}
+	}
+	k, err := Preset(name)
+	if err != nil {
+		return KeyMap{}, err
+	}
+
+	errs := []error{}
+	for _, section := range slices.Sorted(maps.Keys(config)) {
+		if section == "preset" {
+			continue
+		}
+		actions, ok := config[section].(map[string]any)
+		if !ok || !slices.Contains(k.sections(), section) {
+			errs = append(errs, fmt.Errorf("unknown section [%s], expected one of %s", section, strings.Join(k.sections(), ", ")))
+			continue
+		}
+		for _, name := range slices.Sorted(maps.Keys(actions)) {
+			action := k.Action(section, name)
+			if action == nil {
+				errs = append(errs, fmt.Errorf("unknown action %s.%s, expected one of %s", section, name, strings.Join(k.names(section), ", ")))
+				continue
+			}
+			keys, err := keyList(actions[name])
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s.%s: %w", section, name, err))
+				continue
+			}
+			Rebind(action.Binding, keys...)
+		}
+	}
+	if len(errs) > 0 {
+		return KeyMap{}, errors.Join(errs...)
+	}
+	return k, k.Validate()
+}
+
+// a key or a list of keys
+func keyList(value any) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return nil, errors.New("empty key, use [] to unbind the action")
+		}
+		return []string{v}, nil
+	case []any:
+		keys := []string{}
+		for _, item := range v {
+			k, ok := item.(string)
+			if !ok || k == "" {
+				return nil, fmt.Errorf("%v is not a key", item)
+			}
+			keys = append(keys, k)
+		}
+		return keys, nil
+	}
+	return nil, errors.New("must be a key or a list of keys")
+}
+
+// Checks that no key is bound to two actions of the same section, or to
+// an action and a global one. With a modal keymap the editor and the
+// search must also have a way into and out of insert mode.
+func (k *KeyMap) Validate() error {
+	errs := []error{}
+	global := map[string]string{}
+	bound := map[string]map[string]string{} // section, key, action
+	for _, a := range k.Actions() {
+		name := a.Section + "." + a.Name
+		if bound[a.Section] == nil {
+			bound[a.Section] = map[string]string{}
+		}
+		for _, key := range a.Binding.Keys() {
+			if other, ok := global[key]; ok && a.Section != "global" {
+				errs = append(errs, &ConflictError{Key: key, Actions: [2]string{other, name}})
+			}
+			if other, ok := bound[a.Section][key]; ok && other != name {
+				errs = append(errs, &ConflictError{Key: key, Actions: [2]string{other, name}})
+			}
+			bound[a.Section][key] = name
+			if a.Section == "global" {
+				global[key] = name
+			}
+		}
+	}
+
+	if k.Modal {
+		for _, a := range []string{"form_normal.insert", "form.normal", "search.insert", "search_insert.normal"} {
+			section, name, _ := strings.Cut(a, ".")
+			if len(k.Action(section, name).Binding.Keys()) == 0 {
+				errs = append(errs, fmt.Errorf("%s needs a key in a modal keymap", a))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// the sections of the keymap, in order
+func (k *KeyMap) sections() []string {
+	sections := []string{}
+	for _, a := range k.Actions() {
+		if !slices.Contains(sections, a.Section) {
+			sections = append(sections, a.Section)
+		}
+	}
+	return sections
+}
+
+// the actions of a section, in order
+func (k *KeyMap) names(section string) []string {
+	names := []string{}
+	for _, a := range k.Actions() {
+		if a.Section == section {
+			names = append(names, a.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,369 @@
+// Package keymap binds the actions of the tui to keys.
+// Every screen of the tui has a section of actions, the keys of a section
+// must not clash with each other or with the global ones. The keys can be
+// changed from a config file, starting from the default or the vim preset.
+package keymap
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+// KeyMap binds every action of the tui, by the screen it is used on.
+type KeyMap struct {
+	// Modal switches the editor and the search between a normal mode,
+	// where keys are actions, and an insert mode for typing, as in vim.
+	// FormNormal and SearchInsert are only used when it is set.
+	Modal bool
+
+	Global       GlobalKeys
+	Fatal        FatalKeys
+	List         ListKeys
+	Filter       FilterKeys
+	Search       SearchKeys
+	SearchInsert SearchInsertKeys
+	Form         FormKeys
+	FormNormal   FormNormalKeys
+	Preview      PreviewKeys
+	ErrorLog     ErrorLogKeys
+	Logs         LogsKeys
+	Palette      PaletteKeys
+	Confirm      ConfirmKeys
+	Duplicates   DuplicatesKeys
+	Merge        MergeKeys
+}
+
+// keys that work on every screen
+type GlobalKeys struct {
+	Quit    key.Binding
+	Dismiss key.Binding // dismisses the error toasts
+	Palette key.Binding // opens the command palette
+}
+
+// keys of the modal shown when the vault cannot be loaded
+type FatalKeys struct {
+	Retry   key.Binding
+	Dismiss key.Binding
+	Quit    key.Binding
+}
+
+// keys of the list of prompts
+type ListKeys struct {
+	Up          key.Binding
+	Down        key.Binding
+	PrevPage    key.Binding
+	NextPage    key.Binding
+	Start       key.Binding
+	End         key.Binding
+	Filter      key.Binding
+	ClearFilter key.Binding
+	Help        key.Binding
+	Quit        key.Binding
+
+	New        key.Binding
+	Edit       key.Binding
+	Delete     key.Binding
+	Copy       key.Binding
+	Preview    key.Binding
+	Search     key.Binding
+	Pin        key.Binding
+	Sync       key.Binding
+	Duplicates key.Binding
+	Errors     key.Binding
+	Logs       key.Binding
+	Clone      key.Binding // duplicates the prompt into the editor
+	Sort       key.Binding // cycles the order of the prompts
+	Export     key.Binding
+}
+
+// keys of the list while a filter is typed
+type FilterKeys struct {
+	Cancel key.Binding
+	Accept key.Binding
+}
+
+// keys of the relevance search. when modal they are the keys of its
+// normal mode, otherwise they are shared with typing the search.
+type SearchKeys struct {
+	Back     key.Binding
+	Copy     key.Binding
+	Up       key.Binding
+	Down     key.Binding
+	PrevPage key.Binding
+	NextPage key.Binding
+	Insert   key.Binding // modal only, back to typing
+}
+
+// keys of the relevance search while typing, when modal
+type SearchInsertKeys struct {
+	Normal key.Binding
+}
+
+// keys of the editor. when modal they are the keys of its insert mode.
+type FormKeys struct {
+	Next key.Binding
+	Prev key.Binding
+	// saves on the submit button, moves to the next field elsewhere
+	Submit key.Binding
+	Redact key.Binding
+	Cancel key.Binding
+	Normal key.Binding // modal only, stops typing
+}
+
+// keys of the editor in normal mode, when modal
+type FormNormalKeys struct {
+	Next   key.Binding
+	Prev   key.Binding
+	Submit key.Binding
+	Redact key.Binding
+	Cancel key.Binding
+	Insert key.Binding
+}
+
+// keys of the preview of a prompt
+type PreviewKeys struct {
+	Up           key.Binding
+	Down         key.Binding
+	PageUp       key.Binding
+	PageDown     key.Binding
+	HalfPageUp   key.Binding
+	HalfPageDown key.Binding
+	Copy         key.Binding
+	Back         key.Binding
+}
+
+// keys of the error log
+type ErrorLogKeys struct {
+	Back key.Binding
+}
+
+// keys of the log panel
+type LogsKeys struct {
+	Up       key.Binding
+	Down     key.Binding
+	PageUp   key.Binding
+	PageDown key.Binding
+	Top      key.Binding
+	Bottom   key.Binding // and follows the new records again
+	Level    key.Binding // cycles the least level shown
+	Back     key.Binding
+}
+
+// keys of the command palette. letters are typed into its search.
+type PaletteKeys struct {
+	Up    key.Binding
+	Down  key.Binding
+	Run   key.Binding
+	Close key.Binding
+}
+
+// keys of the confirmation before deleting a prompt
+type ConfirmKeys struct {
+	Yes key.Binding
+	No  key.Binding
+}
+
+// keys of the merge of near duplicates
+type DuplicatesKeys struct {
+	Up     key.Binding
+	Down   key.Binding
+	Keep   key.Binding
+	Toggle key.Binding
+	Next   key.Binding
+	Prev   key.Binding
+	Merge  key.Binding
+	Back   key.Binding
+}
+
+// keys of the merge of sync conflicts
+type MergeKeys struct {
+	Local  key.Binding
+	Remote key.Binding
+	Next   key.Binding
+	Prev   key.Binding
+	Apply  key.Binding
+	Back   key.Binding
+}
+
+// Action is a binding of the keymap with the names it is configured by.
+type Action struct {
+	Section string
+	Name    string
+	Binding *key.Binding
+}
+
+// Returns every action of the keymap, section by section, global first.
+func (k *KeyMap) Actions() []Action {
+	return []Action{
+		{"global", "quit", &k.Global.Quit},
+		{"global", "dismiss", &k.Global.Dismiss},
+		{"global", "palette", &k.Global.Palette},
+
+		{"fatal", "retry", &k.Fatal.Retry},
+		{"fatal", "dismiss", &k.Fatal.Dismiss},
+		{"fatal", "quit", &k.Fatal.Quit},
+
+		{"list", "up", &k.List.Up},
+		{"list", "down", &k.List.Down},
+		{"list", "prev_page", &k.List.PrevPage},
+		{"list", "next_page", &k.List.NextPage},
+		{"list", "start", &k.List.Start},
+		{"list", "end", &k.List.End},
+		{"list", "filter", &k.List.Filter},
+		{"list", "clear_filter", &k.List.ClearFilter},
+		{"list", "help", &k.List.Help},
+		{"list", "quit", &k.List.Quit},
+		{"list", "new", &k.List.New},
+		{"list", "edit", &k.List.Edit},
+		{"list", "delete", &k.List.Delete},
+		{"list", "copy", &k.List.Copy},
+		{"list", "preview", &k.List.Preview},
+		{"list", "search", &k.List.Search},
+		{"list", "pin", &k.List.Pin},
+		{"list", "sync", &k.List.Sync},
+		{"list", "duplicates", &k.List.Duplicates},
+		{"list", "errors", &k.List.Errors},
+		{"list", "logs", &k.List.Logs},
+		{"list", "clone", &k.List.Clone},
+		{"list", "sort", &k.List.Sort},
+		{"list", "export", &k.List.Export},
+
+		{"filter", "cancel", &k.Filter.Cancel},
+		{"filter", "accept", &k.Filter.Accept},
+
+		{"search", "back", &k.Search.Back},
+		{"search", "copy", &k.Search.Copy},
+		{"search", "up", &k.Search.Up},
+		{"search", "down", &k.Search.Down},
+		{"search", "prev_page", &k.Search.PrevPage},
+		{"search", "next_page", &k.Search.NextPage},
+		{"search", "insert", &k.Search.Insert},
+
+		{"search_insert", "normal", &k.SearchInsert.Normal},
+
+		{"form", "next", &k.Form.Next},
+		{"form", "prev", &k.Form.Prev},
+		{"form", "submit", &k.Form.Submit},
+		{"form", "redact", &k.Form.Redact},
+		{"form", "cancel", &k.Form.Cancel},
+		{"form", "normal", &k.Form.Normal},
+
+		{"form_normal", "next", &k.FormNormal.Next},
+		{"form_normal", "prev", &k.FormNormal.Prev},
+		{"form_normal", "submit", &k.FormNormal.Submit},
+		{"form_normal", "redact", &k.FormNormal.Redact},
+		{"form_normal", "cancel", &k.FormNormal.Cancel},
+		{"form_normal", "insert", &k.FormNormal.Insert},
+
+		{"preview", "up", &k.Preview.Up},
+		{"preview", "down", &k.Preview.Down},
+		{"preview", "page_up", &k.Preview.PageUp},
+		{"preview", "page_down", &k.Preview.PageDown},
+		{"preview", "half_page_up", &k.Preview.HalfPageUp},
+		{"preview", "half_page_down", &k.Preview.HalfPageDown},
+		{"preview", "copy", &k.Preview.Copy},
+		{"preview", "back", &k.Preview.Back},
+
+		{"error_log", "back", &k.ErrorLog.Back},
+
+		{"logs", "up", &k.Logs.Up},
+		{"logs", "down", &k.Logs.Down},
+		{"logs", "page_up", &k.Logs.PageUp},
+		{"logs", "page_down", &k.Logs.PageDown},
+		{"logs", "top", &k.Logs.Top},
+		{"logs", "bottom", &k.Logs.Bottom},
+		{"logs", "level", &k.Logs.Level},
+		{"logs", "back", &k.Logs.Back},
+
+		{"palette", "up", &k.Palette.Up},
+		{"palette", "down", &k.Palette.Down},
+		{"palette", "run", &k.Palette.Run},
+		{"palette", "close", &k.Palette.Close},
+
+		{"confirm", "yes", &k.Confirm.Yes},
+		{"confirm", "no", &k.Confirm.No},
+
+		{"duplicates", "up", &k.Duplicates.Up},
+		{"duplicates", "down", &k.Duplicates.Down},
+		{"duplicates", "keep", &k.Duplicates.Keep},
+		{"duplicates", "toggle", &k.Duplicates.Toggle},
+		{"duplicates", "next", &k.Duplicates.Next},
+		{"duplicates", "prev", &k.Duplicates.Prev},
+		{"duplicates", "merge", &k.Duplicates.Merge},
+		{"duplicates", "back", &k.Duplicates.Back},
+
+		{"merge", "local", &k.Merge.Local},
+		{"merge", "remote", &k.Merge.Remote},
+		{"merge", "next", &k.Merge.Next},
+		{"merge", "prev", &k.Merge.Prev},
+		{"merge", "apply", &k.Merge.Apply},
+		{"merge", "back", &k.Merge.Back},
+	}
+}
+
+// Looks up an action by its section and name, nil if there is none.
+func (k *KeyMap) Action(section, name string) *Action {
+	for _, a := range k.Actions() {
+		if a.Section == section && a.Name == name {
+			return &a
+		}
+	}
+	return nil
+}
+
+// Binds an action to keys, replacing the ones it had. No keys unbind it.
+// "space" stands for the space bar.
+func Rebind(b *key.Binding, keys ...string) {
+	var normalized []string // nil keys disable the binding
+	for _, k := range keys {
+		if k == "space" {
+			k = " "
+		}
+		normalized = append(normalized, k)
+	}
+	b.SetKeys(normalized...)
+	b.SetHelp(Label(normalized), b.Help().Desc)
+}
+
+// bind makes a binding with its help
+func bind(desc string, keys ...string) key.Binding {
+	return key.NewBinding(key.WithKeys(keys...), key.WithHelp(Label(keys), desc))
+}
+
+// how keys are shown in the help
+var keySymbols = map[string]string{
+	"enter": "↵",
+	"up":    "↑",
+	"down":  "↓",
+	"left":  "←",
+	"right": "→",
+	" ":     "space",
+}
+
+// Returns how keys are shown in the help: the first two, as in ↑/k.
+func Label(keys []string) string {
+	labels := []string{}
+	for _, k := range keys[:min(len(keys), 2)] {
+		if symbol, ok := keySymbols[k]; ok {
+			k = symbol
+		}
+		labels = append(labels, k)
+	}
+	return strings.Join(labels, "/")
+}
+
+// ConflictError is a key bound to two actions that can be used at the same time.
+type ConflictError struct {
+	Key     string
+	Actions [2]string // as section.name
+}
+
+func (e *ConflictError) Error() string {
+	k := e.Key
+	if k == " " {
+		k = "space"
+	}
+	return fmt.Sprintf("%q is bound to both %s and %s", k, e.Actions[0], e.Actions[1])
+}
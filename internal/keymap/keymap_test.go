@@ -0,0 +1,151 @@
+package keymap_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/keymap"
+)
+
+func TestPresets_Validate(t *testing.T) {
+	for _, name := range []string{"default", "vim"} {
+		t.Run(name, func(t *testing.T) {
+			k, err := keymap.Preset(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := k.Validate(); err != nil {
+				t.Errorf("Validate() = %v, want the preset to be free of conflicts", err)
+			}
+			for _, a := range k.Actions() {
+				if a.Binding.Help().Desc == "" {
+					t.Errorf("%s.%s has no help", a.Section, a.Name)
+				}
+			}
+		})
+	}
+	if _, err := keymap.Preset("emacs"); err == nil {
+		t.Error("Preset(emacs) succeeded, want an error")
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string // description of this test case
+		config  string
+		check   func(t *testing.T, k keymap.KeyMap)
+		wantErr string
+	}{
+		{
+			name:   "Empty",
+			config: "",
+			check: func(t *testing.T, k keymap.KeyMap) {
+				if k.Modal || !slices.Equal(k.List.New.Keys(), []string{"a"}) {
+					t.Errorf("Parse() = %v, want the default keymap", k.List.New.Keys())
+				}
+			},
+		},
+		{
+			name:   "Rebinding",
+			config: "[list]\nnew = \"n\"\ndelete = [\"x\", \"delete\"]\npin = []\n\n[duplicates]\ntoggle = \"space\"",
+			check: func(t *testing.T, k keymap.KeyMap) {
+				if !slices.Equal(k.List.New.Keys(), []string{"n"}) || !slices.Equal(k.List.Delete.Keys(), []string{"x", "delete"}) {
+					t.Errorf("Parse() new = %v, delete = %v", k.List.New.Keys(), k.List.Delete.Keys())
+				}
+				if len(k.List.Pin.Keys()) != 0 || k.List.Pin.Enabled() {
+					t.Errorf("Parse() pin = %v, want it unbound", k.List.Pin.Keys())
+				}
+				if !slices.Equal(k.Duplicates.Toggle.Keys(), []string{" "}) {
+					t.Errorf("Parse() toggle = %q, want the space bar", k.Duplicates.Toggle.Keys())
+				}
+				if h := k.List.Delete.Help(); h.Key != "x/delete" || h.Desc != "delete" {
+					t.Errorf("Parse() help of delete = %+v, want it to show the new keys", h)
+				}
+			},
+		},
+		{
+			name:   "Preset",
+			config: "preset = \"vim\"\n[list]\ncopy = \"enter\"",
+			check: func(t *testing.T, k keymap.KeyMap) {
+				if !k.Modal || !slices.Equal(k.List.Delete.Keys(), []string{"x", "d"}) || !slices.Equal(k.List.Copy.Keys(), []string{"enter"}) {
+					t.Errorf("Parse() = modal %v, delete %v, copy %v, want vim with copy on enter", k.Modal, k.List.Delete.Keys(), k.List.Copy.Keys())
+				}
+			},
+		},
+		{name: "Conflict", config: "[list]\nnew = \"d\"", wantErr: `"d" is bound to both list.new and list.delete`},
+		{name: "Conflict with a global key", config: "[merge]\napply = \"ctrl+c\"", wantErr: `"ctrl+c" is bound to both global.quit and merge.apply`},
+		{name: "Same key in another section", config: "[preview]\ncopy = \"d\"", wantErr: `"d" is bound to both preview.half_page_down and preview.copy`},
+		{name: "Unknown section", config: "[lst]\nnew = \"n\"", wantErr: "unknown section [lst], expected one of global, fatal, list"},
+		{name: "Unknown action", config: "[list]\nnwe = \"n\"", wantErr: "unknown action list.nwe, expected one of up, down"},
+		{name: "Bad value", config: "[list]\nnew = 1", wantErr: "list.new: must be a key or a list of keys"},
+		{name: "Empty key", config: "[list]\nnew = \"\"", wantErr: "list.new: empty key"},
+		{name: "Unknown preset", config: "preset = \"emacs\"", wantErr: `unknown preset "emacs"`},
+		{name: "Modal without insert", config: "preset = \"vim\"\n[form_normal]\ninsert = []", wantErr: "form_normal.insert needs a key"},
+		{name: "Malformed", config: "[list\nnew = ", wantErr: "toml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := keymap.Parse([]byte(tt.config))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse() = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, k)
+		})
+	}
+}
+
+func TestValidate_ConflictError(t *testing.T) {
+	k := keymap.Default()
+	keymap.Rebind(&k.Merge.Remote, "l")
+
+	var conflict *keymap.ConflictError
+	if err := k.Validate(); !errors.As(err, &conflict) {
+		t.Fatalf("Validate() = %v, want a *ConflictError", err)
+	}
+	if conflict.Key != "l" || conflict.Actions != [2]string{"merge.local", "merge.remote"} {
+		t.Errorf("Validate() = %+v, want l bound to merge.local and merge.remote", conflict)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	k, err := keymap.Load(filepath.Join(dir, "missing.toml"))
+	if err != nil || k.Modal {
+		t.Errorf("Load() of a missing file = %v, want the default keymap", err)
+	}
+
+	path := filepath.Join(dir, "keys.toml")
+	if err := os.WriteFile(path, []byte("[list]\nnew = \"d\""), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keymap.Load(path); err == nil || !strings.HasPrefix(err.Error(), path+": ") {
+		t.Errorf("Load() = %v, want an error naming the file", err)
+	}
+}
+
+func TestLabel(t *testing.T) {
+	tests := []struct {
+		keys []string
+		want string
+	}{
+		{[]string{"enter"}, "↵"},
+		{[]string{"up", "k", "ctrl+p"}, "↑/k"},
+		{[]string{" "}, "space"},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := keymap.Label(tt.keys); got != tt.want {
+			t.Errorf("Label(%q) = %q, want %q", tt.keys, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package keymap
+
+import "fmt"
+
+// the presets a config file can start from
+var presets = map[string]func() KeyMap{
+	"default": Default,
+	"vim":     Vim,
+}
+
+// Returns the preset called name, "default" or "vim".
+func Preset(name string) (KeyMap, error) {
+	preset, ok := presets[name]
+	if !ok {
+		return KeyMap{}, fmt.Errorf("unknown preset %q, expected default or vim", name)
+	}
+	return preset(), nil
+}
+
+// The keys of the tui when nothing is configured.
+func Default() KeyMap {
+	return KeyMap{
+		Global: GlobalKeys{
+			Quit:    bind("quit", "ctrl+c"),
+			Dismiss: bind("dismiss", "ctrl+x"),
+			Palette: bind("commands", "ctrl+p"),
+		},
+		Fatal: FatalKeys{
+			Retry:   bind("retry", "r"),
+			Dismiss: bind("dismiss", "esc", "enter"),
+			Quit:    bind("quit", "q"),
+		},
+		List: ListKeys{
+			Up:          bind("up", "up", "k"),
+			Down:        bind("down", "down", "j"),
+			PrevPage:    bind("prev page", "left", "h", "pgup", "b", "u"),
+			NextPage:    bind("next page", "right", "l", "pgdown"),
+			Start:       bind("go to start", "home", "g"),
+			End:         bind("go to end", "end", "G"),
+			Filter:      bind("filter", "/"),
+			ClearFilter: bind("clear filter", "esc"),
+			Help:        bind("more", "?"),
+			Quit:        bind("quit", "q"),
+			New:         bind("new", "a"),
+			Edit:        bind("edit", "e"),
+			Delete:      bind("delete", "d"),
+			Copy:        bind("copy", "enter"),
+			Preview:     bind("preview", "p"),
+			Search:      bind("find by relevance", "f"),
+			Pin:         bind("pin", "*"),
+			Sync:        bind("sync", "S"),
+			Duplicates:  bind("duplicates", "D"),
+			Errors:      bind("errors", "!"),
+			Logs:        bind("logs", "L"),
+			Clone:       bind("duplicate", "c"),
+			Sort:        bind("sort", "s"),
+			Export:      bind("export"),
+		},
+		Filter: FilterKeys{
+			Cancel: bind("cancel", "esc"),
+			Accept: bind("apply filter", "enter", "tab", "shift+tab", "ctrl+k", "up", "ctrl+j", "down"),
+		},
+		Search: SearchKeys{
+			Back:     bind("back", "esc"),
+			Copy:     bind("copy", "enter"),
+			Up:       bind("up", "up"),
+			Down:     bind("down", "down"),
+			PrevPage: bind("prev page", "pgup"),
+			NextPage: bind("next page", "pgdown"),
+			Insert:   bind("type"),
+		},
+		SearchInsert: SearchInsertKeys{
+			Normal: bind("done typing"),
+		},
+		Form: FormKeys{
+			Next:   bind("next field", "tab", "down"),
+			Prev:   bind("previous field", "shift+tab", "up"),
+			Submit: bind("submit", "enter"),
+			Redact: bind("redact & save", "ctrl+r"),
+			Cancel: bind("cancel", "esc"),
+			Normal: bind("done typing"),
+		},
+		FormNormal: FormNormalKeys{
+			Next:   bind("next field"),
+			Prev:   bind("previous field"),
+			Submit: bind("submit"),
+			Redact: bind("redact & save"),
+			Cancel: bind("cancel"),
+			Insert: bind("type"),
+		},
+		Preview: PreviewKeys{
+			Up:           bind("up", "up", "k"),
+			Down:         bind("down", "down", "j"),
+			PageUp:       bind("page up", "pgup", "b"),
+			PageDown:     bind("page down", "pgdown", " ", "f"),
+			HalfPageUp:   bind("½ page up", "u", "ctrl+u"),
+			HalfPageDown: bind("½ page down", "d", "ctrl+d"),
+			Copy:         bind("copy", "enter"),
+			Back:         bind("back", "esc", "q", "p"),
+		},
+		ErrorLog: ErrorLogKeys{
+			Back: bind("close", "esc", "q", "!"),
+		},
+		Logs: LogsKeys{
+			Up:       bind("up", "up", "k"),
+			Down:     bind("down", "down", "j"),
+			PageUp:   bind("page up", "pgup", "b", "ctrl+u"),
+			PageDown: bind("page down", "pgdown", " ", "f", "ctrl+d"),
+			Top:      bind("top", "home", "g"),
+			Bottom:   bind("follow", "end", "G"),
+			Level:    bind("level", "v"),
+			Back:     bind("close", "esc", "q", "L"),
+		},
+		Palette: PaletteKeys{
+			Up:    bind("up", "up", "ctrl+k"),
+			Down:  bind("down", "down", "ctrl+j"),
+			Run:   bind("run", "enter"),
+			Close: bind("close", "esc"),
+		},
+		Confirm: ConfirmKeys{
+			Yes: bind("confirm", "y", "Y", "enter"),
+			No:  bind("cancel", "n", "N", "esc", "q"),
+		},
+		Duplicates: DuplicatesKeys{
+			Up:     bind("up", "up"),
+			Down:   bind("down", "down"),
+			Keep:   bind("keep", "k"),
+			Toggle: bind("merge/leave", " "),
+			Next:   bind("next group", "right", "tab", "n"),
+			Prev:   bind("previous group", "left", "shift+tab", "p"),
+			Merge:  bind("merge", "m"),
+			Back:   bind("back", "esc", "q"),
+		},
+		Merge: MergeKeys{
+			Local:  bind("keep local", "l"),
+			Remote: bind("keep remote", "r"),
+			Next:   bind("next conflict", "right", "tab", "n"),
+			Prev:   bind("previous conflict", "left", "shift+tab", "p"),
+			Apply:  bind("apply", "enter"),
+			Back:   bind("cancel", "esc", "q"),
+		},
+	}
+}
+
+// Vim style keys: hjkl everywhere, y to copy, and the editor and the
+// search switch between a normal and an insert mode, with esc to stop
+// typing and i to start again.
+func Vim() KeyMap {
+	k := Default()
+	k.Modal = true
+
+	Rebind(&k.List.PrevPage, "ctrl+u", "ctrl+b", "left", "h", "pgup")
+	Rebind(&k.List.NextPage, "ctrl+d", "ctrl+f", "right", "l", "pgdown")
+	Rebind(&k.List.New, "o", "a")
+	Rebind(&k.List.Edit, "i", "e")
+	Rebind(&k.List.Delete, "x", "d")
+	Rebind(&k.List.Copy, "y", "enter")
+	Rebind(&k.List.Pin, "m", "*")
+
+	Rebind(&k.Search.Back, "esc", "q")
+	Rebind(&k.Search.Copy, "y", "enter")
+	Rebind(&k.Search.Up, "k", "up")
+	Rebind(&k.Search.Down, "j", "down")
+	Rebind(&k.Search.PrevPage, "ctrl+u", "pgup")
+	Rebind(&k.Search.NextPage, "ctrl+d", "pgdown")
+	Rebind(&k.Search.Insert, "i", "a", "/")
+	Rebind(&k.SearchInsert.Normal, "esc", "enter")
+
+	Rebind(&k.Form.Next, "tab")
+	Rebind(&k.Form.Prev, "shift+tab")
+	Rebind(&k.Form.Cancel)
+	Rebind(&k.Form.Normal, "esc")
+	Rebind(&k.FormNormal.Next, "j", "down", "tab")
+	Rebind(&k.FormNormal.Prev, "k", "up", "shift+tab")
+	Rebind(&k.FormNormal.Submit, "enter")
+	Rebind(&k.FormNormal.Redact, "ctrl+r")
+	Rebind(&k.FormNormal.Cancel, "esc", "q")
+	Rebind(&k.FormNormal.Insert, "i", "a")
+
+	Rebind(&k.Preview.PageUp, "ctrl+b", "pgup", "b")
+	Rebind(&k.Preview.PageDown, "ctrl+f", "pgdown", " ", "f")
+	Rebind(&k.Preview.HalfPageUp, "ctrl+u", "u")
+	Rebind(&k.Preview.HalfPageDown, "ctrl+d", "d")
+	Rebind(&k.Preview.Copy, "y", "enter")
+
+	Rebind(&k.Duplicates.Up, "k", "up")
+	Rebind(&k.Duplicates.Down, "j", "down")
+	Rebind(&k.Duplicates.Keep, "enter")
+	Rebind(&k.Duplicates.Toggle, "x", " ")
+	Rebind(&k.Duplicates.Next, "l", "right", "tab", "n")
+	Rebind(&k.Duplicates.Prev, "h", "left", "shift+tab", "p")
+
+	// local is on the left of remote
+	Rebind(&k.Merge.Local, "h")
+	Rebind(&k.Merge.Remote, "l")
+	Rebind(&k.Merge.Next, "j", "n", "tab")
+	Rebind(&k.Merge.Prev, "k", "N", "shift+tab")
+	return k
+}
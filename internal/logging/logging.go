@@ -0,0 +1,127 @@
+// Package logging writes the log of pvt.
+// Records go to a file that is rotated once it grows too big, to stderr
+// when pvt runs verbose, and to a recorder keeping the latest ones in
+// memory for the log panel of the tui. Each of them has its own level.
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Options say where the log goes.
+type Options struct {
+	Path     string     // of the log file
+	Level    slog.Level // records below it are left out of the file
+	JSON     bool       // writes the file as JSON lines rather than key=value text
+	MaxSize  int64      // bytes the file grows to before it is rotated
+	MaxFiles int        // rotated files kept besides the current one
+
+	// Verbose also gets every record, from debug up, as text
+	Verbose io.Writer
+
+	// Recent is how many records the recorder keeps, 0 for no recorder
+	Recent int
+}
+
+// Logger is the logger of pvt with what it writes to.
+type Logger struct {
+	*slog.Logger
+
+	// the latest records, nil if Options.Recent was 0
+	Recorder *Recorder
+
+	file *RotatingFile
+}
+
+// Opens the log file, creating its directory if need be, and returns the
+// logger writing to it and to the other outputs of opts.
+func Open(opts Options) (*Logger, error) {
+	file, err := OpenRotating(opts.Path, opts.MaxSize, opts.MaxFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	var handlers fanout
+	if opts.JSON {
+		handlers = append(handlers, slog.NewJSONHandler(file, &slog.HandlerOptions{Level: opts.Level}))
+	} else {
+		handlers = append(handlers, slog.NewTextHandler(file, &slog.HandlerOptions{Level: opts.Level}))
+	}
+	if opts.Verbose != nil {
+		handlers = append(handlers, slog.NewTextHandler(opts.Verbose, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+
+	l := &Logger{file: file}
+	if opts.Recent > 0 {
+		l.Recorder = NewRecorder(opts.Recent)
+		handlers = append(handlers, l.Recorder)
+	}
+	l.Logger = slog.New(handlers)
+	return l, nil
+}
+
+// Closes the log file.
+func (l *Logger) Close() error {
+	return l.file.Close()
+}
+
+// Parses a level by its name: debug, info, warn or error.
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	switch strings.ToLower(name) {
+	case "debug":
+		level = slog.LevelDebug
+	case "info":
+		level = slog.LevelInfo
+	case "warn":
+		level = slog.LevelWarn
+	case "error":
+		level = slog.LevelError
+	default:
+		return level, fmt.Errorf("unknown level %q, expected debug, info, warn or error", name)
+	}
+	return level, nil
+}
+
+// fanout hands records to every handler that takes their level
+type fanout []slog.Handler
+
+func (f fanout) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanout) Handle(ctx context.Context, r slog.Record) error {
+	errs := []error{}
+	for _, h := range f {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f fanout) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(fanout, len(f))
+	for i, h := range f {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (f fanout) WithGroup(name string) slog.Handler {
+	handlers := make(fanout, len(f))
+	for i, h := range f {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
+}
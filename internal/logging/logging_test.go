@@ -0,0 +1,175 @@
+package logging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/logging"
+)
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "pvt.log")
+	verbose := &bytes.Buffer{}
+	l, err := logging.Open(logging.Options{Path: path, Level: slog.LevelInfo, Verbose: verbose, Recent: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Debug("looked up prompt", "id", 3)
+	l.Info("saved prompt", "id", 3)
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "looked up") || !strings.Contains(string(data), `msg="saved prompt" id=3`) {
+		t.Errorf("log file = %q, want only the info record", data)
+	}
+	if !strings.Contains(verbose.String(), "looked up prompt") || !strings.Contains(verbose.String(), "saved prompt") {
+		t.Errorf("verbose = %q, want every record", verbose.String())
+	}
+	if entries := l.Recorder.Entries(); len(entries) != 2 {
+		t.Errorf("Entries() = %v, want both records", entries)
+	}
+}
+
+func TestOpen_JSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pvt.log")
+	l, err := logging.Open(logging.Options{Path: path, JSON: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.With("id", 7).Warn("skipping undecodable prompt")
+	l.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var record map[string]any
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("log file = %q, want a JSON line: %v", data, err)
+	}
+	if record["msg"] != "skipping undecodable prompt" || record["id"] != float64(7) || record["level"] != "WARN" {
+		t.Errorf("record = %v", record)
+	}
+	if l.Recorder != nil {
+		t.Error("Recorder is set without Recent")
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pvt.log")
+	f, err := logging.OpenRotating(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"aaaaaa\n", "bbbbbb\n", "cccccc\n", "dddddd\n", "e\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"pvt.log": "dddddd\ne\n", "pvt.log.1": "cccccc\n", "pvt.log.2": "bbbbbb\n"}
+	for name, content := range want {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(data) != content {
+			t.Errorf("%s = %q, %v, want %q", name, data, err, content)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "pvt.log.3")); !os.IsNotExist(err) {
+		t.Errorf("pvt.log.3 exists, want only 2 rotated files kept")
+	}
+	if _, err := f.Write([]byte("late\n")); err == nil {
+		t.Error("Write() after Close() succeeded, want an error")
+	}
+
+	// reopening appends to what is there
+	f, err = logging.OpenRotating(path, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("ff\n"))
+	f.Close()
+	if data, _ := os.ReadFile(path); string(data) != "ff\n" {
+		t.Errorf("pvt.log = %q, want it started over with no rotated files kept", data)
+	}
+}
+
+func TestRotatingFile_Failed(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pvt.log")
+	// the oldest rotated file cannot be dropped
+	if err := os.MkdirAll(filepath.Join(dir, "pvt.log.1", "keep"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := logging.OpenRotating(path, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write([]byte("aaaaaa\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("bbbbbb\n")); err == nil {
+		t.Error("Write() with a failing rotation succeeded, want the error")
+	}
+	// reported once, logging goes on in the same file
+	if _, err := f.Write([]byte("cccccc\n")); err != nil {
+		t.Errorf("Write() after a failed rotation = %v, want no error", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "aaaaaa\nbbbbbb\ncccccc\n" {
+		t.Errorf("pvt.log = %q, want every line", data)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	r := logging.NewRecorder(3)
+	logger := slog.New(r).With("backend", "bolt").WithGroup("sync")
+	for i := range 5 {
+		logger.Info("pushed", "id", i, slog.Group("remote", "name", "origin"))
+	}
+
+	entries := r.Entries()
+	if len(entries) != 3 || r.Total() != 5 {
+		t.Fatalf("Entries() = %d, Total() = %d, want the latest 3 of 5", len(entries), r.Total())
+	}
+	for i, e := range entries {
+		want := "INFO  pushed backend=bolt sync.id=" + string(rune('2'+i)) + " sync.remote.name=origin"
+		if got := e.String()[9:]; got != want {
+			t.Errorf("entry %d = %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    slog.Level
+		wantErr bool
+	}{
+		{"debug", slog.LevelDebug, false},
+		{"INFO", slog.LevelInfo, false},
+		{"warn", slog.LevelWarn, false},
+		{"error", slog.LevelError, false},
+		{"trace", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := logging.ParseLevel(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseLevel(%q) = %v, %v, want %v", tt.name, got, err, tt.want)
+		}
+	}
+}
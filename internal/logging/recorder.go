@@ -0,0 +1,134 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is a record kept by a Recorder.
+type Entry struct {
+	Time    time.Time
+	Level   slog.Level
+	Message string
+	Attrs   []slog.Attr // groups flattened into the keys, as in group.key
+}
+
+// Returns the entry on a line, as in 15:04:05 INFO saved prompt id=3.
+func (e Entry) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %-5s %s", e.Time.Format("15:04:05"), e.Level, e.Message)
+	for _, a := range e.Attrs {
+		fmt.Fprintf(&b, " %s=%s", a.Key, a.Value)
+	}
+	return b.String()
+}
+
+// Recorder is a slog.Handler keeping the latest records in memory, from
+// debug up, for the log panel of the tui.
+type Recorder struct {
+	store *entries
+
+	attrs  []slog.Attr
+	prefix string // of the group the attributes go in
+}
+
+// the records shared by a recorder and the ones derived from it
+type entries struct {
+	mu      sync.Mutex
+	entries []Entry // a ring once full, next is the oldest
+	next    int
+	size    int
+	total   int
+}
+
+// Returns a recorder keeping the latest size records.
+func NewRecorder(size int) *Recorder {
+	return &Recorder{store: &entries{size: size}}
+}
+
+// Returns the records kept, the oldest first.
+func (r *Recorder) Entries() []Entry {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]Entry, 0, len(s.entries))
+	out = append(out, s.entries[s.next:]...)
+	return append(out, s.entries[:s.next]...)
+}
+
+// Returns how many records there have been, kept or not, so that a
+// reader can tell when there are new ones.
+func (r *Recorder) Total() int {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.total
+}
+
+func (r *Recorder) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (r *Recorder) Handle(_ context.Context, record slog.Record) error {
+	e := Entry{Time: record.Time, Level: record.Level, Message: record.Message}
+	e.Attrs = append(e.Attrs, r.attrs...)
+	record.Attrs(func(a slog.Attr) bool {
+		e.Attrs = append(e.Attrs, flatten(r.prefix, a)...)
+		return true
+	})
+
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.total++
+	if len(s.entries) < s.size {
+		s.entries = append(s.entries, e)
+		return nil
+	}
+	s.entries[s.next] = e
+	s.next = (s.next + 1) % s.size
+	return nil
+}
+
+func (r *Recorder) WithAttrs(attrs []slog.Attr) slog.Handler {
+	derived := *r
+	derived.attrs = append([]slog.Attr(nil), r.attrs...)
+	for _, a := range attrs {
+		derived.attrs = append(derived.attrs, flatten(r.prefix, a)...)
+	}
+	return &derived
+}
+
+func (r *Recorder) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return r
+	}
+	derived := *r
+	derived.prefix = r.prefix + name + "."
+	return &derived
+}
+
+// the attribute with its key in the group, and groups spread into their
+// attributes
+func flatten(prefix string, a slog.Attr) []slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() != slog.KindGroup {
+		if a.Equal(slog.Attr{}) {
+			return nil
+		}
+		return []slog.Attr{{Key: prefix + a.Key, Value: a.Value}}
+	}
+	if a.Key != "" {
+		prefix += a.Key + "."
+	}
+	out := []slog.Attr{}
+	for _, g := range a.Value.Group() {
+		out = append(out, flatten(prefix, g)...)
+	}
+	return out
+}
@@ -0,0 +1,135 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// RotatingFile is a log file that is moved aside once it would grow past
+// its size: pvt.log becomes pvt.log.1, pvt.log.1 becomes pvt.log.2 and so
+// on, keeping a number of them. Writes are safe for concurrent use.
+type RotatingFile struct {
+	path     string
+	maxSize  int64 // 0 for no rotation
+	maxFiles int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+
+	// a rotation failed, the file grows from then on rather than failing
+	// every write
+	stuck bool
+}
+
+// Opens the log file at path for appending, creating it and its directory
+// if need be.
+func OpenRotating(path string, maxSize int64, maxFiles int) (*RotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	r := &RotatingFile{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.file, r.size = f, info.Size()
+	return nil
+}
+
+// Writes p to the file, rotating it first if p would not fit. A single
+// write bigger than the size still goes to a file of its own. A rotation
+// that fails is reported by the write that ran it, which still goes to the
+// file, and not tried again.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, fs.ErrClosed
+	}
+	var rotateErr error
+	if r.maxSize > 0 && !r.stuck && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			r.stuck = true
+			rotateErr = fmt.Errorf("could not rotate the log: %w", err)
+		}
+	}
+	if r.file == nil {
+		return 0, rotateErr
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, errors.Join(rotateErr, err)
+}
+
+// moves every file one up, dropping the oldest, and starts a new one.
+// whatever fails, the file at path is opened again for logging to go on.
+func (r *RotatingFile) rotate() error {
+	err := r.file.Close()
+	r.file = nil
+	if err == nil {
+		err = r.shift()
+	}
+	if openErr := r.open(); openErr != nil {
+		return errors.Join(err, openErr)
+	}
+	return err
+}
+
+// moves every file one up, the log itself last
+func (r *RotatingFile) shift() error {
+	if r.maxFiles <= 0 {
+		if err := os.Remove(r.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if err := os.Remove(r.backup(r.maxFiles)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for i := r.maxFiles - 1; i >= 0; i-- {
+		from := r.backup(i)
+		if err := os.Rename(from, r.backup(i+1)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// the path of the n-th rotated file, the log itself for 0
+func (r *RotatingFile) backup(n int) string {
+	if n == 0 {
+		return r.path
+	}
+	return fmt.Sprintf("%s.%d", r.path, n)
+}
+
+// Closes the file. Writes after it fail.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
@@ -0,0 +1,231 @@
+package theme
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Loads the theme called name from a config file, or the one the file
+// selects when name is empty. Without a file, or a theme selected, it is
+// auto. The file can also define custom themes, each starting from a
+// built-in one, which is auto unless base is set:
+//
+//	theme = "ocean"
+//
+//	[themes.ocean]
+//	base = "dark"
+//	primary = "#4FC3F7"
+//	border = { light = "#CBD5E1", dark = "#334155" }
+//
+// A color is #rgb, #rrggbb or an ANSI color from 0 to 255, or a table of
+// the colors to use on a light and on a dark background. Every custom
+// theme is checked, not just the selected one.
+func Load(path, name string) (Theme, error) {
+	data, err := readConfig(path)
+	if err != nil {
+		return Theme{}, err
+	}
+	t, err := Parse(data, name)
+	if err != nil {
+		return Theme{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// Loads every theme there is to pick from: the built-in ones, then the
+// custom ones of a config file by name.
+func LoadAll(path string) ([]Theme, error) {
+	data, err := readConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	themes, err := ParseAll(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return themes, nil
+}
+
+// the config file, empty if there is none
+func readConfig(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// Parses a theme config, see Load, and returns the theme called name or
+// the one it selects.
+func Parse(data []byte, name string) (Theme, error) {
+	selected, custom, err := parse(data)
+	if err != nil {
+		return Theme{}, err
+	}
+
+	if name == "" {
+		name = selected
+	}
+	if name == "" {
+		name = "auto"
+	}
+	if i := slices.IndexFunc(custom, func(t Theme) bool { return t.Name == name }); i >= 0 {
+		return custom[i], nil
+	}
+	return Builtin(name)
+}
+
+// Parses a theme config, see LoadAll, and returns every theme.
+func ParseAll(data []byte) ([]Theme, error) {
+	_, custom, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+	themes := []Theme{}
+	for _, name := range builtins {
+		t, _ := Builtin(name)
+		themes = append(themes, t)
+	}
+	return append(themes, custom...), nil
+}
+
+// the name of the theme a config selects and its custom themes, by name
+func parse(data []byte) (string, []Theme, error) {
+	var config map[string]any
+	if _, err := toml.Decode(string(data), &config); err != nil {
+		return "", nil, err
+	}
+
+	errs := []error{}
+	name := ""
+	custom := []Theme{}
+	for _, key := range slices.Sorted(maps.Keys(config)) {
+		switch key {
+		case "theme":
+			selected, ok := config[key].(string)
+			if !ok {
+				errs = append(errs, errors.New("theme must be the name of a theme"))
+			} else {
+				name = selected
+			}
+		case "themes":
+			themes, ok := config[key].(map[string]any)
+			if !ok {
+				errs = append(errs, errors.New("themes must be a table of themes, as in [themes.ocean]"))
+				continue
+			}
+			for _, n := range slices.Sorted(maps.Keys(themes)) {
+				t, err := parseTheme(n, themes[n])
+				if err != nil {
+					errs = append(errs, fmt.Errorf("themes.%s: %w", n, err))
+					continue
+				}
+				custom = append(custom, t)
+			}
+		default:
+			errs = append(errs, fmt.Errorf("unknown key %q, expected theme or themes", key))
+		}
+	}
+	return name, custom, errors.Join(errs...)
+}
+
+// a custom theme, its base with the colors it sets
+func parseTheme(name string, value any) (Theme, error) {
+	if slices.Contains(builtins, name) {
+		return Theme{}, fmt.Errorf("%s is a built-in theme, pick another name", name)
+	}
+	fields, ok := value.(map[string]any)
+	if !ok {
+		return Theme{}, errors.New("must be a table of colors")
+	}
+
+	base := "auto"
+	if b, ok := fields["base"]; ok {
+		if base, ok = b.(string); !ok {
+			return Theme{}, errors.New("base must be the name of a built-in theme")
+		}
+	}
+	t, err := Builtin(base)
+	if err != nil {
+		return Theme{}, fmt.Errorf("base: %w", err)
+	}
+	t.Name = name
+
+	colors := t.colors()
+	names := []string{}
+	for _, c := range colors {
+		names = append(names, c.name)
+	}
+	errs := []error{}
+	for _, key := range slices.Sorted(maps.Keys(fields)) {
+		if key == "base" {
+			continue
+		}
+		i := slices.Index(names, key)
+		if i < 0 {
+			errs = append(errs, fmt.Errorf("unknown color %q, expected base or one of %s", key, strings.Join(names, ", ")))
+			continue
+		}
+		c, err := parseColor(fields[key])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			continue
+		}
+		*colors[i].color = c
+	}
+	return t, errors.Join(errs...)
+}
+
+// a color, or a table of the colors on a light and a dark background
+func parseColor(value any) (lipgloss.TerminalColor, error) {
+	switch v := value.(type) {
+	case string:
+		if !validColor(v) {
+			return nil, fmt.Errorf("%q is not a color, expected #rgb, #rrggbb or 0 to 255", v)
+		}
+		return lipgloss.Color(v), nil
+	case map[string]any:
+		var c lipgloss.AdaptiveColor
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			s, ok := v[key].(string)
+			if !ok || !validColor(s) {
+				return nil, fmt.Errorf("%s: %v is not a color, expected #rgb, #rrggbb or 0 to 255", key, v[key])
+			}
+			switch key {
+			case "light":
+				c.Light = s
+			case "dark":
+				c.Dark = s
+			default:
+				return nil, fmt.Errorf("unknown key %q, expected light and dark", key)
+			}
+		}
+		if c.Light == "" || c.Dark == "" {
+			return nil, errors.New("needs both a light and a dark color")
+		}
+		return c, nil
+	}
+	return nil, errors.New("must be a color or a table with a light and a dark color")
+}
+
+// reports whether s is a color lipgloss understands: a hex or an ANSI color
+func validColor(s string) bool {
+	if hex, ok := strings.CutPrefix(s, "#"); ok {
+		if len(hex) != 3 && len(hex) != 6 {
+			return false
+		}
+		_, err := strconv.ParseUint(hex, 16, 32)
+		return err == nil
+	}
+	n, err := strconv.Atoi(s)
+	return err == nil && n >= 0 && n <= 255
+}
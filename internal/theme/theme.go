@@ -0,0 +1,155 @@
+// Package theme holds the color palettes of the tui.
+// There are dark, light and high-contrast palettes built in, and "auto"
+// which lets lipgloss pick the light or the dark one from the background
+// of the terminal. Custom themes start from a built-in one and change
+// some of its colors from a config file.
+package theme
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Theme is the palette every style of the tui is built from. No color is
+// used as a background, so that themes work on top of the terminal's own.
+type Theme struct {
+	Name string
+
+	Primary   lipgloss.TerminalColor // titles, focus and the selection
+	Secondary lipgloss.TerminalColor // warnings and highlights
+	Accent    lipgloss.TerminalColor // success and additions
+	Danger    lipgloss.TerminalColor // errors and deletions
+	Text      lipgloss.TerminalColor // typed text
+	Subtle    lipgloss.TerminalColor // help and secondary text
+	Muted     lipgloss.TerminalColor // blurred fields and descriptions
+	Border    lipgloss.TerminalColor
+}
+
+// the names of the built-in themes, in the order they are listed
+var builtins = []string{"auto", "dark", "light", "high-contrast"}
+
+// Returns the names of the built-in themes.
+func Builtins() []string {
+	return append([]string(nil), builtins...)
+}
+
+// Returns the built-in theme called name.
+func Builtin(name string) (Theme, error) {
+	switch name {
+	case "auto":
+		return Auto(), nil
+	case "dark":
+		return Dark(), nil
+	case "light":
+		return Light(), nil
+	case "high-contrast":
+		return HighContrast(), nil
+	}
+	return Theme{}, fmt.Errorf("unknown theme %q, expected auto, dark, light, high-contrast or a custom theme", name)
+}
+
+// The light or the dark theme, whichever suits the background of the
+// terminal. lipgloss detects it when the colors are first rendered.
+func Auto() Theme {
+	return adaptive("auto", Light(), Dark())
+}
+
+// Bright colors for a dark terminal.
+func Dark() Theme {
+	return Theme{
+		Name:      "dark",
+		Primary:   lipgloss.Color("#00E5FF"), // electric cyan
+		Secondary: lipgloss.Color("#B388FF"), // soft purple
+		Accent:    lipgloss.Color("#69F0AE"), // mint green
+		Danger:    lipgloss.Color("#FF5370"), // coral red
+		Text:      lipgloss.Color("#E4E4E7"), // cool white
+		Subtle:    lipgloss.Color("#94A3B8"), // slate
+		Muted:     lipgloss.Color("#64748B"), // medium slate
+		Border:    lipgloss.Color("#475569"),
+	}
+}
+
+// Deep colors for a light terminal.
+func Light() Theme {
+	return Theme{
+		Name:      "light",
+		Primary:   lipgloss.Color("#0369A1"), // ocean blue
+		Secondary: lipgloss.Color("#7C3AED"), // violet
+		Accent:    lipgloss.Color("#047857"), // emerald
+		Danger:    lipgloss.Color("#BE123C"), // crimson
+		Text:      lipgloss.Color("#18181B"), // near black
+		Subtle:    lipgloss.Color("#475569"), // slate
+		Muted:     lipgloss.Color("#64748B"), // medium slate
+		Border:    lipgloss.Color("#94A3B8"),
+	}
+}
+
+// Saturated colors and no grays, for either background.
+func HighContrast() Theme {
+	light := Theme{
+		Primary:   lipgloss.Color("#0000CC"),
+		Secondary: lipgloss.Color("#8B008B"),
+		Accent:    lipgloss.Color("#006400"),
+		Danger:    lipgloss.Color("#B00000"),
+		Text:      lipgloss.Color("#000000"),
+		Subtle:    lipgloss.Color("#000000"),
+		Muted:     lipgloss.Color("#303030"),
+		Border:    lipgloss.Color("#000000"),
+	}
+	dark := Theme{
+		Primary:   lipgloss.Color("#FFFF00"),
+		Secondary: lipgloss.Color("#FF00FF"),
+		Accent:    lipgloss.Color("#00FF00"),
+		Danger:    lipgloss.Color("#FF3030"),
+		Text:      lipgloss.Color("#FFFFFF"),
+		Subtle:    lipgloss.Color("#FFFFFF"),
+		Muted:     lipgloss.Color("#D0D0D0"),
+		Border:    lipgloss.Color("#FFFFFF"),
+	}
+	return adaptive("high-contrast", light, dark)
+}
+
+// a theme with the colors of light on a light background and those of
+// dark on a dark one
+func adaptive(name string, light, dark Theme) Theme {
+	t := Theme{Name: name}
+	lc, dc, tc := light.colors(), dark.colors(), t.colors()
+	for i := range tc {
+		*tc[i].color = lipgloss.AdaptiveColor{Light: hex(*lc[i].color, false), Dark: hex(*dc[i].color, true)}
+	}
+	return t
+}
+
+// the color to use on a light or a dark background
+func hex(c lipgloss.TerminalColor, dark bool) string {
+	switch c := c.(type) {
+	case lipgloss.Color:
+		return string(c)
+	case lipgloss.AdaptiveColor:
+		if dark {
+			return c.Dark
+		}
+		return c.Light
+	}
+	return ""
+}
+
+// a color of the theme with the name it is configured by
+type namedColor struct {
+	name  string
+	color *lipgloss.TerminalColor
+}
+
+func (t *Theme) colors() []namedColor {
+	return []namedColor{
+		{"primary", &t.Primary},
+		{"secondary", &t.Secondary},
+		{"accent", &t.Accent},
+		{"danger", &t.Danger},
+		{"text", &t.Text},
+		{"subtle", &t.Subtle},
+		{"muted", &t.Muted},
+		{"border", &t.Border},
+	}
+}
@@ -0,0 +1,178 @@
+package theme_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/theme"
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestBuiltins(t *testing.T) {
+	for _, name := range theme.Builtins() {
+		t.Run(name, func(t *testing.T) {
+			th, err := theme.Builtin(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if th.Name != name {
+				t.Errorf("Name = %q, want %q", th.Name, name)
+			}
+			for _, c := range []lipgloss.TerminalColor{th.Primary, th.Secondary, th.Accent, th.Danger, th.Text, th.Subtle, th.Muted, th.Border} {
+				switch c := c.(type) {
+				case lipgloss.Color:
+					if c == "" {
+						t.Error("a color is empty")
+					}
+				case lipgloss.AdaptiveColor:
+					if c.Light == "" || c.Dark == "" {
+						t.Errorf("color %+v lacks a light or a dark variant", c)
+					}
+				default:
+					t.Errorf("color %#v is not set", c)
+				}
+			}
+		})
+	}
+	if _, err := theme.Builtin("solarized"); err == nil {
+		t.Error("Builtin(solarized) succeeded, want an error")
+	}
+}
+
+func TestAuto(t *testing.T) {
+	auto, light, dark := theme.Auto(), theme.Light(), theme.Dark()
+	want := lipgloss.AdaptiveColor{Light: string(light.Primary.(lipgloss.Color)), Dark: string(dark.Primary.(lipgloss.Color))}
+	if auto.Primary != want {
+		t.Errorf("Auto().Primary = %+v, want %+v", auto.Primary, want)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string // description of this test case
+		config  string
+		choice  string // the theme asked for, as with PVT_THEME
+		check   func(t *testing.T, th theme.Theme)
+		wantErr string
+	}{
+		{
+			name:   "Empty",
+			config: "",
+			check: func(t *testing.T, th theme.Theme) {
+				if th.Name != "auto" {
+					t.Errorf("Parse() = %s, want auto", th.Name)
+				}
+			},
+		},
+		{
+			name:   "Built-in",
+			config: `theme = "light"`,
+			check: func(t *testing.T, th theme.Theme) {
+				if th != theme.Light() {
+					t.Errorf("Parse() = %+v, want the light theme", th)
+				}
+			},
+		},
+		{
+			name:   "Selected over the file",
+			config: `theme = "light"`,
+			choice: "high-contrast",
+			check: func(t *testing.T, th theme.Theme) {
+				if th.Name != "high-contrast" {
+					t.Errorf("Parse() = %s, want high-contrast", th.Name)
+				}
+			},
+		},
+		{
+			name: "Custom",
+			config: "theme = \"ocean\"\n\n[themes.ocean]\nbase = \"dark\"\nprimary = \"#4FC3F7\"\n" +
+				"danger = \"196\"\nborder = { light = \"#CBD5E1\", dark = \"#334\" }",
+			check: func(t *testing.T, th theme.Theme) {
+				if th.Name != "ocean" || th.Primary != lipgloss.Color("#4FC3F7") || th.Danger != lipgloss.Color("196") {
+					t.Errorf("Parse() = %+v, want ocean with its colors", th)
+				}
+				if th.Border != (lipgloss.AdaptiveColor{Light: "#CBD5E1", Dark: "#334"}) {
+					t.Errorf("Parse() border = %+v, want an adaptive color", th.Border)
+				}
+				if th.Accent != theme.Dark().Accent {
+					t.Errorf("Parse() accent = %+v, want the one of the base", th.Accent)
+				}
+			},
+		},
+		{
+			name:   "Custom defaults to auto",
+			config: "[themes.mine]\nprimary = \"5\"",
+			choice: "mine",
+			check: func(t *testing.T, th theme.Theme) {
+				if th.Primary != lipgloss.Color("5") || th.Text != theme.Auto().Text {
+					t.Errorf("Parse() = %+v, want auto with primary 5", th)
+				}
+			},
+		},
+		{name: "Unknown theme", config: `theme = "solarized"`, wantErr: `unknown theme "solarized"`},
+		{name: "Unknown key", config: `colour = "dark"`, wantErr: `unknown key "colour", expected theme or themes`},
+		{name: "Unknown color", config: "[themes.mine]\nprimry = \"#fff\"", wantErr: `themes.mine: unknown color "primry", expected base or one of primary`},
+		{name: "Bad hex", config: "[themes.mine]\nprimary = \"#ggg\"", wantErr: `themes.mine: primary: "#ggg" is not a color`},
+		{name: "ANSI out of range", config: "[themes.mine]\ntext = \"256\"", wantErr: `text: "256" is not a color`},
+		{name: "Bad value", config: "[themes.mine]\ntext = 12", wantErr: "text: must be a color or a table"},
+		{name: "Half adaptive", config: "[themes.mine]\ntext = { light = \"#000\" }", wantErr: "text: needs both a light and a dark color"},
+		{name: "Unknown base", config: "[themes.mine]\nbase = \"sepia\"", wantErr: `themes.mine: base: unknown theme "sepia"`},
+		{name: "Shadows a built-in", config: "[themes.dark]\ntext = \"#fff\"", wantErr: "dark is a built-in theme"},
+		{name: "Malformed", config: "[themes\n", wantErr: "toml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th, err := theme.Parse([]byte(tt.config), tt.choice)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse() = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, th)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	th, err := theme.Load(filepath.Join(dir, "missing.toml"), "")
+	if err != nil || th.Name != "auto" {
+		t.Errorf("Load() of a missing file = %s, %v, want auto", th.Name, err)
+	}
+	if th, err := theme.Load(filepath.Join(dir, "missing.toml"), "dark"); err != nil || th.Name != "dark" {
+		t.Errorf("Load(dark) of a missing file = %s, %v, want dark", th.Name, err)
+	}
+
+	path := filepath.Join(dir, "themes.toml")
+	if err := os.WriteFile(path, []byte(`theme = "sepia"`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := theme.Load(path, ""); err == nil || !strings.HasPrefix(err.Error(), path+": ") {
+		t.Errorf("Load() = %v, want an error naming the file", err)
+	}
+}
+
+func TestParseAll(t *testing.T) {
+	themes, err := theme.ParseAll([]byte("theme = \"light\"\n[themes.sepia]\nbase = \"light\"\nprimary = \"#704214\"\n[themes.ocean]\nprimary = \"39\""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, th := range themes {
+		names = append(names, th.Name)
+	}
+	want := []string{"auto", "dark", "light", "high-contrast", "ocean", "sepia"}
+	if !slices.Equal(names, want) {
+		t.Errorf("ParseAll() = %v, want the built-in themes, then the custom ones by name", names)
+	}
+	if _, err := theme.ParseAll([]byte("[themes.dark]\nprimary = 1")); err == nil {
+		t.Error("ParseAll() of a bad config succeeded, want an error")
+	}
+}
@@ -0,0 +1,269 @@
+// Package tokenizer estimates how many tokens a text takes up for
+// different model families, fully offline.
+//
+// Shipping the real BPE vocabularies would add megabytes to the binary,
+// so the counts come from a heuristic that splits the text the way the
+// tokenizers pre-tokenize it (words, numbers, punctuation, whitespace)
+// and charges each piece a cost set per family. It is an estimate, good
+// for comparing prompts and budgets, not an exact count.
+package tokenizer
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Family describes how a family of models tokenizes text.
+type Family struct {
+	// name used on the command line and in the config
+	Name string
+
+	// size of the context window of the family's current models, in tokens
+	ContextWindow int
+
+	// letters covered by the first token of a word, and by every token after it.
+	// common short words are a single token, long ones get split into chunks.
+	wordHead int
+	wordTail float64
+
+	// digits per token, tokenizers split long numbers into small groups
+	digitGroup int
+
+	// repeated punctuation such as "----" or "===" is merged into one token
+	// up to this many characters
+	punctRun int
+
+	// spaces per token for runs of indentation. a single space is
+	// always merged into the following word.
+	spaceRun int
+
+	// non-latin characters per token. cjk is close to one token per character.
+	cjkPerToken   float64
+	otherPerToken float64
+}
+
+var (
+	GPT = Family{
+		Name:          "gpt",
+		ContextWindow: 128000,
+		wordHead:      7,
+		wordTail:      4.2,
+		digitGroup:    3,
+		punctRun:      4,
+		spaceRun:      4,
+		cjkPerToken:   1.1,
+		otherPerToken: 2.4,
+	}
+	Claude = Family{
+		Name:          "claude",
+		ContextWindow: 200000,
+		wordHead:      6,
+		wordTail:      3.6,
+		digitGroup:    3,
+		punctRun:      3,
+		spaceRun:      4,
+		cjkPerToken:   1.0,
+		otherPerToken: 2.0,
+	}
+	Llama = Family{
+		Name:          "llama",
+		ContextWindow: 128000,
+		wordHead:      7,
+		wordTail:      4.0,
+		digitGroup:    3,
+		punctRun:      4,
+		spaceRun:      4,
+		cjkPerToken:   1.0,
+		otherPerToken: 2.2,
+	}
+	Gemini = Family{
+		Name:          "gemini",
+		ContextWindow: 1000000,
+		wordHead:      7,
+		wordTail:      4.4,
+		digitGroup:    1,
+		punctRun:      4,
+		spaceRun:      8,
+		cjkPerToken:   1.2,
+		otherPerToken: 2.6,
+	}
+)
+
+// Every known family, keyed by name.
+var Families = map[string]Family{
+	GPT.Name:    GPT,
+	Claude.Name: Claude,
+	Llama.Name:  Llama,
+	Gemini.Name: Gemini,
+}
+
+// The family used when none is configured.
+var Default = GPT
+
+// Returns the names of the known families, sorted.
+func Names() []string {
+	names := make([]string, 0, len(Families))
+	for name := range Families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Looks up a family by name, ignoring case.
+func Lookup(name string) (Family, error) {
+	family, ok := Families[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return Family{}, fmt.Errorf("unknown model family %q, expected one of %s", name, strings.Join(Names(), ", "))
+	}
+	return family, nil
+}
+
+// character classes the text is split into
+type class int
+
+const (
+	classLetter class = iota
+	classDigit
+	classSpace
+	classNewline
+	classPunct
+	classCJK
+	classOther
+)
+
+func classify(r rune) class {
+	switch {
+	case r == '\n':
+		return classNewline
+	case unicode.IsSpace(r):
+		return classSpace
+	case r < utf8.RuneSelf && unicode.IsLetter(r):
+		return classLetter
+	case unicode.IsDigit(r):
+		return classDigit
+	case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r):
+		return classCJK
+	case unicode.IsLetter(r):
+		return classOther
+	default:
+		return classPunct
+	}
+}
+
+// Estimates the number of tokens the text takes up for the family.
+func (f Family) Count(text string) int {
+	tokens := 0.0
+
+	// walk runs of characters of the same class
+	for len(text) > 0 {
+		r, size := utf8.DecodeRuneInString(text)
+		c := classify(r)
+
+		n, end := 1, size
+		for end < len(text) {
+			next, nextSize := utf8.DecodeRuneInString(text[end:])
+			if classify(next) != c || (c == classPunct && next != r) {
+				break
+			}
+			n++
+			end += nextSize
+		}
+		text = text[end:]
+
+		tokens += f.runCost(c, n)
+	}
+
+	return int(math.Ceil(tokens))
+}
+
+// cost in tokens of a run of n characters of class c
+func (f Family) runCost(c class, n int) float64 {
+	switch c {
+	case classLetter:
+		if n <= f.wordHead {
+			return 1
+		}
+		return 1 + math.Ceil(float64(n-f.wordHead)/f.wordTail)
+	case classDigit:
+		return math.Ceil(float64(n) / float64(f.digitGroup))
+	case classSpace:
+		// a single space is merged into the following word
+		if n == 1 {
+			return 0
+		}
+		return math.Ceil(float64(n) / float64(f.spaceRun))
+	case classNewline:
+		// consecutive newlines are usually a single token
+		return math.Ceil(float64(n) / 2)
+	case classPunct:
+		return math.Ceil(float64(n) / float64(f.punctRun))
+	case classCJK:
+		return float64(n) / f.cjkPerToken
+	default:
+		return float64(n) / f.otherPerToken
+	}
+}
+
+// Count estimates the number of tokens of the text for the default family.
+func Count(text string) int {
+	return Default.Count(text)
+}
+
+// Budget flags prompts that take up more tokens than allowed.
+// A zero Limit disables the check.
+type Budget struct {
+	Family Family
+	Limit  int
+}
+
+// The budget used when none is configured: the default family with no limit.
+func DefaultBudget() Budget {
+	return Budget{Family: Default}
+}
+
+// Estimate is the token count of a text checked against a budget.
+type Estimate struct {
+	Tokens int
+	Budget Budget
+}
+
+// Estimates the tokens of the text for the budget's family.
+func (b Budget) Estimate(text string) Estimate {
+	return Estimate{Tokens: b.Family.Count(text), Budget: b}
+}
+
+// Reports whether the estimate exceeds the budget limit.
+func (e Estimate) OverBudget() bool {
+	return e.Budget.Limit > 0 && e.Tokens > e.Budget.Limit
+}
+
+// Share of the family's context window taken up by the text, from 0 to 1.
+func (e Estimate) ContextShare() float64 {
+	if e.Budget.Family.ContextWindow == 0 {
+		return 0
+	}
+	return float64(e.Tokens) / float64(e.Budget.Family.ContextWindow)
+}
+
+// Short human readable form, e.g. "~1.2k tokens".
+func (e Estimate) String() string {
+	return "~" + FormatCount(e.Tokens) + " tokens"
+}
+
+// Formats a token count compactly, e.g. 950, 1.2k or 12k.
+func FormatCount(n int) string {
+	switch {
+	case n < 1000:
+		return fmt.Sprintf("%d", n)
+	case n < 10000:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/1000), ".0") + "k"
+	default:
+		return fmt.Sprintf("%dk", int(math.Round(float64(n)/1000)))
+	}
+}
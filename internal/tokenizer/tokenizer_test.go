@@ -0,0 +1,118 @@
+package tokenizer_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/tokenizer"
+)
+
+func TestFamily_Count(t *testing.T) {
+	tests := []struct {
+		name   string // description of this test case
+		family tokenizer.Family
+		text   string
+		// real tokenizer counts are approximated, so accept a range
+		min, max int
+	}{
+		{
+			name:   "Empty text",
+			family: tokenizer.GPT,
+			text:   "",
+			min:    0,
+			max:    0,
+		},
+		{
+			name:   "Short sentence",
+			family: tokenizer.GPT,
+			// 7 tokens with cl100k
+			text: "Hello world, how are you?",
+			min:  6,
+			max:  8,
+		},
+		{
+			name:   "Prose paragraph",
+			family: tokenizer.GPT,
+			// 40 tokens with cl100k
+			text: "You are a senior software engineer reviewing a pull request. " +
+				"Point out bugs, security issues and unclear naming, and suggest " +
+				"concrete improvements. Keep the feedback short and actionable.",
+			min: 34,
+			max: 46,
+		},
+		{
+			name:   "Long numbers are split into groups",
+			family: tokenizer.GPT,
+			text:   "123456789",
+			min:    3,
+			max:    3,
+		},
+		{
+			name:   "CJK is about one token per character",
+			family: tokenizer.Claude,
+			text:   "日本語のテキスト",
+			min:    7,
+			max:    9,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.family.Count(tt.text)
+			if got < tt.min || got > tt.max {
+				t.Errorf("Count() = %d, want between %d and %d", got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestCount_GrowsWithText(t *testing.T) {
+	short := tokenizer.Count("review this code")
+	long := tokenizer.Count(strings.Repeat("review this code ", 100))
+	if long <= short*50 {
+		t.Errorf("Count() of 100 repetitions = %d, want much more than %d", long, short)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	family, err := tokenizer.Lookup(" Claude ")
+	if err != nil || family.Name != "claude" {
+		t.Errorf("Lookup() = %v, %v, want claude", family.Name, err)
+	}
+
+	if _, err := tokenizer.Lookup("gpt-99"); err == nil {
+		t.Error("Lookup() of an unknown family succeeded")
+	}
+}
+
+func TestBudget_Estimate(t *testing.T) {
+	budget := tokenizer.Budget{Family: tokenizer.GPT, Limit: 5}
+
+	if budget.Estimate("hi there").OverBudget() {
+		t.Error("a two word text is over a budget of 5")
+	}
+	if !budget.Estimate(strings.Repeat("word ", 10)).OverBudget() {
+		t.Error("a ten word text is within a budget of 5")
+	}
+
+	// no limit, no warnings
+	unlimited := tokenizer.DefaultBudget()
+	if unlimited.Estimate(strings.Repeat("word ", 1000)).OverBudget() {
+		t.Error("the default budget flagged a prompt")
+	}
+}
+
+func TestFormatCount(t *testing.T) {
+	tests := map[int]string{
+		0:      "0",
+		950:    "950",
+		1000:   "1k",
+		1250:   "1.2k",
+		12400:  "12k",
+		128000: "128k",
+	}
+	for n, want := range tests {
+		if got := tokenizer.FormatCount(n); got != want {
+			t.Errorf("FormatCount(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
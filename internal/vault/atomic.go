@@ -0,0 +1,59 @@
+package vault
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Writes data to the file at path atomically: readers see either the old
+// content or the new one, never a partial write, even if we crash half way.
+// The data goes to a temporary file in the same directory which is synced
+// and then renamed over path.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := writeTemp(path, data, perm)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// Writes data to a synced temporary file next to path, to be renamed over
+// it. The caller removes it if it is not renamed.
+func writeTemp(path string, data []byte, perm os.FileMode) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(perm)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// flushes a directory so that renames and removals in it survive a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	// some platforms cannot sync directories, the rename is still atomic there
+	_ = d.Sync()
+	return nil
+}
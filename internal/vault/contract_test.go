@@ -0,0 +1,57 @@
+package vault_test
+
+import (
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault/vaulttest"
+	"github.com/boltdb/bolt"
+)
+
+func TestPromptRepository_Contract(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// every repository implementation, they must all behave the same
+	backends := map[string]func(t *testing.T) vault.PromptRepository{
+		vault.BackendBolt: func(t *testing.T) vault.PromptRepository {
+			db, err := bolt.Open(filepath.Join(t.TempDir(), "test.db"), 0600, &bolt.Options{Timeout: 1 * time.Second})
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { db.Close() })
+			return vault.NewPromptRepository(db, logger)
+		},
+		vault.BackendMarkdown: func(t *testing.T) vault.PromptRepository {
+			repo, err := vault.NewMarkdownRepository(t.TempDir(), logger)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return repo
+		},
+		vault.BackendSQLite: func(t *testing.T) vault.PromptRepository {
+			db, err := vault.OpenSQLite(filepath.Join(t.TempDir(), "test.sqlite"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { db.Close() })
+			repo, err := vault.NewSQLiteRepository(db, logger)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return repo
+		},
+		"memory": func(t *testing.T) vault.PromptRepository {
+			return vault.NewMemoryRepository()
+		},
+	}
+
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			vaulttest.TestRepository(t, open)
+		})
+	}
+}
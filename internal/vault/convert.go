@@ -0,0 +1,111 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Restorer is implemented by repositories that can store prompts exactly
+// as they are given, ids, slugs, aliases and timestamps included.
+// It is what makes moving a vault between storage backends lossless.
+type Restorer interface {
+	// Stores the prompts and the trash as they are. The repository must
+	// be empty.
+	RestorePrompts(ctx context.Context, prompts []Prompt, trash []TrashedPrompt) error
+}
+
+// Copies every prompt, and the trash, from one repository to another,
+// which must be empty and implement Restorer. The copy is read back and compared with the
+// original, so a conversion that would lose anything fails instead, and so
+// does one from a source with records that cannot be read.
+// It returns the number of prompts copied.
+func ConvertRepository(ctx context.Context, from, to PromptRepository) (int, error) {
+	restorer, ok := to.(Restorer)
+	if !ok {
+		return 0, fmt.Errorf("the target storage cannot restore prompts")
+	}
+
+	existing, err := to.GetAllPrompts(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(existing) > 0 {
+		return 0, fmt.Errorf("the target storage already holds %d prompt(s)", len(existing))
+	}
+
+	// a record holding another prompt reads fine, only a diagnosis finds it
+	if doctor, ok := from.(Doctor); ok {
+		d, err := doctor.Diagnose(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if len(d.BadRecords) > 0 {
+			return 0, fmt.Errorf("cannot convert: %d bad record(s) in the source storage, run pvt doctor --repair", len(d.BadRecords))
+		}
+	}
+	prompts, err := from.GetAllPrompts(ctx)
+	if errors.Is(err, ErrUnreadable) {
+		return 0, fmt.Errorf("cannot convert: %w", err)
+	}
+	if err != nil {
+		return 0, err
+	}
+	trash, err := from.GetTrash(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := restorer.RestorePrompts(ctx, prompts, trash); err != nil {
+		return 0, err
+	}
+
+	// check that nothing was lost on the way
+	copied, err := to.GetAllPrompts(ctx)
+	if err != nil {
+		return 0, err
+	}
+	byID := map[int]Prompt{}
+	for _, p := range copied {
+		byID[p.ID] = p
+	}
+	for _, p := range prompts {
+		if c, ok := byID[p.ID]; !ok || !samePromptRecord(p, c) {
+			return 0, fmt.Errorf("prompt #%d did not survive the conversion unchanged", p.ID)
+		}
+	}
+	copiedTrash, err := to.GetTrash(ctx)
+	if err != nil {
+		return 0, err
+	}
+	trashed := map[int]Prompt{}
+	for _, t := range copiedTrash {
+		trashed[t.Prompt.ID] = t.Prompt
+	}
+	for _, t := range trash {
+		if c, ok := trashed[t.Prompt.ID]; !ok || !samePromptRecord(t.Prompt, c) {
+			return 0, fmt.Errorf("trashed prompt #%d did not survive the conversion unchanged", t.Prompt.ID)
+		}
+	}
+	return len(prompts), nil
+}
+
+// reports whether two prompts are the same record, field by field.
+// times are compared as instants, backends may store them in UTC.
+func samePromptRecord(a, b Prompt) bool {
+	return a.ID == b.ID &&
+		a.UUID == b.UUID &&
+		a.Title == b.Title &&
+		a.Slug == b.Slug &&
+		slices.Equal(a.Aliases, b.Aliases) &&
+		a.Description == b.Description &&
+		a.PromptContent == b.PromptContent &&
+		slices.Equal(a.Variables, b.Variables) &&
+		slices.Equal(a.Tags, b.Tags) &&
+		a.Collection == b.Collection &&
+		a.Pinned == b.Pinned &&
+		a.CreatedAt.Equal(b.CreatedAt) &&
+		a.UpdatedAt.Equal(b.UpdatedAt) &&
+		a.Uses == b.Uses &&
+		a.LastUsedAt.Equal(b.LastUsedAt)
+}
@@ -0,0 +1,64 @@
+package vault
+
+import (
+	"slices"
+	"strings"
+)
+
+// Folds the metadata of duplicates into the prompt kept in their place.
+// Tags and variables are combined, a missing description or collection
+// is taken from the first duplicate that has one, and the prompt stays
+// pinned if any of them was. The content of keep is left as it is.
+func MergeMetadata(keep Prompt, duplicates []Prompt) Prompt {
+	merged := keep
+	merged.Tags = slices.Clone(keep.Tags)
+	merged.Variables = slices.Clone(keep.Variables)
+	for _, p := range duplicates {
+		merged.Tags = append(merged.Tags, p.Tags...)
+		for _, v := range p.Variables {
+			if !slices.Contains(merged.Variables, v) {
+				merged.Variables = append(merged.Variables, v)
+			}
+		}
+		if strings.TrimSpace(merged.Description) == "" {
+			merged.Description = p.Description
+		}
+		if merged.Collection == "" {
+			merged.Collection = p.Collection
+		}
+		merged.Pinned = merged.Pinned || p.Pinned
+	}
+	merged.Tags = NormalizeTags(merged.Tags)
+	return merged
+}
+
+// Points the include directives of content that lead to one of the given
+// prompts at ref instead.
+func RedirectIncludes(content string, prompts []Prompt, from map[int]bool, ref string) string {
+	return includePattern.ReplaceAllStringFunc(content, func(directive string) string {
+		match := includePattern.FindStringSubmatch(directive)
+		if included, ok := ResolveRef(prompts, match[1]); ok && from[included.ID] {
+			return "{{> " + ref + "}}"
+		}
+		return directive
+	})
+}
+
+// Replaces the include directives of content that lead to one of the given
+// prompts with the content of that prompt, so that what it included stays
+// when the prompts are gone. The includes of the content put in are
+// replaced the same way, up to MaxIncludeDepth.
+func InlineIncludes(content string, prompts []Prompt, from map[int]bool) string {
+	return inlineIncludes(content, prompts, from, 0)
+}
+
+func inlineIncludes(content string, prompts []Prompt, from map[int]bool, depth int) string {
+	return includePattern.ReplaceAllStringFunc(content, func(directive string) string {
+		match := includePattern.FindStringSubmatch(directive)
+		included, ok := ResolveRef(prompts, match[1])
+		if !ok || !from[included.ID] || depth >= MaxIncludeDepth {
+			return directive
+		}
+		return inlineIncludes(included.PromptContent, prompts, from, depth+1)
+	})
+}
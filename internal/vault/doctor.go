@@ -0,0 +1,322 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// bucket holding the records Repair took out of the prompts bucket
+var quarantineBucket = []byte("quarantine")
+
+// Doctor is implemented by repositories that can check and repair the
+// file they are kept in, see pvt doctor.
+type Doctor interface {
+	// Reports what is wrong with the storage, without changing anything.
+	Diagnose(ctx context.Context) (*Diagnosis, error)
+
+	// Moves unreadable records to quarantine, fixes the id sequence and
+	// rebuilds the indexes. It returns what was wrong before the repair.
+	Repair(ctx context.Context) (*Diagnosis, error)
+
+	// Copies the storage into a fresh file, leaving out the space freed
+	// by deletes, and switches to it. It returns the sizes of the file
+	// before and after. Nothing else may use the repository meanwhile.
+	Compact(ctx context.Context) (before, after int64, err error)
+}
+
+// BadRecord is a record of the prompts bucket that is not a readable prompt.
+type BadRecord struct {
+	Key []byte
+	// id of the key, 0 if the key is not an id
+	ID      int
+	Problem string
+}
+
+func (r BadRecord) String() string {
+	if r.ID == 0 {
+		return fmt.Sprintf("record %x %s", r.Key, r.Problem)
+	}
+	return fmt.Sprintf("record %d %s", r.ID, r.Problem)
+}
+
+// QuarantinedRecord is a bad record as Repair set it aside.
+type QuarantinedRecord struct {
+	Key           []byte    `json:"key"`
+	Value         []byte    `json:"value"`
+	Problem       string    `json:"problem"`
+	QuarantinedAt time.Time `json:"quarantined_at"`
+}
+
+// Diagnosis is what Diagnose found in the storage.
+type Diagnosis struct {
+	// number of readable prompts
+	Prompts int
+
+	// records that are not readable prompts
+	BadRecords []BadRecord
+
+	// index entries disagreeing with the prompts, orphaned ones included
+	Drift []IndexDrift
+
+	// the last id handed out, and the highest id in use. new prompts
+	// would overwrite existing ones if the sequence is behind.
+	Sequence uint64
+	MaxID    int
+
+	// records set aside by earlier repairs
+	Quarantined int
+
+	// size of the file in bytes
+	Size int64
+}
+
+// reports whether new prompts would be given ids already in use
+func (d *Diagnosis) SequenceBehind() bool {
+	return d.Sequence < uint64(d.MaxID)
+}
+
+// reports whether nothing needs repairing
+func (d *Diagnosis) Healthy() bool {
+	return len(d.BadRecords) == 0 && len(d.Drift) == 0 && !d.SequenceBehind()
+}
+
+// checks every record of the prompts bucket, the indexes and the sequence
+func diagnose(ctx context.Context, tx *bolt.Tx) (*Diagnosis, error) {
+	d := &Diagnosis{Size: tx.Size()}
+	if quarantine := tx.Bucket(quarantineBucket); quarantine != nil {
+		d.Quarantined = quarantine.Stats().KeyN
+	}
+
+	if bucket := tx.Bucket(promptsBucket); bucket != nil {
+		d.Sequence = bucket.Sequence()
+		err := bucket.ForEach(func(key, value []byte) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			// nested buckets are not records
+			if value == nil {
+				return nil
+			}
+
+			// keys are only valid during the transaction
+			key = append([]byte(nil), key...)
+			if len(key) != 8 {
+				d.BadRecords = append(d.BadRecords, BadRecord{Key: key, Problem: "has a key that is not an id"})
+				return nil
+			}
+			id := btoi(key)
+			d.MaxID = max(d.MaxID, id)
+
+			prompt := Prompt{}
+			if err := json.Unmarshal(value, &prompt); err != nil {
+				d.BadRecords = append(d.BadRecords, BadRecord{Key: key, ID: id, Problem: "cannot be decoded: " + err.Error()})
+				return nil
+			}
+			if prompt.ID != id {
+				d.BadRecords = append(d.BadRecords, BadRecord{Key: key, ID: id, Problem: fmt.Sprintf("holds prompt %d", prompt.ID)})
+				return nil
+			}
+			d.Prompts++
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	drift, err := checkIndexes(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	d.Drift = drift
+	return d, nil
+}
+
+// reports what is wrong with the database
+func (repo *promptRepository) Diagnose(ctx context.Context) (*Diagnosis, error) {
+	var d *Diagnosis
+	err := repo.db.View(func(tx *bolt.Tx) error {
+		var err error
+		d, err = diagnose(ctx, tx)
+		return err
+	})
+	if err != nil {
+		repo.logger.Error("failed to diagnose database", "error", err)
+		return nil, storageError("diagnose", err)
+	}
+	return d, nil
+}
+
+// quarantines the bad records, fixes the sequence and rebuilds the indexes,
+// all in one transaction
+func (repo *promptRepository) Repair(ctx context.Context) (*Diagnosis, error) {
+	var d *Diagnosis
+	err := repo.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		d, err = diagnose(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		if len(d.BadRecords) > 0 {
+			quarantine, err := tx.CreateBucketIfNotExists(quarantineBucket)
+			if err != nil {
+				return err
+			}
+			prompts := tx.Bucket(promptsBucket)
+			for _, r := range d.BadRecords {
+				// copied, the value is only valid until it is deleted
+				record := QuarantinedRecord{
+					Key:           r.Key,
+					Value:         append([]byte(nil), prompts.Get(r.Key)...),
+					Problem:       r.Problem,
+					QuarantinedAt: time.Now().UTC(),
+				}
+				encoded, err := json.Marshal(record)
+				if err != nil {
+					return err
+				}
+				n, _ := quarantine.NextSequence()
+				if err := quarantine.Put(itob(n), encoded); err != nil {
+					return err
+				}
+				if err := prompts.Delete(record.Key); err != nil {
+					return err
+				}
+				repo.logger.Warn("quarantined prompt record", "id", r.ID, "problem", r.Problem)
+			}
+		}
+
+		// ids are never reused, even those of quarantined records
+		if d.SequenceBehind() {
+			if err := tx.Bucket(promptsBucket).SetSequence(uint64(d.MaxID)); err != nil {
+				return err
+			}
+		}
+		return rebuildIndexes(ctx, tx)
+	})
+	if err != nil {
+		repo.logger.Error("failed to repair database", "error", err)
+		return nil, storageError("repair", err)
+	}
+	repo.logger.Info("repaired database", "quarantined", len(d.BadRecords), "drift", len(d.Drift))
+	return d, nil
+}
+
+// copies the database into a fresh file next to it, then swaps the files.
+// the original is linked aside until the copy opens, and put back if it
+// does not, so that the repository is left open on one or the other.
+func (repo *promptRepository) Compact(ctx context.Context) (before, after int64, err error) {
+	path := repo.db.Path()
+	tmp := path + ".compact"
+	orig := path + ".orig"
+	if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		return 0, 0, storageError("compact", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, 0, storageError("compact", err)
+	}
+	before = info.Size()
+
+	if err := compactInto(ctx, repo.db, tmp); err != nil {
+		os.Remove(tmp)
+		repo.logger.Error("failed to compact database", "error", err)
+		return 0, 0, storageError("compact", err)
+	}
+
+	// the file cannot be replaced while it is open, on windows at least
+	if err := repo.db.Close(); err != nil {
+		os.Remove(tmp)
+		return 0, 0, storageError("compact", err)
+	}
+	// file systems without hard links go without the way back, and so does
+	// an original left aside by an earlier compaction, never overwritten
+	linked := os.Link(path, orig) == nil
+	compactErr := os.Rename(tmp, path)
+	if compactErr == nil {
+		compactErr = syncDir(filepath.Dir(path))
+	}
+	os.Remove(tmp)
+
+	// the old file is still there if the rename failed
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil && compactErr == nil && linked {
+		repo.logger.Error("failed to open compacted database, restoring the original", "error", err)
+		compactErr = fmt.Errorf("open compacted database: %w", err)
+		if err = os.Rename(orig, path); err == nil {
+			linked = false
+			db, err = bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+		}
+	}
+	if err != nil {
+		// the original stays aside if it could not be put back
+		repo.logger.Error("failed to reopen database", "error", err)
+		return 0, 0, storageError("reopen database", err)
+	}
+	repo.db = db
+	if linked {
+		os.Remove(orig)
+	}
+	if compactErr != nil {
+		return 0, 0, storageError("compact", compactErr)
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		after = info.Size()
+	}
+	repo.logger.Info("compacted database", "before", before, "after", after)
+	return before, after, nil
+}
+
+// writes a copy of every bucket of db, sequences included, to a new database at path
+func compactInto(ctx context.Context, db *bolt.DB, path string) error {
+	dst, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	err = db.View(func(src *bolt.Tx) error {
+		return dst.Update(func(tx *bolt.Tx) error {
+			return src.ForEach(func(name []byte, b *bolt.Bucket) error {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				copied, err := tx.CreateBucket(name)
+				if err != nil {
+					return err
+				}
+				return copyBucket(b, copied)
+			})
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return dst.Close()
+}
+
+// copies the keys, nested buckets and sequence of src into dst
+func copyBucket(src, dst *bolt.Bucket) error {
+	if err := dst.SetSequence(src.Sequence()); err != nil {
+		return err
+	}
+	return src.ForEach(func(key, value []byte) error {
+		if value != nil {
+			return dst.Put(key, value)
+		}
+		nested, err := dst.CreateBucket(key)
+		if err != nil {
+			return err
+		}
+		return copyBucket(src.Bucket(key), nested)
+	})
+}
@@ -0,0 +1,170 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestPromptRepository_Doctor_Integration(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	repo := NewPromptRepository(db, slog.New(slog.NewTextHandler(io.Discard, nil))).(*promptRepository)
+
+	prompts, err := repo.CreateOrUpdatePrompts(ctx, []Prompt{
+		{Title: "Review", PromptContent: "c", Tags: []string{"code"}},
+		{Title: "Summary", PromptContent: "c"},
+		{Title: "Draft", PromptContent: "c"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := repo.Diagnose(ctx); err != nil || !d.Healthy() || d.Prompts != 3 {
+		t.Fatalf("Diagnose() on a sound database = %+v, %v, want 3 prompts and no problems", d, err)
+	}
+
+	// damage the database the ways a crash or a bad hand edit could
+	mismatched, _ := json.Marshal(Prompt{ID: prompts[0].ID, Title: "Copy", PromptContent: "c"})
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(promptsBucket)
+		if err := bucket.Put(itob(uint64(prompts[1].ID)), []byte("{not json")); err != nil {
+			return err
+		}
+		if err := bucket.Put([]byte("junk"), []byte("{}")); err != nil {
+			return err
+		}
+		if err := bucket.Put(itob(9), mismatched); err != nil {
+			return err
+		}
+		return bucket.SetSequence(1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := repo.Diagnose(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, r := range d.BadRecords {
+		got = append(got, r.String())
+	}
+	want := []string{
+		"record 2 cannot be decoded",
+		"record 9 holds prompt 1",
+		"record 6a756e6b has a key that is not an id",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Diagnose() bad records = %q, want %q", got, want)
+	}
+	for i := range want {
+		if !strings.HasPrefix(got[i], want[i]) {
+			t.Errorf("Diagnose() bad record %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+	// the slug of the undecodable prompt now leads nowhere
+	if len(d.Drift) == 0 {
+		t.Error("Diagnose() found no drift, want the indexes of prompt 2 reported")
+	}
+	if !d.SequenceBehind() || d.MaxID != 9 || d.Healthy() {
+		t.Errorf("Diagnose() sequence = %d, max id = %d, want the sequence behind 9", d.Sequence, d.MaxID)
+	}
+
+	// the rest of the vault stays readable meanwhile, the bad record is reported
+	all, err := repo.GetAllPrompts(ctx)
+	var unreadable *UnreadableError
+	if !errors.As(err, &unreadable) || !slices.Equal(unreadable.IDs, []int{prompts[1].ID}) {
+		t.Fatalf("GetAllPrompts() with a bad record = %v, want an *UnreadableError for prompt %d", err, prompts[1].ID)
+	}
+	if len(all) != 4 {
+		t.Errorf("GetAllPrompts() with a bad record = %d prompts, want 4", len(all))
+	}
+	page, err := repo.QueryPrompts(ctx, Query{})
+	if err != nil {
+		t.Fatalf("QueryPrompts() with a bad record = %v, want it skipped", err)
+	}
+	if !slices.Contains(page.Skipped, prompts[1].ID) {
+		t.Errorf("QueryPrompts() skipped = %v, want prompt %d", page.Skipped, prompts[1].ID)
+	}
+
+	if _, err := repo.Repair(ctx); err != nil {
+		t.Fatal(err)
+	}
+	d, err = repo.Diagnose(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Healthy() || d.Prompts != 2 || d.Quarantined != 3 || d.Sequence != 9 {
+		t.Errorf("Diagnose() after Repair() = %+v, want 2 prompts, 3 quarantined and the sequence at 9", d)
+	}
+	if _, err := repo.GetAllPrompts(ctx); err != nil {
+		t.Errorf("GetAllPrompts() after Repair() = %v, want no unreadable records", err)
+	}
+	created, err := repo.CreateOrUpdatePrompt(ctx, &Prompt{Title: "New", PromptContent: "c"})
+	if err != nil || created.ID != 10 {
+		t.Errorf("CreateOrUpdatePrompt() after Repair() = %v, %v, want id 10", created, err)
+	}
+
+	// the quarantine keeps the records as they were
+	err = db.View(func(tx *bolt.Tx) error {
+		record := QuarantinedRecord{}
+		if err := json.Unmarshal(tx.Bucket(quarantineBucket).Get(itob(1)), &record); err != nil {
+			return err
+		}
+		if string(record.Value) != "{not json" || btoi(record.Key) != prompts[1].ID {
+			t.Errorf("quarantined record = %+v, want prompt %d as written", record, prompts[1].ID)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPromptRepository_Compact_Integration(t *testing.T) {
+	ctx := context.Background()
+	repo := NewPromptRepository(openTestDB(t), slog.New(slog.NewTextHandler(io.Discard, nil))).(*promptRepository)
+
+	batch := []Prompt{}
+	for range 200 {
+		batch = append(batch, Prompt{Title: "Filler", PromptContent: strings.Repeat("x", 4096)})
+	}
+	filler, err := repo.CreateOrUpdatePrompts(ctx, batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, err := repo.CreateOrUpdatePrompt(ctx, &Prompt{Title: "Keep", PromptContent: "c", Tags: []string{"code"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range filler {
+		if err := repo.DeletePrompt(ctx, p.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	before, after, err := repo.Compact(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after >= before {
+		t.Errorf("Compact() = %d -> %d bytes, want the file to shrink", before, after)
+	}
+
+	// the data, the indexes and the sequence made it across
+	d, err := repo.Diagnose(ctx)
+	if err != nil || !d.Healthy() || d.Prompts != 1 || d.Sequence != uint64(kept.ID) {
+		t.Errorf("Diagnose() after Compact() = %+v, %v, want one sound prompt and the sequence at %d", d, err, kept.ID)
+	}
+	if p, err := repo.GetPromptBySlug(ctx, "keep"); err != nil || p.ID != kept.ID {
+		t.Errorf("GetPromptBySlug() after Compact() = %v, %v, want prompt %d", p, err, kept.ID)
+	}
+}
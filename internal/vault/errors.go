@@ -0,0 +1,146 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors returned by the repository and the service.
+// Callers should match on them with errors.Is instead of comparing strings.
+var (
+	// the requested prompt does not exist
+	ErrNotFound = errors.New("prompt not found")
+
+	// the prompt failed validation, see ValidationError for the offending field
+	ErrValidation = errors.New("invalid prompt")
+
+	// the underlying storage failed to read, write or encode a record
+	ErrStorage = errors.New("storage failure")
+
+	// some records of the vault cannot be read, see UnreadableError
+	ErrUnreadable = errors.New("unreadable prompts")
+)
+
+// Names of the prompt fields reported by ValidationError.
+const (
+	FieldUUID        = "uuid"
+	FieldTitle       = "title"
+	FieldSlug        = "slug"
+	FieldDescription = "description"
+	FieldVariables   = "variables"
+	FieldTags        = "tags"
+	FieldCollection  = "collection"
+	FieldPinned      = "pinned"
+	FieldContent     = "content"
+)
+
+// ValidationError reports a problem with a single field of a prompt.
+// It matches ErrValidation with errors.Is.
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// StorageError wraps a failure of the underlying storage with the operation
+// that caused it. It matches ErrStorage with errors.Is and unwraps to the cause.
+type StorageError struct {
+	Op  string
+	Err error
+}
+
+func (e *StorageError) Error() string {
+	return fmt.Sprintf("storage: %s: %v", e.Op, e.Err)
+}
+
+func (e *StorageError) Unwrap() error {
+	return e.Err
+}
+
+func (e *StorageError) Is(target error) bool {
+	return target == ErrStorage
+}
+
+// UnreadableError reports the records left out of a listing of the
+// vault because they cannot be read. It comes along with the prompts that
+// could be, so that what only shows them can go on, while what writes or
+// publishes the whole vault must not. It matches ErrUnreadable with
+// errors.Is.
+type UnreadableError struct {
+	// ids of the records, 0 for a record whose key is not an id
+	IDs []int
+}
+
+func (e *UnreadableError) Error() string {
+	return fmt.Sprintf("%d prompt record(s) cannot be read, run pvt doctor --repair to quarantine them", len(e.IDs))
+}
+
+func (e *UnreadableError) Is(target error) bool {
+	return target == ErrUnreadable
+}
+
+// the prompts that could be read, and any other error. for what only
+// shows prompts, an unreadable record is reported elsewhere.
+func readable(prompts []Prompt, err error) ([]Prompt, error) {
+	if errors.Is(err, ErrUnreadable) {
+		return prompts, nil
+	}
+	return prompts, err
+}
+
+// wraps a storage failure, leaving nil and context errors untouched
+func storageError(op string, err error) error {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return &StorageError{Op: op, Err: err}
+}
+
+// wraps ErrNotFound with the id that was looked up
+func notFoundError(id int) error {
+	return fmt.Errorf("%w: id %d", ErrNotFound, id)
+}
+
+// Collects the field level messages of every ValidationError in err,
+// including errors joined with errors.Join. Returns nil if there are none.
+func FieldErrors(err error) map[string]string {
+	var fields map[string]string
+
+	var walk func(err error)
+	walk = func(err error) {
+		if err == nil {
+			return
+		}
+
+		if verr, ok := err.(*ValidationError); ok {
+			if fields == nil {
+				fields = make(map[string]string)
+			}
+			// keep the first message reported for a field
+			if _, ok := fields[verr.Field]; !ok {
+				fields[verr.Field] = verr.Message
+			}
+			return
+		}
+
+		switch e := err.(type) {
+		case interface{ Unwrap() []error }:
+			for _, inner := range e.Unwrap() {
+				walk(inner)
+			}
+		case interface{ Unwrap() error }:
+			walk(e.Unwrap())
+		}
+	}
+	walk(err)
+
+	return fields
+}
@@ -0,0 +1,56 @@
+package vault
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// version of the export format written by ExportPrompts
+const ExportVersion = 1
+
+// Export is the document written by ExportPrompts.
+type Export struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	Prompts    []Prompt  `json:"prompts"`
+}
+
+// ExportOptions controls what ExportPrompts writes.
+type ExportOptions struct {
+	// replace credentials found in the prompt content with [REDACTED]
+	Redact bool
+}
+
+// The options used unless the user asks otherwise.
+// Secrets are redacted so that an export can be shared safely.
+func DefaultExportOptions() ExportOptions {
+	return ExportOptions{Redact: true}
+}
+
+// Writes the prompts to w as an indented JSON export document.
+// It returns the number of secrets that were redacted.
+func ExportPrompts(w io.Writer, prompts []Prompt, opts ExportOptions) (int, error) {
+	doc := Export{
+		Version:    ExportVersion,
+		ExportedAt: time.Now().UTC(),
+		Prompts:    make([]Prompt, 0, len(prompts)),
+	}
+
+	redacted := 0
+	for _, prompt := range prompts {
+		if opts.Redact {
+			var n int
+			prompt, n = RedactPrompt(prompt)
+			redacted += n
+		}
+		doc.Prompts = append(doc.Prompts, prompt)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return 0, err
+	}
+	return redacted, nil
+}
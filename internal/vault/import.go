@@ -0,0 +1,173 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+)
+
+// ImportResult counts what happened to each prompt of an import.
+type ImportResult struct {
+	// prompts that were not in the vault yet
+	Created int
+	// prompts that replaced an older copy in the vault
+	Updated int
+	// prompts identical to the copy in the vault
+	Unchanged int
+	// prompts skipped because the copy in the vault was edited more recently
+	Kept int
+	// prompts that appeared more than once in the import, only the newest is used
+	Duplicates int
+	// new prompts whose ID was already taken in the vault and got a fresh one
+	Renumbered int
+	// numeric includes of the prompts written that point to no prompt of the
+	// import. they are left as they are, so they include whichever prompt
+	// has that ID in the vault.
+	Dangling int
+}
+
+// Reads an export document written by ExportPrompts.
+func ReadExport(r io.Reader) (*Export, error) {
+	doc := &Export{}
+	if err := json.NewDecoder(r).Decode(doc); err != nil {
+		return nil, fmt.Errorf("read export: %w", err)
+	}
+	if doc.Version < 1 || doc.Version > ExportVersion {
+		return nil, fmt.Errorf("read export: unsupported version %d", doc.Version)
+	}
+	return doc, nil
+}
+
+// Works out which of the incoming prompts to write to a vault holding local.
+//
+// Prompts are matched by UUID, never by ID: IDs are only unique within the
+// vault that assigned them. A match replaces the local copy unless the local
+// copy was edited more recently. Everything else is created with a fresh ID,
+// and numeric includes between incoming prompts are rewritten to UUIDs so that
+// they keep pointing at the same prompt after renumbering. Local copies are
+// compared with their includes rewritten the same way, so importing a
+// vault's own export changes nothing.
+func planImport(local, incoming []Prompt) ([]Prompt, ImportResult) {
+	result := ImportResult{}
+
+	byUUID := map[string]*Prompt{}
+	usedIDs := map[int]bool{}
+	localUUIDs := includeUUIDs(local)
+	slugOwners := map[string]int{} // slug to local id, -1 for slugs claimed by this import
+	for i := range local {
+		byUUID[local[i].UUID] = &local[i]
+		usedIDs[local[i].ID] = true
+		for _, slug := range append([]string{local[i].Slug}, local[i].Aliases...) {
+			slugOwners[slug] = local[i].ID
+		}
+	}
+
+	// older exports have no uuids, and only the newest copy of a prompt is kept
+	prompts := []Prompt{}
+	index := map[string]int{}
+	for _, p := range incoming {
+		if !ValidUUID(p.UUID) {
+			p.UUID = NewUUID()
+		}
+		if i, ok := index[p.UUID]; ok {
+			result.Duplicates++
+			if p.UpdatedAt.After(prompts[i].UpdatedAt) {
+				prompts[i] = p
+			}
+			continue
+		}
+		index[p.UUID] = len(prompts)
+		prompts = append(prompts, p)
+	}
+
+	uuids := includeUUIDs(prompts)
+
+	writes := []Prompt{}
+	for _, p := range prompts {
+		p.Aliases = nil
+		content, dangling := uuidIncludes(p.PromptContent, uuids)
+		p.PromptContent = content
+
+		if existing, ok := byUUID[p.UUID]; ok {
+			normalized := *existing
+			normalized.PromptContent, _ = uuidIncludes(existing.PromptContent, localUUIDs)
+			switch {
+			case samePrompt(&normalized, &p):
+				result.Unchanged++
+				continue
+			case existing.UpdatedAt.After(p.UpdatedAt):
+				result.Kept++
+				continue
+			}
+
+			p.ID = existing.ID
+			p.CreatedAt = existing.CreatedAt
+			// the incoming slug only wins when it is free here
+			if owner, taken := slugOwners[p.Slug]; !ValidSlug(p.Slug) || (taken && owner != existing.ID) {
+				p.Slug = existing.Slug
+			}
+			slugOwners[p.Slug] = -1
+			result.Updated++
+			result.Dangling += dangling
+			writes = append(writes, p)
+			continue
+		}
+
+		if usedIDs[p.ID] {
+			result.Renumbered++
+		}
+		p.ID = 0
+		if _, taken := slugOwners[p.Slug]; taken || !ValidSlug(p.Slug) {
+			// derived from the title, with a suffix if needed
+			p.Slug = ""
+		} else {
+			slugOwners[p.Slug] = -1
+		}
+		result.Created++
+		result.Dangling += dangling
+		writes = append(writes, p)
+	}
+
+	return writes, result
+}
+
+// maps the ids of the prompts, as numeric includes name them, to their uuids
+func includeUUIDs(prompts []Prompt) map[string]string {
+	uuids := map[string]string{}
+	for _, p := range prompts {
+		if p.ID != 0 {
+			uuids[strconv.Itoa(p.ID)] = p.UUID
+		}
+	}
+	return uuids
+}
+
+// rewrites the numeric includes of content to the uuids of the prompts they
+// name, counting those naming none of them
+func uuidIncludes(content string, uuids map[string]string) (string, int) {
+	dangling := 0
+	content = includePattern.ReplaceAllStringFunc(content, func(directive string) string {
+		ref := includePattern.FindStringSubmatch(directive)[1]
+		if uuid, ok := uuids[ref]; ok {
+			return "{{> " + uuid + "}}"
+		}
+		if _, err := strconv.Atoi(ref); err == nil {
+			dangling++
+		}
+		return directive
+	})
+	return content, dangling
+}
+
+// reports whether two copies of a prompt have the same content
+func samePrompt(a, b *Prompt) bool {
+	return a.Title == b.Title &&
+		a.Description == b.Description &&
+		a.PromptContent == b.PromptContent &&
+		slices.Equal(a.Variables, b.Variables) &&
+		slices.Equal(a.Tags, b.Tags) &&
+		a.Collection == b.Collection &&
+		a.Pinned == b.Pinned
+}
@@ -0,0 +1,198 @@
+package vault_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+	"github.com/boltdb/bolt"
+)
+
+func newTestService(t *testing.T, prompts ...vault.Prompt) vault.PromptService {
+	t.Helper()
+
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "test.db"), 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	service := vault.NewPromptService(vault.NewPromptRepository(db, logger))
+	for i := range prompts {
+		if _, err := service.CreateOrUpdatePrompt(context.Background(), &prompts[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return service
+}
+
+// exports every prompt of the service and reads the export back
+func roundTrip(t *testing.T, service vault.PromptService) []vault.Prompt {
+	t.Helper()
+
+	prompts, err := service.GetAllPrompts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := vault.ExportPrompts(&buf, prompts, vault.DefaultExportOptions()); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := vault.ReadExport(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc.Prompts
+}
+
+func TestImportPrompts(t *testing.T) {
+	ctx := context.Background()
+
+	theirs := newTestService(t,
+		vault.Prompt{Title: "House Style", PromptContent: "Be concise."},
+		vault.Prompt{Title: "Reviewer", PromptContent: "{{> 1}}\nReview the code."},
+	)
+	ours := newTestService(t,
+		vault.Prompt{Title: "House Style", PromptContent: "Our own house style."},
+	)
+
+	// first import: nothing matches, ids 1 and 2 collide with or follow ours
+	result, err := ours.ImportPrompts(ctx, roundTrip(t, theirs))
+	if err != nil {
+		t.Fatalf("ImportPrompts() failed: %v", err)
+	}
+	if want := (vault.ImportResult{Created: 2, Renumbered: 1}); result != want {
+		t.Errorf("ImportPrompts() = %+v, want %+v", result, want)
+	}
+
+	// our prompt is untouched, theirs got a slug of its own
+	mine, err := ours.GetPromptByRef(ctx, "house-style")
+	if err != nil || mine.PromptContent != "Our own house style." {
+		t.Fatalf("GetPromptByRef(house-style) = %v, %v, want our prompt untouched", mine, err)
+	}
+	if _, err := ours.GetPromptByRef(ctx, "house-style-2"); err != nil {
+		t.Errorf("GetPromptByRef(house-style-2) failed: %v", err)
+	}
+
+	// the include still points to their house style after renumbering
+	reviewer, err := ours.GetPromptByRef(ctx, "reviewer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rendered, err := ours.RenderPrompt(ctx, reviewer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Be concise.\nReview the code."; rendered != want {
+		t.Errorf("RenderPrompt() = %q, want %q", rendered, want)
+	}
+
+	// importing the same export again changes nothing
+	result, err = ours.ImportPrompts(ctx, roundTrip(t, theirs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (vault.ImportResult{Unchanged: 2}); result != want {
+		t.Errorf("second ImportPrompts() = %+v, want %+v", result, want)
+	}
+
+	// their edit wins over our older copy, our later edit wins over theirs
+	exported := roundTrip(t, theirs)
+	for i := range exported {
+		exported[i].PromptContent += "!"
+		exported[i].UpdatedAt = time.Now().Add(time.Hour)
+	}
+	exported[0].UpdatedAt = time.Now().Add(-time.Hour)
+
+	result, err = ours.ImportPrompts(ctx, exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (vault.ImportResult{Updated: 1, Kept: 1}); result != want {
+		t.Errorf("third ImportPrompts() = %+v, want %+v", result, want)
+	}
+
+	all, err := ours.GetAllPrompts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 {
+		t.Errorf("vault holds %d prompts, want 3", len(all))
+	}
+}
+
+func TestImportPrompts_OwnExport(t *testing.T) {
+	ctx := context.Background()
+	service := newTestService(t,
+		vault.Prompt{Title: "House Style", PromptContent: "Be concise."},
+		vault.Prompt{Title: "Reviewer", PromptContent: "{{> 1}}\nReview the code."},
+	)
+
+	// numeric includes are compared by the prompt they name, not as written
+	result, err := service.ImportPrompts(ctx, roundTrip(t, service))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (vault.ImportResult{Unchanged: 2}); result != want {
+		t.Errorf("ImportPrompts() of the vault's own export = %+v, want %+v", result, want)
+	}
+	reviewer, err := service.GetPromptByRef(ctx, "reviewer")
+	if err != nil || reviewer.PromptContent != "{{> 1}}\nReview the code." {
+		t.Errorf("GetPromptByRef(reviewer) = %v, %v, want it untouched", reviewer, err)
+	}
+}
+
+func TestImportPrompts_Dangling(t *testing.T) {
+	ctx := context.Background()
+	service := newTestService(t)
+
+	// an include of a prompt that was not exported along with it
+	result, err := service.ImportPrompts(ctx, []vault.Prompt{
+		{ID: 2, UUID: vault.NewUUID(), Title: "Reviewer", PromptContent: "{{> 1}} {{> house-style}}\nReview the code."},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (vault.ImportResult{Created: 1, Dangling: 1}); result != want {
+		t.Errorf("ImportPrompts() = %+v, want %+v", result, want)
+	}
+}
+
+func TestImportPrompts_Duplicates(t *testing.T) {
+	ctx := context.Background()
+	service := newTestService(t)
+
+	uuid := vault.NewUUID()
+	older := vault.Prompt{UUID: uuid, Title: "Old", PromptContent: "old", UpdatedAt: time.Now().Add(-time.Hour)}
+	newer := vault.Prompt{UUID: uuid, Title: "New", PromptContent: "new", UpdatedAt: time.Now()}
+
+	result, err := service.ImportPrompts(ctx, []vault.Prompt{newer, older})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (vault.ImportResult{Created: 1, Duplicates: 1}); result != want {
+		t.Errorf("ImportPrompts() = %+v, want %+v", result, want)
+	}
+
+	got, err := service.GetPromptByRef(ctx, uuid)
+	if err != nil {
+		t.Fatalf("GetPromptByRef(uuid) failed: %v", err)
+	}
+	if got.Title != "New" {
+		t.Errorf("imported %q, want the newest copy", got.Title)
+	}
+}
+
+func TestReadExport_UnsupportedVersion(t *testing.T) {
+	_, err := vault.ReadExport(strings.NewReader(`{"version": 99, "prompts": []}`))
+	if err == nil || !strings.Contains(err.Error(), "unsupported version 99") {
+		t.Errorf("ReadExport() error = %v, want unsupported version", err)
+	}
+}
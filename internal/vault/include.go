@@ -0,0 +1,130 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// includes nested deeper than this are rejected
+const MaxIncludeDepth = 8
+
+var (
+	// a prompt ends up including itself
+	ErrIncludeCycle = errors.New("include cycle")
+
+	// includes are nested deeper than MaxIncludeDepth
+	ErrIncludeDepth = errors.New("includes nested too deeply")
+)
+
+// matches include directives such as {{> house-style}} or {{> 12}}
+var includePattern = regexp.MustCompile(`\{\{>\s*([^{}\s]+)\s*\}\}`)
+
+// Returns the references of the include directives in the content, in order.
+func Includes(content string) []string {
+	refs := []string{}
+	for _, match := range includePattern.FindAllStringSubmatch(content, -1) {
+		refs = append(refs, match[1])
+	}
+	return refs
+}
+
+// Finds the prompt a reference points to.
+// A reference is either the numeric ID of a prompt, its UUID, its slug or
+// one of its former slugs. Prompts that were never saved match the slug of
+// their title.
+func ResolveRef(prompts []Prompt, ref string) (*Prompt, bool) {
+	if id, err := strconv.Atoi(ref); err == nil {
+		for i := range prompts {
+			if prompts[i].ID == id {
+				return &prompts[i], true
+			}
+		}
+		return nil, false
+	}
+
+	for i := range prompts {
+		slug := prompts[i].Slug
+		if slug == "" {
+			slug = Slugify(prompts[i].Title)
+		}
+		if slug == ref || prompts[i].UUID == ref || slices.Contains(prompts[i].Aliases, ref) {
+			return &prompts[i], true
+		}
+	}
+	return nil, false
+}
+
+// Expands the include directives of the prompt recursively, using prompts
+// to resolve the references. Cycles, references that cannot be resolved and
+// nesting deeper than MaxIncludeDepth are reported as errors.
+func ExpandIncludes(prompt *Prompt, prompts []Prompt) (string, error) {
+	return expandIncludes(prompt, prompts, []int{prompt.ID})
+}
+
+// path holds the ids of the prompts being expanded, outermost first
+func expandIncludes(prompt *Prompt, prompts []Prompt, path []int) (string, error) {
+	var expandErr error
+
+	expanded := includePattern.ReplaceAllStringFunc(prompt.PromptContent, func(directive string) string {
+		if expandErr != nil {
+			return directive
+		}
+
+		ref := includePattern.FindStringSubmatch(directive)[1]
+		included, ok := ResolveRef(prompts, ref)
+		if !ok {
+			expandErr = fmt.Errorf("%w: include %q in prompt #%d", ErrNotFound, ref, prompt.ID)
+			return directive
+		}
+
+		for _, id := range path {
+			if id == included.ID {
+				expandErr = fmt.Errorf("%w: %s", ErrIncludeCycle, includePath(append(path, included.ID)))
+				return directive
+			}
+		}
+		if len(path) > MaxIncludeDepth {
+			expandErr = fmt.Errorf("%w: %s", ErrIncludeDepth, includePath(path))
+			return directive
+		}
+
+		content, err := expandIncludes(included, prompts, append(path[:len(path):len(path)], included.ID))
+		if err != nil {
+			expandErr = err
+			return directive
+		}
+		return content
+	})
+
+	return expanded, expandErr
+}
+
+// formats an include chain as "#1 -> #2 -> #1"
+func includePath(path []int) string {
+	parts := make([]string, len(path))
+	for i, id := range path {
+		parts[i] = fmt.Sprintf("#%d", id)
+	}
+	return strings.Join(parts, " -> ")
+}
+
+// Returns the prompts that include the prompt with the given id directly.
+func Dependents(id int, prompts []Prompt) []Prompt {
+	dependents := []Prompt{}
+	for i := range prompts {
+		if prompts[i].ID == id {
+			continue
+		}
+		for _, ref := range Includes(prompts[i].PromptContent) {
+			if included, ok := ResolveRef(prompts, ref); ok && included.ID == id {
+				dependents = append(dependents, prompts[i])
+				break
+			}
+		}
+	}
+	return dependents
+}
@@ -0,0 +1,129 @@
+package vault_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+)
+
+func TestExpandIncludes(t *testing.T) {
+	prompts := []vault.Prompt{
+		{ID: 1, Title: "House Style", PromptContent: "Be concise."},
+		{ID: 2, Title: "Reviewer", PromptContent: "{{> house-style}}\nReview the code."},
+		{ID: 3, Title: "Senior Reviewer", PromptContent: "{{> 2}}\nBe thorough."},
+		{ID: 4, Title: "Loop A", PromptContent: "{{> loop-b}}"},
+		{ID: 5, Title: "Loop B", PromptContent: "{{>4}}"},
+		{ID: 6, Title: "Broken", PromptContent: "{{> missing}}"},
+		{ID: 7, Title: "Self", PromptContent: "again {{> 7}}"},
+	}
+
+	tests := []struct {
+		name    string // description of this test case
+		id      int
+		want    string
+		wantErr error
+	}{
+		{
+			name: "No includes",
+			id:   1,
+			want: "Be concise.",
+		},
+		{
+			name: "Include by slug",
+			id:   2,
+			want: "Be concise.\nReview the code.",
+		},
+		{
+			name: "Nested include by id",
+			id:   3,
+			want: "Be concise.\nReview the code.\nBe thorough.",
+		},
+		{
+			name:    "Cycle",
+			id:      4,
+			wantErr: vault.ErrIncludeCycle,
+		},
+		{
+			name:    "Self include",
+			id:      7,
+			wantErr: vault.ErrIncludeCycle,
+		},
+		{
+			name:    "Missing include",
+			id:      6,
+			wantErr: vault.ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompt, ok := vault.ResolveRef(prompts, fmt.Sprint(tt.id))
+			if !ok {
+				t.Fatalf("ResolveRef(%d) found nothing", tt.id)
+			}
+
+			got, gotErr := vault.ExpandIncludes(prompt, prompts)
+			if tt.wantErr != nil {
+				if !errors.Is(gotErr, tt.wantErr) {
+					t.Fatalf("ExpandIncludes() error = %v, want %v", gotErr, tt.wantErr)
+				}
+				return
+			}
+			if gotErr != nil {
+				t.Fatalf("ExpandIncludes() failed: %v", gotErr)
+			}
+			if got != tt.want {
+				t.Errorf("ExpandIncludes() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandIncludes_DepthLimit(t *testing.T) {
+	// a chain one longer than the limit allows
+	prompts := []vault.Prompt{}
+	for id := 1; id <= vault.MaxIncludeDepth+2; id++ {
+		prompts = append(prompts, vault.Prompt{ID: id, Title: fmt.Sprint("p", id), PromptContent: fmt.Sprintf("{{> %d}}", id+1)})
+	}
+	prompts[len(prompts)-1].PromptContent = "end"
+
+	_, err := vault.ExpandIncludes(&prompts[0], prompts)
+	if !errors.Is(err, vault.ErrIncludeDepth) {
+		t.Errorf("ExpandIncludes() error = %v, want ErrIncludeDepth", err)
+	}
+
+	// the deepest allowed chain still expands
+	_, err = vault.ExpandIncludes(&prompts[1], prompts)
+	if err != nil {
+		t.Errorf("ExpandIncludes() of a chain within the limit failed: %v", err)
+	}
+}
+
+func TestDependents(t *testing.T) {
+	prompts := []vault.Prompt{
+		{ID: 1, Title: "House Style", PromptContent: "Be concise."},
+		{ID: 2, Title: "Reviewer", PromptContent: "{{> house-style}} and {{> 1}}"},
+		{ID: 3, Title: "Writer", PromptContent: "{{> 1}}"},
+		{ID: 4, Title: "Other", PromptContent: "{{> 3}}"},
+	}
+
+	got := vault.Dependents(1, prompts)
+	if len(got) != 2 || got[0].ID != 2 || got[1].ID != 3 {
+		t.Errorf("Dependents() = %v, want prompts 2 and 3", got)
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"House Style":               "house-style",
+		"  Review: SQL migrations!": "review-sql-migrations",
+		"Ünïcode & more":            "unicode-more",
+		"":                          "",
+	}
+	for title, want := range tests {
+		if got := vault.Slugify(title); got != want {
+			t.Errorf("Slugify(%q) = %q, want %q", title, got, want)
+		}
+	}
+}
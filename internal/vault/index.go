@@ -0,0 +1,148 @@
+package vault
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/boltdb/bolt"
+)
+
+// Indexer is implemented by repositories keeping secondary indexes next to
+// the prompts. They are written in the same transaction as the prompts,
+// but a crash of an older pvt or a bug can still let them drift apart.
+type Indexer interface {
+	// Reports every index entry that is missing or should not be there.
+	// No drift means the indexes agree with the prompts.
+	CheckIndexes(ctx context.Context) ([]IndexDrift, error)
+
+	// Drops the indexes and builds them again from the prompts.
+	Reindex(ctx context.Context) error
+}
+
+// IndexDrift is an entry of a secondary index disagreeing with the prompts.
+type IndexDrift struct {
+	// name of the index
+	Index string
+	// the indexed value, a slug, a tag, a title...
+	Key string
+	// the prompt the entry leads to, or should lead to.
+	// 0 for entries leading nowhere, or drift of a whole index.
+	ID int
+	// true if the entry is missing, false if it should not be there
+	Missing bool
+}
+
+func (d IndexDrift) String() string {
+	switch {
+	case d.Missing && d.ID == 0:
+		// an index that can only be checked as a whole
+		return fmt.Sprintf("%s: does not match the prompts", d.Index)
+	case d.Missing:
+		return fmt.Sprintf("%s: %q of prompt %d is missing", d.Index, d.Key, d.ID)
+	case d.ID == 0:
+		return fmt.Sprintf("%s: %q leads to no prompt", d.Index, d.Key)
+	}
+	return fmt.Sprintf("%s: %q leads to prompt %d, which it should not", d.Index, d.Key, d.ID)
+}
+
+// an entry of a secondary index: a key and the prompt it leads to
+type indexEntry struct {
+	key string
+	id  int
+}
+
+// compares the entries an index has with the ones it should have
+func indexDrift(index string, want, have map[indexEntry]bool) []IndexDrift {
+	drift := []IndexDrift{}
+	for e := range want {
+		if !have[e] {
+			drift = append(drift, IndexDrift{Index: index, Key: e.key, ID: e.id, Missing: true})
+		}
+	}
+	for e := range have {
+		if !want[e] {
+			drift = append(drift, IndexDrift{Index: index, Key: e.key, ID: e.id})
+		}
+	}
+	slices.SortFunc(drift, func(a, b IndexDrift) int {
+		return cmp.Or(strings.Compare(a.Key, b.Key), cmp.Compare(a.ID, b.ID))
+	})
+	return drift
+}
+
+// A unique secondary index from keys, such as slugs or uuids, to prompt ids.
+// Every storage backend provides one so that slugs and uuids are assigned
+// the same way whatever the prompts are stored in.
+type promptIndex interface {
+	// returns the id of the prompt owning key
+	owner(key string) (id int, ok bool)
+	put(key string, id int) error
+	// removes key if it still belongs to the prompt with the given id
+	remove(key string, id int) error
+}
+
+// index kept in a bolt bucket
+type boltIndex struct {
+	bucket *bolt.Bucket
+}
+
+func (i boltIndex) owner(key string) (int, bool) {
+	if i.bucket == nil {
+		return 0, false
+	}
+	value := i.bucket.Get([]byte(key))
+	if value == nil {
+		return 0, false
+	}
+	return btoi(value), true
+}
+
+func (i boltIndex) put(key string, id int) error {
+	return i.bucket.Put([]byte(key), itob(uint64(id)))
+}
+
+func (i boltIndex) remove(key string, id int) error {
+	if owner, ok := i.owner(key); ok && owner == id {
+		return i.bucket.Delete([]byte(key))
+	}
+	return nil
+}
+
+// index kept in memory, built from the prompts themselves
+type mapIndex map[string]int
+
+func (i mapIndex) owner(key string) (int, bool) {
+	id, ok := i[key]
+	return id, ok
+}
+
+func (i mapIndex) put(key string, id int) error {
+	i[key] = id
+	return nil
+}
+
+func (i mapIndex) remove(key string, id int) error {
+	if owner, ok := i[key]; ok && owner == id {
+		delete(i, key)
+	}
+	return nil
+}
+
+// builds the slug and uuid indexes of the prompts
+func indexPrompts(prompts []Prompt) (slugs mapIndex, uuids mapIndex) {
+	slugs, uuids = mapIndex{}, mapIndex{}
+	for _, p := range prompts {
+		for _, slug := range append([]string{p.Slug}, p.Aliases...) {
+			if slug != "" {
+				slugs[slug] = p.ID
+			}
+		}
+		if p.UUID != "" {
+			uuids[p.UUID] = p.ID
+		}
+	}
+	return slugs, uuids
+}
@@ -0,0 +1,314 @@
+package vault
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Severity of a lint issue, from least to most serious.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+// Parses a severity name as printed by String.
+func ParseSeverity(name string) (Severity, error) {
+	switch strings.ToLower(name) {
+	case "info":
+		return SeverityInfo, nil
+	case "warning", "warn":
+		return SeverityWarning, nil
+	case "error":
+		return SeverityError, nil
+	}
+	return 0, fmt.Errorf("unknown severity %q", name)
+}
+
+// lines longer than this are flagged by the long-line rule
+const MaxLineLength = 200
+
+// LintIssue is a single problem found in a prompt.
+// Line is 1-based and only set for issues in the prompt content.
+type LintIssue struct {
+	Rule     string
+	Severity Severity
+	Field    string
+	Line     int
+	Message  string
+}
+
+func (i LintIssue) String() string {
+	location := i.Field
+	if i.Line > 0 {
+		location = fmt.Sprintf("%s:%d", i.Field, i.Line)
+	}
+	return fmt.Sprintf("%s: %s [%s] %s", location, i.Severity, i.Rule, i.Message)
+}
+
+// LintRule checks one aspect of a prompt.
+// The check receives the prompt and every other prompt in the vault.
+type LintRule struct {
+	Name     string
+	Severity Severity
+	check    func(prompt *Prompt, others []Prompt) []LintIssue
+}
+
+// LintReport holds the issues found in a single prompt of the vault.
+type LintReport struct {
+	Prompt Prompt
+	Issues []LintIssue
+}
+
+// matches template placeholders such as {{name}} and {{ name }}
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([^{}]*?)\s*\}\}`)
+
+// matches the names that are valid for template variables
+var variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// name of the rule that flags credentials in the prompt content
+const RulePossibleSecret = "possible-secret"
+
+// The rules run by Lint, in the order their issues are reported.
+var LintRules = []LintRule{
+	{Name: "duplicate-title", Severity: SeverityWarning, check: checkDuplicateTitle},
+	{Name: "unbalanced-braces", Severity: SeverityError, check: checkUnbalancedBraces},
+	{Name: "undefined-variable", Severity: SeverityWarning, check: checkUndefinedVariables},
+	{Name: "unused-variable", Severity: SeverityInfo, check: checkUnusedVariables},
+	{Name: "broken-include", Severity: SeverityError, check: checkIncludes},
+	{Name: "trailing-whitespace", Severity: SeverityInfo, check: checkTrailingWhitespace},
+	{Name: "long-line", Severity: SeverityInfo, check: checkLongLines},
+	{Name: RulePossibleSecret, Severity: SeverityError, check: checkSecrets},
+}
+
+// Runs every lint rule against the prompt.
+// others is the rest of the vault and may include the prompt itself,
+// which is recognised by its ID and ignored.
+func Lint(prompt *Prompt, others []Prompt) []LintIssue {
+	issues := []LintIssue{}
+	for _, rule := range LintRules {
+		for _, issue := range rule.check(prompt, others) {
+			issue.Rule = rule.Name
+			issue.Severity = rule.Severity
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// Lints every prompt of the vault and returns the reports
+// of the prompts that have at least one issue.
+func LintAll(prompts []Prompt) []LintReport {
+	reports := []LintReport{}
+	for i := range prompts {
+		issues := Lint(&prompts[i], prompts)
+		if len(issues) > 0 {
+			reports = append(reports, LintReport{Prompt: prompts[i], Issues: issues})
+		}
+	}
+	return reports
+}
+
+// Returns the highest severity among the issues.
+// ok is false if there are no issues at all.
+func MaxSeverity(issues []LintIssue) (severity Severity, ok bool) {
+	for _, issue := range issues {
+		if !ok || issue.Severity > severity {
+			severity = issue.Severity
+			ok = true
+		}
+	}
+	return severity, ok
+}
+
+// Returns the names of the template variables used in the content,
+// sorted and without duplicates.
+func TemplateVariables(content string) []string {
+	seen := map[string]bool{}
+	names := []string{}
+	for _, match := range placeholderPattern.FindAllStringSubmatch(content, -1) {
+		name := match[1]
+		if !variableNamePattern.MatchString(name) || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func checkDuplicateTitle(prompt *Prompt, others []Prompt) []LintIssue {
+	title := strings.ToLower(strings.TrimSpace(prompt.Title))
+	if title == "" {
+		return nil
+	}
+
+	for _, other := range others {
+		if prompt.ID != 0 && other.ID == prompt.ID {
+			continue
+		}
+		if strings.ToLower(strings.TrimSpace(other.Title)) == title {
+			return []LintIssue{{
+				Field:   FieldTitle,
+				Message: fmt.Sprintf("title is also used by prompt #%d", other.ID),
+			}}
+		}
+	}
+	return nil
+}
+
+func checkUnbalancedBraces(prompt *Prompt, _ []Prompt) []LintIssue {
+	issues := []LintIssue{}
+
+	// line of every "{{" that is still waiting for its "}}"
+	open := []int{}
+	for n, line := range strings.Split(prompt.PromptContent, "\n") {
+		for i := 0; i < len(line)-1; i++ {
+			switch line[i : i+2] {
+			case "{{":
+				open = append(open, n+1)
+				i++
+			case "}}":
+				if len(open) == 0 {
+					issues = append(issues, LintIssue{
+						Field:   FieldContent,
+						Line:    n + 1,
+						Message: `"}}" has no matching "{{"`,
+					})
+				} else {
+					open = open[:len(open)-1]
+				}
+				i++
+			}
+		}
+	}
+
+	for _, line := range open {
+		issues = append(issues, LintIssue{
+			Field:   FieldContent,
+			Line:    line,
+			Message: `"{{" is never closed`,
+		})
+	}
+	return issues
+}
+
+func checkUndefinedVariables(prompt *Prompt, _ []Prompt) []LintIssue {
+	declared := map[string]bool{}
+	for _, name := range prompt.Variables {
+		declared[name] = true
+	}
+
+	issues := []LintIssue{}
+	reported := map[string]bool{}
+	for n, line := range strings.Split(prompt.PromptContent, "\n") {
+		for _, match := range placeholderPattern.FindAllStringSubmatch(line, -1) {
+			name := match[1]
+			if !variableNamePattern.MatchString(name) || declared[name] || reported[name] {
+				continue
+			}
+			reported[name] = true
+			issues = append(issues, LintIssue{
+				Field:   FieldContent,
+				Line:    n + 1,
+				Message: fmt.Sprintf("variable %q is used but not declared", name),
+			})
+		}
+	}
+	return issues
+}
+
+func checkUnusedVariables(prompt *Prompt, _ []Prompt) []LintIssue {
+	used := map[string]bool{}
+	for _, name := range TemplateVariables(prompt.PromptContent) {
+		used[name] = true
+	}
+
+	issues := []LintIssue{}
+	for _, name := range prompt.Variables {
+		if !used[name] {
+			issues = append(issues, LintIssue{
+				Field:   FieldVariables,
+				Message: fmt.Sprintf("variable %q is declared but never used", name),
+			})
+		}
+	}
+	return issues
+}
+
+func checkIncludes(prompt *Prompt, others []Prompt) []LintIssue {
+	if len(Includes(prompt.PromptContent)) == 0 {
+		return nil
+	}
+
+	// resolve against the vault as it would be after saving this prompt
+	prompts := []Prompt{*prompt}
+	for _, other := range others {
+		if prompt.ID == 0 || other.ID != prompt.ID {
+			prompts = append(prompts, other)
+		}
+	}
+
+	if _, err := ExpandIncludes(&prompts[0], prompts); err != nil {
+		return []LintIssue{{Field: FieldContent, Message: err.Error()}}
+	}
+	return nil
+}
+
+func checkTrailingWhitespace(prompt *Prompt, _ []Prompt) []LintIssue {
+	issues := []LintIssue{}
+	for n, line := range strings.Split(prompt.PromptContent, "\n") {
+		if line != strings.TrimRight(line, " \t\r") {
+			issues = append(issues, LintIssue{
+				Field:   FieldContent,
+				Line:    n + 1,
+				Message: "line has trailing whitespace",
+			})
+		}
+	}
+	return issues
+}
+
+func checkLongLines(prompt *Prompt, _ []Prompt) []LintIssue {
+	issues := []LintIssue{}
+	for n, line := range strings.Split(prompt.PromptContent, "\n") {
+		if length := utf8.RuneCountInString(line); length > MaxLineLength {
+			issues = append(issues, LintIssue{
+				Field:   FieldContent,
+				Line:    n + 1,
+				Message: fmt.Sprintf("line is %d characters long (max %d)", length, MaxLineLength),
+			})
+		}
+	}
+	return issues
+}
+
+func checkSecrets(prompt *Prompt, _ []Prompt) []LintIssue {
+	issues := []LintIssue{}
+	for _, finding := range ScanSecrets(prompt.PromptContent) {
+		issues = append(issues, LintIssue{
+			Field:   FieldContent,
+			Line:    finding.Line,
+			Message: fmt.Sprintf("looks like it contains a %s", finding.Kind),
+		})
+	}
+	return issues
+}
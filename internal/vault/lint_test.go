@@ -0,0 +1,102 @@
+package vault_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name   string // description of this test case
+		prompt vault.Prompt
+		others []vault.Prompt
+		// rules that must be reported, in order
+		wantRules []string
+	}{
+		{
+			name: "Clean prompt has no issues",
+			prompt: vault.Prompt{
+				ID:            1,
+				Title:         "reviewer",
+				PromptContent: "Review the {{language}} code.",
+				Variables:     []string{"language"},
+			},
+			wantRules: []string{},
+		},
+		{
+			name:      "Duplicate title ignores case and the prompt itself",
+			prompt:    vault.Prompt{ID: 1, Title: "Reviewer", PromptContent: "content"},
+			others:    []vault.Prompt{{ID: 1, Title: "Reviewer"}, {ID: 2, Title: " reviewer "}},
+			wantRules: []string{"duplicate-title"},
+		},
+		{
+			name:      "Unbalanced braces",
+			prompt:    vault.Prompt{Title: "t", PromptContent: "{{open\nclose}}}}"},
+			wantRules: []string{"unbalanced-braces"},
+		},
+		{
+			name:      "Undefined and unused variables",
+			prompt:    vault.Prompt{Title: "t", PromptContent: "Hello {{name}} and {{ name }}", Variables: []string{"tone"}},
+			wantRules: []string{"undefined-variable", "unused-variable"},
+		},
+		{
+			name:      "Trailing whitespace and long lines",
+			prompt:    vault.Prompt{Title: "t", PromptContent: "trailing \n" + strings.Repeat("x", vault.MaxLineLength+1)},
+			wantRules: []string{"trailing-whitespace", "long-line"},
+		},
+		{
+			name:      "Likely secret",
+			prompt:    vault.Prompt{Title: "t", PromptContent: "use key sk-ant-REDACTED"},
+			wantRules: []string{"possible-secret"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := vault.Lint(&tt.prompt, tt.others)
+
+			gotRules := []string{}
+			for _, issue := range got {
+				if len(gotRules) == 0 || gotRules[len(gotRules)-1] != issue.Rule {
+					gotRules = append(gotRules, issue.Rule)
+				}
+			}
+			if strings.Join(gotRules, ",") != strings.Join(tt.wantRules, ",") {
+				t.Errorf("Lint() rules = %v, want %v (issues: %v)", gotRules, tt.wantRules, got)
+			}
+		})
+	}
+}
+
+func TestLint_Severity(t *testing.T) {
+	prompt := vault.Prompt{Title: "t", PromptContent: "{{unclosed \nAKIAABCDEFGHIJKLMNOP"}
+	issues := vault.Lint(&prompt, nil)
+
+	got, ok := vault.MaxSeverity(issues)
+	if !ok || got != vault.SeverityError {
+		t.Errorf("MaxSeverity() = %v, %v, want %v, true", got, ok, vault.SeverityError)
+	}
+
+	if _, ok := vault.MaxSeverity(nil); ok {
+		t.Error("MaxSeverity(nil) reported an issue")
+	}
+}
+
+func TestLintAll(t *testing.T) {
+	prompts := []vault.Prompt{
+		{ID: 1, Title: "same", PromptContent: "a"},
+		{ID: 2, Title: "same", PromptContent: "b"},
+		{ID: 3, Title: "other", PromptContent: "c"},
+	}
+
+	reports := vault.LintAll(prompts)
+	if len(reports) != 2 {
+		t.Fatalf("LintAll() returned %d reports, want 2", len(reports))
+	}
+	for _, report := range reports {
+		if report.Prompt.ID == 3 {
+			t.Errorf("LintAll() reported the clean prompt %d", report.Prompt.ID)
+		}
+	}
+}
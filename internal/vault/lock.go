@@ -0,0 +1,55 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// how long to wait for another process to release a lock,
+// the same as for the bolt database
+const lockTimeout = time.Second
+
+// how often a busy lock is tried again
+const lockRetryInterval = 10 * time.Millisecond
+
+// the lock is held by another process
+var errLocked = errors.New("locked by another process")
+
+// Locks the file at path, creating it if needed, shared or exclusive.
+// It waits for other processes to release the lock until lockTimeout or
+// until ctx is done. The returned function releases the lock.
+func lockFile(ctx context.Context, path string, exclusive bool) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		err := tryLock(f, exclusive)
+		if err == nil {
+			return func() {
+				unlock(f)
+				f.Close()
+			}, nil
+		}
+		if !errors.Is(err, errLocked) {
+			f.Close()
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("%s: timed out waiting for the lock", path)
+		}
+
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// delimits the front matter of a markdown prompt file
+const frontMatterDelimiter = "---"
+
+// Encodes a prompt as a markdown file: front matter holding the metadata,
+// followed by the prompt content as is.
+//
+// The front matter is YAML, with every string written as a double quoted
+// scalar (which is also valid JSON) so that titles with colons, quotes or
+// leading dashes never need special casing. The ID is left out as it only
+// means something inside the vault that assigned it.
+func MarshalMarkdown(prompt Prompt) []byte {
+	return marshalMarkdown(prompt, false)
+}
+
+// encodes a prompt as a markdown file, with its id when the file is
+// the storage of the vault rather than a copy of it
+func marshalMarkdown(prompt Prompt, withID bool) []byte {
+	var b bytes.Buffer
+	b.WriteString(frontMatterDelimiter + "\n")
+
+	writeField := func(key string, value any) {
+		encoded, _ := json.Marshal(value)
+		fmt.Fprintf(&b, "%s: %s\n", key, encoded)
+	}
+
+	if withID {
+		writeField("id", prompt.ID)
+	}
+	writeField("uuid", prompt.UUID)
+	writeField("title", prompt.Title)
+	writeField("slug", prompt.Slug)
+	if len(prompt.Aliases) > 0 {
+		writeField("aliases", prompt.Aliases)
+	}
+	if prompt.Description != "" {
+		writeField("description", prompt.Description)
+	}
+	if len(prompt.Variables) > 0 {
+		writeField("variables", prompt.Variables)
+	}
+	if len(prompt.Tags) > 0 {
+		writeField("tags", prompt.Tags)
+	}
+	if prompt.Collection != "" {
+		writeField("collection", prompt.Collection)
+	}
+	if prompt.Pinned {
+		writeField("pinned", true)
+	}
+	if !prompt.CreatedAt.IsZero() {
+		writeField("created", prompt.CreatedAt.UTC())
+	}
+	if !prompt.UpdatedAt.IsZero() {
+		writeField("updated", prompt.UpdatedAt.UTC())
+	}
+	// usage is local to the vault, like the id
+	if withID && prompt.Uses > 0 {
+		writeField("uses", prompt.Uses)
+		writeField("last_used", prompt.LastUsedAt.UTC())
+	}
+
+	b.WriteString(frontMatterDelimiter + "\n")
+	b.WriteString(prompt.PromptContent)
+	b.WriteString("\n")
+	return b.Bytes()
+}
+
+// Decodes a markdown file written by MarshalMarkdown.
+// The ID is only set if the front matter has one.
+// Hand written front matter may leave strings unquoted, unknown keys are ignored.
+func UnmarshalMarkdown(data []byte) (Prompt, error) {
+	prompt := Prompt{}
+
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	if lines[0] != frontMatterDelimiter {
+		return prompt, errors.New("missing front matter")
+	}
+
+	end := slices.Index(lines[1:], frontMatterDelimiter) + 1
+	if end == 0 {
+		return prompt, errors.New("front matter is never closed")
+	}
+
+	// the newline ending the file is not part of the content
+	content := strings.Join(lines[end+1:], "\n")
+	prompt.PromptContent = strings.TrimSuffix(content, "\n")
+
+	for n, line := range lines[1:end] {
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return prompt, fmt.Errorf("front matter line %d: expected key: value", n+2)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		var err error
+		switch key {
+		case "id":
+			prompt.ID, err = strconv.Atoi(value)
+		case "uuid":
+			prompt.UUID, err = decodeString(value)
+		case "title":
+			prompt.Title, err = decodeString(value)
+		case "slug":
+			prompt.Slug, err = decodeString(value)
+		case "description":
+			prompt.Description, err = decodeString(value)
+		case "aliases":
+			err = json.Unmarshal([]byte(value), &prompt.Aliases)
+		case "variables":
+			err = json.Unmarshal([]byte(value), &prompt.Variables)
+		case "tags":
+			err = json.Unmarshal([]byte(value), &prompt.Tags)
+		case "collection":
+			prompt.Collection, err = decodeString(value)
+		case "pinned":
+			prompt.Pinned, err = strconv.ParseBool(value)
+		case "created":
+			prompt.CreatedAt, err = decodeTime(value)
+		case "updated":
+			prompt.UpdatedAt, err = decodeTime(value)
+		case "uses":
+			prompt.Uses, err = strconv.Atoi(value)
+		case "last_used":
+			prompt.LastUsedAt, err = decodeTime(value)
+		}
+		if err != nil {
+			return prompt, fmt.Errorf("front matter line %d: %s: %w", n+2, key, err)
+		}
+	}
+
+	return prompt, nil
+}
+
+// decodes a quoted or plain front matter string
+func decodeString(value string) (string, error) {
+	if strings.HasPrefix(value, `"`) {
+		var s string
+		err := json.Unmarshal([]byte(value), &s)
+		return s, err
+	}
+	return value, nil
+}
+
+// decodes a quoted or plain RFC 3339 time
+func decodeTime(value string) (time.Time, error) {
+	s, err := decodeString(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
@@ -0,0 +1,746 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// files of a markdown vault that are not prompts
+const (
+	// locked while the directory is read or written, across processes
+	markdownLockFile = ".pvt.lock"
+
+	// highest id ever assigned, so that ids of deleted prompts are never reused
+	markdownSequenceFile = ".pvt-sequence"
+
+	// prompts taken out by merges, in files named after their id. the time
+	// a file was last modified is the time the prompt was trashed.
+	markdownTrashDir = ".trash"
+)
+
+// Repository keeping every prompt in its own markdown file, named after
+// its slug, with the metadata in YAML front matter (see MarshalMarkdown).
+// The files can be read, grepped and edited by hand.
+type markdownRepository struct {
+	dir    string
+	logger *slog.Logger
+
+	// the lock file serialises processes, this serialises goroutines
+	mu sync.RWMutex
+}
+
+// a prompt and the file it was read from
+type promptFile struct {
+	name   string
+	prompt Prompt
+}
+
+// Creates a repository storing prompts as markdown files in dir.
+// Files added by hand are given an id, a uuid and a slug when it is opened.
+func NewMarkdownRepository(dir string, logger *slog.Logger) (PromptRepository, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, storageError("create directory", err)
+	}
+
+	repo := &markdownRepository{dir: dir, logger: logger}
+	if err := repo.adopt(context.Background()); err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// runs fn with every prompt file of the directory, holding the lock
+func (repo *markdownRepository) withLock(ctx context.Context, exclusive bool, fn func(files []promptFile) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if exclusive {
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+	} else {
+		repo.mu.RLock()
+		defer repo.mu.RUnlock()
+	}
+
+	unlock, err := lockFile(ctx, filepath.Join(repo.dir, markdownLockFile), exclusive)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		repo.logger.Error("failed to lock vault directory", "error", err)
+		return storageError("lock directory", err)
+	}
+	defer unlock()
+
+	files, err := repo.load()
+	if err != nil {
+		return err
+	}
+	return fn(files)
+}
+
+// reads every prompt file of the directory.
+// files that cannot be decoded are skipped, never overwritten.
+func (repo *markdownRepository) load() ([]promptFile, error) {
+	entries, err := os.ReadDir(repo.dir)
+	if err != nil {
+		repo.logger.Error("failed to read vault directory", "error", err)
+		return nil, storageError("read directory", err)
+	}
+
+	files := []promptFile{}
+	ids := map[int]string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) != ".md" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(repo.dir, name))
+		if err != nil {
+			repo.logger.Error("failed to read prompt file", "file", name, "error", err)
+			return nil, storageError("read prompt", err)
+		}
+		prompt, err := readPromptFile(name, data)
+		if err != nil {
+			repo.logger.Warn("skipping undecodable prompt file", "file", name, "error", err)
+			continue
+		}
+		if other, ok := ids[prompt.ID]; ok && prompt.ID != 0 {
+			repo.logger.Warn("skipping prompt file with a duplicate id", "file", name, "id", prompt.ID, "other", other)
+			continue
+		}
+		ids[prompt.ID] = name
+		files = append(files, promptFile{name: name, prompt: prompt})
+	}
+	return files, nil
+}
+
+// decodes a prompt file. plain markdown without front matter is a prompt
+// of its own, titled after the file.
+func readPromptFile(name string, data []byte) (Prompt, error) {
+	if !bytes.HasPrefix(data, []byte(frontMatterDelimiter)) {
+		content := strings.TrimSuffix(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+		return Prompt{Title: strings.TrimSuffix(name, ".md"), PromptContent: content}, nil
+	}
+
+	prompt, err := UnmarshalMarkdown(data)
+	if err == nil && prompt.Title == "" {
+		prompt.Title = strings.TrimSuffix(name, ".md")
+	}
+	return prompt, err
+}
+
+// a batch of changes to the directory, applied by commit
+type markdownChanges struct {
+	repo     *markdownRepository
+	files    map[int]promptFile
+	slugs    mapIndex
+	uuids    mapIndex
+	sequence int
+
+	written []int    // ids of the prompts to write
+	removed []string // files to remove
+	trashed []Prompt // prompts to move to the trash
+}
+
+func (repo *markdownRepository) changes(files []promptFile) (*markdownChanges, error) {
+	c := &markdownChanges{repo: repo, files: map[int]promptFile{}}
+
+	prompts := make([]Prompt, 0, len(files))
+	for _, f := range files {
+		// files written by hand have no id until they are adopted
+		if f.prompt.ID == 0 {
+			continue
+		}
+		c.files[f.prompt.ID] = f
+		prompts = append(prompts, f.prompt)
+		c.sequence = max(c.sequence, f.prompt.ID)
+	}
+	c.slugs, c.uuids = indexPrompts(prompts)
+
+	sequence, err := repo.readSequence()
+	if err != nil {
+		return nil, err
+	}
+	c.sequence = max(c.sequence, sequence)
+	return c, nil
+}
+
+// assigns the id, uuid, slug and timestamps of the prompt, like the bolt
+// repository does, and schedules it to be written
+func (c *markdownChanges) put(prompt *Prompt) error {
+	var stored *Prompt
+	if f, ok := c.files[prompt.ID]; ok && prompt.ID != 0 {
+		stored = &f.prompt
+	}
+
+	if prompt.ID == 0 {
+		c.sequence++
+		prompt.ID = c.sequence
+		// imported prompts keep the time they were first created
+		if prompt.CreatedAt.IsZero() {
+			prompt.CreatedAt = time.Now()
+		}
+	}
+	prompt.UpdatedAt = time.Now()
+	c.sequence = max(c.sequence, prompt.ID)
+
+	keepUsage(prompt, stored)
+	if err := assignUUID(c.uuids, prompt, stored); err != nil {
+		return err
+	}
+	if err := assignSlug(c.slugs, prompt, stored); err != nil {
+		return err
+	}
+
+	c.store(*prompt)
+	return nil
+}
+
+// schedules the prompt to be written as it is
+func (c *markdownChanges) store(prompt Prompt) {
+	name := markdownFileName(prompt)
+	if old, ok := c.files[prompt.ID]; ok && old.name != name {
+		c.removed = append(c.removed, old.name)
+	}
+	c.files[prompt.ID] = promptFile{name: name, prompt: prompt}
+	c.written = append(c.written, prompt.ID)
+}
+
+// schedules the file of the prompt to be removed, freeing its slugs
+func (c *markdownChanges) remove(id int) error {
+	f, ok := c.files[id]
+	if !ok {
+		return notFoundError(id)
+	}
+	unindexSlugs(c.slugs, &f.prompt)
+	unindexUUID(c.uuids, &f.prompt)
+	delete(c.files, id)
+	c.removed = append(c.removed, f.name)
+	return nil
+}
+
+// schedules the file of the prompt to be moved to the trash
+func (c *markdownChanges) trash(id int) error {
+	f, ok := c.files[id]
+	if !ok {
+		return notFoundError(id)
+	}
+	c.trashed = append(c.trashed, f.prompt)
+	return c.remove(id)
+}
+
+// writes the scheduled changes. every file is written to a temporary one
+// first, and only once they all are they are renamed in place, so that a
+// batch failing to be written leaves the directory as it was.
+func (c *markdownChanges) commit() error {
+	repo := c.repo
+
+	// temporary files by the file they replace, the trash first so that a
+	// prompt is never gone from both, and the sequence last so that ids
+	// are never reused even if the renames stop half way
+	type staged struct{ tmp, path string }
+	temps := []staged{}
+	defer func() {
+		// no-ops for the files renamed
+		for _, t := range temps {
+			os.Remove(t.tmp)
+		}
+	}()
+
+	if len(c.trashed) > 0 {
+		if err := os.MkdirAll(filepath.Join(repo.dir, markdownTrashDir), 0700); err != nil {
+			repo.logger.Error("failed to create trash directory", "error", err)
+			return storageError("create trash", err)
+		}
+	}
+	for _, p := range c.trashed {
+		path := repo.trashPath(p.ID)
+		tmp, err := writeTemp(path, marshalMarkdown(p, true), 0600)
+		if err != nil {
+			repo.logger.Error("failed to write trashed prompt", "id", p.ID, "error", err)
+			return storageError("write trash", err)
+		}
+		temps = append(temps, staged{tmp, path})
+	}
+
+	written := map[string]bool{}
+	for _, id := range c.written {
+		f := c.files[id]
+		path := filepath.Join(repo.dir, f.name)
+		tmp, err := writeTemp(path, marshalMarkdown(f.prompt, true), 0600)
+		if err != nil {
+			repo.logger.Error("failed to write prompt file", "file", f.name, "error", err)
+			return storageError("write prompt", err)
+		}
+		temps = append(temps, staged{tmp, path})
+		written[f.name] = true
+	}
+	path := filepath.Join(repo.dir, markdownSequenceFile)
+	tmp, err := writeTemp(path, []byte(strconv.Itoa(c.sequence)+"\n"), 0600)
+	if err != nil {
+		repo.logger.Error("failed to write sequence", "error", err)
+		return storageError("write sequence", err)
+	}
+	temps = append(temps, staged{tmp, path})
+
+	for _, t := range temps {
+		if err := os.Rename(t.tmp, t.path); err != nil {
+			repo.logger.Error("failed to replace file", "file", filepath.Base(t.path), "error", err)
+			return storageError("write prompt", err)
+		}
+	}
+
+	for _, name := range c.removed {
+		// the name may have been taken over by another prompt of the batch
+		if written[name] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(repo.dir, name)); err != nil && !os.IsNotExist(err) {
+			repo.logger.Error("failed to remove prompt file", "file", name, "error", err)
+			return storageError("remove prompt", err)
+		}
+	}
+	if len(c.trashed) > 0 {
+		if err := syncDir(filepath.Join(repo.dir, markdownTrashDir)); err != nil {
+			return err
+		}
+	}
+	return syncDir(repo.dir)
+}
+
+// path of the trash file of the prompt with the id
+func (repo *markdownRepository) trashPath(id int) string {
+	return filepath.Join(repo.dir, markdownTrashDir, strconv.Itoa(id)+".md")
+}
+
+// reads a file of the trash directory
+func (repo *markdownRepository) readTrash(name string) (TrashedPrompt, error) {
+	path := filepath.Join(repo.dir, markdownTrashDir, name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return TrashedPrompt{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return TrashedPrompt{}, err
+	}
+	prompt, err := readPromptFile(name, data)
+	return TrashedPrompt{Prompt: prompt, TrashedAt: info.ModTime()}, err
+}
+
+// name of the file of a prompt, slugs are unique and safe in file names
+func markdownFileName(prompt Prompt) string {
+	return prompt.Slug + ".md"
+}
+
+func (repo *markdownRepository) readSequence() (int, error) {
+	data, err := os.ReadFile(filepath.Join(repo.dir, markdownSequenceFile))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		repo.logger.Error("failed to read sequence", "error", err)
+		return 0, storageError("read sequence", err)
+	}
+	sequence, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		repo.logger.Error("failed to decode sequence", "error", err)
+		return 0, storageError("decode sequence", err)
+	}
+	return sequence, nil
+}
+
+// gives prompt files written by hand what the vault needs to track them.
+// the slug comes from the file name, so that the file keeps its name.
+func (repo *markdownRepository) adopt(ctx context.Context) error {
+	return repo.withLock(ctx, true, func(files []promptFile) error {
+		c, err := repo.changes(files)
+		if err != nil {
+			return err
+		}
+
+		for _, f := range files {
+			p := f.prompt
+			if p.ID != 0 && p.UUID != "" && p.Slug != "" {
+				continue
+			}
+
+			if p.ID == 0 {
+				c.sequence++
+				p.ID = c.sequence
+			}
+			if owner, ok := c.uuids.owner(p.UUID); p.UUID == "" || (ok && owner != p.ID) {
+				p.UUID = NewUUID()
+			}
+			c.uuids.put(p.UUID, p.ID)
+			if p.Slug == "" || f.prompt.ID == 0 {
+				base := p.Slug
+				if base == "" {
+					base = Slugify(strings.TrimSuffix(f.name, ".md"))
+				}
+				p.Slug = uniqueSlug(c.slugs, base, p.ID)
+				c.slugs.put(p.Slug, p.ID)
+			}
+			if p.CreatedAt.IsZero() || p.UpdatedAt.IsZero() {
+				info, err := os.Stat(filepath.Join(repo.dir, f.name))
+				if err != nil {
+					return storageError("read prompt", err)
+				}
+				p.CreatedAt, p.UpdatedAt = info.ModTime(), info.ModTime()
+			}
+
+			repo.logger.Info("adopting prompt file", "file", f.name, "id", p.ID)
+			// the file is keyed by name here, its id was unknown until now
+			c.files[p.ID] = f
+			c.store(p)
+		}
+
+		if len(c.written) == 0 {
+			return nil
+		}
+		return c.commit()
+	})
+}
+
+// create or update a prompt
+func (repo *markdownRepository) CreateOrUpdatePrompt(ctx context.Context, prompt *Prompt) (*Prompt, error) {
+	// work on a copy, so that a failed write leaves the caller's prompt as it was
+	saved := *prompt
+
+	err := repo.withLock(ctx, true, func(files []promptFile) error {
+		c, err := repo.changes(files)
+		if err != nil {
+			return err
+		}
+		if err := c.put(&saved); err != nil {
+			return err
+		}
+		return c.commit()
+	})
+	if err == nil {
+		*prompt = saved
+		repo.logger.Debug("saved prompt", "id", saved.ID, "slug", saved.Slug)
+	}
+	return prompt, err
+}
+
+// create or update a batch of prompts. nothing is written if any of them
+// fails or the context is cancelled before the files are written.
+func (repo *markdownRepository) CreateOrUpdatePrompts(ctx context.Context, prompts []Prompt) ([]Prompt, error) {
+	saved := make([]Prompt, len(prompts))
+	copy(saved, prompts)
+
+	err := repo.withLock(ctx, true, func(files []promptFile) error {
+		c, err := repo.changes(files)
+		if err != nil {
+			return err
+		}
+		for i := range saved {
+			if err := ctx.Err(); err != nil {
+				repo.logger.Warn("bulk write cancelled, nothing written", "error", err)
+				return err
+			}
+			if err := c.put(&saved[i]); err != nil {
+				return err
+			}
+		}
+		return c.commit()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// delete the prompt
+func (repo *markdownRepository) DeletePrompt(ctx context.Context, id int) error {
+	return repo.withLock(ctx, true, func(files []promptFile) error {
+		for _, f := range files {
+			if f.prompt.ID != id {
+				continue
+			}
+			if err := os.Remove(filepath.Join(repo.dir, f.name)); err != nil {
+				repo.logger.Error("failed to remove prompt file", "id", id, "file", f.name, "error", err)
+				return storageError("remove prompt", err)
+			}
+			repo.logger.Debug("deleted prompt", "id", id, "file", f.name)
+			return syncDir(repo.dir)
+		}
+		return notFoundError(id)
+	})
+}
+
+// moves the prompts with the ids to the trash, then creates or updates
+// prompts. nothing is written or removed if any of them fails.
+func (repo *markdownRepository) ReplacePrompts(ctx context.Context, prompts []Prompt, trashed []int) ([]Prompt, error) {
+	saved := make([]Prompt, len(prompts))
+	copy(saved, prompts)
+
+	err := repo.withLock(ctx, true, func(files []promptFile) error {
+		c, err := repo.changes(files)
+		if err != nil {
+			return err
+		}
+		for _, id := range trashed {
+			if err := c.trash(id); err != nil {
+				return err
+			}
+		}
+		for i := range saved {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := c.put(&saved[i]); err != nil {
+				return err
+			}
+		}
+		return c.commit()
+	})
+	if err != nil {
+		return nil, err
+	}
+	repo.logger.Debug("replaced prompts", "written", len(saved), "trashed", len(trashed))
+	return saved, nil
+}
+
+// get the prompts in the trash, most recently trashed first
+func (repo *markdownRepository) GetTrash(ctx context.Context) ([]TrashedPrompt, error) {
+	trash := []TrashedPrompt{}
+	err := repo.withLock(ctx, false, func([]promptFile) error {
+		entries, err := os.ReadDir(filepath.Join(repo.dir, markdownTrashDir))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			repo.logger.Error("failed to read trash directory", "error", err)
+			return storageError("read trash", err)
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) != ".md" {
+				continue
+			}
+			trashed, err := repo.readTrash(name)
+			if err != nil {
+				repo.logger.Error("failed to read trashed prompt", "file", name, "error", err)
+				return storageError("read trash", err)
+			}
+			trash = append(trash, trashed)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortTrash(trash)
+	return trash, nil
+}
+
+// puts the prompt with the id back from the trash, under the same id
+func (repo *markdownRepository) RestoreTrashed(ctx context.Context, id int) (*Prompt, error) {
+	var prompt *Prompt
+	err := repo.withLock(ctx, true, func(files []promptFile) error {
+		trashed, err := repo.readTrash(strconv.Itoa(id) + ".md")
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: prompt %d is not in the trash", ErrNotFound, id)
+		}
+		if err != nil {
+			repo.logger.Error("failed to read trashed prompt", "id", id, "error", err)
+			return storageError("read trash", err)
+		}
+
+		c, err := repo.changes(files)
+		if err != nil {
+			return err
+		}
+		if _, ok := c.files[id]; ok {
+			return fmt.Errorf("cannot restore prompt %d, the id is in use", id)
+		}
+
+		prompt = &trashed.Prompt
+		prompt.ID = id
+		untrash(c.slugs, prompt)
+		if err := c.put(prompt); err != nil {
+			return err
+		}
+		if err := c.commit(); err != nil {
+			return err
+		}
+		if err := os.Remove(repo.trashPath(id)); err != nil {
+			repo.logger.Error("failed to remove trashed prompt", "id", id, "error", err)
+			return storageError("remove from trash", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	repo.logger.Info("restored prompt from the trash", "id", id, "slug", prompt.Slug)
+	return prompt, nil
+}
+
+// get a prompt by its id
+func (repo *markdownRepository) GetPromptByID(ctx context.Context, id int) (*Prompt, error) {
+	return repo.find(ctx, notFoundError(id), func(p *Prompt) bool {
+		return p.ID == id
+	})
+}
+
+// get a prompt by its slug, or one of its former slugs
+func (repo *markdownRepository) GetPromptBySlug(ctx context.Context, slug string) (*Prompt, error) {
+	return repo.find(ctx, fmt.Errorf("%w: slug %q", ErrNotFound, slug), func(p *Prompt) bool {
+		if p.Slug == slug {
+			return true
+		}
+		for _, alias := range p.Aliases {
+			if alias == slug {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// get a prompt by its uuid
+func (repo *markdownRepository) GetPromptByUUID(ctx context.Context, uuid string) (*Prompt, error) {
+	return repo.find(ctx, fmt.Errorf("%w: uuid %q", ErrNotFound, uuid), func(p *Prompt) bool {
+		return p.UUID == uuid
+	})
+}
+
+// returns the first prompt matching, or notFound
+func (repo *markdownRepository) find(ctx context.Context, notFound error, match func(p *Prompt) bool) (*Prompt, error) {
+	var prompt *Prompt
+	err := repo.withLock(ctx, false, func(files []promptFile) error {
+		for i := range files {
+			if match(&files[i].prompt) {
+				prompt = &files[i].prompt
+				return nil
+			}
+		}
+		return notFound
+	})
+	if err != nil {
+		return nil, err
+	}
+	return prompt, nil
+}
+
+// get all prompts, most recently updated first
+func (repo *markdownRepository) GetAllPrompts(ctx context.Context) ([]Prompt, error) {
+	prompts := []Prompt{}
+	err := repo.withLock(ctx, false, func(files []promptFile) error {
+		for _, f := range files {
+			prompts = append(prompts, f.prompt)
+		}
+		return nil
+	})
+
+	// newest first, like the bolt repository
+	sort.Slice(prompts, func(i, j int) bool {
+		return prompts[i].ID > prompts[j].ID
+	})
+	sort.SliceStable(prompts, func(i, j int) bool {
+		return prompts[i].UpdatedAt.After(prompts[j].UpdatedAt)
+	})
+	return prompts, err
+}
+
+// counts a use of the prompt, leaving its update time alone
+func (repo *markdownRepository) RecordUse(ctx context.Context, id int) (*Prompt, error) {
+	var prompt *Prompt
+	err := repo.withLock(ctx, true, func(files []promptFile) error {
+		c, err := repo.changes(files)
+		if err != nil {
+			return err
+		}
+		f, ok := c.files[id]
+		if !ok {
+			return notFoundError(id)
+		}
+
+		used := f.prompt
+		recordUse(&used)
+		c.store(used)
+		if err := c.commit(); err != nil {
+			return err
+		}
+		prompt = &used
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return prompt, nil
+}
+
+// get a page of the prompts matching the query.
+// the files are not indexed, so every prompt is read and filtered.
+func (repo *markdownRepository) QueryPrompts(ctx context.Context, query Query) (Page, error) {
+	if err := query.normalize(); err != nil {
+		return Page{}, err
+	}
+	prompts, err := repo.GetAllPrompts(ctx)
+	if err != nil {
+		return Page{}, err
+	}
+	return queryPrompts(prompts, query)
+}
+
+// ranks the prompts by relevance to the query, see rankPrompts
+func (repo *markdownRepository) RankPrompts(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	prompts, err := repo.GetAllPrompts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return rankPrompts(prompts, query, limit), nil
+}
+
+// stores the prompts and the trash as they are, for conversions from
+// another backend
+func (repo *markdownRepository) RestorePrompts(ctx context.Context, prompts []Prompt, trash []TrashedPrompt) error {
+	return repo.withLock(ctx, true, func(files []promptFile) error {
+		if len(files) > 0 {
+			return fmt.Errorf("cannot restore into %s, it already holds prompts", repo.dir)
+		}
+
+		c, err := repo.changes(files)
+		if err != nil {
+			return err
+		}
+		for _, p := range prompts {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			c.sequence = max(c.sequence, p.ID)
+			c.store(p)
+		}
+		for _, t := range trash {
+			c.sequence = max(c.sequence, t.Prompt.ID)
+			c.trashed = append(c.trashed, t.Prompt)
+		}
+		if err := c.commit(); err != nil {
+			return err
+		}
+
+		// the files tell when their prompts were trashed
+		for _, t := range trash {
+			if err := os.Chtimes(repo.trashPath(t.Prompt.ID), t.TrashedAt, t.TrashedAt); err != nil {
+				return storageError("write trash", err)
+			}
+		}
+		return nil
+	})
+}
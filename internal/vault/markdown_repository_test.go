@@ -0,0 +1,342 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+func openTestMarkdown(t *testing.T, dir string) PromptRepository {
+	t.Helper()
+
+	repo, err := NewMarkdownRepository(dir, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func TestMarkdownRepository_Integration(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	repo := openTestMarkdown(t, dir)
+
+	first := &Prompt{Title: "House Style", PromptContent: "Be concise."}
+	second := &Prompt{Title: "House style!", PromptContent: "Be brief."}
+	for _, p := range []*Prompt{first, second} {
+		if _, err := repo.CreateOrUpdatePrompt(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if first.ID != 1 || second.ID != 2 || !ValidUUID(first.UUID) || first.CreatedAt.IsZero() {
+		t.Fatalf("saved prompts = %+v, %+v, want ids, uuids and timestamps assigned", first, second)
+	}
+	if first.Slug != "house-style" || second.Slug != "house-style-2" {
+		t.Fatalf("slugs = %q, %q, want house-style, house-style-2", first.Slug, second.Slug)
+	}
+
+	// one readable file per prompt, named after its slug
+	data, err := os.ReadFile(filepath.Join(dir, "house-style.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := UnmarshalMarkdown(data); err != nil || got.Title != "House Style" || got.PromptContent != "Be concise." {
+		t.Errorf("house-style.md = %+v, %v", got, err)
+	}
+
+	// renaming moves the file and keeps the old slug as an alias
+	first.Slug = "style-guide"
+	if _, err := repo.CreateOrUpdatePrompt(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "house-style.md")); !os.IsNotExist(err) {
+		t.Errorf("house-style.md still exists after the rename: %v", err)
+	}
+	if got, err := repo.GetPromptBySlug(ctx, "house-style"); err != nil || got.ID != first.ID {
+		t.Errorf("GetPromptBySlug(old slug) = %+v, %v, want prompt %d", got, err, first.ID)
+	}
+	if got, err := repo.GetPromptByUUID(ctx, second.UUID); err != nil || got.ID != second.ID {
+		t.Errorf("GetPromptByUUID() = %+v, %v, want prompt %d", got, err, second.ID)
+	}
+
+	// ids of deleted prompts are never reused
+	if err := repo.DeletePrompt(ctx, second.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeletePrompt(ctx, second.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeletePrompt() twice error = %v, want ErrNotFound", err)
+	}
+	third := &Prompt{Title: "Third", PromptContent: "c"}
+	if _, err := repo.CreateOrUpdatePrompt(ctx, third); err != nil {
+		t.Fatal(err)
+	}
+	if third.ID != 3 {
+		t.Errorf("new prompt id = %d, want 3", third.ID)
+	}
+
+	// a taken uuid is rejected and nothing is written
+	if _, err := repo.CreateOrUpdatePrompts(ctx, []Prompt{
+		{Title: "Fine", PromptContent: "c"},
+		{Title: "Clash", PromptContent: "c", UUID: first.UUID},
+	}); !errors.Is(err, ErrValidation) {
+		t.Errorf("CreateOrUpdatePrompts() error = %v, want ErrValidation", err)
+	}
+	if prompts, err := repo.GetAllPrompts(ctx); err != nil || len(prompts) != 2 {
+		t.Errorf("GetAllPrompts() = %d prompts, %v, want 2", len(prompts), err)
+	}
+}
+
+func TestMarkdownRepository_FailedBatch_Integration(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	repo := openTestMarkdown(t, dir)
+
+	// the file of the second prompt fits in a file name, its temporary
+	// file does not
+	long := strings.Repeat("a", 245)
+	_, err := repo.CreateOrUpdatePrompts(ctx, []Prompt{
+		{Title: "Free", PromptContent: "x"},
+		{Title: long, PromptContent: "y"},
+	})
+	if !errors.Is(err, ErrStorage) {
+		t.Fatalf("CreateOrUpdatePrompts() = %v, want a storage error", err)
+	}
+
+	// nothing of the batch was written, and no temporary file is left
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Name() != markdownLockFile {
+			t.Errorf("%s left in the vault after a failed batch", e.Name())
+		}
+	}
+	if prompts, err := repo.GetAllPrompts(ctx); err != nil || len(prompts) != 0 {
+		t.Errorf("GetAllPrompts() = %d prompts, %v, want none", len(prompts), err)
+	}
+}
+
+func TestMarkdownRepository_HandWrittenFiles_Integration(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	files := map[string]string{
+		// no front matter at all
+		"Review Checklist.md": "Check the tests.\n",
+		// front matter without the bookkeeping
+		"notes.md": "---\ntitle: \"Notes\"\n---\nSome notes.\n",
+		// cannot be decoded, skipped
+		"broken.md": "---\ntitle: \"unterminated\n",
+		// not a prompt
+		"README.txt": "hello",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repo := openTestMarkdown(t, dir)
+	prompts, err := repo.GetAllPrompts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prompts) != 2 {
+		t.Fatalf("GetAllPrompts() = %+v, want the 2 readable prompt files", prompts)
+	}
+
+	notes, err := repo.GetPromptBySlug(ctx, "notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if notes.ID == 0 || !ValidUUID(notes.UUID) || notes.PromptContent != "Some notes." || notes.CreatedAt.IsZero() {
+		t.Errorf("adopted prompt = %+v, want an id, uuid and timestamps", notes)
+	}
+
+	// the file got a slug from its name and was renamed after it
+	review, err := repo.GetPromptBySlug(ctx, "review-checklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "review-checklist.md")); err != nil {
+		t.Errorf("adopted file was not renamed after its slug: %v", err)
+	}
+
+	// opening again keeps what was assigned
+	again, err := openTestMarkdown(t, dir).GetPromptByID(ctx, review.ID)
+	if err != nil || again.UUID != review.UUID || again.Slug != review.Slug {
+		t.Errorf("reopened prompt = %+v, %v, want %+v", again, err, review)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "broken.md")); err != nil {
+		t.Errorf("undecodable file was touched: %v", err)
+	}
+}
+
+func TestMarkdownRepository_Concurrent_Integration(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// two repositories on the same directory, like two pvt processes
+	repos := []PromptRepository{openTestMarkdown(t, dir), openTestMarkdown(t, dir)}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := &Prompt{Title: fmt.Sprintf("Prompt %d", i), PromptContent: "c"}
+			if _, err := repos[i%2].CreateOrUpdatePrompt(ctx, p); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	prompts, err := repos[0].GetAllPrompts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[int]bool{}
+	for _, p := range prompts {
+		ids[p.ID] = true
+	}
+	if len(prompts) != 20 || len(ids) != 20 {
+		t.Errorf("got %d prompts with %d distinct ids, want 20", len(prompts), len(ids))
+	}
+}
+
+func TestConvertRepository_Integration(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	source := NewPromptRepository(openTestDB(t), logger)
+
+	prompts := []Prompt{
+		{Title: "House Style", Description: "d", PromptContent: "Be {{tone}}.", Variables: []string{"tone"}},
+		{Title: "Deleted", PromptContent: "c"},
+		{Title: "Review", PromptContent: "{{> house-style}}\nReview it."},
+		{Title: "Trashed", PromptContent: "c"},
+	}
+	saved, err := source.CreateOrUpdatePrompts(ctx, prompts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := source.DeletePrompt(ctx, saved[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	// and so must the trash
+	if _, err := source.ReplacePrompts(ctx, nil, []int{saved[3].ID}); err != nil {
+		t.Fatal(err)
+	}
+	// an alias must survive as well
+	saved[0].Slug = "style"
+	if _, err := source.CreateOrUpdatePrompt(ctx, &saved[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	// bolt to markdown and back, nothing may change on the way
+	markdown := openTestMarkdown(t, t.TempDir())
+	back := NewPromptRepository(openTestDB(t), logger)
+	for _, to := range []PromptRepository{markdown, back} {
+		from := source
+		if to == back {
+			from = markdown
+		}
+		if n, err := ConvertRepository(ctx, from, to); err != nil || n != 2 {
+			t.Fatalf("ConvertRepository() = %d, %v, want 2", n, err)
+		}
+	}
+
+	want, _ := source.GetAllPrompts(ctx)
+	got, err := back.GetAllPrompts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("converted %d prompts, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !samePromptRecord(got[i], want[i]) {
+			t.Errorf("converted prompt = %+v, want %+v", got[i], want[i])
+		}
+	}
+	if p, err := back.GetPromptBySlug(ctx, "house-style"); err != nil || p.ID != saved[0].ID {
+		t.Errorf("GetPromptBySlug(alias) = %+v, %v", p, err)
+	}
+	if trash, err := back.GetTrash(ctx); err != nil || len(trash) != 1 || !samePromptRecord(trash[0].Prompt, saved[3]) {
+		t.Errorf("converted trash = %+v, %v, want %+v", trash, err, saved[3])
+	}
+
+	// new prompts do not reuse the id of the deleted one, nor the trashed one
+	next := &Prompt{Title: "Next", PromptContent: "c"}
+	if _, err := back.CreateOrUpdatePrompt(ctx, next); err != nil {
+		t.Fatal(err)
+	}
+	if next.ID != 5 {
+		t.Errorf("new prompt id = %d, want 5", next.ID)
+	}
+
+	// the target must be empty
+	if _, err := ConvertRepository(ctx, source, back); err == nil {
+		t.Error("ConvertRepository() into a non empty repository succeeded")
+	}
+}
+
+func TestConvertRepository_BadRecords(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tests := []struct {
+		name  string // description of this test case
+		key   uint64
+		value func(saved []Prompt) []byte
+	}{
+		{"undecodable", 2, func([]Prompt) []byte { return []byte("{not json") }},
+		{"holding another prompt", 9, func(saved []Prompt) []byte {
+			value, _ := json.Marshal(saved[0])
+			return value
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			source := NewPromptRepository(db, logger)
+			saved, err := source.CreateOrUpdatePrompts(ctx, []Prompt{
+				{Title: "House Style", PromptContent: "c"},
+				{Title: "Review", PromptContent: "c"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = db.Update(func(tx *bolt.Tx) error {
+				return tx.Bucket(promptsBucket).Put(itob(tt.key), tt.value(saved))
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			target := openTestMarkdown(t, t.TempDir())
+			if n, err := ConvertRepository(ctx, source, target); err == nil || !strings.Contains(err.Error(), "pvt doctor --repair") {
+				t.Errorf("ConvertRepository() = %d, %v, want it to fail pointing at the repair", n, err)
+			}
+			if prompts, err := target.GetAllPrompts(ctx); err != nil || len(prompts) != 0 {
+				t.Errorf("target holds %d prompts, %v, want none", len(prompts), err)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package vault_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+)
+
+func TestMarkdown_RoundTrip(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []vault.Prompt{
+		{
+			UUID:          vault.NewUUID(),
+			Title:         `Review: "the" code`,
+			Slug:          "review-the-code",
+			Aliases:       []string{"review"},
+			Description:   "--- not a delimiter",
+			Variables:     []string{"language", "tone"},
+			PromptContent: "Review this {{language}} code.\n---\nBe {{tone}}.\n",
+			CreatedAt:     created,
+			UpdatedAt:     created.Add(time.Hour),
+		},
+		{
+			UUID:          vault.NewUUID(),
+			Title:         "Minimal",
+			PromptContent: "one line",
+		},
+	}
+	for _, want := range tests {
+		t.Run(want.Title, func(t *testing.T) {
+			got, err := vault.UnmarshalMarkdown(vault.MarshalMarkdown(want))
+			if err != nil {
+				t.Fatalf("UnmarshalMarkdown() failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("round trip = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalMarkdown_HandWritten(t *testing.T) {
+	got, err := vault.UnmarshalMarkdown([]byte("---\r\ntitle: House Style\r\n# a comment\r\nowner: someone\r\n---\r\nBe concise.\r\n"))
+	if err != nil {
+		t.Fatalf("UnmarshalMarkdown() failed: %v", err)
+	}
+	if got.Title != "House Style" || got.PromptContent != "Be concise." {
+		t.Errorf("UnmarshalMarkdown() = %+v", got)
+	}
+
+	for _, doc := range []string{"no front matter", "---\ntitle: x\n", "---\njust text\n---\n"} {
+		if _, err := vault.UnmarshalMarkdown([]byte(doc)); err == nil {
+			t.Errorf("UnmarshalMarkdown(%q) succeeded, want an error", doc)
+		}
+	}
+}
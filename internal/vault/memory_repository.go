@@ -0,0 +1,316 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Repository keeping prompts in memory only, for tests and tools that need
+// a vault without touching the disk. It behaves like the other repositories
+// and is safe for concurrent use.
+type MemoryRepository struct {
+	mu       sync.RWMutex
+	prompts  map[int]Prompt
+	slugs    mapIndex
+	uuids    mapIndex
+	trash    map[int]TrashedPrompt
+	sequence int
+}
+
+// Creates an empty in-memory repository.
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{prompts: map[int]Prompt{}, slugs: mapIndex{}, uuids: mapIndex{}, trash: map[int]TrashedPrompt{}}
+}
+
+// creates or updates a prompt
+func (repo *MemoryRepository) CreateOrUpdatePrompt(ctx context.Context, prompt *Prompt) (*Prompt, error) {
+	saved, err := repo.CreateOrUpdatePrompts(ctx, []Prompt{*prompt})
+	if err != nil {
+		return prompt, err
+	}
+	*prompt = saved[0]
+	return prompt, nil
+}
+
+// creates or updates a batch of prompts, all of them or none
+func (repo *MemoryRepository) CreateOrUpdatePrompts(ctx context.Context, prompts []Prompt) ([]Prompt, error) {
+	return repo.replace(ctx, prompts, nil, false)
+}
+
+// assigns the id, uuid, slug and timestamps of the prompt and stores it
+func (repo *MemoryRepository) put(prompt *Prompt) error {
+	var stored *Prompt
+	if p, ok := repo.prompts[prompt.ID]; ok && prompt.ID != 0 {
+		stored = &p
+	}
+
+	if prompt.ID == 0 {
+		repo.sequence++
+		prompt.ID = repo.sequence
+		// imported prompts keep the time they were first created
+		if prompt.CreatedAt.IsZero() {
+			prompt.CreatedAt = time.Now()
+		}
+	}
+	prompt.UpdatedAt = time.Now()
+	repo.sequence = max(repo.sequence, prompt.ID)
+
+	keepUsage(prompt, stored)
+	if err := assignUUID(repo.uuids, prompt, stored); err != nil {
+		return err
+	}
+	if err := assignSlug(repo.slugs, prompt, stored); err != nil {
+		return err
+	}
+	repo.prompts[prompt.ID] = clonePrompt(*prompt)
+	return nil
+}
+
+// deletes the prompt
+func (repo *MemoryRepository) DeletePrompt(ctx context.Context, id int) error {
+	_, err := repo.replace(ctx, nil, []int{id}, false)
+	return err
+}
+
+// moves the prompts with the ids to the trash, then creates or updates
+// prompts, all of them or none
+func (repo *MemoryRepository) ReplacePrompts(ctx context.Context, prompts []Prompt, trashed []int) ([]Prompt, error) {
+	return repo.replace(ctx, prompts, trashed, true)
+}
+
+// removes the prompts with the ids, to the trash or for good, then creates
+// or updates prompts, all of them or none
+func (repo *MemoryRepository) replace(ctx context.Context, prompts []Prompt, removed []int, trash bool) ([]Prompt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	// work on copies, swapped in once every change is made
+	next := repo.clone()
+	for _, id := range removed {
+		stored, ok := next.prompts[id]
+		if !ok {
+			return nil, notFoundError(id)
+		}
+		unindexSlugs(next.slugs, &stored)
+		unindexUUID(next.uuids, &stored)
+		delete(next.prompts, id)
+		if trash {
+			next.trash[id] = TrashedPrompt{Prompt: stored, TrashedAt: time.Now()}
+		}
+	}
+
+	saved := make([]Prompt, len(prompts))
+	copy(saved, prompts)
+	for i := range saved {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := next.put(&saved[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	repo.swap(next)
+	return saved, nil
+}
+
+// copies the state of the repository, for changes made all at once by swap
+func (repo *MemoryRepository) clone() *MemoryRepository {
+	return &MemoryRepository{
+		prompts:  maps.Clone(repo.prompts),
+		slugs:    maps.Clone(repo.slugs),
+		uuids:    maps.Clone(repo.uuids),
+		trash:    maps.Clone(repo.trash),
+		sequence: repo.sequence,
+	}
+}
+
+// takes the state of a clone
+func (repo *MemoryRepository) swap(next *MemoryRepository) {
+	repo.prompts, repo.slugs, repo.uuids, repo.trash, repo.sequence = next.prompts, next.slugs, next.uuids, next.trash, next.sequence
+}
+
+// gets the prompts in the trash, most recently trashed first
+func (repo *MemoryRepository) GetTrash(ctx context.Context) ([]TrashedPrompt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	trash := make([]TrashedPrompt, 0, len(repo.trash))
+	for _, t := range repo.trash {
+		t.Prompt = clonePrompt(t.Prompt)
+		trash = append(trash, t)
+	}
+	sortTrash(trash)
+	return trash, nil
+}
+
+// puts the prompt with the id back from the trash, under the same id
+func (repo *MemoryRepository) RestoreTrashed(ctx context.Context, id int) (*Prompt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	trashed, ok := repo.trash[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: prompt %d is not in the trash", ErrNotFound, id)
+	}
+	if _, ok := repo.prompts[id]; ok {
+		return nil, fmt.Errorf("cannot restore prompt %d, the id is in use", id)
+	}
+
+	next := repo.clone()
+	prompt := clonePrompt(trashed.Prompt)
+	untrash(next.slugs, &prompt)
+	if err := next.put(&prompt); err != nil {
+		return nil, err
+	}
+	delete(next.trash, id)
+	repo.swap(next)
+	return &prompt, nil
+}
+
+// gets a prompt by its id
+func (repo *MemoryRepository) GetPromptByID(ctx context.Context, id int) (*Prompt, error) {
+	return repo.get(ctx, id, true, notFoundError(id))
+}
+
+// gets a prompt by its slug, or one of its former slugs
+func (repo *MemoryRepository) GetPromptBySlug(ctx context.Context, slug string) (*Prompt, error) {
+	repo.mu.RLock()
+	id, ok := repo.slugs.owner(slug)
+	repo.mu.RUnlock()
+	return repo.get(ctx, id, ok, fmt.Errorf("%w: slug %q", ErrNotFound, slug))
+}
+
+// gets a prompt by its uuid
+func (repo *MemoryRepository) GetPromptByUUID(ctx context.Context, uuid string) (*Prompt, error) {
+	repo.mu.RLock()
+	id, ok := repo.uuids.owner(uuid)
+	repo.mu.RUnlock()
+	return repo.get(ctx, id, ok, fmt.Errorf("%w: uuid %q", ErrNotFound, uuid))
+}
+
+// returns a copy of the prompt with the id, or notFound
+func (repo *MemoryRepository) get(ctx context.Context, id int, ok bool, notFound error) (*Prompt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	prompt, exists := repo.prompts[id]
+	if !ok || !exists {
+		return nil, notFound
+	}
+	prompt = clonePrompt(prompt)
+	return &prompt, nil
+}
+
+// gets all prompts, most recently updated first
+func (repo *MemoryRepository) GetAllPrompts(ctx context.Context) ([]Prompt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	prompts := make([]Prompt, 0, len(repo.prompts))
+	for _, p := range repo.prompts {
+		prompts = append(prompts, clonePrompt(p))
+	}
+	sort.Slice(prompts, func(i, j int) bool {
+		if !prompts[i].UpdatedAt.Equal(prompts[j].UpdatedAt) {
+			return prompts[i].UpdatedAt.After(prompts[j].UpdatedAt)
+		}
+		return prompts[i].ID > prompts[j].ID
+	})
+	return prompts, nil
+}
+
+// gets a page of the prompts matching the query
+func (repo *MemoryRepository) QueryPrompts(ctx context.Context, query Query) (Page, error) {
+	if err := query.normalize(); err != nil {
+		return Page{}, err
+	}
+	prompts, err := repo.GetAllPrompts(ctx)
+	if err != nil {
+		return Page{}, err
+	}
+	return queryPrompts(prompts, query)
+}
+
+// ranks the prompts by relevance to the query, see rankPrompts
+func (repo *MemoryRepository) RankPrompts(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	prompts, err := repo.GetAllPrompts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return rankPrompts(prompts, query, limit), nil
+}
+
+// counts a use of the prompt, leaving its update time alone
+func (repo *MemoryRepository) RecordUse(ctx context.Context, id int) (*Prompt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	prompt, ok := repo.prompts[id]
+	if !ok {
+		return nil, notFoundError(id)
+	}
+	recordUse(&prompt)
+	repo.prompts[id] = prompt
+
+	prompt = clonePrompt(prompt)
+	return &prompt, nil
+}
+
+// stores the prompts and the trash as they are, for conversions from
+// another backend
+func (repo *MemoryRepository) RestorePrompts(ctx context.Context, prompts []Prompt, trash []TrashedPrompt) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if len(repo.prompts) > 0 {
+		return fmt.Errorf("cannot restore into a repository that already holds prompts")
+	}
+	for _, p := range prompts {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		repo.prompts[p.ID] = clonePrompt(p)
+		repo.sequence = max(repo.sequence, p.ID)
+	}
+	for _, t := range trash {
+		t.Prompt = clonePrompt(t.Prompt)
+		repo.trash[t.Prompt.ID] = t
+		repo.sequence = max(repo.sequence, t.Prompt.ID)
+	}
+	repo.slugs, repo.uuids = indexPrompts(prompts)
+	return nil
+}
+
+// copies the slices of a prompt, so that callers never share them with the repository
+func clonePrompt(p Prompt) Prompt {
+	if p.Aliases != nil {
+		p.Aliases = append([]string{}, p.Aliases...)
+	}
+	if p.Variables != nil {
+		p.Variables = append([]string{}, p.Variables...)
+	}
+	return p
+}
@@ -0,0 +1,145 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/boltdb/bolt"
+)
+
+// bucket holding bookkeeping about the database itself
+var metaBucket = []byte("meta")
+
+// key of the schema version in the meta bucket
+var schemaVersionKey = []byte("schema_version")
+
+// a schema change, applied once to every database
+type migration struct {
+	version int
+	name    string
+	run     func(tx *bolt.Tx) error
+}
+
+// every migration, in the order they must be applied.
+// never reorder or remove entries, only append new ones.
+var migrations = []migration{
+	{version: 1, name: "backfill slugs", run: migrateSlugs},
+	{version: 2, name: "backfill uuids", run: migrateUUIDs},
+	{version: 3, name: "build query indexes", run: rebuildQueryIndexes},
+	{version: 4, name: "index usage", run: rebuildQueryIndexes},
+	{version: 5, name: "index search terms", run: rebuildQueryIndexes},
+}
+
+// Migrate brings the database up to the latest schema.
+// Each migration runs in its own transaction and records the new
+// schema version in the same transaction, so a failed migration leaves
+// the database at the previous version and is retried on the next start.
+func Migrate(db *bolt.DB, logger *slog.Logger) error {
+	for _, m := range migrations {
+		err := db.Update(func(tx *bolt.Tx) error {
+			meta, err := tx.CreateBucketIfNotExists(metaBucket)
+			if err != nil {
+				return err
+			}
+
+			if schemaVersion(meta) >= m.version {
+				return nil
+			}
+
+			logger.Info("migrating database", "version", m.version, "migration", m.name)
+			if err := m.run(tx); err != nil {
+				return err
+			}
+			return meta.Put(schemaVersionKey, []byte(strconv.Itoa(m.version)))
+		})
+		if err != nil {
+			logger.Error("migration failed", "version", m.version, "migration", m.name, "error", err)
+			return storageError(fmt.Sprintf("migrate to version %d (%s)", m.version, m.name), err)
+		}
+	}
+	return nil
+}
+
+// reads the schema version from the meta bucket, 0 if it was never migrated
+func schemaVersion(meta *bolt.Bucket) int {
+	version, err := strconv.Atoi(string(meta.Get(schemaVersionKey)))
+	if err != nil {
+		return 0
+	}
+	return version
+}
+
+// gives every prompt created before slugs existed a unique slug
+func migrateSlugs(tx *bolt.Tx) error {
+	slugs, err := tx.CreateBucketIfNotExists(slugsBucket)
+	if err != nil {
+		return err
+	}
+
+	return updateEachPrompt(tx, func(prompt *Prompt) (bool, error) {
+		if prompt.Slug != "" {
+			return false, nil
+		}
+		prompt.Slug = uniqueSlug(boltIndex{slugs}, Slugify(prompt.Title), prompt.ID)
+		return true, slugs.Put([]byte(prompt.Slug), itob(uint64(prompt.ID)))
+	})
+}
+
+// gives every prompt created before uuids existed a fresh uuid
+func migrateUUIDs(tx *bolt.Tx) error {
+	uuids, err := tx.CreateBucketIfNotExists(uuidsBucket)
+	if err != nil {
+		return err
+	}
+
+	return updateEachPrompt(tx, func(prompt *Prompt) (bool, error) {
+		if prompt.UUID != "" {
+			return false, nil
+		}
+		prompt.UUID = NewUUID()
+		return true, uuids.Put([]byte(prompt.UUID), itob(uint64(prompt.ID)))
+	})
+}
+
+// calls fn with every decodable prompt, in id order, and writes back
+// the prompts it reports as changed. undecodable records are left alone.
+func updateEachPrompt(tx *bolt.Tx, fn func(prompt *Prompt) (bool, error)) error {
+	bucket, err := tx.CreateBucketIfNotExists(promptsBucket)
+	if err != nil {
+		return err
+	}
+
+	// collect first, bolt does not allow writing while iterating
+	prompts := []Prompt{}
+	err = bucket.ForEach(func(k, v []byte) error {
+		prompt := Prompt{}
+		if err := json.Unmarshal(v, &prompt); err == nil {
+			prompts = append(prompts, prompt)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range prompts {
+		changed, err := fn(&prompts[i])
+		if err != nil {
+			return err
+		}
+		if !changed {
+			continue
+		}
+
+		encoded, err := json.Marshal(prompts[i])
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put(itob(uint64(prompts[i].ID)), encoded); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -4,12 +4,30 @@ import "time"
 
 // Prompt struct
 type Prompt struct {
-	ID            int
-	Title         string
+	ID int
+	// identifies the prompt across vaults, IDs are only unique within one
+	UUID  string `json:",omitempty"`
+	Title string
+	// unique, human friendly identifier, derived from the title unless set
+	Slug string `json:",omitempty"`
+	// former slugs of the prompt, they keep resolving to it
+	Aliases       []string `json:",omitempty"`
 	Description   string
 	PromptContent string
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
+	// names of the {{variables}} the content is expected to use
+	Variables []string `json:",omitempty"`
+	// lowercase labels to filter by, sorted
+	Tags []string `json:",omitempty"`
+	// the collection the prompt is filed under, "" for none
+	Collection string `json:",omitempty"`
+	// pinned prompts can be listed on their own
+	Pinned    bool `json:",omitempty"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// how many times the prompt was copied and when it last was.
+	// kept by the repository, see RecordUse.
+	Uses       int       `json:",omitempty"`
+	LastUsedAt time.Time `json:",omitzero"`
 }
 
 // prompts array for fuzzy search
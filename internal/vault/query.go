@@ -0,0 +1,228 @@
+package vault
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SortKey is what a query orders prompts by.
+type SortKey string
+
+const (
+	// most recently updated first, the order of GetAllPrompts
+	SortUpdated SortKey = "updated"
+
+	// most recently created first
+	SortCreated SortKey = "created"
+
+	// alphabetically, case insensitive
+	SortTitle SortKey = "title"
+
+	// most used first
+	SortUses SortKey = "uses"
+)
+
+// SortKeys lists the sort keys, the default first.
+var SortKeys = []SortKey{SortUpdated, SortCreated, SortTitle, SortUses}
+
+// the cursor of a page, a query field for validation errors
+const FieldCursor = "cursor"
+
+// Query selects a page of prompts. The zero Query returns every prompt,
+// most recently updated first.
+type Query struct {
+	// only prompts having every one of these tags
+	Tags []string
+
+	// only prompts of this collection, any collection if ""
+	Collection string
+
+	// only pinned prompts
+	Pinned bool
+
+	// only prompts created or updated within these bounds, inclusive.
+	// zero times leave that side open.
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+
+	// order of the prompts, SortUpdated if empty. Reverse flips it.
+	Sort    SortKey
+	Reverse bool
+
+	// maximum number of prompts of the page, 0 for no limit
+	Limit int
+
+	// where the page starts, the Next of the previous page. "" for the first page.
+	Cursor string
+}
+
+// Page is the result of a query.
+type Page struct {
+	Prompts []Prompt
+
+	// cursor of the next page, "" if this is the last one
+	Next string
+
+	// ids of the records that could not be read and were left out,
+	// see pvt doctor
+	Skipped []int
+}
+
+// checks the query and fills in its defaults
+func (q *Query) normalize() error {
+	if q.Sort == "" {
+		q.Sort = SortUpdated
+	}
+	if !slices.Contains(SortKeys, q.Sort) {
+		return &ValidationError{Field: "sort", Message: fmt.Sprintf("must be one of %v", SortKeys)}
+	}
+	if q.Limit < 0 {
+		return &ValidationError{Field: "limit", Message: "cannot be negative"}
+	}
+	q.Tags = NormalizeTags(q.Tags)
+	q.Collection = strings.TrimSpace(q.Collection)
+	if q.Cursor != "" {
+		if _, err := q.decodeCursor(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reports whether the prompt passes the filters of the query
+func (q Query) matches(p *Prompt) bool {
+	for _, tag := range q.Tags {
+		if !slices.Contains(p.Tags, tag) {
+			return false
+		}
+	}
+	if q.Collection != "" && p.Collection != q.Collection {
+		return false
+	}
+	if q.Pinned && !p.Pinned {
+		return false
+	}
+	return within(p.CreatedAt, q.CreatedAfter, q.CreatedBefore) &&
+		within(p.UpdatedAt, q.UpdatedAfter, q.UpdatedBefore)
+}
+
+// reports whether t is within the inclusive bounds, zero bounds are open
+func within(t, after, before time.Time) bool {
+	return (after.IsZero() || !t.Before(after)) && (before.IsZero() || !t.After(before))
+}
+
+// position of a prompt in the order of a query, compared value first, then id
+type sortPosition struct {
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+// position of the prompt in the order of the query
+func (q Query) position(p *Prompt) sortPosition {
+	switch q.Sort {
+	case SortCreated:
+		return sortPosition{Value: timeSortValue(p.CreatedAt), ID: p.ID}
+	case SortTitle:
+		return sortPosition{Value: titleSortValue(p.Title), ID: p.ID}
+	case SortUses:
+		return sortPosition{Value: usageSortValue(p.Uses), ID: p.ID}
+	}
+	return sortPosition{Value: timeSortValue(p.UpdatedAt), ID: p.ID}
+}
+
+// times are compared as fixed width strings, so that every sort key
+// compares the same way and fits in a cursor
+func timeSortValue(t time.Time) string {
+	return fmt.Sprintf("%020d", uint64(t.UnixNano())^1<<63)
+}
+
+// titles sort case insensitively
+func titleSortValue(title string) string {
+	return strings.ToLower(title)
+}
+
+// compares two positions in the order of the query: times newest first,
+// uses most first and titles a to z, ties broken by id the same way
+func (q Query) compare(a, b sortPosition) int {
+	c := cmp.Or(strings.Compare(a.Value, b.Value), cmp.Compare(a.ID, b.ID))
+	if q.Sort != SortTitle {
+		c = -c
+	}
+	if q.Reverse {
+		c = -c
+	}
+	return c
+}
+
+// the position the page starts after, nil for the first page
+func (q Query) decodeCursor() (*sortPosition, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+	invalid := &ValidationError{Field: FieldCursor, Message: "is not a cursor of this query"}
+
+	data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, invalid
+	}
+	var c struct {
+		Sort    SortKey `json:"s"`
+		Reverse bool    `json:"r,omitempty"`
+		sortPosition
+	}
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort != q.Sort || c.Reverse != q.Reverse {
+		return nil, invalid
+	}
+	return &c.sortPosition, nil
+}
+
+// the cursor of the page starting after pos
+func (q Query) encodeCursor(pos sortPosition) string {
+	data, _ := json.Marshal(struct {
+		Sort    SortKey `json:"s"`
+		Reverse bool    `json:"r,omitempty"`
+		sortPosition
+	}{q.Sort, q.Reverse, pos})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// runs the query over prompts held in memory, for the repositories that
+// have no index to do better. q must be normalized.
+func queryPrompts(prompts []Prompt, q Query) (Page, error) {
+	after, err := q.decodeCursor()
+	if err != nil {
+		return Page{}, err
+	}
+
+	matched := []Prompt{}
+	for i := range prompts {
+		p := &prompts[i]
+		if !q.matches(p) {
+			continue
+		}
+		if after != nil && q.compare(q.position(p), *after) <= 0 {
+			continue
+		}
+		matched = append(matched, *p)
+	}
+	slices.SortFunc(matched, func(a, b Prompt) int {
+		return q.compare(q.position(&a), q.position(&b))
+	})
+	return q.page(matched), nil
+}
+
+// cuts the sorted prompts following the cursor down to a page
+func (q Query) page(prompts []Prompt) Page {
+	if q.Limit == 0 || len(prompts) <= q.Limit {
+		return Page{Prompts: prompts}
+	}
+	prompts = prompts[:q.Limit]
+	return Page{Prompts: prompts, Next: q.encodeCursor(q.position(&prompts[len(prompts)-1]))}
+}
@@ -0,0 +1,73 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestMigrate_BuildsQueryIndexes_Integration(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := openTestDB(t)
+
+	// a database from before the query indexes, at schema version 2
+	err := db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(promptsBucket)
+		if err != nil {
+			return err
+		}
+		for _, p := range []Prompt{
+			{ID: 1, UUID: NewUUID(), Title: "Zeta", Slug: "zeta", PromptContent: "c", Tags: []string{"review"}},
+			{ID: 2, UUID: NewUUID(), Title: "alpha", Slug: "alpha", PromptContent: "c", Collection: "work"},
+		} {
+			encoded, err := json.Marshal(p)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(itob(uint64(p.ID)), encoded); err != nil {
+				return err
+			}
+		}
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+		return meta.Put(schemaVersionKey, []byte("2"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Migrate(db, logger); err != nil {
+		t.Fatalf("Migrate() failed: %v", err)
+	}
+
+	repo := NewPromptRepository(db, logger)
+	tests := []struct {
+		query Query
+		want  []int
+	}{
+		{Query{Sort: SortTitle}, []int{2, 1}},
+		{Query{Tags: []string{"review"}}, []int{1}},
+		{Query{Collection: "work"}, []int{2}},
+	}
+	for _, tt := range tests {
+		page, err := repo.QueryPrompts(ctx, tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []int{}
+		for _, p := range page.Prompts {
+			ids = append(ids, p.ID)
+		}
+		if !slices.Equal(ids, tt.want) {
+			t.Errorf("QueryPrompts(%+v) = %v, want %v", tt.query, ids, tt.want)
+		}
+	}
+}
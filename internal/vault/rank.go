@@ -0,0 +1,210 @@
+package vault
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// SearchResult is a prompt found by a relevance search.
+type SearchResult struct {
+	Prompt Prompt
+
+	// how well the prompt matches the search, higher is better. scores
+	// only compare results of the same search.
+	Score float64
+}
+
+// BM25 parameters: how quickly repeating a term stops adding to the
+// score, and how much long prompts are held back
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// how much more a term counts in each field than in the content
+const (
+	titleWeight       = 3
+	tagWeight         = 3
+	collectionWeight  = 2
+	descriptionWeight = 2
+)
+
+// words too common to tell prompts apart
+var stopWords = map[string]bool{
+	"a": true, "about": true, "all": true, "an": true, "and": true, "any": true, "are": true,
+	"as": true, "at": true, "be": true, "but": true, "by": true, "can": true, "do": true,
+	"for": true, "from": true, "has": true, "have": true, "how": true, "i": true, "if": true,
+	"in": true, "into": true, "is": true, "it": true, "its": true, "me": true, "my": true,
+	"of": true, "on": true, "or": true, "our": true, "so": true, "that": true, "the": true,
+	"their": true, "them": true, "then": true, "there": true, "these": true, "they": true,
+	"this": true, "to": true, "up": true, "us": true, "was": true, "we": true, "what": true,
+	"when": true, "which": true, "while": true, "who": true, "will": true, "with": true,
+	"you": true, "your": true,
+}
+
+// the lowercase words of text that are worth searching for, in order
+func searchWords(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return slices.DeleteFunc(words, func(w string) bool { return stopWords[w] })
+}
+
+// the stems of the words of the query, each once
+func queryTerms(query string) []string {
+	terms := []string{}
+	for _, w := range searchWords(query) {
+		if term := stem(w); !slices.Contains(terms, term) {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// Returns the positions of the runes of text that match the terms, in
+// order, for highlighting: words in the same form as a word of a term
+// ("reviews" for "reviewing"), and wherever the text contains a term,
+// ignoring case.
+func MatchPositions(text string, terms []string) []int {
+	runes := []rune(text)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	matched := make([]bool, len(runes))
+
+	stems := map[string]bool{}
+	for _, term := range terms {
+		for _, w := range searchWords(term) {
+			stems[stem(w)] = true
+		}
+		// single letters would light up everywhere
+		needle := []rune(strings.ToLower(strings.TrimSpace(term)))
+		if len(needle) < 2 {
+			continue
+		}
+		for i := 0; i+len(needle) <= len(lower); i++ {
+			if slices.Equal(lower[i:i+len(needle)], needle) {
+				for j := range needle {
+					matched[i+j] = true
+				}
+			}
+		}
+	}
+
+	isWord := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+	for start := 0; start < len(lower); {
+		if !isWord(lower[start]) {
+			start++
+			continue
+		}
+		end := start
+		for end < len(lower) && isWord(lower[end]) {
+			end++
+		}
+		if stems[stem(string(lower[start:end]))] {
+			for j := start; j < end; j++ {
+				matched[j] = true
+			}
+		}
+		start = end
+	}
+
+	positions := []int{}
+	for i, m := range matched {
+		if m {
+			positions = append(positions, i)
+		}
+	}
+	return positions
+}
+
+// how often each term occurs in the prompt, weighted by the field it is in
+func promptTerms(p *Prompt) map[string]int {
+	terms := map[string]int{}
+	add := func(text string, weight int) {
+		for _, w := range searchWords(text) {
+			terms[stem(w)] += weight
+		}
+	}
+	add(p.Title, titleWeight)
+	add(p.Description, descriptionWeight)
+	add(p.Collection, collectionWeight)
+	for _, tag := range p.Tags {
+		add(tag, tagWeight)
+	}
+	add(p.PromptContent, 1)
+	return terms
+}
+
+// the weighted number of terms of a prompt
+func termsLength(terms map[string]int) int {
+	n := 0
+	for _, tf := range terms {
+		n += tf
+	}
+	return n
+}
+
+// the BM25 score of a term occurring tf times in a prompt of the given
+// length, and in df of the docs prompts
+func bm25(tf, df, docs, length int, avgLength float64) float64 {
+	idf := math.Log(1 + (float64(docs-df)+0.5)/(float64(df)+0.5))
+	norm := 1 - bm25B + bm25B*float64(length)/max(avgLength, 1)
+	return idf * float64(tf) * (bm25K1 + 1) / (float64(tf) + bm25K1*norm)
+}
+
+// best match first, the most recently updated of equal ones first
+func sortResults(results []SearchResult, limit int) []SearchResult {
+	slices.SortFunc(results, func(a, b SearchResult) int {
+		return cmp.Or(
+			cmp.Compare(b.Score, a.Score),
+			b.Prompt.UpdatedAt.Compare(a.Prompt.UpdatedAt),
+			cmp.Compare(a.Prompt.ID, b.Prompt.ID),
+		)
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// ranks the prompts against the query in memory, for repositories
+// without stored term statistics. a limit of 0 returns every match.
+func rankPrompts(prompts []Prompt, query string, limit int) []SearchResult {
+	results := []SearchResult{}
+	terms := queryTerms(query)
+	if len(terms) == 0 || len(prompts) == 0 {
+		return results
+	}
+
+	docs := make([]map[string]int, len(prompts))
+	df := map[string]int{}
+	total := 0
+	for i := range prompts {
+		docs[i] = promptTerms(&prompts[i])
+		total += termsLength(docs[i])
+		for _, term := range terms {
+			if docs[i][term] > 0 {
+				df[term]++
+			}
+		}
+	}
+
+	avgLength := float64(total) / float64(len(prompts))
+	for i := range prompts {
+		score, length := 0.0, termsLength(docs[i])
+		for _, term := range terms {
+			if tf := docs[i][term]; tf > 0 {
+				score += bm25(tf, df[term], len(prompts), length, avgLength)
+			}
+		}
+		if score > 0 {
+			results = append(results, SearchResult{Prompt: prompts[i], Score: score})
+		}
+	}
+	return sortResults(results, limit)
+}
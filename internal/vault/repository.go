@@ -1,20 +1,48 @@
 package vault
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
 	"encoding/json"
-	"errors"
 	"log/slog"
+	"fmt"
+	"slices"
 	"sort"
+	"strconv"
 	"time"
 	"github.com/boltdb/bolt"
 )
 
+// buckets of the bolt database
+var (
+	// prompt records keyed by id
+	promptsBucket = []byte("prompts")
+
+	// secondary index of slugs, current and former, to prompt ids
+	slugsBucket = []byte("slugs")
+
+	// secondary index of uuids to prompt ids
+	uuidsBucket = []byte("uuids")
+
+	// prompts taken out by merges, as TrashedPrompt records keyed by id
+	trashBucket = []byte("trash")
+)
+
 type PromptRepository interface {
-	CreateOrUpdatePrompt(prompt *Prompt) (*Prompt, error)
-	DeletePrompt(id int) error
-	GetPromptByID(id int) (*Prompt, error)
-	GetAllPrompts() ([]Prompt, error)
+	CreateOrUpdatePrompt(ctx context.Context, prompt *Prompt) (*Prompt, error)
+	CreateOrUpdatePrompts(ctx context.Context, prompts []Prompt) ([]Prompt, error)
+	DeletePrompt(ctx context.Context, id int) error
+	ReplacePrompts(ctx context.Context, prompts []Prompt, trashed []int) ([]Prompt, error)
+	GetTrash(ctx context.Context) ([]TrashedPrompt, error)
+	RestoreTrashed(ctx context.Context, id int) (*Prompt, error)
+	GetPromptByID(ctx context.Context, id int) (*Prompt, error)
+	GetPromptBySlug(ctx context.Context, slug string) (*Prompt, error)
+	GetPromptByUUID(ctx context.Context, uuid string) (*Prompt, error)
+	GetAllPrompts(ctx context.Context) ([]Prompt, error)
+	QueryPrompts(ctx context.Context, query Query) (Page, error)
+	RecordUse(ctx context.Context, id int) (*Prompt, error)
+	RankPrompts(ctx context.Context, query string, limit int) ([]SearchResult, error)
 }
 
 type promptRepository struct {
@@ -28,81 +56,286 @@ func NewPromptRepository(db *bolt.DB, logger *slog.Logger) PromptRepository {
 }
 
 // creates or updates an individual prompt
-func (repo *promptRepository) CreateOrUpdatePrompt(prompt *Prompt) (*Prompt, error) {
+func (repo *promptRepository) CreateOrUpdatePrompt(ctx context.Context, prompt *Prompt) (*Prompt, error) {
 	// get the prompt bucket
 	db := repo.db
 
+	// work on a copy, so that a rolled back write leaves the caller's prompt as it was
+	saved := *prompt
+
 	// write the prompt to the bucket
 	err := db.Update(func(tx *bolt.Tx) error {
-		bucket, err := tx.CreateBucketIfNotExists([]byte("prompts"))
-		if err != nil {
-			repo.logger.Error("failed to create bucket", "error", err)
+		// bail out before touching the bucket if the caller gave up
+		if err := ctx.Err(); err != nil {
 			return err
 		}
 
-		// check for existence
-		// if the id is 0, it means that it is a new prompt
-		if prompt.ID == 0 {
-			// create a unique key for the prompt
-			id, _ := bucket.NextSequence()
-			prompt.ID = int(id)
-			// set the created at time and update time
-			prompt.CreatedAt = time.Now()
-			prompt.UpdatedAt = time.Now()
-		} else {
-			// just update the update time
-			prompt.UpdatedAt = time.Now()
+		bucket, err := tx.CreateBucketIfNotExists(promptsBucket)
+		if err != nil {
+			repo.logger.Error("failed to create bucket", "error", err)
+			return storageError("create bucket", err)
 		}
 
-		// encode the prompt
-		encodedPrompt, err := json.Marshal(prompt)
+		return repo.putPrompt(tx, bucket, &saved)
+	})
+	if err == nil {
+		*prompt = saved
+		repo.logger.Debug("saved prompt", "id", saved.ID, "slug", saved.Slug)
+	}
+
+	return prompt, err
+}
+
+// creates or updates a batch of prompts in a single transaction.
+// the context is checked before every write, so cancelling mid-way
+// rolls the whole batch back and nothing is persisted.
+func (repo *promptRepository) CreateOrUpdatePrompts(ctx context.Context, prompts []Prompt) ([]Prompt, error) {
+	// work on a copy so that the caller's prompts are untouched
+	// if the transaction gets rolled back
+	saved := make([]Prompt, len(prompts))
+	copy(saved, prompts)
+
+	err := repo.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(promptsBucket)
 		if err != nil {
-			repo.logger.Error("failed to encode prompt", "error", err)
-			return err
+			repo.logger.Error("failed to create bucket", "error", err)
+			return storageError("create bucket", err)
 		}
 
-		// write the prompt to the bucket
-		key := itob(uint64(prompt.ID))
-		err = bucket.Put(key, encodedPrompt)
-		if err != nil {
-			repo.logger.Error("failed to write prompt to bucket", "error", err)
-			return err
+		for i := range saved {
+			if err := ctx.Err(); err != nil {
+				repo.logger.Warn("bulk write cancelled, rolling back", "written", i, "error", err)
+				return err
+			}
+			if err := repo.putPrompt(tx, bucket, &saved[i]); err != nil {
+				return err
+			}
 		}
 
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
 
-	return prompt, err
+	return saved, nil
 }
 
-// delete the prompt
-func (repo *promptRepository) DeletePrompt(id int) error {
-	// get the prompt bucket
-	db := repo.db
+// assigns the id, uuid, slug and timestamps of the prompt and writes it to the bucket,
+// keeping the indexes in sync. must be called inside a writable transaction.
+func (repo *promptRepository) putPrompt(tx *bolt.Tx, bucket *bolt.Bucket, prompt *Prompt) error {
+	slugs, err := tx.CreateBucketIfNotExists(slugsBucket)
+	if err != nil {
+		repo.logger.Error("failed to create bucket", "error", err)
+		return storageError("create bucket", err)
+	}
+	uuids, err := tx.CreateBucketIfNotExists(uuidsBucket)
+	if err != nil {
+		repo.logger.Error("failed to create bucket", "error", err)
+		return storageError("create bucket", err)
+	}
+
+	// the version currently stored, nil for new prompts
+	var stored *Prompt
+	if prompt.ID != 0 {
+		if value := bucket.Get(itob(uint64(prompt.ID))); value != nil {
+			stored = &Prompt{}
+			if err := json.Unmarshal(value, stored); err != nil {
+				// an undecodable record is simply overwritten
+				repo.logger.Warn("overwriting undecodable prompt", "id", prompt.ID, "error", err)
+				stored = nil
+			}
+		}
+	}
+
+	// check for existence
+	// if the id is 0, it means that it is a new prompt
+	if prompt.ID == 0 {
+		// create a unique key for the prompt
+		id, _ := bucket.NextSequence()
+		prompt.ID = int(id)
+		// set the created at time and update time.
+		// imported prompts keep the time they were first created.
+		if prompt.CreatedAt.IsZero() {
+			prompt.CreatedAt = time.Now()
+		}
+		prompt.UpdatedAt = time.Now()
+	} else {
+		// just update the update time
+		prompt.UpdatedAt = time.Now()
+	}
+
+	keepUsage(prompt, stored)
+	if err := assignUUID(boltIndex{uuids}, prompt, stored); err != nil {
+		return err
+	}
+	if err := assignSlug(boltIndex{slugs}, prompt, stored); err != nil {
+		return err
+	}
+
+	// encode the prompt
+	encodedPrompt, err := json.Marshal(prompt)
+	if err != nil {
+		repo.logger.Error("failed to encode prompt", "id", prompt.ID, "error", err)
+		return storageError("encode prompt", err)
+	}
 
-	err := db.Update(func(tx *bolt.Tx) error {
-		bucket, err := tx.CreateBucketIfNotExists([]byte("prompts"))
-		if err != nil {
-			repo.logger.Error("failed to create bucket", "error", err)
-			return err
+	// write the prompt to the bucket
+	key := itob(uint64(prompt.ID))
+	err = bucket.Put(key, encodedPrompt)
+	if err != nil {
+		repo.logger.Error("failed to write prompt to bucket", "id", prompt.ID, "error", err)
+		return storageError("write prompt", err)
+	}
+
+	// and move it in the query indexes
+	if stored != nil {
+		if err := unindexPrompt(tx, stored); err != nil {
+			repo.logger.Error("failed to update indexes", "id", prompt.ID, "error", err)
+			return storageError("update indexes", err)
 		}
+	}
+	if err := indexPrompt(tx, prompt); err != nil {
+		repo.logger.Error("failed to update indexes", "id", prompt.ID, "error", err)
+		return storageError("update indexes", err)
+	}
 
-		// delete the prompt
-		key := itob(uint64(id))
-		err = bucket.Delete(key)
-		if err != nil {
-			repo.logger.Error("failed to delete prompt", "error", err)
+	return nil
+}
+
+// delete the prompt
+func (repo *promptRepository) DeletePrompt(ctx context.Context, id int) error {
+	err := repo.db.Update(func(tx *bolt.Tx) error {
+		if err := ctx.Err(); err != nil {
 			return err
 		}
+		return repo.deletePrompt(tx, id)
+	})
+	if err == nil {
+		repo.logger.Debug("deleted prompt", "id", id)
+	}
+
+	return err
+}
+
+// moves the prompts with the ids to the trash and creates or updates
+// prompts, in a single transaction. the prompts are trashed first, so that
+// their slugs can be taken as aliases by the others.
+func (repo *promptRepository) ReplacePrompts(ctx context.Context, prompts []Prompt, trashed []int) ([]Prompt, error) {
+	saved := make([]Prompt, len(prompts))
+	copy(saved, prompts)
+
+	err := repo.db.Update(func(tx *bolt.Tx) error {
+		for _, id := range trashed {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := repo.trashPrompt(tx, id); err != nil {
+				return err
+			}
+		}
 
+		bucket, err := tx.CreateBucketIfNotExists(promptsBucket)
+		if err != nil {
+			repo.logger.Error("failed to create bucket", "error", err)
+			return storageError("create bucket", err)
+		}
+		for i := range saved {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := repo.putPrompt(tx, bucket, &saved[i]); err != nil {
+				return err
+			}
+		}
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
 
-	return err
+	repo.logger.Debug("replaced prompts", "written", len(saved), "trashed", len(trashed))
+	return saved, nil
+}
+
+// copies the prompt to the trash bucket and deletes it. must be called
+// inside a writable transaction.
+func (repo *promptRepository) trashPrompt(tx *bolt.Tx, id int) error {
+	var value []byte
+	if bucket := tx.Bucket(promptsBucket); bucket != nil {
+		value = bucket.Get(itob(uint64(id)))
+	}
+	if value == nil {
+		return notFoundError(id)
+	}
+	prompt := Prompt{}
+	if err := json.Unmarshal(value, &prompt); err != nil {
+		repo.logger.Error("failed to decode prompt", "id", id, "error", err)
+		return storageError("decode prompt", err)
+	}
+	if err := putTrash(tx, TrashedPrompt{Prompt: prompt, TrashedAt: time.Now()}); err != nil {
+		repo.logger.Error("failed to write to the trash", "id", id, "error", err)
+		return storageError("write trash", err)
+	}
+	return repo.deletePrompt(tx, id)
+}
+
+// writes a record of the trash bucket
+func putTrash(tx *bolt.Tx, trashed TrashedPrompt) error {
+	trash, err := tx.CreateBucketIfNotExists(trashBucket)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(trashed)
+	if err != nil {
+		return err
+	}
+	return trash.Put(itob(uint64(trashed.Prompt.ID)), encoded)
+}
+
+// deletes the prompt and drops it from the indexes. must be called inside
+// a writable transaction.
+func (repo *promptRepository) deletePrompt(tx *bolt.Tx, id int) error {
+	bucket, err := tx.CreateBucketIfNotExists(promptsBucket)
+	if err != nil {
+		repo.logger.Error("failed to create bucket", "id", id, "error", err)
+		return storageError("create bucket", err)
+	}
+
+	// deleting something that is not there is reported to the caller
+	key := itob(uint64(id))
+	value := bucket.Get(key)
+	if value == nil {
+		return notFoundError(id)
+	}
+
+	// drop the slug and aliases of the prompt from the index
+	stored := &Prompt{}
+	if err := json.Unmarshal(value, stored); err == nil {
+		if err := unindexSlugs(boltIndex{tx.Bucket(slugsBucket)}, stored); err != nil {
+			repo.logger.Error("failed to remove slugs", "id", id, "error", err)
+			return storageError("remove slugs", err)
+		}
+		if err := unindexUUID(boltIndex{tx.Bucket(uuidsBucket)}, stored); err != nil {
+			repo.logger.Error("failed to remove uuid", "id", id, "error", err)
+			return storageError("remove uuid", err)
+		}
+		if err := unindexPrompt(tx, stored); err != nil {
+			repo.logger.Error("failed to update indexes", "id", id, "error", err)
+			return storageError("update indexes", err)
+		}
+	}
+
+	// delete the prompt
+	if err := bucket.Delete(key); err != nil {
+		repo.logger.Error("failed to delete prompt", "id", id, "error", err)
+		return storageError("delete prompt", err)
+	}
+	return nil
 }
 
 // get specific prompt details by id
-func (repo *promptRepository) GetPromptByID(id int) (*Prompt, error) {
+func (repo *promptRepository) GetPromptByID(ctx context.Context, id int) (*Prompt, error) {
 	// get the prompt bucket
 	db := repo.db
 
@@ -110,10 +343,16 @@ func (repo *promptRepository) GetPromptByID(id int) (*Prompt, error) {
 	prompt := &Prompt{}
 
 	err := db.View(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte("prompts"))
+		if err := ctx.Err(); err != nil {
+			prompt = nil
+			return err
+		}
+
+		bucket := tx.Bucket(promptsBucket)
 		if bucket == nil {
 			repo.logger.Error("bucket not found")
-			return errors.New("prompt not found")
+			prompt = nil
+			return notFoundError(id)
 		}
 
 		// get the prompt
@@ -127,32 +366,84 @@ func (repo *promptRepository) GetPromptByID(id int) (*Prompt, error) {
 			prompt = nil
 
 			// then return the error
-			return errors.New("prompt not found")
+			return notFoundError(id)
 		}
 
 		// decode the prompt
 		err := json.Unmarshal(value, prompt)
 		if err != nil {
 			repo.logger.Error("failed to decode prompt", "error", err)
+			prompt = nil
+			return storageError("decode prompt", err)
+		}
+
+		return nil
+	})
+
+	return prompt, err
+}
+
+// get a prompt by its slug, or one of its former slugs
+func (repo *promptRepository) GetPromptBySlug(ctx context.Context, slug string) (*Prompt, error) {
+	return repo.getIndexedPrompt(ctx, slugsBucket, "slug", slug)
+}
+
+// get a prompt by its uuid
+func (repo *promptRepository) GetPromptByUUID(ctx context.Context, uuid string) (*Prompt, error) {
+	return repo.getIndexedPrompt(ctx, uuidsBucket, "uuid", uuid)
+}
+
+// looks up a prompt through one of the secondary indexes
+func (repo *promptRepository) getIndexedPrompt(ctx context.Context, index []byte, kind string, key string) (*Prompt, error) {
+	var prompt *Prompt
+
+	err := repo.db.View(func(tx *bolt.Tx) error {
+		if err := ctx.Err(); err != nil {
 			return err
 		}
 
+		ids := tx.Bucket(index)
+		bucket := tx.Bucket(promptsBucket)
+		if ids == nil || bucket == nil {
+			return fmt.Errorf("%w: %s %q", ErrNotFound, kind, key)
+		}
+
+		id := ids.Get([]byte(key))
+		if id == nil {
+			return fmt.Errorf("%w: %s %q", ErrNotFound, kind, key)
+		}
+
+		value := bucket.Get(id)
+		if value == nil {
+			// the index points to a prompt that is gone
+			repo.logger.Warn("index points to a missing prompt", kind, key, "id", btoi(id))
+			return fmt.Errorf("%w: %s %q", ErrNotFound, kind, key)
+		}
+
+		prompt = &Prompt{}
+		if err := json.Unmarshal(value, prompt); err != nil {
+			repo.logger.Error("failed to decode prompt", "error", err)
+			prompt = nil
+			return storageError("decode prompt", err)
+		}
 		return nil
 	})
 
 	return prompt, err
 }
 
-// get all prompts
-func (repo *promptRepository) GetAllPrompts() ([]Prompt, error) {
+// get all prompts. the records that cannot be read are left out and
+// reported with an *UnreadableError, along with the rest.
+func (repo *promptRepository) GetAllPrompts(ctx context.Context) ([]Prompt, error) {
 	// get the prompt bucket
 	db := repo.db
 
 	// create empty prompt struct
 	prompts := []Prompt{}
+	skipped := []int{}
 
 	err := db.View(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte("prompts"))
+		bucket := tx.Bucket(promptsBucket)
 		if bucket == nil {
 			return nil // No prompts yet
 		}
@@ -163,11 +454,22 @@ func (repo *promptRepository) GetAllPrompts() ([]Prompt, error) {
 		// effectively, this is sorting by creation date
 		// but we will sort by updated at after this
 		for k, v := cursor.Last(); k != nil; k, v = cursor.Prev() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			// one bad record must not hide the rest of the vault, it is
+			// reported along with it
 			prompt := &Prompt{}
 			err := json.Unmarshal(v, prompt)
 			if err != nil {
-				repo.logger.Error("failed to decode prompt", "error", err)
-				return err
+				repo.logger.Warn("skipping undecodable prompt", "key", k, "error", err)
+				id := 0
+				if len(k) == 8 {
+					id = btoi(k)
+				}
+				skipped = append(skipped, id)
+				continue
 			}
 			prompts = append(prompts, *prompt)
 		}
@@ -181,12 +483,409 @@ func (repo *promptRepository) GetAllPrompts() ([]Prompt, error) {
 		return prompts[i].UpdatedAt.After(prompts[j].UpdatedAt)
 	})
 
+	if err == nil && len(skipped) > 0 {
+		slices.Sort(skipped)
+		err = &UnreadableError{IDs: skipped}
+	}
 	return prompts, err
 }
 
+// get a page of the prompts matching the query.
+// filters on tags, collection and pinned are answered from their indexes,
+// otherwise the index of the sort key is walked until the page is full.
+func (repo *promptRepository) QueryPrompts(ctx context.Context, query Query) (Page, error) {
+	if err := query.normalize(); err != nil {
+		return Page{}, err
+	}
+
+	page := Page{Prompts: []Prompt{}}
+	// undecodable records are left out and reported with the page
+	var skipped []int
+	err := repo.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(promptsBucket)
+		if bucket == nil {
+			return nil // No prompts yet
+		}
+
+		decode := func(id int) (*Prompt, error) {
+			value := bucket.Get(itob(uint64(id)))
+			if value == nil {
+				// the index points to a prompt that is gone
+				repo.logger.Warn("index points to a missing prompt", "id", id)
+				return nil, nil
+			}
+			prompt := &Prompt{}
+			if err := json.Unmarshal(value, prompt); err != nil {
+				repo.logger.Warn("skipping undecodable prompt", "id", id, "error", err)
+				skipped = append(skipped, id)
+				return nil, nil
+			}
+			return prompt, nil
+		}
+
+		// the few prompts passing the indexed filters are sorted in memory
+		if ids, ok := filteredIDs(tx, query); ok {
+			prompts := make([]Prompt, 0, len(ids))
+			for id := range ids {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				prompt, err := decode(id)
+				if err != nil {
+					return err
+				}
+				if prompt != nil {
+					prompts = append(prompts, *prompt)
+				}
+			}
+			var err error
+			page, err = queryPrompts(prompts, query)
+			return err
+		}
+
+		index := tx.Bucket(sortIndex(query.Sort).bucket)
+		if index == nil {
+			// a database written before the indexes existed, see Migrate
+			repo.logger.Warn("query index missing, scanning every prompt")
+			prompts := []Prompt{}
+			err := bucket.ForEach(func(key, value []byte) error {
+				prompt := Prompt{}
+				if err := json.Unmarshal(value, &prompt); err != nil {
+					repo.logger.Warn("skipping undecodable prompt", "key", key, "error", err)
+					if len(key) == 8 {
+						skipped = append(skipped, btoi(key))
+					}
+					return nil
+				}
+				prompts = append(prompts, prompt)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			page, err = queryPrompts(prompts, query)
+			return err
+		}
+
+		after, err := query.decodeCursor()
+		if err != nil {
+			return err
+		}
+
+		// times are indexed oldest first and titles a to z
+		descending := (query.Sort != SortTitle) != query.Reverse
+		c := index.Cursor()
+		next := c.Next
+		if descending {
+			next = c.Prev
+		}
+
+		var k []byte
+		switch {
+		case after == nil && descending:
+			k, _ = c.Last()
+		case after == nil:
+			k, _ = c.First()
+		default:
+			// start right after the last prompt of the previous page
+			start := indexKey(after.Value, after.ID)
+			k, _ = c.Seek(start)
+			if k == nil && descending {
+				k, _ = c.Last()
+			} else if descending || bytes.Equal(k, start) {
+				k, _ = next()
+			}
+		}
+
+		matched := []Prompt{}
+		for ; k != nil; k, _ = next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			prompt, err := decode(indexKeyID(k))
+			if err != nil {
+				return err
+			}
+			if prompt == nil || !query.matches(prompt) {
+				continue
+			}
+			matched = append(matched, *prompt)
+			// one more than the page tells whether there is a next one
+			if query.Limit > 0 && len(matched) > query.Limit {
+				break
+			}
+		}
+		page = query.page(matched)
+		return nil
+	})
+	if err != nil {
+		return Page{}, err
+	}
+	page.Skipped = skipped
+	return page, nil
+}
+
+// ranks the prompts by relevance to the query with BM25, from the terms
+// index and the stats kept next to it. a limit of 0 returns every match.
+func (repo *promptRepository) RankPrompts(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	results := []SearchResult{}
+	terms := queryTerms(query)
+	if len(terms) == 0 {
+		return results, nil
+	}
+
+	err := repo.db.View(func(tx *bolt.Tx) error {
+		index, prompts := tx.Bucket(termsIndex.bucket), tx.Bucket(promptsBucket)
+		docs, total := searchStats(tx)
+		if index == nil || prompts == nil || docs == 0 {
+			return nil
+		}
+
+		// how often each term occurs in each prompt having it
+		postings := make([]map[int]int, len(terms))
+		for i, term := range terms {
+			postings[i] = map[int]int{}
+			prefix := []byte(term + "\x1f")
+			c := index.Cursor()
+			for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				if len(k) < len(prefix)+9 {
+					continue
+				}
+				tf, err := strconv.Atoi(string(k[len(prefix) : len(k)-9]))
+				if err != nil {
+					continue
+				}
+				postings[i][indexKeyID(k)] += tf
+			}
+		}
+
+		avgLength := float64(total) / float64(docs)
+		scores := map[int]float64{}
+		for _, posting := range postings {
+			for id := range posting {
+				scores[id] = 0
+			}
+		}
+		for id := range scores {
+			value := prompts.Get(itob(uint64(id)))
+			prompt := Prompt{}
+			if value == nil || json.Unmarshal(value, &prompt) != nil {
+				// a stale entry or a bad record, pvt doctor reports both
+				continue
+			}
+			score, length := 0.0, termsLength(promptTerms(&prompt))
+			for _, posting := range postings {
+				if tf := posting[id]; tf > 0 {
+					score += bm25(tf, len(posting), docs, length, avgLength)
+				}
+			}
+			results = append(results, SearchResult{Prompt: prompt, Score: score})
+		}
+		return nil
+	})
+	if err != nil {
+		repo.logger.Error("failed to rank prompts", "error", err)
+		return nil, storageError("rank prompts", err)
+	}
+	return sortResults(results, limit), nil
+}
+
+// counts a use of the prompt, leaving its update time alone
+func (repo *promptRepository) RecordUse(ctx context.Context, id int) (*Prompt, error) {
+	var prompt *Prompt
+
+	err := repo.db.Update(func(tx *bolt.Tx) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		bucket := tx.Bucket(promptsBucket)
+		if bucket == nil {
+			return notFoundError(id)
+		}
+		value := bucket.Get(itob(uint64(id)))
+		if value == nil {
+			return notFoundError(id)
+		}
+		stored := &Prompt{}
+		if err := json.Unmarshal(value, stored); err != nil {
+			repo.logger.Error("failed to decode prompt", "error", err)
+			return storageError("decode prompt", err)
+		}
+
+		used := *stored
+		recordUse(&used)
+		encoded, err := json.Marshal(used)
+		if err != nil {
+			repo.logger.Error("failed to encode prompt", "error", err)
+			return storageError("encode prompt", err)
+		}
+		if err := bucket.Put(itob(uint64(id)), encoded); err != nil {
+			repo.logger.Error("failed to write prompt to bucket", "error", err)
+			return storageError("write prompt", err)
+		}
+		if err := unindexPrompt(tx, stored); err != nil {
+			return storageError("update indexes", err)
+		}
+		if err := indexPrompt(tx, &used); err != nil {
+			return storageError("update indexes", err)
+		}
+
+		prompt = &used
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return prompt, nil
+}
+
+// get the prompts in the trash, most recently trashed first
+func (repo *promptRepository) GetTrash(ctx context.Context) ([]TrashedPrompt, error) {
+	trash := []TrashedPrompt{}
+	err := repo.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(trashBucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(key, value []byte) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			trashed := TrashedPrompt{}
+			if err := json.Unmarshal(value, &trashed); err != nil {
+				repo.logger.Error("failed to decode trashed prompt", "key", key, "error", err)
+				return storageError("decode trash", err)
+			}
+			trash = append(trash, trashed)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortTrash(trash)
+	return trash, nil
+}
+
+// puts the prompt with the id back from the trash, under the same id
+func (repo *promptRepository) RestoreTrashed(ctx context.Context, id int) (*Prompt, error) {
+	var prompt *Prompt
+	err := repo.db.Update(func(tx *bolt.Tx) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		trash := tx.Bucket(trashBucket)
+		var value []byte
+		if trash != nil {
+			value = trash.Get(itob(uint64(id)))
+		}
+		if value == nil {
+			return fmt.Errorf("%w: prompt %d is not in the trash", ErrNotFound, id)
+		}
+		trashed := TrashedPrompt{}
+		if err := json.Unmarshal(value, &trashed); err != nil {
+			repo.logger.Error("failed to decode trashed prompt", "id", id, "error", err)
+			return storageError("decode trash", err)
+		}
+
+		bucket, err := tx.CreateBucketIfNotExists(promptsBucket)
+		if err != nil {
+			repo.logger.Error("failed to create bucket", "error", err)
+			return storageError("create bucket", err)
+		}
+		if bucket.Get(itob(uint64(id))) != nil {
+			return fmt.Errorf("cannot restore prompt %d, the id is in use", id)
+		}
+
+		prompt = &trashed.Prompt
+		untrash(boltIndex{tx.Bucket(slugsBucket)}, prompt)
+		if err := repo.putPrompt(tx, bucket, prompt); err != nil {
+			return err
+		}
+		if err := trash.Delete(itob(uint64(id))); err != nil {
+			return storageError("remove from trash", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	repo.logger.Info("restored prompt from the trash", "id", id, "slug", prompt.Slug)
+	return prompt, nil
+}
+
+// stores the prompts and the trash as they are, for conversions from
+// another backend
+func (repo *promptRepository) RestorePrompts(ctx context.Context, prompts []Prompt, trash []TrashedPrompt) error {
+	return repo.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(promptsBucket)
+		if err != nil {
+			repo.logger.Error("failed to create bucket", "error", err)
+			return storageError("create bucket", err)
+		}
+		if key, _ := bucket.Cursor().First(); key != nil {
+			return fmt.Errorf("cannot restore into a database that already holds prompts")
+		}
+		slugs, err := tx.CreateBucketIfNotExists(slugsBucket)
+		if err != nil {
+			return storageError("create bucket", err)
+		}
+		uuids, err := tx.CreateBucketIfNotExists(uuidsBucket)
+		if err != nil {
+			return storageError("create bucket", err)
+		}
+
+		sequence := bucket.Sequence()
+		for _, prompt := range prompts {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			encoded, err := json.Marshal(prompt)
+			if err != nil {
+				return storageError("encode prompt", err)
+			}
+			if err := bucket.Put(itob(uint64(prompt.ID)), encoded); err != nil {
+				return storageError("write prompt", err)
+			}
+			for _, slug := range append([]string{prompt.Slug}, prompt.Aliases...) {
+				if err := slugs.Put([]byte(slug), itob(uint64(prompt.ID))); err != nil {
+					return storageError("write slug", err)
+				}
+			}
+			if err := uuids.Put([]byte(prompt.UUID), itob(uint64(prompt.ID))); err != nil {
+				return storageError("write uuid", err)
+			}
+			if err := indexPrompt(tx, &prompt); err != nil {
+				return storageError("update indexes", err)
+			}
+			sequence = max(sequence, uint64(prompt.ID))
+		}
+		for _, trashed := range trash {
+			if err := putTrash(tx, trashed); err != nil {
+				return storageError("write trash", err)
+			}
+			sequence = max(sequence, uint64(trashed.Prompt.ID))
+		}
+
+		// new prompts must never reuse a restored id, nor a trashed one
+		return bucket.SetSequence(sequence)
+	})
+}
+
 // helper function to convert uint64 to []byte
 func itob(v uint64) []byte {
 	b := make([]byte, 8)
 	binary.BigEndian.PutUint64(b, v)
 	return b
 }
+
+// helper function to convert []byte back to an id
+func btoi(b []byte) int {
+	return int(binary.BigEndian.Uint64(b))
+}
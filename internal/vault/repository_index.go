@@ -0,0 +1,427 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/boltdb/bolt"
+)
+
+// An index bucket queries are answered from. Keys are <value> 0x00 <id>,
+// with the id as 8 big endian bytes, and values are empty, so that a
+// cursor walks the prompts in the order of their values.
+type queryIndex struct {
+	bucket []byte
+
+	// the values of a prompt in the index, none to leave it out
+	values func(p *Prompt) []string
+}
+
+// the query indexes of the bolt database
+var (
+	updatedIndex = queryIndex{
+		bucket: []byte("index:updated"),
+		values: func(p *Prompt) []string { return []string{timeSortValue(p.UpdatedAt)} },
+	}
+	createdIndex = queryIndex{
+		bucket: []byte("index:created"),
+		values: func(p *Prompt) []string { return []string{timeSortValue(p.CreatedAt)} },
+	}
+	titleIndex = queryIndex{
+		bucket: []byte("index:title"),
+		values: func(p *Prompt) []string { return []string{titleSortValue(p.Title)} },
+	}
+	usageIndex = queryIndex{
+		bucket: []byte("index:usage"),
+		values: func(p *Prompt) []string { return []string{usageSortValue(p.Uses)} },
+	}
+	tagsIndex = queryIndex{
+		bucket: []byte("index:tags"),
+		values: func(p *Prompt) []string { return p.Tags },
+	}
+	collectionIndex = queryIndex{
+		bucket: []byte("index:collection"),
+		values: func(p *Prompt) []string {
+			if p.Collection == "" {
+				return nil
+			}
+			return []string{p.Collection}
+		},
+	}
+	pinnedIndex = queryIndex{
+		bucket: []byte("index:pinned"),
+		values: func(p *Prompt) []string {
+			if !p.Pinned {
+				return nil
+			}
+			return []string{""}
+		},
+	}
+
+	// the terms of the prompt for relevance search, each with how often it
+	// occurs: <term> 0x1f <count>. terms never hold the separator.
+	termsIndex = queryIndex{
+		bucket: []byte("index:terms"),
+		values: func(p *Prompt) []string {
+			values := []string{}
+			for term, tf := range promptTerms(p) {
+				values = append(values, term+"\x1f"+strconv.Itoa(tf))
+			}
+			return values
+		},
+	}
+
+	queryIndexes = []queryIndex{updatedIndex, createdIndex, titleIndex, usageIndex, tagsIndex, collectionIndex, pinnedIndex, termsIndex}
+)
+
+// bucket holding the number of prompts in the terms index and their
+// total length, which relevance search needs and should not count
+var searchStatsBucket = []byte("index:stats")
+
+var (
+	statsDocsKey   = []byte("docs")
+	statsLengthKey = []byte("length")
+)
+
+// the number of prompts in the terms index and their total length
+func searchStats(tx *bolt.Tx) (docs, length int) {
+	bucket := tx.Bucket(searchStatsBucket)
+	if bucket == nil {
+		return 0, 0
+	}
+	if v := bucket.Get(statsDocsKey); len(v) == 8 {
+		docs = btoi(v)
+	}
+	if v := bucket.Get(statsLengthKey); len(v) == 8 {
+		length = btoi(v)
+	}
+	return docs, length
+}
+
+func putSearchStats(tx *bolt.Tx, docs, length int) error {
+	bucket, err := tx.CreateBucketIfNotExists(searchStatsBucket)
+	if err != nil {
+		return err
+	}
+	if err := bucket.Put(statsDocsKey, itob(uint64(max(docs, 0)))); err != nil {
+		return err
+	}
+	return bucket.Put(statsLengthKey, itob(uint64(max(length, 0))))
+}
+
+// adds the prompt to the search stats, or takes it out with a sign of -1
+func countSearchStats(tx *bolt.Tx, p *Prompt, sign int) error {
+	docs, length := searchStats(tx)
+	return putSearchStats(tx, docs+sign, length+sign*termsLength(promptTerms(p)))
+}
+
+// the search stats the stored prompts call for
+func wantedSearchStats(ctx context.Context, tx *bolt.Tx) (docs, length int, err error) {
+	prompts := tx.Bucket(promptsBucket)
+	if prompts == nil {
+		return 0, 0, nil
+	}
+	err = prompts.ForEach(func(key, value []byte) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		p := Prompt{}
+		if err := json.Unmarshal(value, &p); err == nil && len(key) == 8 && p.ID == btoi(key) {
+			docs++
+			length += termsLength(promptTerms(&p))
+		}
+		return nil
+	})
+	return docs, length, err
+}
+
+// index of the sort key of a query
+func sortIndex(sort SortKey) queryIndex {
+	switch sort {
+	case SortCreated:
+		return createdIndex
+	case SortTitle:
+		return titleIndex
+	case SortUses:
+		return usageIndex
+	}
+	return updatedIndex
+}
+
+// key of a prompt in an index
+func indexKey(value string, id int) []byte {
+	key := make([]byte, 0, len(value)+9)
+	key = append(key, value...)
+	key = append(key, 0)
+	return append(key, itob(uint64(id))...)
+}
+
+// id of the prompt of an index key
+func indexKeyID(key []byte) int {
+	return btoi(key[len(key)-8:])
+}
+
+// adds the prompt to every query index. must be called inside a writable transaction.
+func indexPrompt(tx *bolt.Tx, p *Prompt) error {
+	for _, index := range queryIndexes {
+		bucket, err := tx.CreateBucketIfNotExists(index.bucket)
+		if err != nil {
+			return err
+		}
+		for _, value := range index.values(p) {
+			if err := bucket.Put(indexKey(value, p.ID), nil); err != nil {
+				return err
+			}
+		}
+	}
+	return countSearchStats(tx, p, 1)
+}
+
+// removes the prompt from every query index
+func unindexPrompt(tx *bolt.Tx, p *Prompt) error {
+	for _, index := range queryIndexes {
+		bucket := tx.Bucket(index.bucket)
+		if bucket == nil {
+			continue
+		}
+		for _, value := range index.values(p) {
+			if err := bucket.Delete(indexKey(value, p.ID)); err != nil {
+				return err
+			}
+		}
+	}
+	return countSearchStats(tx, p, -1)
+}
+
+// drops the query indexes and builds them again from the prompts
+func rebuildQueryIndexes(tx *bolt.Tx) error {
+	for _, index := range queryIndexes {
+		if err := tx.DeleteBucket(index.bucket); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+	}
+	if err := tx.DeleteBucket(searchStatsBucket); err != nil && err != bolt.ErrBucketNotFound {
+		return err
+	}
+
+	prompts := tx.Bucket(promptsBucket)
+	if prompts == nil {
+		return nil
+	}
+	return prompts.ForEach(func(_, value []byte) error {
+		p := Prompt{}
+		if err := json.Unmarshal(value, &p); err != nil {
+			// nothing to index, the record cannot be read anyway
+			return nil
+		}
+		return indexPrompt(tx, &p)
+	})
+}
+
+// ids of the prompts with value in the index
+func indexedIDs(bucket *bolt.Bucket, value string) map[int]bool {
+	ids := map[int]bool{}
+	if bucket == nil {
+		return ids
+	}
+	prefix := append([]byte(value), 0)
+	c := bucket.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix) && len(k) == len(prefix)+8; k, _ = c.Next() {
+		ids[indexKeyID(k)] = true
+	}
+	return ids
+}
+
+// ids of the prompts passing the filters of the query that have an index,
+// ok is false if the query has none of them
+func filteredIDs(tx *bolt.Tx, q Query) (ids map[int]bool, ok bool) {
+	narrow := func(next map[int]bool) {
+		if !ok {
+			ids, ok = next, true
+			return
+		}
+		for id := range ids {
+			if !next[id] {
+				delete(ids, id)
+			}
+		}
+	}
+
+	for _, tag := range q.Tags {
+		narrow(indexedIDs(tx.Bucket(tagsIndex.bucket), tag))
+	}
+	if q.Collection != "" {
+		narrow(indexedIDs(tx.Bucket(collectionIndex.bucket), q.Collection))
+	}
+	if q.Pinned {
+		narrow(indexedIDs(tx.Bucket(pinnedIndex.bucket), ""))
+	}
+	return ids, ok
+}
+
+// the buckets of the secondary indexes, slugs and uuids first
+func indexBuckets() [][]byte {
+	buckets := [][]byte{slugsBucket, uuidsBucket}
+	for _, index := range queryIndexes {
+		buckets = append(buckets, index.bucket)
+	}
+	return buckets
+}
+
+// reports whether the bucket maps keys to ids, like the slugs and uuids,
+// rather than holding the ids in its keys like the query indexes
+func lookupBucket(name []byte) bool {
+	return bytes.Equal(name, slugsBucket) || bytes.Equal(name, uuidsBucket)
+}
+
+// the entries the prompt should have in every index, by bucket
+func addIndexEntries(entries map[string]map[indexEntry]bool, p *Prompt) {
+	add := func(bucket []byte, key string) {
+		if key == "" && lookupBucket(bucket) {
+			return
+		}
+		if entries[string(bucket)] == nil {
+			entries[string(bucket)] = map[indexEntry]bool{}
+		}
+		entries[string(bucket)][indexEntry{key: key, id: p.ID}] = true
+	}
+
+	for _, slug := range append([]string{p.Slug}, p.Aliases...) {
+		add(slugsBucket, slug)
+	}
+	add(uuidsBucket, p.UUID)
+	for _, index := range queryIndexes {
+		for _, value := range index.values(p) {
+			add(index.bucket, value)
+		}
+	}
+}
+
+// the entries every index should have for the stored prompts.
+// undecodable records have nothing to check against and are skipped.
+func wantedIndexEntries(ctx context.Context, tx *bolt.Tx) (map[string]map[indexEntry]bool, error) {
+	entries := map[string]map[indexEntry]bool{}
+	prompts := tx.Bucket(promptsBucket)
+	if prompts == nil {
+		return entries, nil
+	}
+	err := prompts.ForEach(func(key, value []byte) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// bad records are not indexed, pvt doctor reports them instead
+		p := Prompt{}
+		if err := json.Unmarshal(value, &p); err == nil && len(key) == 8 && p.ID == btoi(key) {
+			addIndexEntries(entries, &p)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// the entries an index bucket holds. slugs and uuids lead to the id
+// stored as the value, the query indexes to the id ending the key.
+func storedIndexEntries(bucket *bolt.Bucket, lookup bool) map[indexEntry]bool {
+	entries := map[indexEntry]bool{}
+	if bucket == nil {
+		return entries
+	}
+	bucket.ForEach(func(k, v []byte) error {
+		switch {
+		case lookup && len(v) == 8:
+			entries[indexEntry{key: string(k), id: btoi(v)}] = true
+		case !lookup && len(k) >= 9 && k[len(k)-9] == 0:
+			entries[indexEntry{key: string(k[:len(k)-9]), id: indexKeyID(k)}] = true
+		default:
+			// malformed, it leads nowhere
+			entries[indexEntry{key: string(k)}] = true
+		}
+		return nil
+	})
+	return entries
+}
+
+// reports every index entry disagreeing with the prompts
+func (repo *promptRepository) CheckIndexes(ctx context.Context) ([]IndexDrift, error) {
+	var drift []IndexDrift
+	err := repo.db.View(func(tx *bolt.Tx) error {
+		var err error
+		drift, err = checkIndexes(ctx, tx)
+		return err
+	})
+	if err != nil {
+		repo.logger.Error("failed to check indexes", "error", err)
+		return nil, storageError("check indexes", err)
+	}
+	return drift, nil
+}
+
+// compares every index bucket with the entries the prompts call for
+func checkIndexes(ctx context.Context, tx *bolt.Tx) ([]IndexDrift, error) {
+	want, err := wantedIndexEntries(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	drift := []IndexDrift{}
+	for _, bucket := range indexBuckets() {
+		have := storedIndexEntries(tx.Bucket(bucket), lookupBucket(bucket))
+		drift = append(drift, indexDrift(string(bucket), want[string(bucket)], have)...)
+	}
+
+	// the stats are counters, they can only be checked as a whole
+	docs, length, err := wantedSearchStats(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	if haveDocs, haveLength := searchStats(tx); haveDocs != docs || haveLength != length {
+		drift = append(drift, IndexDrift{Index: string(searchStatsBucket), Missing: true})
+	}
+	return drift, nil
+}
+
+// drops every secondary index and builds them again from the prompts
+func (repo *promptRepository) Reindex(ctx context.Context) error {
+	err := repo.db.Update(func(tx *bolt.Tx) error {
+		return rebuildIndexes(ctx, tx)
+	})
+	if err != nil {
+		repo.logger.Error("failed to rebuild indexes", "error", err)
+		return storageError("rebuild indexes", err)
+	}
+	repo.logger.Info("rebuilt indexes")
+	return nil
+}
+
+// writes every index bucket afresh from the prompts
+func rebuildIndexes(ctx context.Context, tx *bolt.Tx) error {
+	want, err := wantedIndexEntries(ctx, tx)
+	if err != nil {
+		return err
+	}
+	for _, name := range indexBuckets() {
+		if err := tx.DeleteBucket(name); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+		bucket, err := tx.CreateBucket(name)
+		if err != nil {
+			return err
+		}
+		for e := range want[string(name)] {
+			key, value := indexKey(e.key, e.id), []byte(nil)
+			if lookupBucket(name) {
+				key, value = []byte(e.key), itob(uint64(e.id))
+			}
+			if err := bucket.Put(key, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	docs, length, err := wantedSearchStats(ctx, tx)
+	if err != nil {
+		return err
+	}
+	return putSearchStats(tx, docs, length)
+}
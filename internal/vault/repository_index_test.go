@@ -0,0 +1,119 @@
+package vault
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestPromptRepository_CheckIndexes_Integration(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	repo := NewPromptRepository(db, slog.New(slog.NewTextHandler(io.Discard, nil))).(*promptRepository)
+
+	prompts, err := repo.CreateOrUpdatePrompts(ctx, []Prompt{
+		{Title: "Review", PromptContent: "c", Tags: []string{"code"}},
+		{Title: "Summary", PromptContent: "c", Collection: "work"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.RecordUse(ctx, prompts[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if drift, err := repo.CheckIndexes(ctx); err != nil || len(drift) != 0 {
+		t.Fatalf("CheckIndexes() on a sound database = %v, %v, want no drift", drift, err)
+	}
+
+	// let the indexes drift the ways a crash or a bug could
+	err = db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(slugsBucket).Delete([]byte("review")); err != nil {
+			return err
+		}
+		if err := tx.Bucket(tagsIndex.bucket).Put(indexKey("stale", prompts[1].ID), nil); err != nil {
+			return err
+		}
+		return tx.Bucket(uuidsBucket).Put([]byte(prompts[0].UUID), itob(uint64(prompts[1].ID)))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	drift, err := repo.CheckIndexes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, d := range drift {
+		got = append(got, d.String())
+	}
+	want := []string{
+		`slugs: "review" of prompt 1 is missing`,
+		`uuids: "` + prompts[0].UUID + `" of prompt 1 is missing`,
+		`uuids: "` + prompts[0].UUID + `" leads to prompt 2, which it should not`,
+		`index:tags: "stale" leads to prompt 2, which it should not`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("CheckIndexes() =\n%q\nwant\n%q", got, want)
+	}
+
+	if err := repo.Reindex(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if drift, err := repo.CheckIndexes(ctx); err != nil || len(drift) != 0 {
+		t.Errorf("CheckIndexes() after Reindex() = %v, %v, want no drift", drift, err)
+	}
+	if p, err := repo.GetPromptBySlug(ctx, "review"); err != nil || p.ID != prompts[0].ID {
+		t.Errorf("GetPromptBySlug() after Reindex() = %v, %v, want prompt %d", p, err, prompts[0].ID)
+	}
+	if page, err := repo.QueryPrompts(ctx, Query{Sort: SortUses, Limit: 1}); err != nil || page.Prompts[0].ID != prompts[0].ID {
+		t.Errorf("QueryPrompts() by uses after Reindex() = %v, %v, want prompt %d first", page, err, prompts[0].ID)
+	}
+}
+
+func TestPromptRepository_SearchStats_Integration(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	repo := NewPromptRepository(db, slog.New(slog.NewTextHandler(io.Discard, nil))).(*promptRepository)
+
+	prompts, err := repo.CreateOrUpdatePrompts(ctx, []Prompt{
+		{Title: "Review", PromptContent: "Review the migration."},
+		{Title: "Summary", PromptContent: "Summarize the article."},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeletePrompt(ctx, prompts[1].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// the stats follow every write
+	db.View(func(tx *bolt.Tx) error {
+		docs, length := searchStats(tx)
+		if want := termsLength(promptTerms(&prompts[0])); docs != 1 || length != want {
+			t.Errorf("searchStats() = %d, %d, want 1, %d", docs, length, want)
+		}
+		return nil
+	})
+
+	if err := db.Update(func(tx *bolt.Tx) error { return putSearchStats(tx, 7, 3) }); err != nil {
+		t.Fatal(err)
+	}
+	drift, err := repo.CheckIndexes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drift) != 1 || drift[0].String() != "index:stats: does not match the prompts" {
+		t.Errorf("CheckIndexes() with wrong stats = %q, want them reported", drift)
+	}
+	if err := repo.Reindex(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if drift, err := repo.CheckIndexes(ctx); err != nil || len(drift) != 0 {
+		t.Errorf("CheckIndexes() after Reindex() = %v, %v, want no drift", drift, err)
+	}
+}
@@ -1,6 +1,8 @@
 package vault
 
 import (
+	"context"
+	"errors"
 	"io"
 	"log/slog"
 	"path/filepath"
@@ -46,7 +48,7 @@ func TestCreateOrUpdatePrompt_Integration(t *testing.T) {
 			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
 			repo := NewPromptRepository(db, logger)
 
-			got, gotErr := repo.CreateOrUpdatePrompt(tt.prompt)
+			got, gotErr := repo.CreateOrUpdatePrompt(context.Background(), tt.prompt)
 			if gotErr != nil {
 				if !tt.wantErr {
 					t.Errorf("CreateOrUpdatePrompt() failed: %v", gotErr)
@@ -64,3 +66,113 @@ func TestCreateOrUpdatePrompt_Integration(t *testing.T) {
 		})
 	}
 }
+
+// context that reports itself as cancelled after a fixed number of Err calls,
+// used to cancel a bulk write deterministically half way through
+type cancelAfterContext struct {
+	context.Context
+	remaining int
+}
+
+func (c *cancelAfterContext) Err() error {
+	if c.remaining <= 0 {
+		return context.Canceled
+	}
+	c.remaining--
+	return nil
+}
+
+func TestCreateOrUpdatePrompts_Integration(t *testing.T) {
+	prompts := []Prompt{
+		{Title: "first", PromptContent: "first content"},
+		{Title: "second", PromptContent: "second content"},
+		{Title: "third", PromptContent: "third content"},
+	}
+
+	tests := []struct {
+		name    string // description of this test case
+		ctx     context.Context
+		wantErr error
+		wantLen int
+	}{
+		{
+			name:    "Bulk write commits every prompt",
+			ctx:     context.Background(),
+			wantErr: nil,
+			wantLen: 3,
+		},
+		{
+			name:    "Cancelled mid-way rolls back the transaction",
+			ctx:     &cancelAfterContext{Context: context.Background(), remaining: 2},
+			wantErr: context.Canceled,
+			wantLen: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup temporary DB
+			dir := t.TempDir()
+			dbPath := filepath.Join(dir, "test.db")
+			db, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: 1 * time.Second})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			repo := NewPromptRepository(db, logger)
+
+			_, gotErr := repo.CreateOrUpdatePrompts(tt.ctx, prompts)
+			if !errors.Is(gotErr, tt.wantErr) {
+				t.Fatalf("CreateOrUpdatePrompts() error = %v, want %v", gotErr, tt.wantErr)
+			}
+
+			// the caller's prompts must never be mutated
+			for _, p := range prompts {
+				if p.ID != 0 {
+					t.Errorf("CreateOrUpdatePrompts() mutated the input prompt %q", p.Title)
+				}
+			}
+
+			got, err := repo.GetAllPrompts(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != tt.wantLen {
+				t.Errorf("GetAllPrompts() returned %d prompts, want %d", len(got), tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestPromptRepository_NotFound_Integration(t *testing.T) {
+	dir := t.TempDir()
+	db, err := bolt.Open(filepath.Join(dir, "test.db"), 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := NewPromptRepository(db, logger)
+
+	// before the bucket exists
+	got, err := repo.GetPromptByID(context.Background(), 1)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetPromptByID() error = %v, want ErrNotFound", err)
+	}
+	if got != nil {
+		t.Errorf("GetPromptByID() = %v, want nil", got)
+	}
+
+	// after the bucket exists
+	if _, err := repo.CreateOrUpdatePrompt(context.Background(), &Prompt{Title: "t", PromptContent: "c"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetPromptByID(context.Background(), 99); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetPromptByID() error = %v, want ErrNotFound", err)
+	}
+	if err := repo.DeletePrompt(context.Background(), 99); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeletePrompt() error = %v, want ErrNotFound", err)
+	}
+}
@@ -19,8 +19,13 @@ func SearchPrompts(prompts Prompts, query string) fuzzy.Matches {
 // Copies the prompt content to the clipboard.
 // Requires xsel or xclip to be installed for Linux according to the atotto/clipboard docs.
 func CopyToClipboard(prompt *Prompt) error {
+	return CopyTextToClipboard(prompt.PromptContent)
+}
+
+// Copies arbitrary text to the clipboard, e.g. a prompt with its includes expanded.
+func CopyTextToClipboard(text string) error {
 	// copy the text to the clipboard
-	err := clipboard.WriteAll(prompt.PromptContent)
+	err := clipboard.WriteAll(text)
 	if err != nil {
 		return err
 	}
@@ -51,6 +51,31 @@ func TestSearchPrompts(t *testing.T) {
 	}
 }
 
+func TestMatchPositions(t *testing.T) {
+	tests := []struct {
+		name  string // description of this test case
+		text  string
+		terms []string
+		want  []int
+	}{
+		{"Word in another form", "Reviews code", []string{"reviewing"}, []int{0, 1, 2, 3, 4, 5, 6}},
+		{"Part of a word", "Summarize", []string{"sum"}, []int{0, 1, 2}},
+		{"Phrase", "an Exact phrase", []string{"exact phrase"}, []int{3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}},
+		{"Every occurrence", "go, Go", []string{"go"}, []int{0, 1, 4, 5}},
+		{"Runes, not bytes", "héllo wörld", []string{"wörld"}, []int{6, 7, 8, 9, 10}},
+		{"Single letters are skipped", "a cat", []string{"a"}, []int{}},
+		{"Nothing", "code", []string{"tests"}, []int{}},
+		{"No terms", "code", nil, []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := vault.MatchPositions(tt.text, tt.terms); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MatchPositions(%q, %q) = %v, want %v", tt.text, tt.terms, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestCopyToClipboard(t *testing.T) {
 	tests := []struct {
 		name string // description of this test case
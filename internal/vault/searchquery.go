@@ -0,0 +1,638 @@
+package vault
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SearchQuery is a parsed search, see ParseSearch.
+type SearchQuery struct {
+	root searchNode // nil matches every prompt
+}
+
+// QueryError is a malformed search query.
+// It matches ErrValidation with errors.Is.
+type QueryError struct {
+	// column of the query the problem was found at, from 1
+	Column  int
+	Message string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("invalid query at column %d: %s", e.Column, e.Message)
+}
+
+func (e *QueryError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// the fields a term can be qualified with, and what they compare
+var searchFields = []struct {
+	names []string
+	kind  fieldKind
+}{
+	{[]string{"tag", "tags"}, textField},
+	{[]string{"collection", "col"}, textField},
+	{[]string{"title"}, textField},
+	{[]string{"slug"}, textField},
+	{[]string{"description", "desc"}, textField},
+	{[]string{"content"}, textField},
+	{[]string{"pinned"}, boolField},
+	{[]string{"uses"}, numberField},
+	{[]string{"created"}, dateField},
+	{[]string{"updated"}, dateField},
+	{[]string{"used"}, dateField},
+}
+
+type fieldKind int
+
+const (
+	textField fieldKind = iota
+	boolField
+	numberField
+	dateField
+)
+
+// the canonical name and the kind of a field, ok is false if there is no such field
+func lookupField(name string) (canonical string, kind fieldKind, ok bool) {
+	for _, f := range searchFields {
+		for _, n := range f.names {
+			if strings.EqualFold(n, name) {
+				return f.names[0], f.kind, true
+			}
+		}
+	}
+	return "", 0, false
+}
+
+// Parses a search query. The query is made of terms that must all match:
+//
+//	review                 a word, in any form, anywhere in the prompt
+//	"exact phrase"         the words in this order
+//	tag:review             a field: tag, collection, title, slug, description or content
+//	title:"code review"    a field holding a phrase
+//	-draft                 anything but
+//	a OR b                 either
+//	(a OR b) c             grouping
+//	pinned:yes             pinned, or not with pinned:no
+//	uses:>=3               compared to a number
+//	updated:>2026-01-01    compared to a day, a month (2026-01) or a year (2026)
+//
+// Dates are in local time and compare whole days: updated:2026-01-01 is
+// anything during that day, updated:>2026-01-01 anything after it. Words
+// and fields ignore case. A word with a colon that names no field, like a
+// link or 10:30, is a plain word. The empty query matches every prompt.
+func ParseSearch(query string) (*SearchQuery, error) {
+	p := &searchParser{query: query}
+	p.skipSpace()
+	if p.done() {
+		return &SearchQuery{}, nil
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		// the only way to stop early is a closing parenthesis
+		return nil, p.errorf("unexpected ), there is no ( to close")
+	}
+	return &SearchQuery{root: root}, nil
+}
+
+// Reports whether the prompt matches the query.
+func (q *SearchQuery) Match(p *Prompt) bool {
+	if q.root == nil {
+		return true
+	}
+	return q.root.match(&searchDoc{prompt: p})
+}
+
+// Returns the words and phrases the prompts are searched for, leaving
+// out negated ones and fields. They are what results are ranked by.
+func (q *SearchQuery) Words() []string {
+	return q.terms(false)
+}
+
+// Returns what to highlight in the prompts matching the query: its
+// words and phrases, and the values of its text fields like title:review.
+// Negated ones are left out.
+func (q *SearchQuery) Highlights() []string {
+	return q.terms(true)
+}
+
+// the positive words and phrases, and the values of text fields if fields is set
+func (q *SearchQuery) terms(fields bool) []string {
+	terms := []string{}
+	var walk func(n searchNode)
+	walk = func(n searchNode) {
+		switch n := n.(type) {
+		case andNode:
+			for _, c := range n {
+				walk(c)
+			}
+		case orNode:
+			for _, c := range n {
+				walk(c)
+			}
+		case textNode:
+			terms = append(terms, n.text)
+		case fieldNode:
+			if fields {
+				terms = append(terms, n.text)
+			}
+		}
+	}
+	walk(q.root)
+	return terms
+}
+
+// Reports whether the query is nothing but plain words, no fields,
+// phrases, negations or OR. Such queries can be matched less strictly,
+// like the fuzzy filter of the tui does.
+func (q *SearchQuery) Plain() bool {
+	switch n := q.root.(type) {
+	case textNode:
+		return !n.phrase
+	case andNode:
+		for _, c := range n {
+			if t, ok := c.(textNode); !ok || t.phrase {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// -- Parser --
+
+type searchParser struct {
+	query string
+	pos   int // byte offset into query
+}
+
+func (p *searchParser) done() bool {
+	return p.pos >= len(p.query)
+}
+
+func (p *searchParser) peek() byte {
+	return p.query[p.pos]
+}
+
+func (p *searchParser) skipSpace() {
+	for !p.done() {
+		r, size := utf8.DecodeRuneInString(p.query[p.pos:])
+		if !unicode.IsSpace(r) {
+			return
+		}
+		p.pos += size
+	}
+}
+
+// an error at the current position
+func (p *searchParser) errorf(format string, args ...any) error {
+	return p.errorAt(p.pos, format, args...)
+}
+
+// an error at the byte offset pos, reported as a column
+func (p *searchParser) errorAt(pos int, format string, args ...any) error {
+	return &QueryError{Column: utf8.RuneCountInString(p.query[:pos]) + 1, Message: fmt.Sprintf(format, args...)}
+}
+
+// reports whether the next token is the OR keyword
+func (p *searchParser) atOr() bool {
+	rest := p.query[p.pos:]
+	if !strings.HasPrefix(rest, "OR") {
+		return false
+	}
+	if len(rest) == 2 {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(rest[2:])
+	return unicode.IsSpace(r) || r == '(' || r == '"' || r == '-'
+}
+
+// or := and ("OR" and)*
+func (p *searchParser) parseOr() (searchNode, error) {
+	if p.atOr() {
+		return nil, p.errorf("OR needs a term before it")
+	}
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	nodes := orNode{first}
+	for p.atOr() {
+		at := p.pos
+		p.pos += 2
+		p.skipSpace()
+		if p.done() || p.peek() == ')' || p.atOr() {
+			return nil, p.errorAt(at, "OR needs a term after it")
+		}
+		next, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, next)
+	}
+	if len(nodes) == 1 {
+		return first, nil
+	}
+	return nodes, nil
+}
+
+// and := unary+, up to an OR, a closing parenthesis or the end
+func (p *searchParser) parseAnd() (searchNode, error) {
+	nodes := andNode{}
+	for !p.done() && p.peek() != ')' && !p.atOr() {
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+		p.skipSpace()
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+// unary := "-" unary | "(" or ")" | phrase | field ":" value | word
+func (p *searchParser) parseUnary() (searchNode, error) {
+	start := p.pos
+	switch p.peek() {
+	case '-':
+		p.pos++
+		if p.done() || unicode.IsSpace(rune(p.peek())) || p.peek() == ')' {
+			return nil, p.errorAt(start, "- needs a term right after it, as in -draft")
+		}
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{node}, nil
+
+	case '(':
+		p.pos++
+		p.skipSpace()
+		if !p.done() && p.peek() == ')' {
+			return nil, p.errorAt(start, "() is empty")
+		}
+		if p.done() {
+			return nil, p.errorAt(start, "( is never closed")
+		}
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.done() {
+			return nil, p.errorAt(start, "( is never closed")
+		}
+		p.pos++ // the )
+		return node, nil
+
+	case '"':
+		phrase, err := p.parsePhrase()
+		if err != nil {
+			return nil, err
+		}
+		return textNode{text: phrase, phrase: true}, nil
+	}
+
+	word := p.parseWord()
+	name, value, qualified := strings.Cut(word, ":")
+	if !qualified {
+		return textNode{text: word}, nil
+	}
+
+	field, kind, ok := lookupField(name)
+	if !ok {
+		return textNode{text: word}, nil
+	}
+	valueAt := start + len(name) + 1
+	quoted := false
+	if value == "" && !p.done() && p.peek() == '"' {
+		phrase, err := p.parsePhrase()
+		if err != nil {
+			return nil, err
+		}
+		value, quoted = phrase, true
+	}
+	if value == "" {
+		return nil, p.errorAt(start, "%s: needs a value, as in %s", field, fieldExample(field))
+	}
+	return p.fieldTerm(field, kind, value, quoted, valueAt)
+}
+
+// a run of anything but spaces, parentheses and quotes. a quote right
+// after a colon is left for the value of the field.
+func (p *searchParser) parseWord() string {
+	start := p.pos
+	for !p.done() {
+		r, size := utf8.DecodeRuneInString(p.query[p.pos:])
+		if unicode.IsSpace(r) || r == '(' || r == ')' || r == '"' {
+			break
+		}
+		p.pos += size
+	}
+	return p.query[start:p.pos]
+}
+
+// a quoted phrase, with \" and \\ standing for a quote and a backslash
+func (p *searchParser) parsePhrase() (string, error) {
+	start := p.pos
+	p.pos++ // the opening quote
+	var b strings.Builder
+	for !p.done() {
+		c := p.peek()
+		switch {
+		case c == '"':
+			p.pos++
+			phrase := strings.Join(strings.Fields(b.String()), " ")
+			if phrase == "" {
+				return "", p.errorAt(start, `"" is empty`)
+			}
+			return phrase, nil
+		case c == '\\' && p.pos+1 < len(p.query):
+			b.WriteByte(p.query[p.pos+1])
+			p.pos += 2
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+	return "", p.errorAt(start, "quote is never closed")
+}
+
+// builds the term of a field from its value. valueAt is where the value
+// starts in the query, for errors.
+func (p *searchParser) fieldTerm(field string, kind fieldKind, value string, quoted bool, valueAt int) (searchNode, error) {
+	op := ""
+	if !quoted {
+		for _, candidate := range []string{">=", "<=", ">", "<", "="} {
+			if strings.HasPrefix(value, candidate) {
+				op, value = candidate, value[len(candidate):]
+				break
+			}
+		}
+	}
+	if op != "" && kind != numberField && kind != dateField {
+		return nil, p.errorAt(valueAt, "%s: cannot be compared with %s, only uses and dates can", field, op)
+	}
+	if op != "" && value == "" {
+		return nil, p.errorAt(valueAt, "%s: needs a value after %s, as in %s", field, op, fieldExample(field))
+	}
+
+	switch kind {
+	case boolField:
+		switch strings.ToLower(value) {
+		case "yes", "true":
+			return boolNode{field: field, value: true}, nil
+		case "no", "false":
+			return boolNode{field: field, value: false}, nil
+		}
+		return nil, p.errorAt(valueAt, "%s: expected yes or no, got %q", field, value)
+
+	case numberField:
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return nil, p.errorAt(valueAt, "%s: %q is not a number", field, value)
+		}
+		return numberNode{field: field, op: op, n: n}, nil
+
+	case dateField:
+		start, end, ok := parseSearchDate(value)
+		if !ok {
+			return nil, p.errorAt(valueAt, "%s: %q is not a date, expected YYYY-MM-DD, YYYY-MM or YYYY", field, value)
+		}
+		return dateNode{field: field, op: op, start: start, end: end}, nil
+	}
+	return fieldNode{field: field, text: value}, nil
+}
+
+// an example term of the field, for error messages
+func fieldExample(field string) string {
+	_, kind, _ := lookupField(field)
+	switch kind {
+	case boolField:
+		return field + ":yes"
+	case numberField:
+		return field + ":>=3"
+	case dateField:
+		return field + ":>2026-01-01"
+	}
+	return field + ":review"
+}
+
+// the span of local time a date stands for, a day, a month or a year
+func parseSearchDate(value string) (start, end time.Time, ok bool) {
+	for _, layout := range []struct {
+		format string
+		years  int
+		months int
+		days   int
+	}{
+		{"2006-01-02", 0, 0, 1},
+		{"2006-01", 0, 1, 0},
+		{"2006", 1, 0, 0},
+	} {
+		if len(value) != len(layout.format) {
+			continue
+		}
+		t, err := time.ParseInLocation(layout.format, value, time.Local)
+		if err == nil {
+			return t, t.AddDate(layout.years, layout.months, layout.days), true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// -- Evaluation --
+
+// a prompt being matched, with what matching needs worked out once
+type searchDoc struct {
+	prompt *Prompt
+	terms  map[string]int
+	text   string
+}
+
+// every field of the prompt in one lowercase string
+func (d *searchDoc) allText() string {
+	if d.text == "" {
+		p := d.prompt
+		d.text = strings.ToLower(strings.Join([]string{
+			p.Title, p.Slug, p.Description, p.PromptContent, strings.Join(p.Tags, " "), p.Collection,
+		}, "\n"))
+	}
+	return d.text
+}
+
+func (d *searchDoc) stems() map[string]int {
+	if d.terms == nil {
+		d.terms = promptTerms(d.prompt)
+	}
+	return d.terms
+}
+
+type searchNode interface {
+	match(d *searchDoc) bool
+}
+
+type andNode []searchNode
+
+func (n andNode) match(d *searchDoc) bool {
+	for _, c := range n {
+		if !c.match(d) {
+			return false
+		}
+	}
+	return true
+}
+
+type orNode []searchNode
+
+func (n orNode) match(d *searchDoc) bool {
+	for _, c := range n {
+		if c.match(d) {
+			return true
+		}
+	}
+	return false
+}
+
+type notNode struct {
+	node searchNode
+}
+
+func (n notNode) match(d *searchDoc) bool {
+	return !n.node.match(d)
+}
+
+// a word, found as written or in another form, or a phrase found as written
+type textNode struct {
+	text   string
+	phrase bool
+}
+
+func (n textNode) match(d *searchDoc) bool {
+	if containsFold(d.allText(), n.text) {
+		return true
+	}
+	if n.phrase {
+		// whitespace of the prompt does not have to match the phrase
+		return strings.Contains(strings.Join(strings.Fields(d.allText()), " "), strings.ToLower(n.text))
+	}
+	words := searchWords(n.text)
+	if len(words) == 0 {
+		return false
+	}
+	for _, w := range words {
+		if d.stems()[stem(w)] == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// a value of a text field
+type fieldNode struct {
+	field string
+	text  string
+}
+
+func (n fieldNode) match(d *searchDoc) bool {
+	p := d.prompt
+	switch n.field {
+	case "tag":
+		tag := strings.ToLower(strings.TrimPrefix(n.text, "#"))
+		for _, t := range p.Tags {
+			if t == tag {
+				return true
+			}
+		}
+		return false
+	case "collection":
+		return strings.EqualFold(p.Collection, n.text)
+	case "title":
+		return containsFold(p.Title, n.text)
+	case "slug":
+		return containsFold(p.Slug, n.text)
+	case "description":
+		return containsFold(p.Description, n.text)
+	}
+	return containsFold(p.PromptContent, n.text)
+}
+
+type boolNode struct {
+	field string
+	value bool
+}
+
+func (n boolNode) match(d *searchDoc) bool {
+	return d.prompt.Pinned == n.value
+}
+
+type numberNode struct {
+	field string
+	op    string
+	n     int
+}
+
+func (n numberNode) match(d *searchDoc) bool {
+	uses := d.prompt.Uses
+	switch n.op {
+	case ">":
+		return uses > n.n
+	case ">=":
+		return uses >= n.n
+	case "<":
+		return uses < n.n
+	case "<=":
+		return uses <= n.n
+	}
+	return uses == n.n
+}
+
+// a date compared with the span [start, end)
+type dateNode struct {
+	field      string
+	op         string
+	start, end time.Time
+}
+
+func (n dateNode) match(d *searchDoc) bool {
+	var t time.Time
+	switch n.field {
+	case "created":
+		t = d.prompt.CreatedAt
+	case "updated":
+		t = d.prompt.UpdatedAt
+	default:
+		t = d.prompt.LastUsedAt
+		// never used is neither before nor after anything
+		if t.IsZero() {
+			return false
+		}
+	}
+	switch n.op {
+	case ">":
+		return !t.Before(n.end)
+	case ">=":
+		return !t.Before(n.start)
+	case "<":
+		return t.Before(n.start)
+	case "<=":
+		return t.Before(n.end)
+	}
+	return !t.Before(n.start) && t.Before(n.end)
+}
+
+// reports whether substr is in s, ignoring case
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
@@ -0,0 +1,185 @@
+package vault_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+)
+
+func TestParseSearch_Match(t *testing.T) {
+	day := func(s string) time.Time {
+		d, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	prompts := []vault.Prompt{
+		{ID: 1, Title: "Code review", Slug: "code-review", PromptContent: "Review the code for bugs.", Tags: []string{"review", "code"}, Collection: "Work", Pinned: true, Uses: 5, UpdatedAt: day("2026-02-10 09:00")},
+		{ID: 2, Title: "Draft review", Slug: "draft-review", PromptContent: "An exact  phrase\nto match.\nAt 10:30, see https://example.com/a.", Tags: []string{"review", "draft"}, UpdatedAt: day("2025-12-31 23:59")},
+		{ID: 3, Title: "Summary", Slug: "summary", Description: "Summarizes articles", PromptContent: "Summarize the article.", Uses: 1, UpdatedAt: day("2026-01-01 00:00"), LastUsedAt: day("2026-01-05 12:00")},
+	}
+	tests := []struct {
+		name  string // description of this test case
+		query string
+		want  []int
+	}{
+		{"Empty", "  ", []int{1, 2, 3}},
+		{"Word in any form", "reviewing", []int{1, 2}},
+		{"Words are all needed", "review bugs", []int{1}},
+		{"Part of a word", "summar", []int{3}},
+		{"Phrase", `"exact phrase to"`, []int{2}},
+		{"Phrase in order", `"phrase exact"`, nil},
+		{"Tag", "tag:review", []int{1, 2}},
+		{"Tag with a hash", "tag:#draft", []int{2}},
+		{"Negation", "tag:review -draft", []int{1}},
+		{"Negated group", "-(tag:draft OR pinned:yes)", []int{3}},
+		{"Or", "tag:draft OR collection:work", []int{1, 2}},
+		{"Or binds looser than and", "tag:review pinned:yes OR summary", []int{1, 3}},
+		{"Field phrase", `title:"code review"`, []int{1}},
+		{"Field ignores case", "TITLE:SUMMARY", []int{3}},
+		{"Description", "desc:articles", []int{3}},
+		{"Slug", "slug:draft-", []int{2}},
+		{"Not pinned", "pinned:no", []int{2, 3}},
+		{"Uses", "uses:>=1", []int{1, 3}},
+		{"Uses exactly", "uses:5", []int{1}},
+		{"Updated after a day", "updated:>2026-01-01", []int{1}},
+		{"Updated from a day", "updated:>=2026-01-01", []int{1, 3}},
+		{"Updated before a day", "updated:<2026-01-01", []int{2}},
+		{"Updated during a month", "updated:2026-01", []int{3}},
+		{"Updated during a year", "updated:<=2025", []int{2}},
+		{"Never used", "used:<2027", []int{3}},
+		{"Word with a colon", "10:30", []int{2}},
+		{"Link", "https://example.com/a", []int{2}},
+		{"Unknown field is a word", "foo:bar", nil},
+		{"Example", `tag:review updated:>2025-06 "exact phrase" -code`, []int{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := vault.ParseSearch(tt.query)
+			if err != nil {
+				t.Fatalf("ParseSearch(%q) = %v", tt.query, err)
+			}
+			var got []int
+			for i := range prompts {
+				if q.Match(&prompts[i]) {
+					got = append(got, prompts[i].ID)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParseSearch(%q) matches %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSearch_Errors(t *testing.T) {
+	tests := []struct {
+		name       string // description of this test case
+		query      string
+		wantColumn int
+		wantMsg    string
+	}{
+		{"Missing value", "tag:", 1, "tag: needs a value, as in tag:review"},
+		{"Unclosed quote", `review "exact`, 8, "quote is never closed"},
+		{"Empty phrase", `""`, 1, `"" is empty`},
+		{"Unclosed parenthesis", "(a OR b", 1, "( is never closed"},
+		{"Stray parenthesis", "a b)", 4, "unexpected )"},
+		{"Empty parentheses", "a ()", 3, "() is empty"},
+		{"Leading OR", "OR a", 1, "OR needs a term before it"},
+		{"Trailing OR", "a OR", 3, "OR needs a term after it"},
+		{"Lone minus", "a - b", 3, "- needs a term right after it"},
+		{"Bad date", "updated:>2026-13-01", 9, `updated: "2026-13-01" is not a date, expected YYYY-MM-DD`},
+		{"Bad number", "uses:many", 6, `uses: "many" is not a number`},
+		{"Bad bool", "pinned:maybe", 8, `pinned: expected yes or no, got "maybe"`},
+		{"Compared text", "tag:>x", 5, "tag: cannot be compared with >"},
+		{"Comparison without value", "uses:>=", 6, "uses: needs a value after >="},
+		{"Column in runes", "héllo tag:", 7, "tag: needs a value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := vault.ParseSearch(tt.query)
+			var queryErr *vault.QueryError
+			if !errors.As(err, &queryErr) {
+				t.Fatalf("ParseSearch(%q) = %v, want a *QueryError", tt.query, err)
+			}
+			if queryErr.Column != tt.wantColumn || !strings.Contains(queryErr.Message, tt.wantMsg) {
+				t.Errorf("ParseSearch(%q) = column %d %q, want column %d %q", tt.query, queryErr.Column, queryErr.Message, tt.wantColumn, tt.wantMsg)
+			}
+			if !errors.Is(err, vault.ErrValidation) {
+				t.Errorf("ParseSearch(%q) = %v, want it to be a validation error", tt.query, err)
+			}
+		})
+	}
+}
+
+func TestSearchQuery_WordsAndPlain(t *testing.T) {
+	tests := []struct {
+		query     string
+		wantWords []string
+		wantPlain bool
+	}{
+		{"code review", []string{"code", "review"}, true},
+		{"", []string{}, false},
+		{`tag:x "exact phrase" -draft (a OR b)`, []string{"exact phrase", "a", "b"}, false},
+		{"-(a b)", []string{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := vault.ParseSearch(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := q.Words(); !slices.Equal(got, tt.wantWords) {
+				t.Errorf("Words() = %q, want %q", got, tt.wantWords)
+			}
+			if got := q.Plain(); got != tt.wantPlain {
+				t.Errorf("Plain() = %v, want %v", got, tt.wantPlain)
+			}
+		})
+	}
+}
+
+func TestSearchQuery_Highlights(t *testing.T) {
+	q, err := vault.ParseSearch(`review title:"code review" -draft -tag:old uses:>1 (a OR tag:go)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := q.Highlights(), []string{"review", "code review", "a", "go"}; !slices.Equal(got, want) {
+		t.Errorf("Highlights() = %q, want %q", got, want)
+	}
+}
+
+func TestPromptService_Search_Integration(t *testing.T) {
+	ctx := context.Background()
+	service := newTestService(t,
+		vault.Prompt{Title: "Code review", PromptContent: "Review the code. Review it twice.", Tags: []string{"review"}},
+		vault.Prompt{Title: "Draft", PromptContent: "A review of the draft.", Tags: []string{"review", "draft"}},
+		vault.Prompt{Title: "Pinned", PromptContent: "Something else.", Tags: []string{"review"}, Pinned: true},
+	)
+
+	results, err := service.Search(ctx, "tag:review -draft twice", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Prompt.Title != "Code review" || results[0].Score <= 0 {
+		t.Errorf("Search() = %+v, want the code review with a score", results)
+	}
+
+	results, err = service.Search(ctx, "tag:review", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Errorf("Search() = %d result(s), want the limit of 2", len(results))
+	}
+
+	if _, err := service.Search(ctx, "tag:review (", 0); !errors.Is(err, vault.ErrValidation) {
+		t.Errorf("Search() of a malformed query = %v, want a validation error", err)
+	}
+}
@@ -0,0 +1,175 @@
+package vault
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// SecretFinding is a credential, or something that looks like one, found in a text.
+// Start and End are byte offsets into the scanned text, Line is 1-based.
+type SecretFinding struct {
+	Kind  string
+	Line  int
+	Start int
+	End   int
+}
+
+// patterns of credentials that should never end up in a prompt.
+// more specific patterns come first, as overlapping matches are dropped.
+var secretPatterns = []struct {
+	kind    string
+	pattern *regexp.Regexp
+}{
+	{"private key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----(?s:.*?)(?:-----END [A-Z ]*PRIVATE KEY-----|$)`)},
+	{"Anthropic API key", regexp.MustCompile(`sk-ant-[A-Za-z0-9_-]{20,}`)},
+	{"OpenAI API key", regexp.MustCompile(`sk-(?:proj-)?[A-Za-z0-9_-]{20,}`)},
+	{"AWS access key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"GitHub token", regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`)},
+	{"GitHub token", regexp.MustCompile(`\bgithub_pat_[A-Za-z0-9_]{50,}\b`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`)},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{"Stripe key", regexp.MustCompile(`\b[rs]k_(?:live|test)_[0-9A-Za-z]{16,}\b`)},
+	{"JSON web token", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+	{"bearer token", regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/-]{20,}=*`)},
+}
+
+// candidates for the entropy check: long runs of token-like characters
+var highEntropyCandidate = regexp.MustCompile(`[A-Za-z0-9+/_=-]{24,}`)
+
+// a candidate must have at least this many bits of entropy per character
+// to be reported. random base64 sits around 5-6, english prose below 4.
+// hex only has 16 symbols, so hex strings get a lower bar of their own.
+const (
+	highEntropyThreshold    = 4.2
+	highEntropyHexThreshold = 3.5
+	minHexSecretLength      = 32
+)
+
+// Scans the text for credentials and high entropy strings.
+// Findings are ordered by position and never overlap.
+func ScanSecrets(text string) []SecretFinding {
+	findings := []SecretFinding{}
+
+	covered := func(start, end int) bool {
+		for _, f := range findings {
+			if start < f.End && end > f.Start {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, secret := range secretPatterns {
+		for _, loc := range secret.pattern.FindAllStringIndex(text, -1) {
+			if covered(loc[0], loc[1]) {
+				continue
+			}
+			findings = append(findings, SecretFinding{Kind: secret.kind, Start: loc[0], End: loc[1]})
+		}
+	}
+
+	for _, loc := range highEntropyCandidate.FindAllStringIndex(text, -1) {
+		if covered(loc[0], loc[1]) || !looksRandom(text[loc[0]:loc[1]]) {
+			continue
+		}
+		findings = append(findings, SecretFinding{Kind: "high entropy string", Start: loc[0], End: loc[1]})
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		return findings[i].Start < findings[j].Start
+	})
+	for i := range findings {
+		findings[i].Line = strings.Count(text[:findings[i].Start], "\n") + 1
+	}
+
+	return findings
+}
+
+// Replaces every secret found in the text with a [REDACTED] marker.
+// It returns the redacted text and the number of secrets replaced.
+func RedactSecrets(text string) (string, int) {
+	findings := ScanSecrets(text)
+	if len(findings) == 0 {
+		return text, 0
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, f := range findings {
+		b.WriteString(text[last:f.Start])
+		b.WriteString("[REDACTED]")
+		last = f.End
+	}
+	b.WriteString(text[last:])
+
+	return b.String(), len(findings)
+}
+
+// Returns a copy of the prompt with the secrets in its content redacted,
+// and the number of secrets replaced.
+func RedactPrompt(prompt Prompt) (Prompt, int) {
+	content, n := RedactSecrets(prompt.PromptContent)
+	prompt.PromptContent = content
+	return prompt, n
+}
+
+// reports whether s looks like a random token rather than a word or a path
+func looksRandom(s string) bool {
+	var upper, lower, digits int
+	for _, r := range s {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		case unicode.IsDigit(r):
+			digits++
+		}
+	}
+
+	// identifiers like some_long_variable_name or all-lowercase-slugs
+	// have low character diversity, real tokens mix cases and digits
+	kinds := 0
+	for _, n := range []int{upper, lower, digits} {
+		if n > 0 {
+			kinds++
+		}
+	}
+	if kinds < 2 || digits == 0 {
+		return false
+	}
+
+	if isHex(s) {
+		return len(s) >= minHexSecretLength && shannonEntropy(s) >= highEntropyHexThreshold
+	}
+	return shannonEntropy(s) >= highEntropyThreshold
+}
+
+func isHex(s string) bool {
+	for _, r := range s {
+		if !unicode.Is(unicode.ASCII_Hex_Digit, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// bits of entropy per character of s
+func shannonEntropy(s string) float64 {
+	counts := map[rune]int{}
+	total := 0
+	for _, r := range s {
+		counts[r]++
+		total++
+	}
+
+	entropy := 0.0
+	for _, n := range counts {
+		p := float64(n) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
@@ -1,12 +1,34 @@
 package vault
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
 
 type PromptService interface {
-	CreateOrUpdatePrompt(prompt *Prompt) (*Prompt, error)
-	DeletePrompt(id int) error
-	GetPromptByID(id int) (*Prompt, error)
-	GetAllPrompts() ([]Prompt, error)
+	CreateOrUpdatePrompt(ctx context.Context, prompt *Prompt) (*Prompt, error)
+	CreateOrUpdatePrompts(ctx context.Context, prompts []Prompt) ([]Prompt, error)
+	DeletePrompt(ctx context.Context, id int) error
+	GetPromptByID(ctx context.Context, id int) (*Prompt, error)
+	GetPromptByRef(ctx context.Context, ref string) (*Prompt, error)
+	GetAllPrompts(ctx context.Context) ([]Prompt, error)
+	QueryPrompts(ctx context.Context, query Query) (Page, error)
+	RecordUse(ctx context.Context, id int) (*Prompt, error)
+	RankPrompts(ctx context.Context, query string, limit int) ([]SearchResult, error)
+	Search(ctx context.Context, query string, limit int) ([]SearchResult, error)
+	LintPrompt(ctx context.Context, prompt *Prompt) ([]LintIssue, error)
+	LintVault(ctx context.Context) ([]LintReport, error)
+	RenderPrompt(ctx context.Context, id int) (string, error)
+	GetDependents(ctx context.Context, id int) ([]Prompt, error)
+	ImportPrompts(ctx context.Context, prompts []Prompt) (ImportResult, error)
+	FindDuplicates(ctx context.Context, threshold float64) ([]DuplicateCluster, error)
+	MergePrompts(ctx context.Context, keepID int, duplicateIDs []int) (*Prompt, error)
+	GetTrash(ctx context.Context) ([]TrashedPrompt, error)
+	RestoreTrashed(ctx context.Context, id int) (*Prompt, error)
 }
 
 type promptService struct {
@@ -24,32 +46,311 @@ func NewPromptService(promptRepository PromptRepository) PromptService {
 	Creates or updates an individual prompt.
 	It returns the created or updated prompt and an error if any.
 */
-func (service *promptService) CreateOrUpdatePrompt(prompt *Prompt) (*Prompt, error) {
+func (service *promptService) CreateOrUpdatePrompt(ctx context.Context, prompt *Prompt) (*Prompt, error) {
 	// validate the prompt
-	if prompt.Title == "" {
-		return nil, errors.New("title is required")
+	if err := validatePrompt(prompt); err != nil {
+		return nil, err
 	}
-	if prompt.PromptContent == "" {
-		return nil, errors.New("prompt content is required")
+	return service.promptRepository.CreateOrUpdatePrompt(ctx, prompt)
+}
+
+/*
+	Creates or updates a batch of prompts atomically.
+	Every prompt is validated before anything is written, and cancelling
+	the context mid-way leaves the vault untouched.
+*/
+func (service *promptService) CreateOrUpdatePrompts(ctx context.Context, prompts []Prompt) ([]Prompt, error) {
+	for i := range prompts {
+		if err := validatePrompt(&prompts[i]); err != nil {
+			return nil, err
+		}
 	}
-	return service.promptRepository.CreateOrUpdatePrompt(prompt)
+	return service.promptRepository.CreateOrUpdatePrompts(ctx, prompts)
 }
 
 
 // Deletes a specific prompt by ID.
-func (service *promptService) DeletePrompt(id int) error {
-	return service.promptRepository.DeletePrompt(id)
+func (service *promptService) DeletePrompt(ctx context.Context, id int) error {
+	return service.promptRepository.DeletePrompt(ctx, id)
 }
 
 
 // Gets a specific prompt by ID.
-func (service *promptService) GetPromptByID(id int) (*Prompt, error) {
-	return service.promptRepository.GetPromptByID(id)
+func (service *promptService) GetPromptByID(ctx context.Context, id int) (*Prompt, error) {
+	return service.promptRepository.GetPromptByID(ctx, id)
+}
+
+
+// Gets a prompt by reference: either its numeric ID, its UUID, its slug
+// or one of its former slugs. Used wherever users name a prompt.
+func (service *promptService) GetPromptByRef(ctx context.Context, ref string) (*Prompt, error) {
+	if id, err := strconv.Atoi(ref); err == nil {
+		return service.promptRepository.GetPromptByID(ctx, id)
+	}
+	if ValidUUID(ref) {
+		return service.promptRepository.GetPromptByUUID(ctx, ref)
+	}
+	return service.promptRepository.GetPromptBySlug(ctx, ref)
+}
+
+// Gets all prompts. Records that cannot be read are left out and
+// reported with an *UnreadableError, along with the rest.
+func (service *promptService) GetAllPrompts(ctx context.Context) ([]Prompt, error) {
+	return service.promptRepository.GetAllPrompts(ctx)
 }
 
+// Gets a page of the prompts matching the query.
+// Used by the list view to load the vault a page at a time.
+func (service *promptService) QueryPrompts(ctx context.Context, query Query) (Page, error) {
+	return service.promptRepository.QueryPrompts(ctx, query)
+}
+
+// Counts a use of the prompt, e.g. copying it. Its update time stays
+// as it is, so that using a prompt does not reorder the list.
+func (service *promptService) RecordUse(ctx context.Context, id int) (*Prompt, error) {
+	return service.promptRepository.RecordUse(ctx, id)
+}
+
+// Ranks the prompts by how relevant they are to the query, best first.
+// Words are matched in any form, "reviewing" finds "reviews".
+func (service *promptService) RankPrompts(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	return service.promptRepository.RankPrompts(ctx, query, limit)
+}
+
+// Searches the prompts with the query language of ParseSearch, the
+// prompts matching its words best first. Plain words are a relevance
+// search like RankPrompts, finding prompts with any of them. A malformed
+// query is a *QueryError.
+func (service *promptService) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	q, err := ParseSearch(query)
+	if err != nil {
+		return nil, err
+	}
+	if q.Plain() {
+		return service.promptRepository.RankPrompts(ctx, query, limit)
+	}
+	prompts, err := readable(service.promptRepository.GetAllPrompts(ctx))
+	if err != nil {
+		return nil, err
+	}
 
-// Gets all prompts.
-// Primarily used for the list view and initial loading of the app
-func (service *promptService) GetAllPrompts() ([]Prompt, error) {
-	return service.promptRepository.GetAllPrompts()
+	scores := map[int]float64{}
+	if words := q.Words(); len(words) > 0 {
+		ranked, err := service.promptRepository.RankPrompts(ctx, strings.Join(words, " "), 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range ranked {
+			scores[r.Prompt.ID] = r.Score
+		}
+	}
+
+	results := []SearchResult{}
+	for i := range prompts {
+		if q.Match(&prompts[i]) {
+			results = append(results, SearchResult{Prompt: prompts[i], Score: scores[prompts[i].ID]})
+		}
+	}
+	return sortResults(results, limit), nil
+}
+
+// Lints a prompt against the rest of the vault without saving it.
+// Used to show warnings before a prompt is saved.
+func (service *promptService) LintPrompt(ctx context.Context, prompt *Prompt) ([]LintIssue, error) {
+	prompts, err := readable(service.promptRepository.GetAllPrompts(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return Lint(prompt, prompts), nil
+}
+
+// Lints every prompt in the vault.
+func (service *promptService) LintVault(ctx context.Context) ([]LintReport, error) {
+	prompts, err := readable(service.promptRepository.GetAllPrompts(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return LintAll(prompts), nil
+}
+
+// Renders the content of a prompt with its includes expanded.
+// This is what gets copied or printed.
+func (service *promptService) RenderPrompt(ctx context.Context, id int) (string, error) {
+	prompts, err := readable(service.promptRepository.GetAllPrompts(ctx))
+	if err != nil {
+		return "", err
+	}
+
+	prompt, ok := ResolveRef(prompts, strconv.Itoa(id))
+	if !ok {
+		return "", notFoundError(id)
+	}
+	return ExpandIncludes(prompt, prompts)
+}
+
+// Gets the prompts that include the prompt with the given id.
+// Used to warn before deleting a prompt others depend on.
+func (service *promptService) GetDependents(ctx context.Context, id int) ([]Prompt, error) {
+	prompts, err := readable(service.promptRepository.GetAllPrompts(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return Dependents(id, prompts), nil
+}
+
+// Imports prompts exported from another vault, matching them by UUID.
+// Nothing is written unless every prompt to write is valid, nor while
+// records of the vault cannot be read: their prompts would look absent
+// and be imported a second time.
+func (service *promptService) ImportPrompts(ctx context.Context, prompts []Prompt) (ImportResult, error) {
+	local, err := service.promptRepository.GetAllPrompts(ctx)
+	if errors.Is(err, ErrUnreadable) {
+		return ImportResult{}, fmt.Errorf("cannot import: %w", err)
+	}
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	writes, result := planImport(local, prompts)
+	if len(writes) == 0 {
+		return result, nil
+	}
+	if _, err := service.CreateOrUpdatePrompts(ctx, writes); err != nil {
+		return ImportResult{}, err
+	}
+	return result, nil
+}
+
+// Finds the groups of prompts whose content is at least threshold similar.
+func (service *promptService) FindDuplicates(ctx context.Context, threshold float64) ([]DuplicateCluster, error) {
+	prompts, err := readable(service.promptRepository.GetAllPrompts(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return FindDuplicates(prompts, threshold), nil
+}
+
+// Merges duplicates into the prompt with id keepID and moves them to the
+// trash. The kept prompt gains their metadata, see MergeMetadata, and
+// their slugs as aliases, and prompts including a duplicate are changed to
+// include the kept prompt instead, save the kept prompt itself: that would
+// include itself, the duplicates it includes are replaced by their content.
+// Everything is written in a single batch, and nothing while records of
+// the vault cannot be read, as their includes could not be redirected.
+func (service *promptService) MergePrompts(ctx context.Context, keepID int, duplicateIDs []int) (*Prompt, error) {
+	prompts, err := service.promptRepository.GetAllPrompts(ctx)
+	if errors.Is(err, ErrUnreadable) {
+		return nil, fmt.Errorf("cannot merge: %w", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	byID := map[int]Prompt{}
+	for _, p := range prompts {
+		byID[p.ID] = p
+	}
+	keep, ok := byID[keepID]
+	if !ok {
+		return nil, notFoundError(keepID)
+	}
+	duplicates := []Prompt{}
+	from := map[int]bool{}
+	for _, id := range duplicateIDs {
+		p, ok := byID[id]
+		if !ok {
+			return nil, notFoundError(id)
+		}
+		if id == keepID || from[id] {
+			continue
+		}
+		duplicates = append(duplicates, p)
+		from[id] = true
+	}
+
+	merged := MergeMetadata(keep, duplicates)
+	merged.PromptContent = InlineIncludes(merged.PromptContent, prompts, from)
+	for _, p := range duplicates {
+		// references to the duplicates by slug keep working
+		for _, slug := range append([]string{p.Slug}, p.Aliases...) {
+			if slug != "" && !slices.Contains(merged.Aliases, slug) {
+				merged.Aliases = append(merged.Aliases, slug)
+			}
+		}
+	}
+
+	writes := []Prompt{merged}
+	if len(duplicates) > 0 {
+		ref := keep.Slug
+		if ref == "" {
+			ref = fmt.Sprint(keep.ID)
+		}
+		for _, p := range prompts {
+			if p.ID == keepID || from[p.ID] {
+				continue
+			}
+			if content := RedirectIncludes(p.PromptContent, prompts, from, ref); content != p.PromptContent {
+				p.PromptContent = content
+				writes = append(writes, p)
+			}
+		}
+	}
+
+	for i := range writes {
+		if err := validatePrompt(&writes[i]); err != nil {
+			return nil, err
+		}
+	}
+	trashed := make([]int, len(duplicates))
+	for i, p := range duplicates {
+		trashed[i] = p.ID
+	}
+	saved, err := service.promptRepository.ReplacePrompts(ctx, writes, trashed)
+	if err != nil {
+		return nil, err
+	}
+	return &saved[0], nil
+}
+
+// Gets the prompts merges moved to the trash, most recently trashed first.
+func (service *promptService) GetTrash(ctx context.Context) ([]TrashedPrompt, error) {
+	return service.promptRepository.GetTrash(ctx)
+}
+
+// Puts a prompt back from the trash, under its id. It keeps its slug unless
+// another prompt took it meanwhile, as the one it was merged into does,
+// and gets a new one made from its title then.
+func (service *promptService) RestoreTrashed(ctx context.Context, id int) (*Prompt, error) {
+	return service.promptRepository.RestoreTrashed(ctx, id)
+}
+
+// checks the required fields of a prompt, normalizing its tags and collection.
+// every failing field is reported, joined into a single error
+// so that the tui can show each message next to its input.
+func validatePrompt(prompt *Prompt) error {
+	var errs []error
+	if strings.TrimSpace(prompt.Title) == "" {
+		errs = append(errs, &ValidationError{Field: FieldTitle, Message: "is required"})
+	}
+	if strings.TrimSpace(prompt.PromptContent) == "" {
+		errs = append(errs, &ValidationError{Field: FieldContent, Message: "is required"})
+	}
+	if prompt.Slug != "" && !ValidSlug(prompt.Slug) {
+		errs = append(errs, &ValidationError{Field: FieldSlug, Message: "must be lowercase letters and digits separated by dashes"})
+	}
+	for _, name := range prompt.Variables {
+		if !variableNamePattern.MatchString(name) {
+			errs = append(errs, &ValidationError{Field: FieldVariables, Message: fmt.Sprintf("has an invalid name %q", name)})
+			break
+		}
+	}
+	prompt.Tags = NormalizeTags(prompt.Tags)
+	for _, tag := range prompt.Tags {
+		if !validTag(tag) {
+			errs = append(errs, &ValidationError{Field: FieldTags, Message: fmt.Sprintf("has an invalid tag %q", tag)})
+			break
+		}
+	}
+	prompt.Collection = strings.TrimSpace(prompt.Collection)
+	return errors.Join(errs...)
 }
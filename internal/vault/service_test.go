@@ -1,9 +1,17 @@
 package vault
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"io"
+	"log/slog"
 	"reflect"
+	"slices"
 	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
 )
 
 func TestCreateOrUpdatePrompt_Unit(t *testing.T) {
@@ -70,7 +78,7 @@ func TestCreateOrUpdatePrompt_Unit(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			repo.failCreateOrUpdate = tt.failCreateOrUpdate
-			got, gotErr := service.CreateOrUpdatePrompt(tt.prompt)
+			got, gotErr := service.CreateOrUpdatePrompt(context.Background(), tt.prompt)
 			if gotErr != nil {
 				if !tt.wantErr {
 					t.Errorf("CreateOrUpdatePrompt() failed: %v", gotErr)
@@ -127,7 +135,7 @@ func Test_promptService_DeletePrompt(t *testing.T) {
 
 
 			repo.failDelete = tt.failDelete
-			gotErr := service.DeletePrompt(tt.id)
+			gotErr := service.DeletePrompt(context.Background(), tt.id)
 			if gotErr != nil {
 				if !tt.wantErr {
 					t.Errorf("DeletePrompt() failed: %v", gotErr)
@@ -179,7 +187,7 @@ func Test_promptService_GetPromptByID(t *testing.T) {
 				PromptContent: "test prompt content",
 			}
 			repo.failGetByID = tt.failGetByID
-			got, gotErr := service.GetPromptByID(tt.id)
+			got, gotErr := service.GetPromptByID(context.Background(), tt.id)
 			if gotErr != nil {
 				if !tt.wantErr {
 					t.Errorf("GetPromptByID() failed: %v", gotErr)
@@ -232,7 +240,7 @@ func Test_promptService_GetAllPrompts(t *testing.T) {
 				PromptContent: "test prompt content",
 			}
 			repo.failGetAll = tt.failGetAll
-			got, gotErr := service.GetAllPrompts()
+			got, gotErr := service.GetAllPrompts(context.Background())
 			if gotErr != nil {
 				if !tt.wantErr {
 					t.Errorf("GetAllPrompts() failed: %v", gotErr)
@@ -252,9 +260,146 @@ func Test_promptService_GetAllPrompts(t *testing.T) {
 }
 
 
+func Test_promptService_CreateOrUpdatePrompts(t *testing.T) {
+	tests := []struct {
+		name    string // description of this test case
+		prompts []Prompt
+		wantErr bool
+		wantLen int
+	}{
+		{
+			name: "Create Prompts Success",
+			prompts: []Prompt{
+				{Title: "first", PromptContent: "first content"},
+				{Title: "second", PromptContent: "second content"},
+			},
+			wantErr: false,
+			wantLen: 2,
+		},
+		{
+			name: "Create Prompts Failure - one prompt is invalid",
+			prompts: []Prompt{
+				{Title: "first", PromptContent: "first content"},
+				{Title: "", PromptContent: "second content"},
+			},
+			wantErr: true,
+			wantLen: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewFakePromptRepository()
+			service := NewPromptService(repo)
+
+			_, gotErr := service.CreateOrUpdatePrompts(context.Background(), tt.prompts)
+			if gotErr != nil {
+				if !tt.wantErr {
+					t.Errorf("CreateOrUpdatePrompts() failed: %v", gotErr)
+				}
+			} else if tt.wantErr {
+				t.Fatal("CreateOrUpdatePrompts() succeeded unexpectedly")
+			}
+
+			// validation happens before anything is written
+			if len(repo.prompts) != tt.wantLen {
+				t.Errorf("repository holds %d prompts, want %d", len(repo.prompts), tt.wantLen)
+			}
+		})
+	}
+}
+func Test_promptService_CreateOrUpdatePrompt_ValidationErrors(t *testing.T) {
+	tests := []struct {
+		name       string // description of this test case
+		prompt     *Prompt
+		wantFields []string
+	}{
+		{
+			name:       "Title is empty",
+			prompt:     &Prompt{Title: "", PromptContent: "test prompt content"},
+			wantFields: []string{FieldTitle},
+		},
+		{
+			name:       "Title and content are blank",
+			prompt:     &Prompt{Title: "   ", PromptContent: "\n"},
+			wantFields: []string{FieldTitle, FieldContent},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewPromptService(NewFakePromptRepository())
+
+			_, gotErr := service.CreateOrUpdatePrompt(context.Background(), tt.prompt)
+			if !errors.Is(gotErr, ErrValidation) {
+				t.Fatalf("CreateOrUpdatePrompt() error = %v, want ErrValidation", gotErr)
+			}
+
+			fields := FieldErrors(gotErr)
+			if len(fields) != len(tt.wantFields) {
+				t.Fatalf("FieldErrors() = %v, want fields %v", fields, tt.wantFields)
+			}
+			for _, field := range tt.wantFields {
+				if _, ok := fields[field]; !ok {
+					t.Errorf("FieldErrors() is missing field %q", field)
+				}
+			}
+		})
+	}
+}
+
+func Test_promptService_SentinelErrors_Integration(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	service := NewPromptService(NewPromptRepository(db, slog.New(slog.NewTextHandler(io.Discard, nil))))
+
+	saved, err := service.CreateOrUpdatePrompt(ctx, &Prompt{Title: "Review", PromptContent: "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a missing id is not found, and not a storage failure
+	if _, err := service.GetPromptByID(ctx, 42); !errors.Is(err, ErrNotFound) || errors.Is(err, ErrStorage) {
+		t.Errorf("GetPromptByID() of a missing id error = %v, want ErrNotFound", err)
+	}
+	if err := service.DeletePrompt(ctx, 42); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeletePrompt() of a missing id error = %v, want ErrNotFound", err)
+	}
+
+	// a record that cannot be decoded is a storage failure, with its cause
+	err = db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(promptsBucket).Put(itob(uint64(saved.ID)), []byte("{not json"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = service.GetPromptByID(ctx, saved.ID)
+	var storageErr *StorageError
+	if !errors.Is(err, ErrStorage) || !errors.As(err, &storageErr) || storageErr.Op != "decode prompt" {
+		t.Fatalf("GetPromptByID() of a corrupt record error = %v, want a *StorageError decoding it", err)
+	}
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) || errors.Is(err, ErrNotFound) || FieldErrors(err) != nil {
+		t.Errorf("GetPromptByID() of a corrupt record error = %v, want it to unwrap to the json error only", err)
+	}
+
+	// every invalid field survives errors.Join, and nothing is written
+	_, err = service.CreateOrUpdatePrompt(ctx, &Prompt{Slug: "Not A Slug"})
+	if !errors.Is(err, ErrValidation) || errors.Is(err, ErrStorage) {
+		t.Fatalf("CreateOrUpdatePrompt() of an invalid prompt error = %v, want ErrValidation", err)
+	}
+	fields := FieldErrors(err)
+	for _, field := range []string{FieldTitle, FieldContent, FieldSlug} {
+		if _, ok := fields[field]; !ok {
+			t.Errorf("FieldErrors() = %v, want a message for %s", fields, field)
+		}
+	}
+	if _, err := service.GetPromptByID(ctx, saved.ID+1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetPromptByID() after a failed validation error = %v, want nothing written", err)
+	}
+}
 
 type fakePromptRepository struct {
 	prompts            map[int]*Prompt
+	trash              map[int]TrashedPrompt
 	nextID             int
 	failCreateOrUpdate bool
 	failDelete         bool
@@ -266,21 +411,22 @@ type fakePromptRepository struct {
 func NewFakePromptRepository() *fakePromptRepository {
 	return &fakePromptRepository{
 		prompts: make(map[int]*Prompt),
+		trash:   make(map[int]TrashedPrompt),
 		nextID:  0,
 	}
 }
 
-func (repo *fakePromptRepository) CreateOrUpdatePrompt(prompt *Prompt) (*Prompt, error) {
+func (repo *fakePromptRepository) CreateOrUpdatePrompt(ctx context.Context, prompt *Prompt) (*Prompt, error) {
 	if prompt.Title == "" {
-		return nil, errors.New("title is required")
+		return nil, &ValidationError{Field: FieldTitle, Message: "is required"}
 	}
 
 	if prompt.PromptContent == "" {
-		return nil, errors.New("prompt content is required")
+		return nil, &ValidationError{Field: FieldContent, Message: "is required"}
 	}
 
 	if repo.failCreateOrUpdate {
-		return nil, errors.New("failed to create or update prompt")
+		return nil, &StorageError{Op: "write prompt", Err: errors.New("failed to create or update prompt")}
 	}
 
 	if (prompt.ID == 0) {
@@ -291,34 +437,149 @@ func (repo *fakePromptRepository) CreateOrUpdatePrompt(prompt *Prompt) (*Prompt,
 	return prompt, nil
 }
 
-func (repo *fakePromptRepository) DeletePrompt(id int) error {
+func (repo *fakePromptRepository) CreateOrUpdatePrompts(ctx context.Context, prompts []Prompt) ([]Prompt, error) {
+	if repo.failCreateOrUpdate {
+		return nil, &StorageError{Op: "write prompts", Err: errors.New("failed to create or update prompts")}
+	}
+
+	saved := make([]Prompt, 0, len(prompts))
+	for i := range prompts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		prompt := prompts[i]
+		if _, err := repo.CreateOrUpdatePrompt(ctx, &prompt); err != nil {
+			return nil, err
+		}
+		saved = append(saved, prompt)
+	}
+	return saved, nil
+}
+
+func (repo *fakePromptRepository) DeletePrompt(ctx context.Context, id int) error {
 	if repo.failDelete {
-		return errors.New("failed to delete prompt")
+		return &StorageError{Op: "delete prompt", Err: errors.New("failed to delete prompt")}
+	}
+	if _, exists := repo.prompts[id]; !exists {
+		return notFoundError(id)
 	}
 	delete(repo.prompts, id)
 	return nil
 }
 
-func (repo *fakePromptRepository) GetPromptByID(id int) (*Prompt, error) {
+func (repo *fakePromptRepository) ReplacePrompts(ctx context.Context, prompts []Prompt, trashed []int) ([]Prompt, error) {
+	if repo.failDelete && len(trashed) > 0 {
+		return nil, &StorageError{Op: "delete prompt", Err: errors.New("failed to delete prompt")}
+	}
+	for _, id := range trashed {
+		if _, exists := repo.prompts[id]; !exists {
+			return nil, notFoundError(id)
+		}
+	}
+	saved, err := repo.CreateOrUpdatePrompts(ctx, prompts)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range trashed {
+		repo.trash[id] = TrashedPrompt{Prompt: *repo.prompts[id], TrashedAt: time.Now()}
+		delete(repo.prompts, id)
+	}
+	return saved, nil
+}
+
+func (repo *fakePromptRepository) GetTrash(ctx context.Context) ([]TrashedPrompt, error) {
+	trash := []TrashedPrompt{}
+	for _, t := range repo.trash {
+		trash = append(trash, t)
+	}
+	sortTrash(trash)
+	return trash, nil
+}
+
+func (repo *fakePromptRepository) RestoreTrashed(ctx context.Context, id int) (*Prompt, error) {
+	trashed, ok := repo.trash[id]
+	if !ok {
+		return nil, notFoundError(id)
+	}
+	delete(repo.trash, id)
+	repo.prompts[id] = &trashed.Prompt
+	return &trashed.Prompt, nil
+}
+
+func (repo *fakePromptRepository) GetPromptByID(ctx context.Context, id int) (*Prompt, error) {
 	if repo.failGetByID {
-		return nil, errors.New("prompt not found")
+		return nil, notFoundError(id)
 	}
 
 	// check if the prompt exists
 	prompt, exists := repo.prompts[id]
 	if !exists {
-		return nil, errors.New("prompt not found")
+		return nil, notFoundError(id)
 	}
 	return prompt, nil
 }
 
-func (repo *fakePromptRepository) GetAllPrompts() ([]Prompt, error) {
+func (repo *fakePromptRepository) GetPromptBySlug(ctx context.Context, slug string) (*Prompt, error) {
+	if repo.failGetByID {
+		return nil, ErrNotFound
+	}
+
+	for _, prompt := range repo.prompts {
+		if prompt.Slug == slug || slices.Contains(prompt.Aliases, slug) {
+			return prompt, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (repo *fakePromptRepository) GetPromptByUUID(ctx context.Context, uuid string) (*Prompt, error) {
+	if repo.failGetByID {
+		return nil, ErrNotFound
+	}
+
+	for _, prompt := range repo.prompts {
+		if prompt.UUID == uuid {
+			return prompt, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (repo *fakePromptRepository) GetAllPrompts(ctx context.Context) ([]Prompt, error) {
 	if repo.failGetAll {
-		return nil, errors.New("failed to get all prompts")
+		return nil, &StorageError{Op: "read prompts", Err: errors.New("failed to get all prompts")}
 	}
 	var prompts []Prompt
 	for _, prompt := range repo.prompts {
 		prompts = append(prompts, *prompt)
 	}
 	return prompts, nil
-}
\ No newline at end of file
+}
+
+func (repo *fakePromptRepository) RecordUse(ctx context.Context, id int) (*Prompt, error) {
+	prompt, ok := repo.prompts[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	recordUse(prompt)
+	return prompt, nil
+}
+
+func (repo *fakePromptRepository) QueryPrompts(ctx context.Context, query Query) (Page, error) {
+	if err := query.normalize(); err != nil {
+		return Page{}, err
+	}
+	prompts, err := repo.GetAllPrompts(ctx)
+	if err != nil {
+		return Page{}, err
+	}
+	return queryPrompts(prompts, query)
+}
+
+func (repo *fakePromptRepository) RankPrompts(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	prompts, err := repo.GetAllPrompts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return rankPrompts(prompts, query, limit), nil
+}
@@ -0,0 +1,222 @@
+package vault
+
+import (
+	"cmp"
+	"hash/fnv"
+	"slices"
+	"strings"
+)
+
+// the similarity pvt dupes and the tui look for unless told otherwise
+const DefaultSimilarity = 0.8
+
+// contents are compared as sets of overlapping runs of this many words
+const shingleSize = 3
+
+// MinHash signatures are this many hashes long, split into bands of
+// bandRows hashes. prompts sharing a band are compared, which catches
+// pairs above ~0.6 similar almost always and pairs below ~0.3 rarely.
+const (
+	signatureSize = 128
+	bandRows      = 4
+)
+
+// seeds of the hash functions of the signatures, the same on every run
+var signatureSeeds = func() []uint64 {
+	seeds := make([]uint64, signatureSize)
+	state := uint64(0x9E3779B97F4A7C15)
+	for i := range seeds {
+		state += 0x9E3779B97F4A7C15
+		seeds[i] = mix64(state)
+	}
+	return seeds
+}()
+
+// DuplicateCluster is a group of prompts with near identical content.
+type DuplicateCluster struct {
+	// the prompt most worth keeping comes first: pinned, most used,
+	// then most recently updated
+	Prompts []Prompt
+
+	// every prompt is at least this similar to another one of the
+	// cluster, between 0 and 1
+	Similarity float64
+}
+
+// Returns how similar the contents are, from 0 for nothing in common to
+// 1 for the same words in the same order. Case and whitespace are ignored.
+func Similarity(a, b string) float64 {
+	return jaccard(shingles(a), shingles(b))
+}
+
+// Finds the groups of prompts whose content is at least threshold similar,
+// see Similarity. Candidates are found with MinHash, so very low
+// thresholds may miss some pairs. The clusters come most similar first.
+func FindDuplicates(prompts []Prompt, threshold float64) []DuplicateCluster {
+	sets := make([]map[uint64]bool, len(prompts))
+	buckets := map[uint64][]int{}
+	for i := range prompts {
+		sets[i] = shingles(prompts[i].PromptContent)
+		if len(sets[i]) == 0 {
+			continue
+		}
+		signature := minHash(sets[i])
+		for band := 0; band < signatureSize/bandRows; band++ {
+			key := bandKey(band, signature[band*bandRows:(band+1)*bandRows])
+			buckets[key] = append(buckets[key], i)
+		}
+	}
+
+	// link the candidates that really are similar
+	parent := make([]int, len(prompts))
+	for i := range parent {
+		parent[i] = i
+	}
+	var root func(int) int
+	root = func(i int) int {
+		if parent[i] != i {
+			parent[i] = root(parent[i])
+		}
+		return parent[i]
+	}
+
+	lowest := map[int]float64{}
+	compared := map[[2]int]bool{}
+	for _, candidates := range buckets {
+		for x := 0; x < len(candidates); x++ {
+			for y := x + 1; y < len(candidates); y++ {
+				pair := [2]int{candidates[x], candidates[y]}
+				if compared[pair] {
+					continue
+				}
+				compared[pair] = true
+
+				similarity := jaccard(sets[pair[0]], sets[pair[1]])
+				if similarity < threshold {
+					continue
+				}
+				a, b := root(pair[0]), root(pair[1])
+				low := similarity
+				for _, r := range []int{a, b} {
+					if s, ok := lowest[r]; ok {
+						low = min(low, s)
+					}
+				}
+				if a != b {
+					parent[b] = a
+					delete(lowest, b)
+				}
+				lowest[a] = low
+			}
+		}
+	}
+
+	members := map[int][]Prompt{}
+	for i := range prompts {
+		r := root(i)
+		members[r] = append(members[r], prompts[i])
+	}
+	clusters := []DuplicateCluster{}
+	for r, group := range members {
+		if len(group) < 2 {
+			continue
+		}
+		slices.SortFunc(group, compareKeepers)
+		clusters = append(clusters, DuplicateCluster{Prompts: group, Similarity: lowest[r]})
+	}
+	slices.SortFunc(clusters, func(a, b DuplicateCluster) int {
+		if c := cmp.Compare(b.Similarity, a.Similarity); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Prompts[0].ID, b.Prompts[0].ID)
+	})
+	return clusters
+}
+
+// orders prompts by how much they are worth keeping, best first
+func compareKeepers(a, b Prompt) int {
+	if a.Pinned != b.Pinned {
+		if a.Pinned {
+			return -1
+		}
+		return 1
+	}
+	if c := cmp.Compare(b.Uses, a.Uses); c != 0 {
+		return c
+	}
+	if c := b.UpdatedAt.Compare(a.UpdatedAt); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.ID, b.ID)
+}
+
+// hashes of the runs of shingleSize words of the content. content shorter
+// than that is a single shingle.
+func shingles(content string) map[uint64]bool {
+	words := strings.Fields(strings.ToLower(content))
+	set := map[uint64]bool{}
+	if len(words) == 0 {
+		return set
+	}
+	if len(words) < shingleSize {
+		set[hashString(strings.Join(words, " "))] = true
+		return set
+	}
+	for i := 0; i+shingleSize <= len(words); i++ {
+		set[hashString(strings.Join(words[i:i+shingleSize], " "))] = true
+	}
+	return set
+}
+
+// the share of shingles the sets have in common
+func jaccard(a, b map[uint64]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	shared := 0
+	for h := range a {
+		if b[h] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// the lowest value of every hash function over the set. two sets agree on
+// a hash about as often as they are similar.
+func minHash(set map[uint64]bool) []uint64 {
+	signature := make([]uint64, signatureSize)
+	for i := range signature {
+		signature[i] = ^uint64(0)
+	}
+	for h := range set {
+		for i, seed := range signatureSeeds {
+			signature[i] = min(signature[i], mix64(h^seed))
+		}
+	}
+	return signature
+}
+
+func bandKey(band int, rows []uint64) uint64 {
+	key := mix64(uint64(band) + 1)
+	for _, v := range rows {
+		key = mix64(key ^ v)
+	}
+	return key
+}
+
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// the splitmix64 finalizer, spreads the bits of x over the whole word
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xBF58476D1CE4E5B9
+	x ^= x >> 27
+	x *= 0x94D049BB133111EB
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,170 @@
+package vault_test
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+)
+
+const reviewContent = "Review the following code for bugs, security issues and style problems. " +
+	"Point out anything that would fail in production and suggest a fix for each issue you find. " +
+	"Keep the review short and group the findings by severity."
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		name    string // description of this test case
+		a, b    string
+		wantMin float64
+		wantMax float64
+	}{
+		{"Identical", reviewContent, reviewContent, 1, 1},
+		{"Case and whitespace", reviewContent, "  " + strings.ToUpper(reviewContent) + "\n", 1, 1},
+		{"One word changed", reviewContent, strings.Replace(reviewContent, "short", "brief", 1), 0.8, 0.95},
+		{"Unrelated", reviewContent, "Summarize this article in three bullet points for a busy executive.", 0, 0.05},
+		{"Short", "hello", "hello", 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := vault.Similarity(tt.a, tt.b)
+			if got < tt.wantMin || got > tt.wantMax {
+				t.Errorf("Similarity() = %.3f, want between %.2f and %.2f", got, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	prompts := []vault.Prompt{
+		{ID: 1, Title: "Review", PromptContent: reviewContent},
+		{ID: 2, Title: "Review copy", PromptContent: strings.Replace(reviewContent, "short", "brief", 1), Uses: 5},
+		{ID: 3, Title: "Review again", PromptContent: reviewContent + " Thanks."},
+		{ID: 4, Title: "Summary", PromptContent: "Summarize this article in three bullet points for a busy executive."},
+		{ID: 5, Title: "Summary copy", PromptContent: "summarize this article in three bullet points for a busy executive.", Pinned: true},
+		{ID: 6, Title: "Translate", PromptContent: "Translate the text into French, keeping the tone and the formatting."},
+	}
+
+	got := vault.FindDuplicates(prompts, vault.DefaultSimilarity)
+	ids := [][]int{}
+	for _, c := range got {
+		cluster := []int{}
+		for _, p := range c.Prompts {
+			cluster = append(cluster, p.ID)
+		}
+		ids = append(ids, cluster)
+	}
+	// most similar first, the prompt most worth keeping first within each
+	want := [][]int{{5, 4}, {2, 1, 3}}
+	if fmt.Sprint(ids) != fmt.Sprint(want) {
+		t.Fatalf("FindDuplicates() = %v, want %v", ids, want)
+	}
+	if got[0].Similarity != 1 || got[1].Similarity < vault.DefaultSimilarity || got[1].Similarity >= 1 {
+		t.Errorf("FindDuplicates() similarities = %.3f, %.3f, want 1 and between %.1f and 1", got[0].Similarity, got[1].Similarity, vault.DefaultSimilarity)
+	}
+
+	if got := vault.FindDuplicates(prompts, 1); len(got) != 1 {
+		t.Errorf("FindDuplicates() at 1 = %d clusters, want only the exact copies", len(got))
+	}
+	if got := vault.FindDuplicates(nil, vault.DefaultSimilarity); len(got) != 0 {
+		t.Errorf("FindDuplicates() of no prompts = %v, want none", got)
+	}
+}
+
+func TestMergeMetadata(t *testing.T) {
+	keep := vault.Prompt{ID: 1, Title: "Review", PromptContent: "c", Tags: []string{"code"}, Variables: []string{"language"}}
+	got := vault.MergeMetadata(keep, []vault.Prompt{
+		{ID: 2, Description: "Reviews code", Tags: []string{"review", "code"}, Variables: []string{"tone", "language"}},
+		{ID: 3, Description: "Another", Collection: "work", Pinned: true},
+	})
+
+	if got.ID != 1 || got.Title != "Review" || got.PromptContent != "c" {
+		t.Errorf("MergeMetadata() = %+v, want the kept prompt", got)
+	}
+	if !slices.Equal(got.Tags, []string{"code", "review"}) || !slices.Equal(got.Variables, []string{"language", "tone"}) {
+		t.Errorf("MergeMetadata() tags = %v, variables = %v, want them combined", got.Tags, got.Variables)
+	}
+	if got.Description != "Reviews code" || got.Collection != "work" || !got.Pinned {
+		t.Errorf("MergeMetadata() = %q, %q, pinned %v, want the gaps filled from the duplicates", got.Description, got.Collection, got.Pinned)
+	}
+	if !slices.Equal(keep.Tags, []string{"code"}) {
+		t.Errorf("MergeMetadata() changed the tags of keep to %v", keep.Tags)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	got := vault.DiffLines("a\nb\nc\nd", "a\nx\nc\nd\ne")
+	want := []vault.DiffLine{
+		{Op: vault.DiffEqual, Text: "a"},
+		{Op: vault.DiffDelete, Text: "b"},
+		{Op: vault.DiffInsert, Text: "x"},
+		{Op: vault.DiffEqual, Text: "c"},
+		{Op: vault.DiffEqual, Text: "d"},
+		{Op: vault.DiffInsert, Text: "e"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("DiffLines() = %v, want %v", got, want)
+	}
+}
+
+func TestPromptService_MergePrompts_Integration(t *testing.T) {
+	ctx := context.Background()
+	service := newTestService(t,
+		vault.Prompt{Title: "Review", PromptContent: reviewContent, Tags: []string{"code"}},
+		vault.Prompt{Title: "Review copy", PromptContent: reviewContent, Tags: []string{"review"}, Collection: "work"},
+		vault.Prompt{Title: "Senior", PromptContent: "{{> review-copy}}\nBe thorough. {{> 2}} {{> review}}"},
+	)
+
+	merged, err := service.MergePrompts(ctx, 1, []int{2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(merged.Tags, []string{"code", "review"}) || merged.Collection != "work" {
+		t.Errorf("MergePrompts() = %+v, want the metadata merged", merged)
+	}
+	if _, err := service.GetPromptByID(ctx, 2); err == nil {
+		t.Error("GetPromptByID() of the merged duplicate succeeded, want it trashed")
+	}
+	if trash, err := service.GetTrash(ctx); err != nil || len(trash) != 1 || trash[0].Prompt.Title != "Review copy" {
+		t.Errorf("GetTrash() = %+v, %v, want the merged duplicate", trash, err)
+	}
+	if p, err := service.GetPromptByRef(ctx, "review-copy"); err != nil || p.ID != 1 {
+		t.Errorf("GetPromptByRef() of the merged duplicate = %+v, %v, want the kept prompt", p, err)
+	}
+
+	senior, err := service.GetPromptByID(ctx, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{{> review}}\nBe thorough. {{> review}} {{> review}}"; senior.PromptContent != want {
+		t.Errorf("content of the dependent = %q, want %q", senior.PromptContent, want)
+	}
+	if _, err := service.RenderPrompt(ctx, 3); err != nil {
+		t.Errorf("RenderPrompt() of the dependent = %v, want it to still render", err)
+	}
+
+	if _, err := service.MergePrompts(ctx, 1, []int{42}); err == nil {
+		t.Error("MergePrompts() of a missing prompt succeeded, want an error")
+	}
+
+	// a duplicate the kept prompt includes would turn into an include of itself
+	part, err := service.CreateOrUpdatePrompt(ctx, &vault.Prompt{Title: "Part", PromptContent: "Check the tests."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	whole, err := service.CreateOrUpdatePrompt(ctx, &vault.Prompt{Title: "Whole", PromptContent: "{{> part}}\nThen review."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged, err = service.MergePrompts(ctx, whole.ID, []int{part.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "Check the tests.\nThen review."; merged.PromptContent != want {
+		t.Errorf("content of the kept prompt = %q, want %q", merged.PromptContent, want)
+	}
+	if rendered, err := service.RenderPrompt(ctx, whole.ID); err != nil || rendered != "Check the tests.\nThen review." {
+		t.Errorf("RenderPrompt() of the kept prompt = %q, %v, want the duplicate inlined", rendered, err)
+	}
+}
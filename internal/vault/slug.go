@@ -0,0 +1,138 @@
+package vault
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Derives a url and filename friendly identifier from a title,
+// e.g. "House Style: Préambule" becomes "house-style-preambule".
+func Slugify(title string) string {
+	var b strings.Builder
+	dash := false
+
+	// decompose accented letters so that their base letter is kept
+	for _, r := range norm.NFD.String(strings.ToLower(title)) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// matches the slugs accepted from users: lowercase words joined by dashes
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
+
+// Reports whether s can be used as a slug.
+// Slugs made only of digits are rejected as they would read as ids.
+func ValidSlug(s string) bool {
+	if !slugPattern.MatchString(s) {
+		return false
+	}
+	_, err := strconv.Atoi(s)
+	return err != nil
+}
+
+// Returns the slug derived from base that is free in the index, or owned
+// by the prompt with the given id, by adding -2, -3... as needed.
+func uniqueSlug(slugs promptIndex, base string, id int) string {
+	if base == "" {
+		base = "prompt"
+	}
+	if !ValidSlug(base) {
+		base = "prompt-" + base
+	}
+
+	slug := base
+	for n := 2; ; n++ {
+		owner, ok := slugs.owner(slug)
+		if !ok || owner == id {
+			return slug
+		}
+		slug = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// Picks the slug of a prompt that is about to be written and indexes it.
+// An explicit slug must be free, otherwise the stored one is kept, or one is
+// derived from the title for new prompts. A slug that gets replaced stays in
+// the index and in Aliases, so that references to it keep working. Aliases
+// the caller adds are taken if no other prompt holds them, as a merge does
+// with the slugs of the prompts it deletes.
+func assignSlug(slugs promptIndex, prompt *Prompt, stored *Prompt) error {
+	added := prompt.Aliases
+	prompt.Aliases = nil
+	if stored != nil {
+		prompt.Aliases = append(prompt.Aliases, stored.Aliases...)
+	}
+
+	switch {
+	case prompt.Slug != "":
+		if owner, ok := slugs.owner(prompt.Slug); ok && owner != prompt.ID {
+			return &ValidationError{
+				Field:   FieldSlug,
+				Message: fmt.Sprintf("%q is already used by prompt #%d", prompt.Slug, owner),
+			}
+		}
+	case stored != nil && stored.Slug != "":
+		prompt.Slug = stored.Slug
+	default:
+		prompt.Slug = uniqueSlug(slugs, Slugify(prompt.Title), prompt.ID)
+	}
+
+	// the old slug becomes an alias when it is renamed
+	if stored != nil && stored.Slug != "" && stored.Slug != prompt.Slug && !slices.Contains(prompt.Aliases, stored.Slug) {
+		prompt.Aliases = append(prompt.Aliases, stored.Slug)
+	}
+
+	for _, alias := range added {
+		if owner, ok := slugs.owner(alias); ok && owner != prompt.ID || !ValidSlug(alias) || slices.Contains(prompt.Aliases, alias) {
+			continue
+		}
+		prompt.Aliases = append(prompt.Aliases, alias)
+	}
+
+	// and renaming back to an alias turns it into the slug again
+	prompt.Aliases = slices.DeleteFunc(prompt.Aliases, func(alias string) bool {
+		return alias == prompt.Slug
+	})
+	if len(prompt.Aliases) == 0 {
+		prompt.Aliases = nil
+	}
+
+	for _, slug := range append([]string{prompt.Slug}, prompt.Aliases...) {
+		if err := slugs.put(slug, prompt.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removes the slug and the aliases of a prompt from the index
+func unindexSlugs(slugs promptIndex, prompt *Prompt) error {
+	for _, slug := range append([]string{prompt.Slug}, prompt.Aliases...) {
+		if slug == "" {
+			continue
+		}
+		// only drop entries that still point to this prompt
+		if err := slugs.remove(slug, prompt.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+func openTestDB(t *testing.T) *bolt.DB {
+	t.Helper()
+
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "test.db"), 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestValidSlug(t *testing.T) {
+	tests := map[string]bool{
+		"house-style":  true,
+		"v2":           true,
+		"42":           false,
+		"House-Style":  false,
+		"house--style": false,
+		"-house":       false,
+		"":             false,
+	}
+	for slug, want := range tests {
+		if got := ValidSlug(slug); got != want {
+			t.Errorf("ValidSlug(%q) = %v, want %v", slug, got, want)
+		}
+	}
+}
+
+func TestPromptRepository_Slugs_Integration(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := NewPromptRepository(openTestDB(t), logger)
+
+	first := &Prompt{Title: "House Style", PromptContent: "c"}
+	second := &Prompt{Title: "House style!", PromptContent: "c"}
+	for _, p := range []*Prompt{first, second} {
+		if _, err := repo.CreateOrUpdatePrompt(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// derived from the title, made unique with a suffix
+	if first.Slug != "house-style" || second.Slug != "house-style-2" {
+		t.Fatalf("slugs = %q, %q, want house-style, house-style-2", first.Slug, second.Slug)
+	}
+
+	// an explicit slug that is taken is a validation error
+	taken := *second
+	taken.Slug = "house-style"
+	if _, err := repo.CreateOrUpdatePrompt(ctx, &taken); !errors.Is(err, ErrValidation) {
+		t.Fatalf("CreateOrUpdatePrompt() error = %v, want ErrValidation", err)
+	}
+	if taken.Slug != "house-style" {
+		t.Errorf("failed write changed the prompt to %q", taken.Slug)
+	}
+
+	// retitling keeps the slug, renaming it keeps the old one as an alias
+	first.Title = "Style Guide"
+	if _, err := repo.CreateOrUpdatePrompt(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	if first.Slug != "house-style" {
+		t.Errorf("retitled slug = %q, want house-style", first.Slug)
+	}
+
+	first.Slug = "style-guide"
+	if _, err := repo.CreateOrUpdatePrompt(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first.Aliases, []string{"house-style"}) {
+		t.Errorf("Aliases = %v, want [house-style]", first.Aliases)
+	}
+
+	for _, slug := range []string{"style-guide", "house-style"} {
+		got, err := repo.GetPromptBySlug(ctx, slug)
+		if err != nil {
+			t.Fatalf("GetPromptBySlug(%q) failed: %v", slug, err)
+		}
+		if got.ID != first.ID {
+			t.Errorf("GetPromptBySlug(%q) = #%d, want #%d", slug, got.ID, first.ID)
+		}
+	}
+
+	// deleting frees the slug and its aliases
+	if err := repo.DeletePrompt(ctx, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, slug := range []string{"style-guide", "house-style"} {
+		if _, err := repo.GetPromptBySlug(ctx, slug); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetPromptBySlug(%q) error = %v, want ErrNotFound", slug, err)
+		}
+	}
+
+	third := &Prompt{Title: "House Style", PromptContent: "c"}
+	if _, err := repo.CreateOrUpdatePrompt(ctx, third); err != nil {
+		t.Fatal(err)
+	}
+	if third.Slug != "house-style" {
+		t.Errorf("slug after delete = %q, want house-style", third.Slug)
+	}
+}
+
+func TestMigrate_BackfillsSlugs_Integration(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := openTestDB(t)
+
+	// records written before slugs existed
+	err := db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(promptsBucket)
+		if err != nil {
+			return err
+		}
+		for id, title := range map[int]string{1: "Reviewer", 2: "Reviewer"} {
+			encoded, err := json.Marshal(Prompt{ID: id, Title: title, PromptContent: "c"})
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(itob(uint64(id)), encoded); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// running twice must be harmless
+	for i := 0; i < 2; i++ {
+		if err := Migrate(db, logger); err != nil {
+			t.Fatalf("Migrate() failed: %v", err)
+		}
+	}
+
+	repo := NewPromptRepository(db, logger)
+	for id, want := range map[int]string{1: "reviewer", 2: "reviewer-2"} {
+		got, err := repo.GetPromptBySlug(ctx, want)
+		if err != nil {
+			t.Fatalf("GetPromptBySlug(%q) failed: %v", want, err)
+		}
+		if got.ID != id {
+			t.Errorf("GetPromptBySlug(%q) = #%d, want #%d", want, got.ID, id)
+		}
+	}
+}
@@ -0,0 +1,1105 @@
+package vault
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	// pure go sqlite driver, no cgo needed
+	_ "modernc.org/sqlite"
+)
+
+// a schema change of the sqlite database, applied once.
+// PRAGMA user_version records the last version applied.
+type sqliteMigration struct {
+	version int
+	name    string
+	run     func(tx *sql.Tx) error
+}
+
+// every migration, in the order they must be applied.
+// never reorder or remove entries, only append new ones.
+var sqliteMigrations = []sqliteMigration{
+	{version: 1, name: "create prompts", run: execSQL(sqlitePromptsSchema)},
+	{version: 2, name: "tags, collections and pins", run: migrateSQLiteTags},
+	{version: 3, name: "usage", run: execSQL(`
+		ALTER TABLE prompts ADD COLUMN uses INTEGER NOT NULL DEFAULT 0;
+		-- unix nanoseconds, 0 if never used
+		ALTER TABLE prompts ADD COLUMN last_used_at INTEGER NOT NULL DEFAULT 0;
+		CREATE INDEX prompts_uses ON prompts (uses DESC, id DESC);
+	`)},
+	{version: 4, name: "trash", run: execSQL(`
+		-- prompts taken out by merges, the prompt as JSON
+		CREATE TABLE trash (
+			id         INTEGER PRIMARY KEY,
+			prompt     TEXT NOT NULL,
+			-- unix nanoseconds
+			trashed_at INTEGER NOT NULL
+		);
+	`)},
+}
+
+// runs statements as a migration
+func execSQL(statements string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(statements)
+		return err
+	}
+}
+
+const sqlitePromptsSchema = `
+	CREATE TABLE prompts (
+		-- AUTOINCREMENT, so that ids of deleted prompts are never reused
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		uuid        TEXT NOT NULL UNIQUE,
+		title       TEXT NOT NULL,
+		slug        TEXT NOT NULL,
+		aliases     TEXT NOT NULL DEFAULT '[]',
+		description TEXT NOT NULL DEFAULT '',
+		content     TEXT NOT NULL,
+		variables   TEXT NOT NULL DEFAULT '[]',
+		-- unix nanoseconds
+		created_at  INTEGER NOT NULL,
+		updated_at  INTEGER NOT NULL
+	);
+	CREATE INDEX prompts_updated_at ON prompts (updated_at DESC, id DESC);
+
+	-- slugs, current and former, to prompt ids
+	CREATE TABLE slugs (
+		slug      TEXT PRIMARY KEY,
+		prompt_id INTEGER NOT NULL REFERENCES prompts (id) ON DELETE CASCADE
+	);
+
+	-- full text index over the prompts, kept in sync by the triggers below
+	CREATE VIRTUAL TABLE prompts_fts USING fts5 (
+		title, description, content,
+		content = 'prompts', content_rowid = 'id',
+		tokenize = 'porter unicode61'
+	);
+	CREATE TRIGGER prompts_fts_insert AFTER INSERT ON prompts BEGIN
+		INSERT INTO prompts_fts (rowid, title, description, content)
+		VALUES (new.id, new.title, new.description, new.content);
+	END;
+	CREATE TRIGGER prompts_fts_delete AFTER DELETE ON prompts BEGIN
+		INSERT INTO prompts_fts (prompts_fts, rowid, title, description, content)
+		VALUES ('delete', old.id, old.title, old.description, old.content);
+	END;
+	CREATE TRIGGER prompts_fts_update AFTER UPDATE ON prompts BEGIN
+		INSERT INTO prompts_fts (prompts_fts, rowid, title, description, content)
+		VALUES ('delete', old.id, old.title, old.description, old.content);
+		INSERT INTO prompts_fts (rowid, title, description, content)
+		VALUES (new.id, new.title, new.description, new.content);
+	END;
+`
+
+// adds the query fields, with a table of tags and an index per sort key
+func migrateSQLiteTags(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		ALTER TABLE prompts ADD COLUMN tags TEXT NOT NULL DEFAULT '[]';
+		ALTER TABLE prompts ADD COLUMN collection TEXT NOT NULL DEFAULT '';
+		ALTER TABLE prompts ADD COLUMN pinned INTEGER NOT NULL DEFAULT 0;
+		-- lowercased in go, sqlite's lower() only knows ascii
+		ALTER TABLE prompts ADD COLUMN sort_title TEXT NOT NULL DEFAULT '';
+
+		CREATE TABLE prompt_tags (
+			tag       TEXT NOT NULL,
+			prompt_id INTEGER NOT NULL REFERENCES prompts (id) ON DELETE CASCADE,
+			PRIMARY KEY (tag, prompt_id)
+		);
+		CREATE INDEX prompts_created_at ON prompts (created_at DESC, id DESC);
+		CREATE INDEX prompts_sort_title ON prompts (sort_title, id);
+		CREATE INDEX prompts_collection ON prompts (collection);
+	`)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(`SELECT id, title FROM prompts`)
+	if err != nil {
+		return err
+	}
+	titles := map[int]string{}
+	for rows.Next() {
+		var id int
+		var title string
+		if err := rows.Scan(&id, &title); err != nil {
+			rows.Close()
+			return err
+		}
+		titles[id] = title
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id, title := range titles {
+		if _, err := tx.Exec(`UPDATE prompts SET sort_title = ? WHERE id = ?`, titleSortValue(title), id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// columns of a prompt row, in the order scanPrompt reads them
+const promptColumns = `id, uuid, title, slug, aliases, description, content, variables, tags, collection, pinned, created_at, updated_at, uses, last_used_at`
+
+// TextSearcher is implemented by repositories with a full text index.
+type TextSearcher interface {
+	// Returns the prompts whose title, description or content match the
+	// query, best match first. Every word must match, words are stemmed
+	// and a trailing * matches a prefix.
+	SearchText(ctx context.Context, query string) ([]Prompt, error)
+}
+
+type sqliteRepository struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+// Opens the sqlite database at path, creating it if needed.
+func OpenSQLite(path string) (*sql.DB, error) {
+	// don't wait forever if another pvt is writing, like bolt's timeout
+	dsn := "file:" + path + "?_pragma=busy_timeout(1000)&_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_txlock=immediate"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Creates a repository storing prompts in a sqlite database,
+// bringing its schema up to date first.
+func NewSQLiteRepository(db *sql.DB, logger *slog.Logger) (PromptRepository, error) {
+	repo := &sqliteRepository{db: db, logger: logger}
+	if err := repo.migrate(); err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// applies the migrations the database does not have yet
+func (repo *sqliteRepository) migrate() error {
+	var version int
+	if err := repo.db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return storageError("read schema version", err)
+	}
+	if latest := sqliteMigrations[len(sqliteMigrations)-1].version; version > latest {
+		return fmt.Errorf("%w: database schema v%d is newer than this pvt supports (v%d)", ErrStorage, version, latest)
+	}
+
+	for _, m := range sqliteMigrations {
+		if version >= m.version {
+			continue
+		}
+		repo.logger.Info("migrating sqlite database", "version", m.version, "migration", m.name)
+		err := repo.update(context.Background(), func(tx *sql.Tx) error {
+			if err := m.run(tx); err != nil {
+				return err
+			}
+			_, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, m.version))
+			return err
+		})
+		if err != nil {
+			repo.logger.Error("migration failed", "version", m.version, "migration", m.name, "error", err)
+			return storageError(fmt.Sprintf("migrate to version %d (%s)", m.version, m.name), err)
+		}
+	}
+	return nil
+}
+
+// runs fn in a transaction, committed if it returns nil and rolled back otherwise
+func (repo *sqliteRepository) update(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		repo.logger.Error("failed to begin transaction", "error", err)
+		return storageError("begin transaction", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		repo.logger.Error("failed to commit transaction", "error", err)
+		return storageError("commit", err)
+	}
+	return nil
+}
+
+// create or update a prompt
+func (repo *sqliteRepository) CreateOrUpdatePrompt(ctx context.Context, prompt *Prompt) (*Prompt, error) {
+	// work on a copy, so that a rolled back write leaves the caller's prompt as it was
+	saved := *prompt
+
+	err := repo.update(ctx, func(tx *sql.Tx) error {
+		return repo.putPrompt(ctx, tx, &saved)
+	})
+	if err == nil {
+		*prompt = saved
+		repo.logger.Debug("saved prompt", "id", saved.ID, "slug", saved.Slug)
+	}
+	return prompt, err
+}
+
+// create or update a batch of prompts in a single transaction
+func (repo *sqliteRepository) CreateOrUpdatePrompts(ctx context.Context, prompts []Prompt) ([]Prompt, error) {
+	saved := make([]Prompt, len(prompts))
+	copy(saved, prompts)
+
+	err := repo.update(ctx, func(tx *sql.Tx) error {
+		for i := range saved {
+			if err := ctx.Err(); err != nil {
+				repo.logger.Warn("bulk write cancelled, rolling back", "error", err)
+				return err
+			}
+			if err := repo.putPrompt(ctx, tx, &saved[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// assigns the id, uuid, slug and timestamps of the prompt and writes it,
+// keeping the slug index in sync. must be called inside a transaction.
+func (repo *sqliteRepository) putPrompt(ctx context.Context, tx *sql.Tx, prompt *Prompt) error {
+	// the version currently stored, nil for new prompts
+	var stored *Prompt
+	if prompt.ID != 0 {
+		p, err := scanPrompt(tx.QueryRowContext(ctx, `SELECT `+promptColumns+` FROM prompts WHERE id = ?`, prompt.ID))
+		switch {
+		case err == nil:
+			stored = p
+		case !errors.Is(err, sql.ErrNoRows):
+			repo.logger.Error("failed to read prompt", "id", prompt.ID, "error", err)
+			return storageError("read prompt", err)
+		}
+	}
+
+	if prompt.ID == 0 {
+		// the next id AUTOINCREMENT would pick, it is needed to index the prompt
+		var sequence int
+		err := tx.QueryRowContext(ctx, `SELECT COALESCE((SELECT seq FROM sqlite_sequence WHERE name = 'prompts'), 0)`).Scan(&sequence)
+		if err != nil {
+			repo.logger.Error("failed to read sequence", "error", err)
+			return storageError("read sequence", err)
+		}
+		prompt.ID = sequence + 1
+		// imported prompts keep the time they were first created
+		if prompt.CreatedAt.IsZero() {
+			prompt.CreatedAt = time.Now()
+		}
+	}
+	prompt.UpdatedAt = time.Now()
+
+	keepUsage(prompt, stored)
+	if err := assignUUID(sqliteUUIDs{ctx, tx}, prompt, stored); err != nil {
+		return err
+	}
+	slugs := &sqliteSlugs{ctx: ctx, tx: tx}
+	if err := assignSlug(slugs, prompt, stored); err != nil {
+		return err
+	}
+
+	if err := writePromptRow(ctx, tx, prompt); err != nil {
+		repo.logger.Error("failed to write prompt", "id", prompt.ID, "error", err)
+		return storageError("write prompt", err)
+	}
+	// the prompt row must exist before its slugs point to it
+	if err := slugs.flush(); err != nil {
+		repo.logger.Error("failed to write slugs", "id", prompt.ID, "error", err)
+		return storageError("write slugs", err)
+	}
+	return nil
+}
+
+// inserts or replaces the row of the prompt as it is
+func writePromptRow(ctx context.Context, tx *sql.Tx, prompt *Prompt) error {
+	aliases, err := json.Marshal(nonNil(prompt.Aliases))
+	if err != nil {
+		return err
+	}
+	variables, err := json.Marshal(nonNil(prompt.Variables))
+	if err != nil {
+		return err
+	}
+	tags, err := json.Marshal(nonNil(prompt.Tags))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO prompts (`+promptColumns+`, sort_title) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			uuid = excluded.uuid, title = excluded.title, slug = excluded.slug,
+			aliases = excluded.aliases, description = excluded.description,
+			content = excluded.content, variables = excluded.variables,
+			tags = excluded.tags, collection = excluded.collection, pinned = excluded.pinned,
+			created_at = excluded.created_at, updated_at = excluded.updated_at,
+			uses = excluded.uses, last_used_at = excluded.last_used_at,
+			sort_title = excluded.sort_title`,
+		prompt.ID, prompt.UUID, prompt.Title, prompt.Slug, string(aliases), prompt.Description,
+		prompt.PromptContent, string(variables), string(tags), prompt.Collection, prompt.Pinned,
+		prompt.CreatedAt.UnixNano(), prompt.UpdatedAt.UnixNano(), prompt.Uses, unixNanoOrZero(prompt.LastUsedAt),
+		titleSortValue(prompt.Title),
+	)
+	if err != nil {
+		return err
+	}
+
+	// the tags table answers tag filters
+	if _, err := tx.ExecContext(ctx, `DELETE FROM prompt_tags WHERE prompt_id = ?`, prompt.ID); err != nil {
+		return err
+	}
+	for _, tag := range prompt.Tags {
+		if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO prompt_tags (tag, prompt_id) VALUES (?, ?)`, tag, prompt.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// delete the prompt, its slugs go with it
+func (repo *sqliteRepository) DeletePrompt(ctx context.Context, id int) error {
+	return repo.update(ctx, func(tx *sql.Tx) error {
+		if err := repo.deletePrompt(ctx, tx, id); err != nil {
+			return err
+		}
+		repo.logger.Debug("deleted prompt", "id", id)
+		return nil
+	})
+}
+
+// moves the prompts with the ids to the trash, then creates or updates
+// prompts, in a single transaction
+func (repo *sqliteRepository) ReplacePrompts(ctx context.Context, prompts []Prompt, trashed []int) ([]Prompt, error) {
+	saved := make([]Prompt, len(prompts))
+	copy(saved, prompts)
+
+	err := repo.update(ctx, func(tx *sql.Tx) error {
+		for _, id := range trashed {
+			if err := repo.trashPrompt(ctx, tx, id); err != nil {
+				return err
+			}
+		}
+		for i := range saved {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := repo.putPrompt(ctx, tx, &saved[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	repo.logger.Debug("replaced prompts", "written", len(saved), "trashed", len(trashed))
+	return saved, nil
+}
+
+// copies the prompt to the trash table and deletes it
+func (repo *sqliteRepository) trashPrompt(ctx context.Context, tx *sql.Tx, id int) error {
+	prompt, err := scanPrompt(tx.QueryRowContext(ctx, `SELECT `+promptColumns+` FROM prompts WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return notFoundError(id)
+	}
+	if err != nil {
+		repo.logger.Error("failed to read prompt", "id", id, "error", err)
+		return storageError("read prompt", err)
+	}
+	if err := insertTrash(ctx, tx, TrashedPrompt{Prompt: *prompt, TrashedAt: time.Now()}); err != nil {
+		repo.logger.Error("failed to write to the trash", "id", id, "error", err)
+		return storageError("write trash", err)
+	}
+	return repo.deletePrompt(ctx, tx, id)
+}
+
+// writes a row of the trash table
+func insertTrash(ctx context.Context, tx *sql.Tx, trashed TrashedPrompt) error {
+	encoded, err := json.Marshal(trashed.Prompt)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT OR REPLACE INTO trash (id, prompt, trashed_at) VALUES (?, ?, ?)`,
+		trashed.Prompt.ID, string(encoded), trashed.TrashedAt.UnixNano())
+	return err
+}
+
+// get the prompts in the trash, most recently trashed first
+func (repo *sqliteRepository) GetTrash(ctx context.Context) ([]TrashedPrompt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	rows, err := repo.db.QueryContext(ctx, `SELECT prompt, trashed_at FROM trash`)
+	if err != nil {
+		repo.logger.Error("failed to read the trash", "error", err)
+		return nil, storageError("read trash", err)
+	}
+	defer rows.Close()
+
+	trash := []TrashedPrompt{}
+	for rows.Next() {
+		trashed, err := scanTrash(rows)
+		if err != nil {
+			repo.logger.Error("failed to decode trashed prompt", "error", err)
+			return nil, storageError("decode trash", err)
+		}
+		trash = append(trash, trashed)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, storageError("read trash", err)
+	}
+	sortTrash(trash)
+	return trash, nil
+}
+
+// reads a row of the trash table selected as prompt, trashed_at
+func scanTrash(row interface{ Scan(dest ...any) error }) (TrashedPrompt, error) {
+	var encoded string
+	var trashedAt int64
+	if err := row.Scan(&encoded, &trashedAt); err != nil {
+		return TrashedPrompt{}, err
+	}
+	trashed := TrashedPrompt{TrashedAt: time.Unix(0, trashedAt)}
+	err := json.Unmarshal([]byte(encoded), &trashed.Prompt)
+	return trashed, err
+}
+
+// puts the prompt with the id back from the trash, under the same id
+func (repo *sqliteRepository) RestoreTrashed(ctx context.Context, id int) (*Prompt, error) {
+	var prompt *Prompt
+	err := repo.update(ctx, func(tx *sql.Tx) error {
+		trashed, err := scanTrash(tx.QueryRowContext(ctx, `SELECT prompt, trashed_at FROM trash WHERE id = ?`, id))
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: prompt %d is not in the trash", ErrNotFound, id)
+		}
+		if err != nil {
+			repo.logger.Error("failed to read trashed prompt", "id", id, "error", err)
+			return storageError("read trash", err)
+		}
+
+		var n int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM prompts WHERE id = ?`, id).Scan(&n); err != nil {
+			return storageError("read prompt", err)
+		}
+		if n > 0 {
+			return fmt.Errorf("cannot restore prompt %d, the id is in use", id)
+		}
+
+		prompt = &trashed.Prompt
+		untrash(&sqliteSlugs{ctx: ctx, tx: tx}, prompt)
+		if err := repo.putPrompt(ctx, tx, prompt); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM trash WHERE id = ?`, id); err != nil {
+			return storageError("remove from trash", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	repo.logger.Info("restored prompt from the trash", "id", id, "slug", prompt.Slug)
+	return prompt, nil
+}
+
+func (repo *sqliteRepository) deletePrompt(ctx context.Context, tx *sql.Tx, id int) error {
+	result, err := tx.ExecContext(ctx, `DELETE FROM prompts WHERE id = ?`, id)
+	if err != nil {
+		repo.logger.Error("failed to delete prompt", "id", id, "error", err)
+		return storageError("delete prompt", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return notFoundError(id)
+	}
+	return nil
+}
+
+// get a prompt by its id
+func (repo *sqliteRepository) GetPromptByID(ctx context.Context, id int) (*Prompt, error) {
+	return repo.getPrompt(ctx, notFoundError(id), `SELECT `+promptColumns+` FROM prompts WHERE id = ?`, id)
+}
+
+// get a prompt by its slug, or one of its former slugs
+func (repo *sqliteRepository) GetPromptBySlug(ctx context.Context, slug string) (*Prompt, error) {
+	return repo.getPrompt(ctx, fmt.Errorf("%w: slug %q", ErrNotFound, slug),
+		`SELECT `+promptColumns+` FROM prompts WHERE id = (SELECT prompt_id FROM slugs WHERE slug = ?)`, slug)
+}
+
+// get a prompt by its uuid
+func (repo *sqliteRepository) GetPromptByUUID(ctx context.Context, uuid string) (*Prompt, error) {
+	return repo.getPrompt(ctx, fmt.Errorf("%w: uuid %q", ErrNotFound, uuid),
+		`SELECT `+promptColumns+` FROM prompts WHERE uuid = ?`, uuid)
+}
+
+// runs a query returning at most one prompt, notFound if it returns none
+func (repo *sqliteRepository) getPrompt(ctx context.Context, notFound error, query string, args ...any) (*Prompt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	prompt, err := scanPrompt(repo.db.QueryRowContext(ctx, query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFound
+	}
+	if err != nil {
+		repo.logger.Error("failed to read prompt", "error", err)
+		return nil, storageError("read prompt", err)
+	}
+	return prompt, nil
+}
+
+// get all prompts, most recently updated first
+func (repo *sqliteRepository) GetAllPrompts(ctx context.Context) ([]Prompt, error) {
+	return repo.queryPrompts(ctx, `SELECT `+promptColumns+` FROM prompts ORDER BY updated_at DESC, id DESC`)
+}
+
+// get a page of the prompts matching the query
+func (repo *sqliteRepository) QueryPrompts(ctx context.Context, query Query) (Page, error) {
+	if err := query.normalize(); err != nil {
+		return Page{}, err
+	}
+	after, err := query.decodeCursor()
+	if err != nil {
+		return Page{}, err
+	}
+
+	where, args := []string{"1 = 1"}, []any{}
+	for _, tag := range query.Tags {
+		where = append(where, `id IN (SELECT prompt_id FROM prompt_tags WHERE tag = ?)`)
+		args = append(args, tag)
+	}
+	if query.Collection != "" {
+		where = append(where, `collection = ?`)
+		args = append(args, query.Collection)
+	}
+	if query.Pinned {
+		where = append(where, `pinned`)
+	}
+	for _, bound := range []struct {
+		condition string
+		t         time.Time
+	}{
+		{`created_at >= ?`, query.CreatedAfter},
+		{`created_at <= ?`, query.CreatedBefore},
+		{`updated_at >= ?`, query.UpdatedAfter},
+		{`updated_at <= ?`, query.UpdatedBefore},
+	} {
+		if !bound.t.IsZero() {
+			where = append(where, bound.condition)
+			args = append(args, bound.t.UnixNano())
+		}
+	}
+
+	// the sort column holds what Query.position compares
+	column, direction, op := "updated_at", "DESC", "<"
+	switch query.Sort {
+	case SortCreated:
+		column = "created_at"
+	case SortTitle:
+		column, direction, op = "sort_title", "ASC", ">"
+	case SortUses:
+		column = "uses"
+	}
+	if query.Reverse {
+		direction, op = map[string]string{"ASC": "DESC", "DESC": "ASC"}[direction], map[string]string{"<": ">", ">": "<"}[op]
+	}
+	if after != nil {
+		var value any = after.Value
+		switch query.Sort {
+		case SortUpdated, SortCreated:
+			value = sortValueTime(after.Value)
+		case SortUses:
+			value, _ = strconv.Atoi(after.Value)
+		}
+		where = append(where, fmt.Sprintf(`(%s, id) %s (?, ?)`, column, op))
+		args = append(args, value, after.ID)
+	}
+
+	sqlQuery := fmt.Sprintf(`SELECT %s FROM prompts WHERE %s ORDER BY %s %s, id %s`,
+		promptColumns, strings.Join(where, " AND "), column, direction, direction)
+	if query.Limit > 0 {
+		// one more than the page tells whether there is a next one
+		sqlQuery += fmt.Sprintf(` LIMIT %d`, query.Limit+1)
+	}
+
+	prompts, err := repo.queryPrompts(ctx, sqlQuery, args...)
+	if err != nil {
+		return Page{}, err
+	}
+	return query.page(prompts), nil
+}
+
+// counts a use of the prompt, leaving its update time alone
+func (repo *sqliteRepository) RecordUse(ctx context.Context, id int) (*Prompt, error) {
+	var prompt *Prompt
+	err := repo.update(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `UPDATE prompts SET uses = uses + 1, last_used_at = ? WHERE id = ?`, time.Now().UnixNano(), id)
+		if err != nil {
+			repo.logger.Error("failed to record use", "error", err)
+			return storageError("record use", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return notFoundError(id)
+		}
+		prompt, err = scanPrompt(tx.QueryRowContext(ctx, `SELECT `+promptColumns+` FROM prompts WHERE id = ?`, id))
+		if err != nil {
+			repo.logger.Error("failed to read prompt", "id", id, "error", err)
+			return storageError("read prompt", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return prompt, nil
+}
+
+// full text search through the fts index, best match first
+func (repo *sqliteRepository) SearchText(ctx context.Context, query string) ([]Prompt, error) {
+	match := ftsQuery(query)
+	if match == "" {
+		return []Prompt{}, nil
+	}
+	return repo.queryPrompts(ctx, `
+		SELECT `+prefixColumns("p.", promptColumns)+`
+		FROM prompts_fts JOIN prompts p ON p.id = prompts_fts.rowid
+		WHERE prompts_fts MATCH ?
+		ORDER BY bm25(prompts_fts, 10.0, 5.0, 1.0), p.updated_at DESC`, match)
+}
+
+// ranks the prompts by relevance with the bm25 of the fts index, which
+// the triggers keep up to date. fts5 stems the words of the query itself.
+func (repo *sqliteRepository) RankPrompts(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	match := ftsAnyQuery(query)
+	if match == "" {
+		return []SearchResult{}, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// no limit is -1 to sqlite
+	if limit <= 0 {
+		limit = -1
+	}
+	rows, err := repo.db.QueryContext(ctx, `
+		SELECT `+prefixColumns("p.", promptColumns)+`, -bm25(prompts_fts, 3.0, 2.0, 1.0)
+		FROM prompts_fts JOIN prompts p ON p.id = prompts_fts.rowid
+		WHERE prompts_fts MATCH ?
+		ORDER BY bm25(prompts_fts, 3.0, 2.0, 1.0), p.updated_at DESC, p.id
+		LIMIT ?`, match, limit)
+	if err != nil {
+		repo.logger.Error("failed to rank prompts", "error", err)
+		return nil, storageError("rank prompts", err)
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		var score float64
+		prompt, err := scanPrompt(extraColumns{rows, []any{&score}})
+		if err != nil {
+			repo.logger.Error("failed to decode prompt", "error", err)
+			return nil, storageError("decode prompt", err)
+		}
+		results = append(results, SearchResult{Prompt: *prompt, Score: score})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, storageError("rank prompts", err)
+	}
+	return results, nil
+}
+
+// scans the columns of a prompt followed by some more
+type extraColumns struct {
+	rows  *sql.Rows
+	extra []any
+}
+
+func (c extraColumns) Scan(dest ...any) error {
+	return c.rows.Scan(append(dest, c.extra...)...)
+}
+
+// runs a query returning prompts
+func (repo *sqliteRepository) queryPrompts(ctx context.Context, query string, args ...any) ([]Prompt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	rows, err := repo.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		repo.logger.Error("failed to read prompts", "error", err)
+		return nil, storageError("read prompts", err)
+	}
+	defer rows.Close()
+
+	prompts := []Prompt{}
+	for rows.Next() {
+		prompt, err := scanPrompt(rows)
+		if err != nil {
+			repo.logger.Error("failed to decode prompt", "error", err)
+			return nil, storageError("decode prompt", err)
+		}
+		prompts = append(prompts, *prompt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, storageError("read prompts", err)
+	}
+	return prompts, nil
+}
+
+// every prompt, read inside a transaction
+func txPrompts(ctx context.Context, tx *sql.Tx) ([]Prompt, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT `+promptColumns+` FROM prompts ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prompts := []Prompt{}
+	for rows.Next() {
+		prompt, err := scanPrompt(rows)
+		if err != nil {
+			return nil, err
+		}
+		prompts = append(prompts, *prompt)
+	}
+	return prompts, rows.Err()
+}
+
+// the entries of an index table, as key and prompt id pairs
+func txIndexEntries(ctx context.Context, tx *sql.Tx, query string) (map[indexEntry]bool, error) {
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := map[indexEntry]bool{}
+	for rows.Next() {
+		var e indexEntry
+		if err := rows.Scan(&e.key, &e.id); err != nil {
+			return nil, err
+		}
+		entries[e] = true
+	}
+	return entries, rows.Err()
+}
+
+// reports every entry of the slugs and tags tables and every title sort
+// key disagreeing with the prompts, and whether the full text index does
+func (repo *sqliteRepository) CheckIndexes(ctx context.Context) ([]IndexDrift, error) {
+	drift := []IndexDrift{}
+	err := repo.update(ctx, func(tx *sql.Tx) error {
+		prompts, err := txPrompts(ctx, tx)
+		if err != nil {
+			return err
+		}
+		slugs, tags, titles := map[indexEntry]bool{}, map[indexEntry]bool{}, map[indexEntry]bool{}
+		for _, p := range prompts {
+			for _, slug := range append([]string{p.Slug}, p.Aliases...) {
+				slugs[indexEntry{key: slug, id: p.ID}] = true
+			}
+			for _, tag := range p.Tags {
+				tags[indexEntry{key: tag, id: p.ID}] = true
+			}
+			titles[indexEntry{key: titleSortValue(p.Title), id: p.ID}] = true
+		}
+
+		for _, index := range []struct {
+			name  string
+			want  map[indexEntry]bool
+			query string
+		}{
+			{"slugs", slugs, `SELECT slug, prompt_id FROM slugs`},
+			{"prompt_tags", tags, `SELECT tag, prompt_id FROM prompt_tags`},
+			{"sort_title", titles, `SELECT sort_title, id FROM prompts`},
+		} {
+			have, err := txIndexEntries(ctx, tx, index.query)
+			if err != nil {
+				return err
+			}
+			drift = append(drift, indexDrift(index.name, index.want, have)...)
+		}
+
+		// fts5 checks its index against the prompts itself,
+		// but cannot tell which entries are off
+		if _, err := tx.ExecContext(ctx, `INSERT INTO prompts_fts (prompts_fts, rank) VALUES ('integrity-check', 1)`); err != nil {
+			repo.logger.Warn("full text index does not match the prompts", "error", err)
+			drift = append(drift, IndexDrift{Index: "prompts_fts", Missing: true})
+		}
+		return nil
+	})
+	if err != nil {
+		repo.logger.Error("failed to check indexes", "error", err)
+		return nil, storageError("check indexes", err)
+	}
+	return drift, nil
+}
+
+// rebuilds the slugs and tags tables, the title sort keys, the full text
+// index and the indexes of the tables from the prompts
+func (repo *sqliteRepository) Reindex(ctx context.Context) error {
+	err := repo.update(ctx, func(tx *sql.Tx) error {
+		prompts, err := txPrompts(ctx, tx)
+		if err != nil {
+			return err
+		}
+		// rebuilt first, the triggers fired by rewriting the rows need a sound index
+		if _, err := tx.ExecContext(ctx, `INSERT INTO prompts_fts (prompts_fts) VALUES ('rebuild')`); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM slugs`); err != nil {
+			return err
+		}
+		slugs := &sqliteSlugs{ctx: ctx, tx: tx}
+		for i := range prompts {
+			// rewriting the row rewrites its tags and sort key too
+			if err := writePromptRow(ctx, tx, &prompts[i]); err != nil {
+				return err
+			}
+			for _, slug := range append([]string{prompts[i].Slug}, prompts[i].Aliases...) {
+				slugs.put(slug, prompts[i].ID)
+			}
+		}
+		if err := slugs.flush(); err != nil {
+			return err
+		}
+		// tags of prompts that are gone
+		if _, err := tx.ExecContext(ctx, `DELETE FROM prompt_tags WHERE prompt_id NOT IN (SELECT id FROM prompts)`); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `REINDEX`)
+		return err
+	})
+	if err != nil {
+		repo.logger.Error("failed to rebuild indexes", "error", err)
+		return storageError("rebuild indexes", err)
+	}
+	repo.logger.Info("rebuilt indexes")
+	return nil
+}
+
+// stores the prompts and the trash as they are, for conversions from
+// another backend
+func (repo *sqliteRepository) RestorePrompts(ctx context.Context, prompts []Prompt, trash []TrashedPrompt) error {
+	return repo.update(ctx, func(tx *sql.Tx) error {
+		var n int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM prompts`).Scan(&n); err != nil {
+			return storageError("count prompts", err)
+		}
+		if n > 0 {
+			return fmt.Errorf("cannot restore into a database that already holds prompts")
+		}
+
+		for i := range prompts {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			// AUTOINCREMENT moves past the highest id written
+			if err := writePromptRow(ctx, tx, &prompts[i]); err != nil {
+				return storageError("write prompt", err)
+			}
+			slugs := &sqliteSlugs{ctx: ctx, tx: tx}
+			for _, slug := range append([]string{prompts[i].Slug}, prompts[i].Aliases...) {
+				slugs.put(slug, prompts[i].ID)
+			}
+			if err := slugs.flush(); err != nil {
+				return storageError("write slugs", err)
+			}
+		}
+
+		// nor past the trashed ones, which new prompts must not reuse either
+		sequence := 0
+		for _, trashed := range trash {
+			if err := insertTrash(ctx, tx, trashed); err != nil {
+				return storageError("write trash", err)
+			}
+			sequence = max(sequence, trashed.Prompt.ID)
+		}
+		if sequence == 0 {
+			return nil
+		}
+		result, err := tx.ExecContext(ctx, `UPDATE sqlite_sequence SET seq = MAX(seq, ?) WHERE name = 'prompts'`, sequence)
+		if err != nil {
+			return storageError("write sequence", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			if _, err := tx.ExecContext(ctx, `INSERT INTO sqlite_sequence (name, seq) VALUES ('prompts', ?)`, sequence); err != nil {
+				return storageError("write sequence", err)
+			}
+		}
+		return nil
+	})
+}
+
+// reads a prompt row selected with promptColumns
+func scanPrompt(row interface{ Scan(dest ...any) error }) (*Prompt, error) {
+	p := &Prompt{}
+	var aliases, variables, tags string
+	var created, updated, lastUsed int64
+	err := row.Scan(&p.ID, &p.UUID, &p.Title, &p.Slug, &aliases, &p.Description,
+		&p.PromptContent, &variables, &tags, &p.Collection, &p.Pinned, &created, &updated,
+		&p.Uses, &lastUsed)
+	if err != nil {
+		return nil, err
+	}
+	for _, list := range []struct {
+		encoded string
+		decoded *[]string
+	}{{aliases, &p.Aliases}, {variables, &p.Variables}, {tags, &p.Tags}} {
+		if err := json.Unmarshal([]byte(list.encoded), list.decoded); err != nil {
+			return nil, err
+		}
+		// stored as [], read back as nil like the other backends
+		if len(*list.decoded) == 0 {
+			*list.decoded = nil
+		}
+	}
+	p.CreatedAt, p.UpdatedAt = time.Unix(0, created), time.Unix(0, updated)
+	if lastUsed != 0 {
+		p.LastUsedAt = time.Unix(0, lastUsed)
+	}
+	return p, nil
+}
+
+// turns what a user typed into an fts5 query: every word must match,
+// quoted so that fts5 syntax in the input is taken literally.
+// a trailing * is kept and matches a prefix.
+func ftsQuery(query string) string {
+	terms := []string{}
+	for _, word := range strings.Fields(query) {
+		prefix := strings.HasSuffix(word, "*")
+		word = strings.Trim(word, `*"`)
+		if word == "" {
+			continue
+		}
+		term := `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+		if prefix {
+			term += "*"
+		}
+		terms = append(terms, term)
+	}
+	return strings.Join(terms, " ")
+}
+
+// turns what a user typed into an fts5 query matching any of its words
+// worth searching for, see searchWords
+func ftsAnyQuery(query string) string {
+	terms := []string{}
+	for _, word := range searchWords(query) {
+		if term := `"` + word + `"`; !slices.Contains(terms, term) {
+			terms = append(terms, term)
+		}
+	}
+	return strings.Join(terms, " OR ")
+}
+
+// unix nanoseconds of a time sort value, see timeSortValue
+func sortValueTime(value string) int64 {
+	n, _ := strconv.ParseUint(value, 10, 64)
+	return int64(n ^ 1<<63)
+}
+
+// unix nanoseconds of t, 0 for the zero time
+func unixNanoOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// qualifies every column of a column list with prefix
+func prefixColumns(prefix, columns string) string {
+	names := strings.Split(columns, ", ")
+	for i := range names {
+		names[i] = prefix + names[i]
+	}
+	return strings.Join(names, ", ")
+}
+
+// a nil slice is stored as [] rather than null
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// the uuid index is the unique uuid column of the prompts table,
+// it is written along with the prompt row
+type sqliteUUIDs struct {
+	ctx context.Context
+	tx  *sql.Tx
+}
+
+func (i sqliteUUIDs) owner(uuid string) (int, bool) {
+	var id int
+	// a failing query surfaces again when the row is written
+	err := i.tx.QueryRowContext(i.ctx, `SELECT id FROM prompts WHERE uuid = ?`, uuid).Scan(&id)
+	return id, err == nil
+}
+
+func (i sqliteUUIDs) put(string, int) error    { return nil }
+func (i sqliteUUIDs) remove(string, int) error { return nil }
+
+// the slugs table. writes are held back until flush, after the prompt row
+// they point to has been written.
+type sqliteSlugs struct {
+	ctx     context.Context
+	tx      *sql.Tx
+	pending map[string]int
+}
+
+func (i *sqliteSlugs) owner(slug string) (int, bool) {
+	if id, ok := i.pending[slug]; ok {
+		return id, true
+	}
+	var id int
+	// a failing query surfaces again when the slugs are flushed
+	err := i.tx.QueryRowContext(i.ctx, `SELECT prompt_id FROM slugs WHERE slug = ?`, slug).Scan(&id)
+	return id, err == nil
+}
+
+func (i *sqliteSlugs) put(slug string, id int) error {
+	if i.pending == nil {
+		i.pending = map[string]int{}
+	}
+	i.pending[slug] = id
+	return nil
+}
+
+func (i *sqliteSlugs) remove(slug string, id int) error {
+	_, err := i.tx.ExecContext(i.ctx, `DELETE FROM slugs WHERE slug = ? AND prompt_id = ?`, slug, id)
+	return err
+}
+
+// writes the slugs put since the last flush
+func (i *sqliteSlugs) flush() error {
+	for slug, id := range i.pending {
+		_, err := i.tx.ExecContext(i.ctx, `INSERT INTO slugs (slug, prompt_id) VALUES (?, ?)
+			ON CONFLICT (slug) DO UPDATE SET prompt_id = excluded.prompt_id`, slug, id)
+		if err != nil {
+			return err
+		}
+	}
+	i.pending = nil
+	return nil
+}
@@ -0,0 +1,181 @@
+package vault
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSQLiteRepository_SearchText_Integration(t *testing.T) {
+	ctx := context.Background()
+	repo := openTestSQLite(t)
+
+	prompts, err := repo.CreateOrUpdatePrompts(ctx, []Prompt{
+		{Title: "Code Review", Description: "for pull requests", PromptContent: "Review the diff."},
+		{Title: "Summary", PromptContent: "Summarise the reviews below."},
+		{Title: "Translate", Description: "into French", PromptContent: "Translate the text."},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		want  []int // ids, best match first
+	}{
+		// stemmed, and a title match ranks above a content match
+		{"reviewing", []int{prompts[0].ID, prompts[1].ID}},
+		{"french", []int{prompts[2].ID}},
+		{"transl*", []int{prompts[2].ID}},
+		// every word must match
+		{"review diff", []int{prompts[0].ID}},
+		// fts syntax is taken literally
+		{`review" OR "translate`, []int{}},
+		{"", []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := repo.(TextSearcher).SearchText(ctx, tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := []int{}
+			for _, p := range got {
+				ids = append(ids, p.ID)
+			}
+			if len(ids) != len(tt.want) {
+				t.Fatalf("SearchText(%q) = %v, want %v", tt.query, ids, tt.want)
+			}
+			for i := range ids {
+				if ids[i] != tt.want[i] {
+					t.Errorf("SearchText(%q) = %v, want %v", tt.query, ids, tt.want)
+				}
+			}
+		})
+	}
+
+	// the index follows updates and deletes
+	prompts[2].PromptContent = "Rewrite it."
+	if _, err := repo.CreateOrUpdatePrompt(ctx, &prompts[2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeletePrompt(ctx, prompts[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	for query, want := range map[string]int{"rewrite": 1, "translate": 1, "diff": 0} {
+		if got, err := repo.(TextSearcher).SearchText(ctx, query); err != nil || len(got) != want {
+			t.Errorf("SearchText(%q) = %d prompts, %v, want %d", query, len(got), err, want)
+		}
+	}
+}
+
+func TestConvertRepository_SQLite_Integration(t *testing.T) {
+	ctx := context.Background()
+	source := openTestMarkdown(t, t.TempDir())
+	if _, err := source.CreateOrUpdatePrompts(ctx, []Prompt{
+		{Title: "House Style", PromptContent: "Be concise.", Variables: []string{"tone"}},
+		{Title: "Review", PromptContent: "{{> house-style}}"},
+		{Title: "Trashed", PromptContent: "c"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.ReplacePrompts(ctx, nil, []int{3}); err != nil {
+		t.Fatal(err)
+	}
+
+	target := openTestSQLite(t)
+	if n, err := ConvertRepository(ctx, source, target); err != nil || n != 2 {
+		t.Fatalf("ConvertRepository() = %d, %v, want 2", n, err)
+	}
+	if got, err := target.(TextSearcher).SearchText(ctx, "concise"); err != nil || len(got) != 1 {
+		t.Errorf("SearchText() after conversion = %+v, %v, want the converted prompt", got, err)
+	}
+
+	next := &Prompt{Title: "Next", PromptContent: "c"}
+	if _, err := target.CreateOrUpdatePrompt(ctx, next); err != nil {
+		t.Fatal(err)
+	}
+	// nor is the id of the trashed prompt
+	if next.ID != 4 {
+		t.Errorf("new prompt id = %d, want 4", next.ID)
+	}
+}
+
+func TestSQLiteRepository_CheckIndexes_Integration(t *testing.T) {
+	ctx := context.Background()
+	repo := openTestSQLite(t)
+
+	prompts, err := repo.CreateOrUpdatePrompts(ctx, []Prompt{
+		{Title: "Code Review", PromptContent: "Review the diff.", Tags: []string{"code"}},
+		{Title: "Summary", PromptContent: "Summarise it."},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexer := repo.(Indexer)
+	if drift, err := indexer.CheckIndexes(ctx); err != nil || len(drift) != 0 {
+		t.Fatalf("CheckIndexes() on a sound database = %v, %v, want no drift", drift, err)
+	}
+
+	// let the indexes drift behind the back of the repository
+	db := repo.(*sqliteRepository).db
+	for _, statement := range []string{
+		`DELETE FROM prompt_tags`,
+		`DELETE FROM slugs WHERE slug = 'summary'`,
+		// dropping a row from the full text index by hand
+		`INSERT INTO prompts_fts (prompts_fts, rowid, title, description, content) VALUES ('delete', 1, 'Code Review', '', 'Review the diff.')`,
+	} {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	drift, err := indexer.CheckIndexes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, d := range drift {
+		got = append(got, d.String())
+	}
+	want := []string{
+		`slugs: "summary" of prompt 2 is missing`,
+		`prompt_tags: "code" of prompt 1 is missing`,
+		`prompts_fts: does not match the prompts`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("CheckIndexes() =\n%q\nwant\n%q", got, want)
+	}
+
+	if err := indexer.Reindex(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if drift, err := indexer.CheckIndexes(ctx); err != nil || len(drift) != 0 {
+		t.Errorf("CheckIndexes() after Reindex() = %v, %v, want no drift", drift, err)
+	}
+	if got, err := repo.(TextSearcher).SearchText(ctx, "diff"); err != nil || len(got) != 1 || got[0].ID != prompts[0].ID {
+		t.Errorf("SearchText() after Reindex() = %v, %v, want prompt %d", got, err, prompts[0].ID)
+	}
+	if page, err := repo.QueryPrompts(ctx, Query{Tags: []string{"code"}}); err != nil || len(page.Prompts) != 1 {
+		t.Errorf("QueryPrompts() by tag after Reindex() = %v, %v, want prompt %d", page, err, prompts[0].ID)
+	}
+}
+
+func openTestSQLite(t *testing.T) PromptRepository {
+	t.Helper()
+
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "test.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	repo, err := NewSQLiteRepository(db, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
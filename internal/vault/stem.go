@@ -0,0 +1,296 @@
+package vault
+
+// Reduces an english word to its stem with the Porter algorithm, so that
+// "reviewing", "reviews" and "reviewed" all become "review". The word
+// must be lowercase, words with anything but a-z are returned as they are.
+// See https://tartarus.org/martin/PorterStemmer/def.txt
+func stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return word
+		}
+	}
+
+	s := &stemmer{b: []byte(word), k: len(word) - 1}
+	s.step1ab()
+	if s.k > 0 {
+		s.step1c()
+		s.step2()
+		s.step3()
+		s.step4()
+		s.step5()
+	}
+	return string(s.b[:s.k+1])
+}
+
+// state of the algorithm, a port of the reference implementation.
+// the word being stemmed is b[0..k], j marks the end of a stem while
+// a suffix is looked at.
+type stemmer struct {
+	b    []byte
+	k, j int
+}
+
+// reports whether b[i] is a consonant
+func (s *stemmer) cons(i int) bool {
+	switch s.b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !s.cons(i-1)
+	}
+	return true
+}
+
+// the number of vowel consonant sequences in b[0..j]
+func (s *stemmer) m() int {
+	n, i := 0, 0
+	for ; ; i++ {
+		if i > s.j {
+			return n
+		}
+		if !s.cons(i) {
+			break
+		}
+	}
+	for i++; ; i++ {
+		for ; ; i++ {
+			if i > s.j {
+				return n
+			}
+			if s.cons(i) {
+				break
+			}
+		}
+		n++
+		for i++; ; i++ {
+			if i > s.j {
+				return n
+			}
+			if !s.cons(i) {
+				break
+			}
+		}
+	}
+}
+
+// reports whether b[0..j] contains a vowel
+func (s *stemmer) vowelInStem() bool {
+	for i := 0; i <= s.j; i++ {
+		if !s.cons(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// reports whether b[i-1..i] is a double consonant
+func (s *stemmer) doublec(i int) bool {
+	return i >= 1 && s.b[i] == s.b[i-1] && s.cons(i)
+}
+
+// reports whether b[i-2..i] is consonant vowel consonant, the last one
+// not w, x or y. used to restore an e, as in hop(e), cav(e) and lov(e).
+func (s *stemmer) cvc(i int) bool {
+	if i < 2 || !s.cons(i) || s.cons(i-1) || !s.cons(i-2) {
+		return false
+	}
+	switch s.b[i] {
+	case 'w', 'x', 'y':
+		return false
+	}
+	return true
+}
+
+// reports whether b[0..k] ends with suffix, setting j to the end of the stem
+func (s *stemmer) ends(suffix string) bool {
+	n := len(suffix)
+	if n > s.k+1 || string(s.b[s.k-n+1:s.k+1]) != suffix {
+		return false
+	}
+	s.j = s.k - n
+	return true
+}
+
+// replaces b[j+1..k] with suffix
+func (s *stemmer) setTo(suffix string) {
+	s.b = append(s.b[:s.j+1], suffix...)
+	s.k = s.j + len(suffix)
+}
+
+// replaces the suffix if the stem has at least one vowel consonant sequence
+func (s *stemmer) replace(suffix string) {
+	if s.m() > 0 {
+		s.setTo(suffix)
+	}
+}
+
+// plurals and -ed or -ing: caresses -> caress, ponies -> poni,
+// agreed -> agree, motoring -> motor, hopping -> hop, filing -> file
+func (s *stemmer) step1ab() {
+	if s.b[s.k] == 's' {
+		switch {
+		case s.ends("sses"):
+			s.k -= 2
+		case s.ends("ies"):
+			s.setTo("i")
+		case s.b[s.k-1] != 's':
+			s.k--
+		}
+	}
+	if s.ends("eed") {
+		if s.m() > 0 {
+			s.k--
+		}
+		return
+	}
+	if (s.ends("ed") || s.ends("ing")) && s.vowelInStem() {
+		s.k = s.j
+		switch {
+		case s.ends("at"):
+			s.setTo("ate")
+		case s.ends("bl"):
+			s.setTo("ble")
+		case s.ends("iz"):
+			s.setTo("ize")
+		case s.doublec(s.k):
+			switch s.b[s.k] {
+			case 'l', 's', 'z':
+			default:
+				s.k--
+			}
+		default:
+			s.j = s.k
+			if s.m() == 1 && s.cvc(s.k) {
+				s.setTo("e")
+			}
+		}
+	}
+}
+
+// a final y becomes i if there is another vowel: happy -> happi
+func (s *stemmer) step1c() {
+	if s.ends("y") && s.vowelInStem() {
+		s.b[s.k] = 'i'
+	}
+}
+
+// double suffixes become single ones: relational -> relate
+func (s *stemmer) step2() {
+	if s.k < 1 {
+		return
+	}
+	var rules [][2]string
+	switch s.b[s.k-1] {
+	case 'a':
+		rules = [][2]string{{"ational", "ate"}, {"tional", "tion"}}
+	case 'c':
+		rules = [][2]string{{"enci", "ence"}, {"anci", "ance"}}
+	case 'e':
+		rules = [][2]string{{"izer", "ize"}}
+	case 'l':
+		rules = [][2]string{{"bli", "ble"}, {"alli", "al"}, {"entli", "ent"}, {"eli", "e"}, {"ousli", "ous"}}
+	case 'o':
+		rules = [][2]string{{"ization", "ize"}, {"ation", "ate"}, {"ator", "ate"}}
+	case 's':
+		rules = [][2]string{{"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"}, {"ousness", "ous"}}
+	case 't':
+		rules = [][2]string{{"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"}}
+	case 'g':
+		rules = [][2]string{{"logi", "log"}}
+	}
+	s.applyFirst(rules)
+}
+
+// -ic-, -full, -ness and the like: electrical -> electric, hopeful -> hope
+func (s *stemmer) step3() {
+	var rules [][2]string
+	switch s.b[s.k] {
+	case 'e':
+		rules = [][2]string{{"icate", "ic"}, {"ative", ""}, {"alize", "al"}}
+	case 'i':
+		rules = [][2]string{{"iciti", "ic"}}
+	case 'l':
+		rules = [][2]string{{"ical", "ic"}, {"ful", ""}}
+	case 's':
+		rules = [][2]string{{"ness", ""}}
+	}
+	s.applyFirst(rules)
+}
+
+// replaces the first of the suffixes b[0..k] ends with
+func (s *stemmer) applyFirst(rules [][2]string) {
+	for _, rule := range rules {
+		if s.ends(rule[0]) {
+			s.replace(rule[1])
+			return
+		}
+	}
+}
+
+// drops -ant, -ence and the like from long stems: adjustment -> adjust
+func (s *stemmer) step4() {
+	if s.k < 1 {
+		return
+	}
+	var suffixes []string
+	switch s.b[s.k-1] {
+	case 'a':
+		suffixes = []string{"al"}
+	case 'c':
+		suffixes = []string{"ance", "ence"}
+	case 'e':
+		suffixes = []string{"er"}
+	case 'i':
+		suffixes = []string{"ic"}
+	case 'l':
+		suffixes = []string{"able", "ible"}
+	case 'n':
+		suffixes = []string{"ant", "ement", "ment", "ent"}
+	case 'o':
+		// -ion only after s or t
+		if s.ends("ion") && s.j >= 0 && (s.b[s.j] == 's' || s.b[s.j] == 't') {
+			break
+		}
+		suffixes = []string{"ou"}
+	case 's':
+		suffixes = []string{"ism"}
+	case 't':
+		suffixes = []string{"ate", "iti"}
+	case 'u':
+		suffixes = []string{"ous"}
+	case 'v':
+		suffixes = []string{"ive"}
+	case 'z':
+		suffixes = []string{"ize"}
+	default:
+		return
+	}
+
+	found := suffixes == nil
+	for _, suffix := range suffixes {
+		if s.ends(suffix) {
+			found = true
+			break
+		}
+	}
+	if found && s.m() > 1 {
+		s.k = s.j
+	}
+}
+
+// drops a final e and a double l from long stems: probate -> probat, controll -> control
+func (s *stemmer) step5() {
+	s.j = s.k
+	if s.b[s.k] == 'e' {
+		if a := s.m(); a > 1 || a == 1 && !s.cvc(s.k-1) {
+			s.k--
+		}
+	}
+	if s.b[s.k] == 'l' && s.doublec(s.k) && s.m() > 1 {
+		s.k--
+	}
+}
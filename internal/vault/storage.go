@@ -0,0 +1,66 @@
+package vault
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// storage backends a vault can be kept in
+const (
+	// a single bolt database file, the default
+	BackendBolt = "bolt"
+
+	// a directory with a markdown file per prompt
+	BackendMarkdown = "markdown"
+
+	// a sqlite database file, with full text search
+	BackendSQLite = "sqlite"
+)
+
+// Backends lists the storage backends, the default first.
+var Backends = []string{BackendBolt, BackendMarkdown, BackendSQLite}
+
+// Opens the repository of a backend at path, a database file for bolt and
+// sqlite and a directory for markdown. Databases are migrated to the current
+// schema. The returned function releases the storage.
+func OpenRepository(backend, path string, logger *slog.Logger) (PromptRepository, func() error, error) {
+	switch backend {
+	case BackendBolt:
+		// don't wait forever if another pvt holds the lock on the file
+		db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+		if err != nil {
+			return nil, nil, fmt.Errorf("open database: %w", err)
+		}
+		// bring older databases up to date
+		if err := Migrate(db, logger); err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		repo := &promptRepository{db: db, logger: logger}
+		// compacting swaps the db, close whichever is open by then
+		return repo, func() error { return repo.db.Close() }, nil
+
+	case BackendMarkdown:
+		repo, err := NewMarkdownRepository(path, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		return repo, func() error { return nil }, nil
+
+	case BackendSQLite:
+		db, err := OpenSQLite(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open database: %w", err)
+		}
+		repo, err := NewSQLiteRepository(db, logger)
+		if err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		return repo, db.Close, nil
+	}
+	return nil, nil, fmt.Errorf("unknown storage backend %q, expected one of %v", backend, Backends)
+}
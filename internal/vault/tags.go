@@ -0,0 +1,39 @@
+package vault
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// longest tag accepted
+const maxTagLength = 40
+
+// Normalizes the tags of a prompt: trimmed, lowercase, without a leading #,
+// sorted and without duplicates or empty ones.
+func NormalizeTags(tags []string) []string {
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+		if tag != "" {
+			normalized = append(normalized, tag)
+		}
+	}
+	slices.Sort(normalized)
+	normalized = slices.Compact(normalized)
+	if len(normalized) == 0 {
+		return nil
+	}
+	return normalized
+}
+
+// reports whether a normalized tag can be stored. tags are listed
+// separated by commas and spaces, so they cannot hold either.
+func validTag(tag string) bool {
+	if tag == "" || len(tag) > maxTagLength {
+		return false
+	}
+	return !strings.ContainsFunc(tag, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r) || unicode.IsControl(r)
+	})
+}
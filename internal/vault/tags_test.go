@@ -0,0 +1,52 @@
+package vault
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		name string
+		tags []string
+		want []string
+	}{
+		{"nil", nil, nil},
+		{"only blanks", []string{"", "  "}, nil},
+		{"lowercased and sorted", []string{"Review", "code"}, []string{"code", "review"}},
+		{"hash stripped", []string{"#draft", " draft "}, []string{"draft"}},
+		{"duplicates dropped", []string{"a", "A", "a"}, []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeTags(tt.tags); !slices.Equal(got, tt.want) {
+				t.Errorf("NormalizeTags(%q) = %q, want %q", tt.tags, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePrompt_Tags(t *testing.T) {
+	tests := []struct {
+		name    string
+		tags    []string
+		wantErr bool
+	}{
+		{"plain", []string{"code-review", "go"}, false},
+		{"comma", []string{"a,b"}, true},
+		{"space", []string{"two words"}, true},
+		{"too long", []string{strings.Repeat("a", 41)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Prompt{Title: "t", PromptContent: "c", Tags: tt.tags}
+			err := validatePrompt(p)
+			var verr *ValidationError
+			if tt.wantErr != (errors.As(err, &verr) && verr.Field == FieldTags) {
+				t.Errorf("validatePrompt() with tags %q error = %v, want error %v", tt.tags, err, tt.wantErr)
+			}
+		})
+	}
+}
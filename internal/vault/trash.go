@@ -0,0 +1,33 @@
+package vault
+
+import (
+	"sort"
+	"time"
+)
+
+// TrashedPrompt is a prompt a merge took out of the vault, kept by the
+// repository until it is restored.
+type TrashedPrompt struct {
+	Prompt    Prompt    `json:"prompt"`
+	TrashedAt time.Time `json:"trashed_at"`
+}
+
+// orders the trash most recently trashed first
+func sortTrash(trash []TrashedPrompt) {
+	sort.Slice(trash, func(i, j int) bool {
+		if !trash[i].TrashedAt.Equal(trash[j].TrashedAt) {
+			return trash[i].TrashedAt.After(trash[j].TrashedAt)
+		}
+		return trash[i].Prompt.ID > trash[j].Prompt.ID
+	})
+}
+
+// readies a trashed prompt to be put back under its id. a slug taken by
+// another prompt meanwhile, as the one it was merged into takes it, is
+// given up for a new one made from the title, and assignSlug leaves out
+// the aliases taken the same way.
+func untrash(slugs promptIndex, prompt *Prompt) {
+	if owner, ok := slugs.owner(prompt.Slug); ok && owner != prompt.ID {
+		prompt.Slug = ""
+	}
+}
@@ -0,0 +1,31 @@
+package vault
+
+import (
+	"fmt"
+	"time"
+)
+
+// Usage is bookkeeping of the vault rather than part of a prompt: writes
+// keep the stored usage and only RecordUse changes it, so that editing,
+// importing or syncing a prompt never resets or inflates its count.
+
+// copies the usage of the stored version onto the prompt being written,
+// none for a new prompt
+func keepUsage(prompt, stored *Prompt) {
+	if stored == nil {
+		prompt.Uses, prompt.LastUsedAt = 0, time.Time{}
+		return
+	}
+	prompt.Uses, prompt.LastUsedAt = stored.Uses, stored.LastUsedAt
+}
+
+// counts a use of the prompt
+func recordUse(prompt *Prompt) {
+	prompt.Uses++
+	prompt.LastUsedAt = time.Now()
+}
+
+// times are compared as fixed width strings, counts too
+func usageSortValue(uses int) string {
+	return fmt.Sprintf("%020d", uses)
+}
@@ -0,0 +1,58 @@
+package vault
+
+import (
+	"crypto/rand"
+	"fmt"
+	"regexp"
+)
+
+// matches the canonical lowercase form of a uuid
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// Generates a random (version 4) uuid in its canonical form.
+// Prompts are identified by it across vaults, integer IDs are only local.
+func NewUUID() string {
+	var b [16]byte
+	// crypto/rand never fails on the platforms we support
+	_, _ = rand.Read(b[:])
+
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // rfc 4122 variant
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// Reports whether s is a uuid in canonical lowercase form.
+func ValidUUID(s string) bool {
+	return uuidPattern.MatchString(s)
+}
+
+// Gives the prompt that is about to be written its uuid and indexes it.
+// The uuid of a stored prompt never changes, new prompts keep the one they
+// come with (e.g. when imported) as long as no other prompt has it.
+func assignUUID(uuids promptIndex, prompt *Prompt, stored *Prompt) error {
+	switch {
+	case stored != nil && stored.UUID != "":
+		prompt.UUID = stored.UUID
+	case prompt.UUID == "":
+		prompt.UUID = NewUUID()
+	case !ValidUUID(prompt.UUID):
+		return &ValidationError{Field: FieldUUID, Message: fmt.Sprintf("%q is not a valid uuid", prompt.UUID)}
+	}
+
+	if owner, ok := uuids.owner(prompt.UUID); ok && owner != prompt.ID {
+		return &ValidationError{
+			Field:   FieldUUID,
+			Message: fmt.Sprintf("%s is already used by prompt #%d", prompt.UUID, owner),
+		}
+	}
+	return uuids.put(prompt.UUID, prompt.ID)
+}
+
+// removes the uuid of a prompt from the index
+func unindexUUID(uuids promptIndex, prompt *Prompt) error {
+	if prompt.UUID == "" {
+		return nil
+	}
+	return uuids.remove(prompt.UUID, prompt.ID)
+}
@@ -0,0 +1,107 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestNewUUID(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		uuid := NewUUID()
+		if !ValidUUID(uuid) {
+			t.Fatalf("NewUUID() = %q, not a valid uuid", uuid)
+		}
+		if uuid[14] != '4' {
+			t.Errorf("NewUUID() = %q, want version 4", uuid)
+		}
+		if seen[uuid] {
+			t.Fatalf("NewUUID() returned %q twice", uuid)
+		}
+		seen[uuid] = true
+	}
+}
+
+func TestPromptRepository_UUIDs_Integration(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := NewPromptRepository(openTestDB(t), logger)
+
+	prompt := &Prompt{Title: "t", PromptContent: "c"}
+	if _, err := repo.CreateOrUpdatePrompt(ctx, prompt); err != nil {
+		t.Fatal(err)
+	}
+	uuid := prompt.UUID
+	if !ValidUUID(uuid) {
+		t.Fatalf("UUID = %q, want one assigned on creation", uuid)
+	}
+
+	// the uuid of a stored prompt never changes
+	prompt.UUID = NewUUID()
+	if _, err := repo.CreateOrUpdatePrompt(ctx, prompt); err != nil {
+		t.Fatal(err)
+	}
+	if prompt.UUID != uuid {
+		t.Errorf("UUID changed to %q on update, want %q", prompt.UUID, uuid)
+	}
+
+	// and no other prompt can take it
+	if _, err := repo.CreateOrUpdatePrompt(ctx, &Prompt{UUID: uuid, Title: "t", PromptContent: "c"}); !errors.Is(err, ErrValidation) {
+		t.Errorf("CreateOrUpdatePrompt() error = %v, want ErrValidation", err)
+	}
+
+	got, err := repo.GetPromptByUUID(ctx, uuid)
+	if err != nil || got.ID != prompt.ID {
+		t.Fatalf("GetPromptByUUID() = %v, %v, want #%d", got, err, prompt.ID)
+	}
+
+	if err := repo.DeletePrompt(ctx, prompt.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetPromptByUUID(ctx, uuid); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetPromptByUUID() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMigrate_BackfillsUUIDs_Integration(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := openTestDB(t)
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(promptsBucket)
+		if err != nil {
+			return err
+		}
+		encoded, err := json.Marshal(Prompt{ID: 1, Title: "Reviewer", PromptContent: "c"})
+		if err != nil {
+			return err
+		}
+		return bucket.Put(itob(1), encoded)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Migrate(db, logger); err != nil {
+		t.Fatalf("Migrate() failed: %v", err)
+	}
+
+	repo := NewPromptRepository(db, logger)
+	prompt, err := repo.GetPromptByID(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ValidUUID(prompt.UUID) {
+		t.Fatalf("UUID = %q, want one backfilled", prompt.UUID)
+	}
+	if got, err := repo.GetPromptByUUID(ctx, prompt.UUID); err != nil || got.ID != 1 {
+		t.Errorf("GetPromptByUUID() = %v, %v, want #1", got, err)
+	}
+}
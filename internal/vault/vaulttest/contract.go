@@ -0,0 +1,631 @@
+// Package vaulttest checks that a vault.PromptRepository behaves like the
+// ones pvt ships with. Every storage backend runs the same suite, so that
+// prompts are stored, looked up and ordered the same whichever is in use.
+package vaulttest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+)
+
+// Runs the contract every repository must follow against the repositories
+// returned by open, which must be empty and independent of each other.
+// Its subtests are create, update, lookups, delete, batch, replace, trash,
+// ordering, query, usage, rank and concurrent: they cover id, uuid and slug
+// assignment, timestamps, not found errors, atomic batches, the trash of
+// replaced prompts, relevance ranking and concurrent use.
+func TestRepository(t *testing.T, open func(t *testing.T) vault.PromptRepository) {
+	t.Run("create", func(t *testing.T) { contractCreate(t, open(t)) })
+	t.Run("update", func(t *testing.T) { contractUpdate(t, open(t)) })
+	t.Run("lookups", func(t *testing.T) { contractLookups(t, open(t)) })
+	t.Run("delete", func(t *testing.T) { contractDelete(t, open(t)) })
+	t.Run("batch", func(t *testing.T) { contractBatch(t, open(t)) })
+	t.Run("replace", func(t *testing.T) { contractReplace(t, open(t)) })
+	t.Run("trash", func(t *testing.T) { contractTrash(t, open(t)) })
+	t.Run("ordering", func(t *testing.T) { contractOrdering(t, open(t)) })
+	t.Run("query", func(t *testing.T) { contractQuery(t, open(t)) })
+	t.Run("usage", func(t *testing.T) { contractUsage(t, open(t)) })
+	t.Run("rank", func(t *testing.T) { contractRank(t, open(t)) })
+	t.Run("concurrent", func(t *testing.T) { contractConcurrent(t, open(t)) })
+}
+
+func contractCreate(t *testing.T, repo vault.PromptRepository) {
+	ctx := context.Background()
+	before := time.Now()
+
+	p := &vault.Prompt{Title: "House Style", Description: "d", PromptContent: "Be {{tone}}.", Variables: []string{"tone"}}
+	if _, err := repo.CreateOrUpdatePrompt(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+	if p.ID != 1 || !vault.ValidUUID(p.UUID) || p.Slug != "house-style" {
+		t.Errorf("created prompt = %+v, want id 1, a uuid and a slug", p)
+	}
+	if p.CreatedAt.Before(before) || p.UpdatedAt.Before(p.CreatedAt) {
+		t.Errorf("timestamps = %v, %v, want both set on creation", p.CreatedAt, p.UpdatedAt)
+	}
+
+	got, err := repo.GetPromptByID(ctx, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameRecord(*got, *p) {
+		t.Errorf("GetPromptByID() = %+v, want %+v", got, p)
+	}
+
+	// imported prompts keep their uuid and creation time
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	imported := &vault.Prompt{Title: "Imported", PromptContent: "c", UUID: vault.NewUUID(), CreatedAt: created}
+	if _, err := repo.CreateOrUpdatePrompt(ctx, imported); err != nil {
+		t.Fatal(err)
+	}
+	if imported.ID != 2 || !imported.CreatedAt.Equal(created) {
+		t.Errorf("imported prompt = %+v, want id 2 created at %v", imported, created)
+	}
+}
+
+func contractUpdate(t *testing.T, repo vault.PromptRepository) {
+	ctx := context.Background()
+
+	p := &vault.Prompt{Title: "House Style", PromptContent: "c"}
+	if _, err := repo.CreateOrUpdatePrompt(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+	created, uuid := p.CreatedAt, p.UUID
+
+	time.Sleep(time.Millisecond)
+	update := *p
+	update.PromptContent = "changed"
+	update.UUID = "" // the stored uuid never changes
+	if _, err := repo.CreateOrUpdatePrompt(ctx, &update); err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.GetPromptByID(ctx, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.PromptContent != "changed" || got.UUID != uuid || !got.CreatedAt.Equal(created) || !got.UpdatedAt.After(created) {
+		t.Errorf("updated prompt = %+v, want the content changed, same uuid and creation, later update", got)
+	}
+
+	// a failed write leaves the caller's prompt alone
+	other := &vault.Prompt{Title: "Other", PromptContent: "c"}
+	if _, err := repo.CreateOrUpdatePrompt(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	clash := *other
+	clash.Slug = p.Slug
+	if _, err := repo.CreateOrUpdatePrompt(ctx, &clash); !errors.Is(err, vault.ErrValidation) {
+		t.Errorf("taking another prompt's slug: error = %v, want vault.ErrValidation", err)
+	}
+	if clash.Slug != p.Slug || !clash.UpdatedAt.Equal(other.UpdatedAt) {
+		t.Errorf("prompt after a failed write = %+v, want it unchanged", clash)
+	}
+}
+
+func contractLookups(t *testing.T, repo vault.PromptRepository) {
+	ctx := context.Background()
+
+	p := &vault.Prompt{Title: "House Style", PromptContent: "c"}
+	if _, err := repo.CreateOrUpdatePrompt(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+	p.Slug = "style"
+	if _, err := repo.CreateOrUpdatePrompt(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Aliases) != 1 || p.Aliases[0] != "house-style" {
+		t.Errorf("aliases = %v, want [house-style]", p.Aliases)
+	}
+
+	for _, slug := range []string{"style", "house-style"} {
+		if got, err := repo.GetPromptBySlug(ctx, slug); err != nil || got.ID != p.ID {
+			t.Errorf("GetPromptBySlug(%q) = %+v, %v, want prompt %d", slug, got, err, p.ID)
+		}
+	}
+	if got, err := repo.GetPromptByUUID(ctx, p.UUID); err != nil || got.ID != p.ID {
+		t.Errorf("GetPromptByUUID() = %+v, %v, want prompt %d", got, err, p.ID)
+	}
+
+	if got, err := repo.GetPromptByID(ctx, 99); !errors.Is(err, vault.ErrNotFound) || got != nil {
+		t.Errorf("GetPromptByID(99) = %+v, %v, want vault.ErrNotFound", got, err)
+	}
+	if _, err := repo.GetPromptBySlug(ctx, "nope"); !errors.Is(err, vault.ErrNotFound) {
+		t.Errorf("GetPromptBySlug(nope) error = %v, want vault.ErrNotFound", err)
+	}
+	if _, err := repo.GetPromptByUUID(ctx, vault.NewUUID()); !errors.Is(err, vault.ErrNotFound) {
+		t.Errorf("GetPromptByUUID() error = %v, want vault.ErrNotFound", err)
+	}
+}
+
+func contractDelete(t *testing.T, repo vault.PromptRepository) {
+	ctx := context.Background()
+
+	if err := repo.DeletePrompt(ctx, 1); !errors.Is(err, vault.ErrNotFound) {
+		t.Errorf("DeletePrompt() on an empty vault error = %v, want vault.ErrNotFound", err)
+	}
+
+	first := &vault.Prompt{Title: "First", PromptContent: "c"}
+	second := &vault.Prompt{Title: "Second", PromptContent: "c"}
+	for _, p := range []*vault.Prompt{first, second} {
+		if _, err := repo.CreateOrUpdatePrompt(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.DeletePrompt(ctx, second.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetPromptByID(ctx, second.ID); !errors.Is(err, vault.ErrNotFound) {
+		t.Errorf("GetPromptByID() after delete error = %v, want vault.ErrNotFound", err)
+	}
+	if _, err := repo.GetPromptBySlug(ctx, second.Slug); !errors.Is(err, vault.ErrNotFound) {
+		t.Errorf("GetPromptBySlug() after delete error = %v, want vault.ErrNotFound", err)
+	}
+
+	// the slug and uuid are free again, the id is not
+	third := &vault.Prompt{Title: "Second", PromptContent: "c", UUID: second.UUID}
+	if _, err := repo.CreateOrUpdatePrompt(ctx, third); err != nil {
+		t.Fatal(err)
+	}
+	if third.ID != 3 || third.Slug != second.Slug {
+		t.Errorf("prompt created after a delete = %+v, want id 3 and slug %q", third, second.Slug)
+	}
+}
+
+func contractBatch(t *testing.T, repo vault.PromptRepository) {
+	ctx := context.Background()
+
+	saved, err := repo.CreateOrUpdatePrompts(ctx, []vault.Prompt{
+		{Title: "Same", PromptContent: "a"},
+		{Title: "Same", PromptContent: "b"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved[0].ID != 1 || saved[1].ID != 2 || saved[0].Slug == saved[1].Slug {
+		t.Errorf("batch = %+v, want ids 1, 2 and distinct slugs", saved)
+	}
+
+	// a failing prompt rolls back the whole batch
+	if _, err := repo.CreateOrUpdatePrompts(ctx, []vault.Prompt{
+		{Title: "Fine", PromptContent: "c"},
+		{Title: "Clash", PromptContent: "c", UUID: saved[0].UUID},
+	}); !errors.Is(err, vault.ErrValidation) {
+		t.Errorf("CreateOrUpdatePrompts() error = %v, want vault.ErrValidation", err)
+	}
+
+	// and so does a cancelled context
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := repo.CreateOrUpdatePrompts(cancelled, []vault.Prompt{{Title: "Late", PromptContent: "c"}}); !errors.Is(err, context.Canceled) {
+		t.Errorf("CreateOrUpdatePrompts() with a cancelled context error = %v, want context.Canceled", err)
+	}
+
+	prompts, err := repo.GetAllPrompts(ctx)
+	if err != nil || len(prompts) != 2 {
+		t.Errorf("GetAllPrompts() = %d prompts, %v, want the 2 of the first batch", len(prompts), err)
+	}
+}
+
+func contractReplace(t *testing.T, repo vault.PromptRepository) {
+	ctx := context.Background()
+
+	saved, err := repo.CreateOrUpdatePrompts(ctx, []vault.Prompt{
+		{Title: "Kept", PromptContent: "a"},
+		{Title: "Gone", PromptContent: "a"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, gone := saved[0], saved[1]
+
+	// a missing prompt fails the whole batch
+	kept.Aliases = []string{gone.Slug}
+	if _, err := repo.ReplacePrompts(ctx, []vault.Prompt{kept}, []int{gone.ID, 42}); !errors.Is(err, vault.ErrNotFound) {
+		t.Errorf("ReplacePrompts() of a missing prompt error = %v, want vault.ErrNotFound", err)
+	}
+	if _, err := repo.GetPromptByID(ctx, gone.ID); err != nil {
+		t.Errorf("GetPromptByID() after a failed replace = %v, want the prompt kept", err)
+	}
+
+	// the slug of a trashed prompt can be taken as an alias in the same batch
+	replaced, err := repo.ReplacePrompts(ctx, []vault.Prompt{kept}, []int{gone.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(replaced[0].Aliases, gone.Slug) {
+		t.Errorf("Aliases = %v, want %q", replaced[0].Aliases, gone.Slug)
+	}
+	if _, err := repo.GetPromptByID(ctx, gone.ID); !errors.Is(err, vault.ErrNotFound) {
+		t.Errorf("GetPromptByID() of the trashed prompt error = %v, want vault.ErrNotFound", err)
+	}
+	if got, err := repo.GetPromptBySlug(ctx, gone.Slug); err != nil || got.ID != kept.ID {
+		t.Errorf("GetPromptBySlug(%q) = %+v, %v, want prompt %d", gone.Slug, got, err, kept.ID)
+	}
+
+	// an alias another prompt holds is not taken
+	other, err := repo.CreateOrUpdatePrompt(ctx, &vault.Prompt{Title: "Other", PromptContent: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept = replaced[0]
+	kept.Aliases = append(kept.Aliases, other.Slug)
+	replaced, err = repo.ReplacePrompts(ctx, []vault.Prompt{kept}, nil)
+	if err != nil || slices.Contains(replaced[0].Aliases, other.Slug) {
+		t.Errorf("ReplacePrompts() with the slug of another prompt = %+v, %v, want it left out", replaced, err)
+	}
+}
+
+func contractTrash(t *testing.T, repo vault.PromptRepository) {
+	ctx := context.Background()
+
+	if trash, err := repo.GetTrash(ctx); err != nil || trash == nil || len(trash) != 0 {
+		t.Errorf("GetTrash() on an empty vault = %#v, %v, want an empty slice", trash, err)
+	}
+
+	saved, err := repo.CreateOrUpdatePrompts(ctx, []vault.Prompt{
+		{Title: "Kept", PromptContent: "a"},
+		{Title: "Gone", PromptContent: "b", Aliases: []string{"gone-before"}, Tags: []string{"draft"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, gone := saved[0], saved[1]
+	kept.Aliases = []string{gone.Slug}
+	if _, err := repo.ReplacePrompts(ctx, []vault.Prompt{kept}, []int{gone.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	trash, err := repo.GetTrash(ctx)
+	if err != nil || len(trash) != 1 || !sameRecord(trash[0].Prompt, gone) {
+		t.Fatalf("GetTrash() = %+v, %v, want the trashed prompt as it was", trash, err)
+	}
+	if since := time.Since(trash[0].TrashedAt); since < -time.Second || since > time.Minute {
+		t.Errorf("TrashedAt = %v, want the time of the replace", trash[0].TrashedAt)
+	}
+	if prompts, err := repo.GetAllPrompts(ctx); err != nil || len(prompts) != 1 {
+		t.Errorf("GetAllPrompts() = %d prompts, %v, want the trashed one left out", len(prompts), err)
+	}
+
+	// it comes back under its id, with a new slug as the kept prompt holds its own
+	restored, err := repo.RestoreTrashed(ctx, gone.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.ID != gone.ID || restored.UUID != gone.UUID || restored.PromptContent != gone.PromptContent || !slices.Equal(restored.Tags, gone.Tags) {
+		t.Errorf("RestoreTrashed() = %+v, want the trashed prompt %+v", restored, gone)
+	}
+	if restored.Slug == "" || restored.Slug == gone.Slug || !slices.Equal(restored.Aliases, []string{"gone-before"}) {
+		t.Errorf("restored slug and aliases = %q, %v, want a new slug and the aliases still free", restored.Slug, restored.Aliases)
+	}
+	if got, err := repo.GetPromptBySlug(ctx, restored.Slug); err != nil || got.ID != gone.ID {
+		t.Errorf("GetPromptBySlug(%q) = %+v, %v, want the restored prompt", restored.Slug, got, err)
+	}
+	if got, err := repo.GetPromptBySlug(ctx, gone.Slug); err != nil || got.ID != kept.ID {
+		t.Errorf("GetPromptBySlug(%q) = %+v, %v, want the kept prompt still", gone.Slug, got, err)
+	}
+	if trash, err := repo.GetTrash(ctx); err != nil || len(trash) != 0 {
+		t.Errorf("GetTrash() after the restore = %+v, %v, want it empty", trash, err)
+	}
+	if _, err := repo.RestoreTrashed(ctx, gone.ID); !errors.Is(err, vault.ErrNotFound) {
+		t.Errorf("RestoreTrashed() of a prompt not in the trash error = %v, want vault.ErrNotFound", err)
+	}
+}
+
+func contractOrdering(t *testing.T, repo vault.PromptRepository) {
+	ctx := context.Background()
+
+	if prompts, err := repo.GetAllPrompts(ctx); err != nil || prompts == nil || len(prompts) != 0 {
+		t.Errorf("GetAllPrompts() on an empty vault = %#v, %v, want an empty slice", prompts, err)
+	}
+
+	ps := []*vault.Prompt{{Title: "One", PromptContent: "c"}, {Title: "Two", PromptContent: "c"}, {Title: "Three", PromptContent: "c"}}
+	for _, p := range ps {
+		time.Sleep(time.Millisecond)
+		if _, err := repo.CreateOrUpdatePrompt(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// touching the first one brings it to the top
+	time.Sleep(time.Millisecond)
+	if _, err := repo.CreateOrUpdatePrompt(ctx, ps[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	prompts, err := repo.GetAllPrompts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, p := range prompts {
+		got = append(got, p.Title)
+	}
+	if fmt.Sprint(got) != "[One Three Two]" {
+		t.Errorf("GetAllPrompts() order = %v, want most recently updated first", got)
+	}
+}
+
+func contractQuery(t *testing.T, repo vault.PromptRepository) {
+	ctx := context.Background()
+
+	day := func(d int) time.Time { return time.Date(2025, 1, d, 0, 0, 0, 0, time.UTC) }
+	// created out of title order, and updated in the order they are saved
+	ps := []*vault.Prompt{
+		{Title: "beta", PromptContent: "c", CreatedAt: day(3), Tags: []string{"review"}},
+		{Title: "Alpha", PromptContent: "c", CreatedAt: day(1), Tags: []string{"code", "review"}, Collection: "work", Pinned: true},
+		{Title: "delta", PromptContent: "c", CreatedAt: day(4), Collection: "work"},
+		{Title: "Gamma", PromptContent: "c", CreatedAt: day(2), Tags: []string{"code"}, Pinned: true},
+	}
+	for _, p := range ps {
+		time.Sleep(time.Millisecond)
+		p.UUID = vault.NewUUID()
+		if _, err := repo.CreateOrUpdatePrompt(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query vault.Query
+		want  string
+	}{
+		{"everything", vault.Query{}, "[Gamma delta Alpha beta]"},
+		{"reversed", vault.Query{Reverse: true}, "[beta Alpha delta Gamma]"},
+		{"created", vault.Query{Sort: vault.SortCreated}, "[delta beta Gamma Alpha]"},
+		{"title", vault.Query{Sort: vault.SortTitle}, "[Alpha beta delta Gamma]"},
+		{"title reversed", vault.Query{Sort: vault.SortTitle, Reverse: true}, "[Gamma delta beta Alpha]"},
+		{"tag", vault.Query{Tags: []string{"review"}}, "[Alpha beta]"},
+		{"every tag", vault.Query{Tags: []string{"code", "review"}}, "[Alpha]"},
+		{"unknown tag", vault.Query{Tags: []string{"nope"}}, "[]"},
+		{"collection", vault.Query{Collection: "work", Sort: vault.SortTitle}, "[Alpha delta]"},
+		{"pinned", vault.Query{Pinned: true}, "[Gamma Alpha]"},
+		{"created within", vault.Query{CreatedAfter: day(2), CreatedBefore: day(3), Sort: vault.SortCreated}, "[beta Gamma]"},
+		{"updated after", vault.Query{UpdatedAfter: ps[2].UpdatedAt}, "[Gamma delta]"},
+		{"filters combined", vault.Query{Tags: []string{"code"}, Pinned: true, Collection: "work"}, "[Alpha]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := repo.QueryPrompts(ctx, tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := titles(page.Prompts); got != tt.want || page.Next != "" {
+				t.Errorf("QueryPrompts() = %v, next %q, want %v and no next page", got, page.Next, tt.want)
+			}
+		})
+	}
+
+	// paging through every sort, both ways, gives every prompt once in order
+	for _, sort := range vault.SortKeys {
+		for _, reverse := range []bool{false, true} {
+			q := vault.Query{Sort: sort, Reverse: reverse}
+			all, err := repo.QueryPrompts(ctx, q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			q.Limit = 3
+			paged := []vault.Prompt{}
+			for pages := 0; ; pages++ {
+				page, err := repo.QueryPrompts(ctx, q)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(page.Prompts) > q.Limit || pages > len(ps) {
+					t.Fatalf("sort %s: page of %d prompts after %d pages, want at most %d", sort, len(page.Prompts), pages, q.Limit)
+				}
+				paged = append(paged, page.Prompts...)
+				if page.Next == "" {
+					break
+				}
+				q.Cursor = page.Next
+			}
+			if got, want := titles(paged), titles(all.Prompts); got != want {
+				t.Errorf("sort %s reverse %v: pages = %v, want %v", sort, reverse, got, want)
+			}
+		}
+	}
+
+	// an exact page has no next one
+	if page, err := repo.QueryPrompts(ctx, vault.Query{Limit: len(ps)}); err != nil || len(page.Prompts) != len(ps) || page.Next != "" {
+		t.Errorf("QueryPrompts() with a limit of every prompt = %d prompts, next %q, %v, want all and no next page", len(page.Prompts), page.Next, err)
+	}
+
+	// cursors only work with the query they came from
+	first, err := repo.QueryPrompts(ctx, vault.Query{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range []vault.Query{
+		{Cursor: "garbage"},
+		{Cursor: first.Next, Sort: vault.SortTitle},
+		{Cursor: first.Next, Reverse: true},
+		{Sort: "size"},
+		{Limit: -1},
+	} {
+		if _, err := repo.QueryPrompts(ctx, q); !errors.Is(err, vault.ErrValidation) {
+			t.Errorf("QueryPrompts(%+v) error = %v, want vault.ErrValidation", q, err)
+		}
+	}
+}
+
+func contractUsage(t *testing.T, repo vault.PromptRepository) {
+	ctx := context.Background()
+
+	// usage given by the caller is ignored, only RecordUse counts
+	first := &vault.Prompt{Title: "First", PromptContent: "c", Uses: 9}
+	second := &vault.Prompt{Title: "Second", PromptContent: "c"}
+	for _, p := range []*vault.Prompt{first, second} {
+		if _, err := repo.CreateOrUpdatePrompt(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if first.Uses != 0 || !first.LastUsedAt.IsZero() {
+		t.Errorf("new prompt usage = %d, %v, want none", first.Uses, first.LastUsedAt)
+	}
+
+	before := time.Now()
+	for range 2 {
+		if _, err := repo.RecordUse(ctx, first.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := repo.GetPromptByID(ctx, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Uses != 2 || got.LastUsedAt.Before(before) || !got.UpdatedAt.Equal(first.UpdatedAt) {
+		t.Errorf("used prompt = %d uses, last %v, updated %v, want 2 uses since %v and the update time unchanged",
+			got.Uses, got.LastUsedAt, got.UpdatedAt, before)
+	}
+
+	// editing the prompt keeps its usage
+	edit := *got
+	edit.Uses, edit.LastUsedAt = 0, time.Time{}
+	edit.PromptContent = "changed"
+	if _, err := repo.CreateOrUpdatePrompt(ctx, &edit); err != nil {
+		t.Fatal(err)
+	}
+	if edit.Uses != 2 || !edit.LastUsedAt.Equal(got.LastUsedAt) {
+		t.Errorf("edited prompt usage = %d, %v, want it kept", edit.Uses, edit.LastUsedAt)
+	}
+
+	page, err := repo.QueryPrompts(ctx, vault.Query{Sort: vault.SortUses})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := titles(page.Prompts); got != "[First Second]" {
+		t.Errorf("QueryPrompts() by uses = %v, want most used first", got)
+	}
+
+	if _, err := repo.RecordUse(ctx, 99); !errors.Is(err, vault.ErrNotFound) {
+		t.Errorf("RecordUse(99) error = %v, want vault.ErrNotFound", err)
+	}
+}
+
+func contractRank(t *testing.T, repo vault.PromptRepository) {
+	ctx := context.Background()
+
+	if _, err := repo.CreateOrUpdatePrompts(ctx, []vault.Prompt{
+		{Title: "Schema changes", PromptContent: "Review this SQL migration. Check that every migration can be rolled back."},
+		{Title: "Code review", PromptContent: "Review the code for bugs."},
+		{Title: "Summary", PromptContent: "Summarize the article in three bullet points."},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// other forms of the words match, and unrelated prompts are left out
+	results, err := repo.RankPrompts(ctx, "the prompt about reviewing SQL migrations", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for i, r := range results {
+		got = append(got, r.Prompt.Title)
+		if r.Score <= 0 || i > 0 && r.Score > results[i-1].Score {
+			t.Errorf("RankPrompts() scores = %v, want positive and best first", results)
+		}
+	}
+	if fmt.Sprint(got) != "[Schema changes Code review]" {
+		t.Errorf("RankPrompts() = %v, want the migration prompt first and no summary", got)
+	}
+
+	// saving a prompt updates what it is found by
+	summary, err := repo.GetPromptBySlug(ctx, "summary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary.PromptContent = "Summarize the migrations of the release."
+	if _, err := repo.CreateOrUpdatePrompt(ctx, summary); err != nil {
+		t.Fatal(err)
+	}
+	if results, err := repo.RankPrompts(ctx, "migrations", 1); err != nil || len(results) != 1 || results[0].Prompt.Title != "Schema changes" {
+		t.Errorf("RankPrompts() limited to 1 = %v, %v, want the prompt mentioning migrations most", results, err)
+	}
+	if results, err := repo.RankPrompts(ctx, "summarize", 0); err != nil || len(results) != 1 {
+		t.Errorf("RankPrompts() after an edit = %v, %v, want the edited prompt", results, err)
+	}
+	if err := repo.DeletePrompt(ctx, summary.ID); err != nil {
+		t.Fatal(err)
+	}
+	if results, err := repo.RankPrompts(ctx, "summarize", 0); err != nil || len(results) != 0 {
+		t.Errorf("RankPrompts() after a delete = %v, %v, want nothing", results, err)
+	}
+
+	if results, err := repo.RankPrompts(ctx, "the of", 0); err != nil || len(results) != 0 {
+		t.Errorf("RankPrompts() of stop words = %v, %v, want nothing", results, err)
+	}
+}
+
+// titles of the prompts, for comparing orders
+func titles(prompts []vault.Prompt) string {
+	got := []string{}
+	for _, p := range prompts {
+		got = append(got, p.Title)
+	}
+	return fmt.Sprint(got)
+}
+
+func contractConcurrent(t *testing.T, repo vault.PromptRepository) {
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for i := range 20 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			p := &vault.Prompt{Title: fmt.Sprintf("Prompt %d", i), PromptContent: "c"}
+			if _, err := repo.CreateOrUpdatePrompt(ctx, p); err != nil {
+				errs <- err
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := repo.GetAllPrompts(ctx); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	prompts, err := repo.GetAllPrompts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, slugs := map[int]bool{}, map[string]bool{}
+	for _, p := range prompts {
+		ids[p.ID], slugs[p.Slug] = true, true
+	}
+	if len(prompts) != 20 || len(ids) != 20 || len(slugs) != 20 {
+		t.Errorf("got %d prompts, %d distinct ids, %d distinct slugs, want 20 of each", len(prompts), len(ids), len(slugs))
+	}
+}
+
+// reports whether two prompts are the same record, times compared as instants
+func sameRecord(a, b vault.Prompt) bool {
+	return a.ID == b.ID &&
+		a.UUID == b.UUID &&
+		a.Title == b.Title &&
+		a.Slug == b.Slug &&
+		slices.Equal(a.Aliases, b.Aliases) &&
+		a.Description == b.Description &&
+		a.PromptContent == b.PromptContent &&
+		slices.Equal(a.Variables, b.Variables) &&
+		slices.Equal(a.Tags, b.Tags) &&
+		a.Collection == b.Collection &&
+		a.Pinned == b.Pinned &&
+		a.CreatedAt.Equal(b.CreatedAt) &&
+		a.UpdatedAt.Equal(b.UpdatedAt) &&
+		a.Uses == b.Uses &&
+		a.LastUsedAt.Equal(b.LastUsedAt)
+}
@@ -1,63 +1,281 @@
 package main
 
 import (
-	"log/slog"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sync"
+	"syscall"
 
+	"github.com/Dima-salang/proompt-vault-tui/internal/cli"
+	"github.com/Dima-salang/proompt-vault-tui/internal/config"
+	"github.com/Dima-salang/proompt-vault-tui/internal/gitsync"
+	"github.com/Dima-salang/proompt-vault-tui/internal/keymap"
+	"github.com/Dima-salang/proompt-vault-tui/internal/logging"
+	"github.com/Dima-salang/proompt-vault-tui/internal/theme"
 	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
 	"github.com/Dima-salang/proompt-vault-tui/tui"
-	"github.com/boltdb/bolt"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
 func main() {
 
-	// logging
-	f, err := os.OpenFile("debug.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	// flags before the command override the config, as in
+	// pvt -storage.backend sqlite list
+	flags, global := globalFlags()
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(cli.ExitOK)
+		}
+		os.Exit(cli.ExitUsage)
+	}
+	args := flags.Args()
+
+	// the config dir holds the config and, by default, the vault
+	dir, err := config.Dir()
+	if err == nil {
+		err = os.MkdirAll(dir, 0755)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "pvt:", err)
+		os.Exit(1)
+	}
+	path := global.config
+	if path == "" {
+		if path, err = config.Path(); err != nil {
+			fmt.Fprintln(os.Stderr, "pvt:", err)
+			os.Exit(1)
+		}
+	}
+
+	// the settings in effect: the file, then the environment, then the flags
+	loadConfig := func() (config.Config, []string, error) {
+		c, warnings, err := config.Load(path)
+		if err != nil {
+			return c, warnings, err
+		}
+		if err := c.ApplyEnv(os.Getenv); err != nil {
+			return c, warnings, err
+		}
+		for _, o := range global.overrides {
+			if err := c.Set(o[0], o[1]); err != nil {
+				return c, warnings, err
+			}
+		}
+		return c, warnings, nil
+	}
+
+	// pvt config does without the vault, so that a setting keeping it
+	// from opening can be fixed
+	if len(args) > 0 && args[0] == "config" {
+		app := &cli.App{
+			Stdout: os.Stdout, Stderr: os.Stderr,
+			ConfigPath: path, ConfigDir: dir, LoadConfig: loadConfig,
+		}
+		os.Exit(app.Run(context.Background(), args))
+	}
+
+	cfg, warnings, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "pvt:", err)
+		os.Exit(1)
+	}
+	if len(args) > 0 {
+		for _, w := range warnings {
+			fmt.Fprintln(os.Stderr, "pvt: warning:", w)
+		}
+	}
+
+	// logging, to the state dir rather than wherever pvt is run from.
+	// -verbose also logs to stderr, which the tui would draw over.
+	logPath, err := cfg.LogPath()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "pvt:", err)
+		os.Exit(1)
+	}
+	level, err := logging.ParseLevel(cfg.Log.Level)
 	if err != nil {
+		fmt.Fprintln(os.Stderr, "pvt:", err)
 		os.Exit(1)
 	}
-	defer f.Close()
-	logger := slog.New(slog.NewTextHandler(f, nil))
+	logOpts := logging.Options{
+		Path:     logPath,
+		Level:    level,
+		JSON:     cfg.Log.Format == "json",
+		MaxSize:  int64(cfg.Log.MaxSize) << 20,
+		MaxFiles: cfg.Log.MaxFiles,
+	}
+	if len(args) > 0 {
+		if global.verbose {
+			logOpts.Verbose = os.Stderr
+		}
+	} else {
+		logOpts.Recent = 500 // for the log panel
+	}
+	log, err := logging.Open(logOpts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "pvt:", err)
+		os.Exit(1)
+	}
+	defer log.Close()
+	logger := log.Logger
 
-	// open the db connection
-	db, err := openDB()
+	// open the storage
+	backend, storagePath := cfg.Storage.Backend, cfg.StoragePath(dir)
+	repo, closeRepo, err := vault.OpenRepository(backend, storagePath, logger)
 	if err != nil {
-		logger.Error("failed to open database", "error", err)
+		logger.Error("failed to open storage", "backend", backend, "error", err)
+		fmt.Fprintln(os.Stderr, "pvt:", err)
 		os.Exit(1)
 	}
-	defer db.Close()
+	defer closeRepo()
 
-	// create the repository and service
-	repo := vault.NewPromptRepository(db, logger)
+	// create the service
 	service := vault.NewPromptService(repo)
 
+	// git sync mirrors the vault to a working copy next to the db
+	syncer := gitsync.New(filepath.Join(dir, "sync"), service)
+
+	// root context, cancelled on SIGINT/SIGTERM so that in-flight
+	// storage calls are abandoned and their transactions rolled back
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// run a subcommand if one was given
+	if len(args) > 0 {
+		app := &cli.App{
+			Service: service, Stdout: os.Stdout, Stderr: os.Stderr, Budget: cfg.Budget(), Sync: syncer,
+			Repository: repo,
+			Backend:    backend,
+			OpenStorage: func(backend, path string) (vault.PromptRepository, func() error, error) {
+				if path == "" {
+					path = config.DefaultStoragePath(dir, backend)
+				}
+				return vault.OpenRepository(backend, path, logger)
+			},
+			ConfigPath: path, ConfigDir: dir, LoadConfig: loadConfig,
+		}
+		code := app.Run(ctx, args)
+		stop()
+		closeRepo()
+		log.Close()
+		os.Exit(code)
+	}
+
+	// keys of the tui, checked before it starts so that conflicts are
+	// reported rather than keys silently doing the wrong thing
+	keys, err := keymap.Load(filepath.Join(dir, "keys.toml"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "pvt:", err)
+		os.Exit(1)
+	}
+
+	// colors of the tui, tui.theme picks one over themes.toml. the
+	// palette can switch to any of them.
+	themesPath := filepath.Join(dir, "themes.toml")
+	th, err := theme.Load(themesPath, cfg.TUI.Theme)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "pvt:", err)
+		os.Exit(1)
+	}
+	themes, err := theme.LoadAll(themesPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "pvt:", err)
+		os.Exit(1)
+	}
+
+	// the vaults of the other backends the palette switches to are kept
+	// open until pvt exits, so that switching back is instant. only the
+	// configured vault syncs, the sync repository mirrors it alone.
+	var mu sync.Mutex
+	vaults := map[string]vault.PromptService{}
+	closers := []func() error{}
+	defer func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, closeRepo := range closers {
+			closeRepo()
+		}
+	}()
+	openVault := func(backend string) (vault.PromptService, *gitsync.Syncer, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if backend == cfg.Storage.Backend {
+			return service, syncer, nil
+		}
+		if s, ok := vaults[backend]; ok {
+			return s, nil, nil
+		}
+		repo, closeRepo, err := vault.OpenRepository(backend, config.DefaultStoragePath(dir, backend), logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		closers = append(closers, closeRepo)
+		vaults[backend] = vault.NewPromptService(repo)
+		return vaults[backend], nil, nil
+	}
+
+	// the recent commands of the palette are kept next to the log
+	history := ""
+	if state, err := config.StateDir(); err == nil {
+		history = filepath.Join(state, "commands")
+	}
+
 	// run the tui
-	p := tea.NewProgram(tui.NewModel(service), tea.WithAltScreen())
+	for _, w := range warnings {
+		logger.Warn("config", "warning", w)
+	}
+	opts := tui.Options{
+		Config: cfg, Syncer: syncer, Keys: keys, Theme: th, Warnings: warnings,
+		Logger: logger, Recorder: log.Recorder,
+		Themes: themes, Backend: backend, OpenVault: openVault, History: history,
+	}
+	p := tea.NewProgram(tui.NewModel(ctx, service, opts), tea.WithAltScreen(), tea.WithContext(ctx))
 	if _, err := p.Run(); err != nil {
 		logger.Error("failed to run tui", "error", err)
 		os.Exit(1)
 	}
 }
 
-func openDB() (*bolt.DB, error) {
-	// create the db on the config directory
-	// we use the user config dir
+// what the flags of pvt itself were set to
+type globals struct {
+	config  string
+	verbose bool
 
-	configDir, err := os.UserConfigDir()
-	if err != nil {
-		return nil, err
-	}
+	// settings in the order given, to be applied over the config file and
+	// the environment
+	overrides [][2]string
+}
 
-	// create the subdir for the app
-	appDir := filepath.Join(configDir, "proompt-vault")
-	if err := os.MkdirAll(appDir, 0755); err != nil {
-		return nil, err
+// the flags of pvt itself: -config, -verbose and one for every setting,
+// named by its key
+func globalFlags() (*flag.FlagSet, *globals) {
+	flags := flag.NewFlagSet("pvt", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: pvt [flags] [command] [flags]")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "The flags override the config file and the environment, run pvt help")
+		fmt.Fprintln(os.Stderr, "for the commands.")
+		fmt.Fprintln(os.Stderr)
+		flags.PrintDefaults()
 	}
+	g := &globals{}
+	flags.StringVar(&g.config, "config", "", "config file, $PVT_CONFIG or config.toml in the config dir by default")
+	flags.BoolVar(&g.verbose, "verbose", false, "also log to stderr, from debug up, when running a command")
 
-	// db path
-	dbPath := filepath.Join(appDir, "prompts.db")
-
-	return bolt.Open(dbPath, 0600, nil)
+	check := config.Default()
+	for _, s := range check.Settings() {
+		flags.Func(s.Key, s.Doc, func(value string) error {
+			if err := s.Set(value); err != nil {
+				return err
+			}
+			g.overrides = append(g.overrides, [2]string{s.Key, value})
+			return nil
+		})
+	}
+	return flags, g
 }
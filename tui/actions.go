@@ -0,0 +1,255 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/gitsync"
+	"github.com/Dima-salang/proompt-vault-tui/internal/theme"
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Actions of the list, run by their keys or from the command palette.
+// Those about a prompt act on the selected one and do nothing without it.
+
+func (m Model) newPrompt() (Model, tea.Cmd) {
+	m.state = stateCreate
+	m.insert = true
+	m.resetForm()
+	return m, nil
+}
+
+func (m Model) editPrompt() (Model, tea.Cmd) {
+	if i, ok := m.list.SelectedItem().(item); ok {
+		m.state = stateCreate
+		m.insert = false
+		m.activePrompt = &i.prompt
+		m.setForm(i.prompt)
+	}
+	return m, nil
+}
+
+// opens the editor on a copy of the prompt, saved as a new one. the slug
+// is left to be derived from the new title, as two prompts cannot share it.
+func (m Model) clonePrompt() (Model, tea.Cmd) {
+	if i, ok := m.list.SelectedItem().(item); ok {
+		m.state = stateCreate
+		m.insert = false
+		m.activePrompt = nil
+		m.setForm(i.prompt)
+		m.titleInput.SetValue(i.prompt.Title + " (copy)")
+		m.slugInput.SetValue("")
+	}
+	return m, nil
+}
+
+func (m Model) copySelected() (Model, tea.Cmd) {
+	if i, ok := m.list.SelectedItem().(item); ok {
+		return m, m.copyPrompt(i.prompt)
+	}
+	return m, nil
+}
+
+func (m Model) previewSelected() (Model, tea.Cmd) {
+	if i, ok := m.list.SelectedItem().(item); ok {
+		return m, m.renderPreview(i.prompt)
+	}
+	return m, nil
+}
+
+func (m Model) pinSelected() (Model, tea.Cmd) {
+	if i, ok := m.list.SelectedItem().(item); ok {
+		return m, m.togglePin(i.prompt)
+	}
+	return m, nil
+}
+
+// looks for prompts including the selected one before asking to delete it
+func (m Model) deleteSelected() (Model, tea.Cmd) {
+	if i, ok := m.list.SelectedItem().(item); ok {
+		m.activePrompt = &i.prompt
+		return m, m.loadDependents
+	}
+	return m, nil
+}
+
+// starts typing a filter, as its key does in the list
+func (m Model) startFilter() (Model, tea.Cmd) {
+	m.list.SetFilterText(m.list.FilterValue())
+	m.list.SetFilterState(list.Filtering)
+	return m, textinput.Blink
+}
+
+func (m Model) openErrors() (Model, tea.Cmd) {
+	m.state = stateErrorLog
+	return m, nil
+}
+
+func (m Model) openLogs() (Model, tea.Cmd) {
+	m.logs = newLogPanel(m.recorder, m.logs.level, m.width, m.height, m.keys.Logs, m.styles)
+	m.state = stateLogs
+	return m, tickLogs()
+}
+
+func (m Model) sync() (Model, tea.Cmd) {
+	return m, tea.Batch(
+		m.list.NewStatusMessage(m.styles.statusMessage.Render("Syncing…")),
+		m.planSync,
+	)
+}
+
+// orders the list by the sort key after the current one
+func (m Model) cycleSort() (Model, tea.Cmd) {
+	return m.sortBy(m.nextSort())
+}
+
+// the sort key the sort action moves to
+func (m Model) nextSort() vault.SortKey {
+	i := slices.Index(vault.SortKeys, m.sort)
+	return vault.SortKeys[(i+1)%len(vault.SortKeys)]
+}
+
+// orders the list by sort, loading it again from the first page
+func (m Model) sortBy(sort vault.SortKey) (Model, tea.Cmd) {
+	m.sort = sort
+	m.next, m.loadingMore = "", false
+	return m, tea.Batch(
+		m.fetchPrompts,
+		m.list.NewStatusMessage(m.styles.statusMessage.Render("Sorted by "+string(sort))),
+	)
+}
+
+// styles the tui with the theme, for the rest of the session
+func (m Model) switchTheme(t theme.Theme) (Model, tea.Cmd) {
+	m.setTheme(t)
+	m.logger.Info("switched theme", "theme", t.Name)
+	return m, m.list.NewStatusMessage(m.styles.statusMessage.Render("✓ Theme " + t.Name))
+}
+
+// the vault of another backend, opened and ready to be shown
+type vaultOpenedMsg struct {
+	backend string
+	service vault.PromptService
+	syncer  *gitsync.Syncer
+}
+
+// opens the vault of another backend in place of this one
+func (m Model) switchVault(backend string) (Model, tea.Cmd) {
+	return m, func() tea.Msg {
+		service, syncer, err := m.openVault(backend)
+		if err != nil {
+			return errMsg{err: fmt.Errorf("could not open the %s vault: %w", backend, err)}
+		}
+		return vaultOpenedMsg{backend: backend, service: service, syncer: syncer}
+	}
+}
+
+// shows the vault opened by switchVault, from its first page
+func (m Model) showVault(msg vaultOpenedMsg) (Model, tea.Cmd) {
+	m.service, m.syncer, m.backend = msg.service, msg.syncer, msg.backend
+	m.state = stateList
+	m.next, m.loadingMore = "", false
+	m.skipped = nil
+	m.list.ResetFilter()
+	m.logger.Info("switched vault", "backend", msg.backend)
+	return m, tea.Batch(
+		m.setItems(nil),
+		m.fetchPrompts,
+		m.list.NewStatusMessage(m.styles.statusMessage.Render("✓ Switched to the "+msg.backend+" vault")),
+	)
+}
+
+// where the vault was written and how many prompts went in
+type exportedMsg struct {
+	path     string
+	prompts  int
+	redacted int
+}
+
+// writes every prompt to a JSON file in the working directory, with
+// secrets redacted, as pvt export does
+func (m Model) exportVault() (Model, tea.Cmd) {
+	return m, func() tea.Msg {
+		ctx, cancel := m.commandContext()
+		defer cancel()
+
+		prompts, err := m.service.GetAllPrompts(ctx)
+		if err != nil {
+			return errMsg{err: fmt.Errorf("could not export prompts: %w", err)}
+		}
+
+		path := "pvt-export-" + time.Now().Format("20060102-150405") + ".json"
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err != nil {
+			return errMsg{err: fmt.Errorf("could not export prompts: %w", err)}
+		}
+		defer f.Close()
+
+		redacted, err := vault.ExportPrompts(f, prompts, vault.DefaultExportOptions())
+		if err == nil {
+			err = f.Close()
+		}
+		if err != nil {
+			return errMsg{err: fmt.Errorf("could not export prompts: %w", err)}
+		}
+		m.logger.Info("exported prompts", "path", path, "prompts", len(prompts), "redacted", redacted)
+		return exportedMsg{path: path, prompts: len(prompts), redacted: redacted}
+	}
+}
+
+// Actions of the other screens, shared with the palette.
+
+func (m Model) backToList() (Model, tea.Cmd) {
+	m.state = stateList
+	return m, nil
+}
+
+// leaves the search for every prompt
+func (m Model) leaveSearch() (Model, tea.Cmd) {
+	m.state = stateList
+	m.searchInput.Blur()
+	return m, m.fetchPrompts
+}
+
+func (m Model) copyPreview() (Model, tea.Cmd) {
+	m.state = stateList
+	return m, m.copyPrompt(m.preview.prompt)
+}
+
+func (m Model) cancelDelete() (Model, tea.Cmd) {
+	m.state = stateList
+	m.activePrompt = nil
+	m.dependents = nil
+	return m, nil
+}
+
+// merges the prompts of the group into the one kept. merging deletes
+// prompts, so it takes a second go.
+func (m Model) mergeGroup() (Model, tea.Cmd) {
+	if len(m.dedupe.merged()) == 0 {
+		return m, nil
+	}
+	if !m.dedupe.confirming {
+		m.dedupe.confirming = true
+		return m, nil
+	}
+	m.dedupe.confirming = false
+	keep := m.dedupe.current().Prompts[m.dedupe.keep]
+	return m, m.mergeDuplicates(keep.ID, m.dedupe.merged())
+}
+
+// applies the sync once every conflict is resolved, or jumps to one
+// that is not
+func (m Model) applyMerge() (Model, tea.Cmd) {
+	if m.merge.plan.Resolved() {
+		m.state = stateList
+		return m, m.applySync(m.merge.plan)
+	}
+	m.merge.resolve(m.merge.current().Resolution)
+	return m, nil
+}
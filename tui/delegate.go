@@ -0,0 +1,114 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Renders a prompt of the list in three lines: its title, its description
+// and a snippet of its content, highlighting what the search matched.
+// The fuzzy filter matches the title, queries in the filter and the full
+// text search match words, which are highlighted on every line.
+type promptDelegate struct {
+	list.DefaultDelegate // styles, spacing and help
+}
+
+func newPromptDelegate(d list.DefaultDelegate) promptDelegate {
+	d.SetHeight(3)
+	return promptDelegate{DefaultDelegate: d}
+}
+
+func (d promptDelegate) Render(w io.Writer, m list.Model, index int, listItem list.Item) {
+	i, ok := listItem.(item)
+	if !ok || m.Width() <= 0 {
+		return
+	}
+	s := &d.Styles
+	width := m.Width() - s.NormalTitle.GetPaddingLeft() - s.NormalTitle.GetPaddingRight()
+
+	title, desc := i.Title(), i.Description()
+	terms, titleRunes := i.terms, []int(nil)
+	filtered := m.FilterState() != list.Unfiltered && m.FilterValue() != ""
+	if filtered {
+		q, err := vault.ParseSearch(m.FilterValue())
+		if err == nil && !q.Plain() {
+			terms = q.Highlights()
+		} else {
+			// the fuzzy filter matched these runes of the title, its
+			// words may also be found in the rest
+			titleRunes = fuzzyTitleRunes(i, m.MatchesForItem(index))
+			terms = strings.Fields(m.FilterValue())
+		}
+	}
+	if titleRunes == nil {
+		titleRunes = vault.MatchPositions(title, terms)
+	}
+	snippet := contentSnippet(i.prompt.PromptContent, terms, width)
+
+	title = ansi.Truncate(title, width, "…")
+	desc = ansi.Truncate(desc, width, "…")
+	snippet = ansi.Truncate(snippet, width, "…")
+	descRunes := vault.MatchPositions(desc, terms)
+	snippetRunes := vault.MatchPositions(snippet, terms)
+
+	titleStyle, descStyle := s.NormalTitle, s.NormalDesc
+	switch {
+	case m.FilterState() == list.Filtering && m.FilterValue() == "":
+		titleStyle, descStyle = s.DimmedTitle, s.DimmedDesc
+	case index == m.Index() && m.FilterState() != list.Filtering:
+		titleStyle, descStyle = s.SelectedTitle, s.SelectedDesc
+	}
+	snippetStyle := descStyle.Faint(true)
+
+	fmt.Fprintf(w, "%s\n%s\n%s", //nolint: errcheck
+		highlight(title, titleRunes, titleStyle, s.FilterMatch),
+		highlight(desc, descRunes, descStyle, s.FilterMatch),
+		highlight(snippet, snippetRunes, snippetStyle, s.FilterMatch))
+}
+
+// renders line in style, the runes at positions also in match
+func highlight(line string, positions []int, style, match lipgloss.Style) string {
+	if len(positions) > 0 {
+		unmatched := style.Inline(true)
+		line = lipgloss.StyleRunes(line, positions, unmatched.Inherit(match), unmatched)
+	}
+	return style.Render(line)
+}
+
+// the runes of the shown title the fuzzy filter matched. the filter
+// matches the title without the star of pinned prompts, then the tags
+// and the collection, which are not highlighted.
+func fuzzyTitleRunes(i item, matched []int) []int {
+	offset := utf8.RuneCountInString(i.Title()) - utf8.RuneCountInString(i.prompt.Title)
+	n := utf8.RuneCountInString(i.prompt.Title)
+	runes := []int{}
+	for _, r := range matched {
+		if r < n {
+			runes = append(runes, r+offset)
+		}
+	}
+	return runes
+}
+
+// the content on one line, starting a little before the first match of
+// the terms so that it shows within width
+func contentSnippet(content string, terms []string, width int) string {
+	text := []rune(strings.Join(strings.Fields(content), " "))
+	matches := vault.MatchPositions(string(text), terms)
+	if len(matches) == 0 || matches[0] < width/2 {
+		return string(text)
+	}
+	// back to the start of a word, a quarter of the width before the match
+	start := matches[0] - width/4
+	for start > 0 && text[start-1] != ' ' {
+		start--
+	}
+	return "…" + string(text[start:])
+}
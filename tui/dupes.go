@@ -0,0 +1,179 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/keymap"
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// the near duplicates found in the vault
+type duplicatesMsg []vault.DuplicateCluster
+
+// duplicates that were merged into kept
+type mergedMsg struct {
+	kept   *vault.Prompt
+	merged int
+}
+
+// Merge of near duplicate prompts.
+// One group is on screen at a time, showing how each prompt differs from
+// the one to keep. Merging keeps that prompt with the metadata of the
+// ones marked for merging, and moves them to the trash.
+type dedupe struct {
+	clusters []vault.DuplicateCluster
+	index    int          // group on screen
+	keep     int          // prompt of the group to keep
+	cursor   int          // prompt compared with the kept one
+	merging  map[int]bool // prompts of the group to merge, by id
+
+	// set after the first m, the second one merges
+	confirming bool
+}
+
+func newDedupe(clusters []vault.DuplicateCluster) dedupe {
+	d := dedupe{clusters: clusters}
+	d.show(0)
+	return d
+}
+
+func (d *dedupe) current() vault.DuplicateCluster {
+	return d.clusters[d.index]
+}
+
+// shows the group at index, keeping its first prompt and merging the rest
+func (d *dedupe) show(index int) {
+	n := len(d.clusters)
+	d.index = ((index % n) + n) % n
+	d.keep, d.cursor, d.confirming = 0, min(1, len(d.current().Prompts)-1), false
+	d.merging = map[int]bool{}
+	for _, p := range d.current().Prompts[1:] {
+		d.merging[p.ID] = true
+	}
+}
+
+// moves to the next (or previous) group, wrapping around
+func (d *dedupe) move(delta int) {
+	d.show(d.index + delta)
+}
+
+// moves the cursor within the group
+func (d *dedupe) moveCursor(delta int) {
+	n := len(d.current().Prompts)
+	d.cursor = ((d.cursor+delta)%n + n) % n
+	d.confirming = false
+}
+
+// keeps the prompt under the cursor, merging the one kept so far instead
+func (d *dedupe) keepCursor() {
+	prompts := d.current().Prompts
+	d.merging[prompts[d.keep].ID] = true
+	delete(d.merging, prompts[d.cursor].ID)
+	d.keep = d.cursor
+	d.confirming = false
+}
+
+// marks the prompt under the cursor for merging, or leaves it alone
+func (d *dedupe) toggleCursor() {
+	if d.cursor == d.keep {
+		return
+	}
+	id := d.current().Prompts[d.cursor].ID
+	if d.merging[id] {
+		delete(d.merging, id)
+	} else {
+		d.merging[id] = true
+	}
+	d.confirming = false
+}
+
+// the ids of the prompts to merge into the kept one
+func (d *dedupe) merged() []int {
+	ids := []int{}
+	for _, p := range d.current().Prompts {
+		if d.merging[p.ID] {
+			ids = append(ids, p.ID)
+		}
+	}
+	return ids
+}
+
+// drops the group on screen once merged, reports whether any are left
+func (d *dedupe) done() bool {
+	d.clusters = append(d.clusters[:d.index], d.clusters[d.index+1:]...)
+	if len(d.clusters) == 0 {
+		return false
+	}
+	d.show(min(d.index, len(d.clusters)-1))
+	return true
+}
+
+func (d dedupe) view(width, height int, keys keymap.DuplicatesKeys, st styles) string {
+	c := d.current()
+	prompts := c.Prompts
+	keep := prompts[d.keep]
+
+	var b strings.Builder
+	b.WriteString(st.formTitle.Render(fmt.Sprintf("Duplicates %d/%d: %.0f%% similar", d.index+1, len(d.clusters), c.Similarity*100)))
+	b.WriteString("\n")
+
+	for i, p := range prompts {
+		cursor := "  "
+		if i == d.cursor {
+			cursor = "▸ "
+		}
+		status, style := "leave", st.blurredPrompt
+		detail := fmt.Sprintf("%.0f%%", vault.Similarity(keep.PromptContent, p.PromptContent)*100)
+		switch {
+		case i == d.keep:
+			status, style = "keep", st.focusedPrompt
+			detail = fmt.Sprintf("%d use(s)", p.Uses)
+		case d.merging[p.ID]:
+			status, style = "merge", lipgloss.NewStyle().Foreground(st.Danger)
+		}
+		b.WriteString(style.Render(fmt.Sprintf("%s%-6s #%d %s", cursor, status, p.ID, p.Title)))
+		b.WriteString(st.blurredPrompt.Render("  " + detail))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	// how the prompt under the cursor differs from the kept one
+	other := prompts[d.cursor]
+	if d.cursor == d.keep {
+		b.WriteString(st.blurredPrompt.Render(fmt.Sprintf("content of #%d", keep.ID)))
+	} else {
+		b.WriteString(st.blurredPrompt.Render(fmt.Sprintf("- #%d (kept)  + #%d", keep.ID, other.ID)))
+	}
+	b.WriteString("\n")
+
+	h, v := st.app.GetFrameSize()
+	lineWidth := max(width-h-2, 20)
+	lines := max(height-v-len(prompts)-14, 3)
+	diff := vault.DiffLines(keep.PromptContent, other.PromptContent)
+	for i, line := range diff {
+		if i == lines {
+			b.WriteString(st.blurredPrompt.Render(fmt.Sprintf("… %d more line(s)", len(diff)-lines)))
+			b.WriteString("\n")
+			break
+		}
+		prefix, style := "  ", st.input
+		switch line.Op {
+		case vault.DiffDelete:
+			prefix, style = "- ", lipgloss.NewStyle().Foreground(st.Danger)
+		case vault.DiffInsert:
+			prefix, style = "+ ", lipgloss.NewStyle().Foreground(st.Accent)
+		}
+		b.WriteString(style.MaxWidth(lineWidth).Render(prefix + line.Text))
+		b.WriteString("\n")
+	}
+
+	help := helpLine(keys.Up, keys.Down, keys.Keep, keys.Toggle, keys.Next, keys.Prev, keys.Merge, keys.Back)
+	if d.confirming {
+		again := fmt.Sprintf("again to merge %d prompt(s) into #%d and move them to the trash", len(d.merged()), keep.ID)
+		help = helpLine(withHelp(keys.Merge, again), keys.Back)
+	}
+	b.WriteString(st.help.Render(help))
+	return b.String()
+}
@@ -0,0 +1,53 @@
+package tui
+
+import (
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Returns the filter of the list showing items, with the query language
+// of vault.ParseSearch. Plain words, and queries still being typed, are
+// fuzzy matched against the titles, tags and collections as before. The
+// list hands its filter the values of the items, in order, so a query
+// looks the prompts up by their index.
+func filterPrompts(items []list.Item) list.FilterFunc {
+	return func(term string, targets []string) []list.Rank {
+		q, err := vault.ParseSearch(term)
+		if err != nil || q.Plain() {
+			return list.DefaultFilter(term, targets)
+		}
+
+		ranks := []list.Rank{}
+		for i := range targets {
+			if i < len(items) {
+				if it, ok := items[i].(item); ok && q.Match(&it.prompt) {
+					ranks = append(ranks, list.Rank{Index: i})
+				}
+			}
+		}
+		return ranks
+	}
+}
+
+// shows items in the list, along with the filter looking at them. the
+// filter goes with the list, so that a filter running in the background
+// sees the items it was given.
+func (m *Model) setItems(items []list.Item) tea.Cmd {
+	m.list.Filter = filterPrompts(items)
+	return m.list.SetItems(items)
+}
+
+// reports a malformed filter query in the status bar, once per change of
+// the filter
+func (m *Model) checkFilter() tea.Cmd {
+	query := m.list.FilterValue()
+	if query == m.filterQuery {
+		return nil
+	}
+	m.filterQuery = query
+	if _, err := vault.ParseSearch(query); err != nil {
+		return m.list.NewStatusMessage(m.styles.lintError.Render("✗ " + err.Error()))
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+// the help line of a screen, listing its bindings that have keys
+func helpLine(bindings ...key.Binding) string {
+	parts := []string{}
+	for _, b := range bindings {
+		if b.Enabled() {
+			parts = append(parts, b.Help().Key+" "+b.Help().Desc)
+		}
+	}
+	return strings.Join(parts, "  •  ")
+}
+
+// the binding with another description in the help
+func withHelp(b key.Binding, desc string) key.Binding {
+	b.SetHelp(b.Help().Key, desc)
+	return b
+}
+
+// reports whether keys are typed into the editor or the search. with a
+// modal keymap only in insert mode, otherwise always.
+func (m Model) typing() bool {
+	return !m.keys.Modal || m.insert
+}
@@ -0,0 +1,136 @@
+package tui
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/keymap"
+	"github.com/Dima-salang/proompt-vault-tui/internal/logging"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// how often the open log panel looks for new records
+const logRefresh = time.Second
+
+// levels the log panel cycles through, the least one shown
+var logLevels = []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError}
+
+// asks the open log panel to look for new records
+type logTickMsg struct{}
+
+// The latest records of the log, as they are written. It follows the new
+// ones while scrolled to the bottom.
+type logPanel struct {
+	recorder *logging.Recorder // nil if nothing is recorded
+	level    slog.Level
+	seen     int // records there had been at the last refresh
+	shown    int // records at the level
+	viewport viewport.Model
+}
+
+func newLogPanel(recorder *logging.Recorder, level slog.Level, width, height int, keys keymap.LogsKeys, st styles) logPanel {
+	h, v := st.app.GetFrameSize()
+
+	// leave room for the header and the help line
+	vp := viewport.New(max(width-h, 20), max(height-v-6, 5))
+	vp.KeyMap = viewport.KeyMap{
+		Up:       keys.Up,
+		Down:     keys.Down,
+		PageUp:   keys.PageUp,
+		PageDown: keys.PageDown,
+	}
+
+	p := logPanel{recorder: recorder, level: level, seen: -1, viewport: vp}
+	p.refresh(st)
+	p.viewport.GotoBottom()
+	return p
+}
+
+// shows the records of the recorder again if there are new ones
+func (p *logPanel) refresh(st styles) {
+	if p.recorder == nil || p.recorder.Total() == p.seen {
+		return
+	}
+	p.seen = p.recorder.Total()
+
+	follow := p.viewport.AtBottom()
+	lines := []string{}
+	for _, e := range p.recorder.Entries() {
+		if e.Level >= p.level {
+			lines = append(lines, logLine(e, st))
+		}
+	}
+	p.shown = len(lines)
+	p.viewport.SetContent(strings.Join(lines, "\n"))
+	if follow {
+		p.viewport.GotoBottom()
+	}
+}
+
+// shows the next level up, back to debug after error
+func (p *logPanel) cycleLevel(st styles) {
+	for i, level := range logLevels {
+		if level == p.level {
+			p.level = logLevels[(i+1)%len(logLevels)]
+			break
+		}
+	}
+	p.seen = -1
+	p.refresh(st)
+	p.viewport.GotoBottom()
+}
+
+// a record on a line, colored by its level
+func logLine(e logging.Entry, st styles) string {
+	level := lipgloss.NewStyle().Foreground(st.Muted)
+	switch {
+	case e.Level >= slog.LevelError:
+		level = level.Foreground(st.Danger).Bold(true)
+	case e.Level >= slog.LevelWarn:
+		level = level.Foreground(st.Secondary)
+	case e.Level >= slog.LevelInfo:
+		level = level.Foreground(st.Accent)
+	}
+
+	var b strings.Builder
+	b.WriteString(st.blurredPrompt.Render(e.Time.Format("15:04:05")))
+	b.WriteString(" " + level.Render(fmt.Sprintf("%-5s", e.Level)))
+	b.WriteString(" " + st.input.Render(e.Message))
+	for _, a := range e.Attrs {
+		b.WriteString(" " + lipgloss.NewStyle().Foreground(st.Subtle).Render(a.Key+"="+a.Value.String()))
+	}
+	return b.String()
+}
+
+func (p logPanel) view(keys keymap.LogsKeys, st styles) string {
+	var b strings.Builder
+	b.WriteString(st.formTitle.Render("Log"))
+	b.WriteString(st.blurredPrompt.Render(fmt.Sprintf("  %s and up", p.level)))
+	b.WriteString("\n\n")
+
+	switch {
+	case p.recorder == nil:
+		b.WriteString(st.blurredPrompt.Render("The log is not recorded."))
+	case p.shown == 0:
+		b.WriteString(st.blurredPrompt.Render("Nothing logged so far."))
+	default:
+		b.WriteString(p.viewport.View())
+	}
+	b.WriteString("\n")
+
+	bottom := keys.Bottom
+	if p.viewport.AtBottom() {
+		bottom = withHelp(bottom, "following")
+	}
+	b.WriteString(st.help.Render(helpLine(keys.Up, keys.Down, keys.Top, bottom, keys.Level, keys.Back)))
+	return b.String()
+}
+
+// looks for new records once a while, for as long as the panel is open
+func tickLogs() tea.Cmd {
+	return tea.Tick(logRefresh, func(time.Time) tea.Msg { return logTickMsg{} })
+}
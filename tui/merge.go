@@ -0,0 +1,158 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/gitsync"
+	"github.com/Dima-salang/proompt-vault-tui/internal/keymap"
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// a sync merged and waiting to be applied
+type syncPlanMsg struct {
+	plan *gitsync.Plan
+}
+
+// a sync that was applied
+type syncedMsg struct {
+	result gitsync.Result
+}
+
+// Three-way merge of the conflicts of a sync.
+// Each conflict shows the last synced version next to both sides,
+// and is resolved by keeping one of them.
+type merge struct {
+	plan  *gitsync.Plan
+	index int // conflict on screen
+}
+
+func (m *merge) current() *gitsync.Conflict {
+	return m.plan.Conflicts[m.index]
+}
+
+// moves to the next (or previous) conflict, wrapping around
+func (m *merge) move(delta int) {
+	n := len(m.plan.Conflicts)
+	m.index = ((m.index+delta)%n + n) % n
+}
+
+// resolves the conflict on screen and moves to the next unresolved one
+func (m *merge) resolve(side gitsync.Side) {
+	m.current().Resolve(side)
+	for i := 1; i < len(m.plan.Conflicts); i++ {
+		next := (m.index + i) % len(m.plan.Conflicts)
+		if m.plan.Conflicts[next].Resolution == gitsync.Unresolved {
+			m.index = next
+			return
+		}
+	}
+}
+
+func (m merge) view(width, height int, keys keymap.MergeKeys, st styles) string {
+	c := m.current()
+
+	var b strings.Builder
+	b.WriteString(st.formTitle.Render(fmt.Sprintf("Sync conflict %d/%d: %s", m.index+1, len(m.plan.Conflicts), c.Title())))
+	b.WriteString("\n")
+
+	status := "unresolved"
+	switch c.Resolution {
+	case gitsync.KeepLocal:
+		status = "keeping local"
+	case gitsync.KeepRemote:
+		status = "keeping remote"
+	}
+	b.WriteString(st.blurredPrompt.PaddingLeft(2).Render(c.Summary() + "  ·  " + status))
+	b.WriteString("\n\n")
+
+	fields := c.Fields
+	if len(fields) == 0 {
+		// deleted on one side, show what the other side did
+		fields = []string{vault.FieldContent}
+	}
+
+	// three columns inside the window, the rest is shared by the fields
+	h, v := st.app.GetFrameSize()
+	columnWidth := max((width-h-4)/3, 16)
+	lines := max((height-v-10)/len(fields)-2, 3)
+
+	for _, field := range fields {
+		b.WriteString(st.focusedPrompt.Render(field))
+		b.WriteString("\n")
+
+		base := fieldValue(c.Base, field)
+		b.WriteString(lipgloss.JoinHorizontal(lipgloss.Top,
+			mergeColumn("base", c.Base, field, base, columnWidth, lines, "(not synced before)", st),
+			"  ",
+			mergeColumn("local", c.Local, field, base, columnWidth, lines, "(deleted)", st),
+			"  ",
+			mergeColumn("remote", c.Remote, field, base, columnWidth, lines, "(deleted)", st),
+		))
+		b.WriteString("\n\n")
+	}
+
+	b.WriteString(st.help.Render(helpLine(keys.Local, keys.Remote, keys.Next, keys.Prev, keys.Apply, keys.Back)))
+	return b.String()
+}
+
+// renders a field of one side, or missing if the side has no such prompt.
+// lines that are not in the base are highlighted.
+func mergeColumn(label string, p *vault.Prompt, field, base string, width, height int, missing string, st styles) string {
+	baseLines := map[string]bool{}
+	for _, line := range strings.Split(base, "\n") {
+		baseLines[line] = true
+	}
+
+	var b strings.Builder
+	b.WriteString(st.blurredPrompt.Render(label))
+	b.WriteString("\n")
+
+	if p == nil {
+		b.WriteString(st.fieldError.UnsetPaddingLeft().Render(missing))
+		return lipgloss.NewStyle().Width(width).Render(b.String())
+	}
+
+	lines := strings.Split(fieldValue(p, field), "\n")
+	for i, line := range lines {
+		if i == height {
+			b.WriteString(st.blurredPrompt.Render(fmt.Sprintf("… %d more line(s)", len(lines)-height)))
+			break
+		}
+		style := st.input
+		if !baseLines[line] {
+			style = lipgloss.NewStyle().Foreground(st.Accent)
+		}
+		b.WriteString(style.MaxWidth(width).Render(line))
+		b.WriteString("\n")
+	}
+	return lipgloss.NewStyle().Width(width).Render(strings.TrimSuffix(b.String(), "\n"))
+}
+
+// value of a merged field of the prompt, "" if there is no prompt
+func fieldValue(p *vault.Prompt, field string) string {
+	if p == nil {
+		return ""
+	}
+	switch field {
+	case vault.FieldTitle:
+		return p.Title
+	case vault.FieldSlug:
+		return p.Slug
+	case vault.FieldDescription:
+		return p.Description
+	case vault.FieldVariables:
+		return strings.Join(p.Variables, ", ")
+	case vault.FieldTags:
+		return strings.Join(p.Tags, ", ")
+	case vault.FieldCollection:
+		return p.Collection
+	case vault.FieldPinned:
+		if p.Pinned {
+			return "pinned"
+		}
+		return ""
+	}
+	return p.PromptContent
+}
@@ -1,10 +1,20 @@
 package tui
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"strings"
 	"time"
 
+	"github.com/Dima-salang/proompt-vault-tui/internal/config"
+	"github.com/Dima-salang/proompt-vault-tui/internal/gitsync"
+	"github.com/Dima-salang/proompt-vault-tui/internal/keymap"
+	"github.com/Dima-salang/proompt-vault-tui/internal/logging"
+	"github.com/Dima-salang/proompt-vault-tui/internal/theme"
+	"github.com/Dima-salang/proompt-vault-tui/internal/tokenizer"
 	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
@@ -20,120 +30,343 @@ const (
 	stateList sessionState = iota
 	stateCreate
 	stateDeleteConfirm
+	stateErrorLog
+	statePreview
+	stateMerge
+	stateDuplicates
+	stateSearch
+	stateLogs
+)
+
+// form fields, in focus order
+const (
+	focusTitle = iota
+	focusSlug
+	focusDescription
+	focusVariables
+	focusTags
+	focusCollection
+	focusContent
+	focusSubmit
 )
 
 type Model struct {
 	state   sessionState
+	ctx     context.Context
 	service vault.PromptService
 	list    list.Model
 
 	// Form inputs
 	titleInput       textinput.Model
+	slugInput        textinput.Model
 	descriptionInput textinput.Model
+	variablesInput   textinput.Model
+	tagsInput        textinput.Model
+	collectionInput  textinput.Model
 	contentInput     textarea.Model
+	searchInput      textinput.Model // words of the relevance search
+	filterQuery      string          // filter of the list last checked for errors
 	focusIndex       int
+	fieldErrors      map[string]string // validation messages keyed by vault field name
+
+	// lint issues of the last submit. while lintPending is set,
+	// submitting again saves the prompt despite the issues.
+	lintIssues  []vault.LintIssue
+	lintPending bool
+
+	// cursor of the next page of prompts, "" once they are all loaded.
+	// loadingMore is set while that page is being fetched.
+	next        string
+	loadingMore bool
+
+	// ids of the unreadable records the user was told about, so that
+	// each refresh does not tell them again
+	skipped map[int]bool
+
+	notifier notifier
+	width    int
+	height   int
+
+	activePrompt *vault.Prompt  // if nil, we are creating. if not, we are editing.
+	dependents   []vault.Prompt // prompts including activePrompt, shown before deleting it
+	preview      preview
+
+	budget tokenizer.Budget // token budget prompts are checked against
+
+	// prompts are loaded pageSize at a time, more as the list is
+	// scrolled. storage calls are given up after timeout so that a stuck
+	// database never freezes the tui, syncs talk to a remote and get longer.
+	pageSize    int
+	timeout     time.Duration
+	syncTimeout time.Duration
+
+	syncer *gitsync.Syncer // nil if the vault cannot be synced
+	merge  merge           // conflicts of the sync waiting to be applied
+
+	dedupe dedupe // near duplicates being merged
 
-	err    error
-	width  int
-	height int
+	keys   keymap.KeyMap
+	insert bool // typing into the editor or the search, see typing
 
-	activePrompt *vault.Prompt // if nil, we are creating. if not, we are editing.
+	styles styles // built from the theme
+
+	warnings []string // about the config, toasted on start
+
+	logger   *slog.Logger
+	recorder *logging.Recorder // the latest records, for the log panel
+	logs     logPanel
+
+	sort vault.SortKey // order of the prompts of the list
+
+	themes    []theme.Theme // to pick from, the current one is in styles
+	backend   string        // of the vault shown
+	openVault VaultOpener   // nil if the vault cannot be switched
+
+	// the command palette, and the commands run from it, the latest
+	// first. they are kept in historyPath across runs, if it is set.
+	palette     palette
+	recent      []string
+	historyPath string
 }
 
-func NewModel(service vault.PromptService) Model {
+// Options set up the tui, besides the vault it shows.
+type Options struct {
+	// sizes of the editor, how many prompts are loaded at a time, how
+	// long storage calls and syncs may take and the token budget
+	Config config.Config
+
+	Syncer *gitsync.Syncer // nil if the vault cannot be synced
+	Keys   keymap.KeyMap
+	Theme  theme.Theme
+
+	// problems with the config that did not keep pvt from starting,
+	// shown as toasts once the tui starts
+	Warnings []string
+
+	Logger   *slog.Logger      // nil to log nothing
+	Recorder *logging.Recorder // nil for an empty log panel
+
+	// themes the palette can switch to, the theme alone if empty
+	Themes []theme.Theme
+
+	// backend of the vault, and how to open the vault of another backend.
+	// the palette can switch vaults if OpenVault is set.
+	Backend   string
+	OpenVault VaultOpener
+
+	// file the commands run from the palette are kept in, so that the
+	// recent ones come first the next time too. "" to keep them for
+	// the session only.
+	History string
+}
+
+// VaultOpener opens the vault kept by a storage backend, along with what
+// syncs it. The tui does not close the vaults it opens.
+type VaultOpener func(backend string) (vault.PromptService, *gitsync.Syncer, error)
+
+// creates the root model. every storage command derives its context
+// from ctx, so cancelling it aborts whatever the tui is waiting on.
+func NewModel(ctx context.Context, service vault.PromptService, opts Options) Model {
+	cfg, keys := opts.Config, opts.Keys
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
 	// Initialize inputs with clean styling
 	ti := textinput.New()
 	ti.Placeholder = "Enter prompt title..."
 	ti.Focus()
-	ti.CharLimit = 50
-	ti.Width = 60
-	ti.PromptStyle = focusedPromptStyle
-	ti.TextStyle = inputStyle
+	ti.CharLimit = cfg.Editor.TitleLimit
+	ti.Width = cfg.Editor.FieldWidth
+
+	slug := textinput.New()
+	slug.Placeholder = "Derived from the title..."
+	slug.CharLimit = cfg.Editor.SlugLimit
+	slug.Width = cfg.Editor.FieldWidth
 
 	desc := textinput.New()
 	desc.Placeholder = "Brief description..."
-	desc.CharLimit = 100
-	desc.Width = 60
-	desc.TextStyle = inputStyle
+	desc.CharLimit = cfg.Editor.DescriptionLimit
+	desc.Width = cfg.Editor.FieldWidth
+
+	vars := textinput.New()
+	vars.Placeholder = "Template variables, e.g. language, tone..."
+	vars.CharLimit = cfg.Editor.VariablesLimit
+	vars.Width = cfg.Editor.FieldWidth
+
+	tags := textinput.New()
+	tags.Placeholder = "Tags, e.g. review, code..."
+	tags.CharLimit = cfg.Editor.TagsLimit
+	tags.Width = cfg.Editor.FieldWidth
+
+	collection := textinput.New()
+	collection.Placeholder = "Collection, optional..."
+	collection.CharLimit = cfg.Editor.CollectionLimit
+	collection.Width = cfg.Editor.FieldWidth
+
+	search := textinput.New()
+	search.Placeholder = "Search by meaning, e.g. reviewing sql migrations..."
+	search.Prompt = "⌕ "
+	search.Width = cfg.Editor.FieldWidth
 
 	cont := textarea.New()
 	cont.Placeholder = "Write your prompt content here..."
 	cont.ShowLineNumbers = true
-	cont.SetWidth(70)
-	cont.SetHeight(12)
-	cont.FocusedStyle.Base = lipgloss.NewStyle().Foreground(textColor)
+	cont.SetWidth(cfg.Editor.Width)
+	cont.SetHeight(cfg.Editor.Height)
+
+	l := list.New([]list.Item{}, newPromptDelegate(list.NewDefaultDelegate()), 0, 0)
+	l.Title = "Prompt Vault"
+	l.Filter = filterPrompts(nil)
+
+	// the list moves and filters with the keys of the keymap too
+	l.KeyMap.CursorUp = keys.List.Up
+	l.KeyMap.CursorDown = keys.List.Down
+	l.KeyMap.PrevPage = keys.List.PrevPage
+	l.KeyMap.NextPage = keys.List.NextPage
+	l.KeyMap.GoToStart = keys.List.Start
+	l.KeyMap.GoToEnd = keys.List.End
+	l.KeyMap.Filter = keys.List.Filter
+	l.KeyMap.ClearFilter = keys.List.ClearFilter
+	l.KeyMap.CancelWhileFiltering = keys.Filter.Cancel
+	l.KeyMap.AcceptWhileFiltering = keys.Filter.Accept
+	l.KeyMap.ShowFullHelp = keys.List.Help
+	l.KeyMap.CloseFullHelp = withHelp(keys.List.Help, "close help")
+	l.KeyMap.Quit = keys.List.Quit
+	l.KeyMap.ForceQuit = keys.Global.Quit
+	l.SetFilteringEnabled(true) // enables the new keys for the empty list
+
+	l.AdditionalShortHelpKeys = func() []key.Binding {
+		return []key.Binding{
+			keys.List.New,
+			keys.List.Edit,
+			keys.List.Delete,
+			keys.List.Copy,
+			keys.List.Preview,
+			keys.List.Search,
+			keys.List.Pin,
+			keys.List.Sync,
+			keys.List.Duplicates,
+			keys.List.Errors,
+			keys.List.Logs,
+			keys.List.Clone,
+			keys.List.Sort,
+			keys.Global.Palette,
+		}
+	}
+	l.AdditionalFullHelpKeys = l.AdditionalShortHelpKeys
+
+	m := Model{
+		state:            stateList,
+		ctx:              ctx,
+		service:          service,
+		list:             l,
+		titleInput:       ti,
+		slugInput:        slug,
+		descriptionInput: desc,
+		variablesInput:   vars,
+		tagsInput:        tags,
+		collectionInput:  collection,
+		contentInput:     cont,
+		searchInput:      search,
+		focusIndex:       0,
+		budget:           cfg.Budget(),
+		pageSize:         cfg.TUI.PageSize,
+		timeout:          cfg.TUI.Timeout,
+		syncTimeout:      cfg.TUI.SyncTimeout,
+		syncer:           opts.Syncer,
+		keys:             keys,
+		warnings:         opts.Warnings,
+		logger:           logger,
+		recorder:         opts.Recorder,
+		sort:             vault.SortUpdated,
+		themes:           opts.Themes,
+		backend:          opts.Backend,
+		openVault:        opts.OpenVault,
+		historyPath:      opts.History,
+		recent:           loadHistory(opts.History),
+	}
+	if len(m.themes) == 0 {
+		m.themes = []theme.Theme{opts.Theme}
+	}
+	m.setTheme(opts.Theme)
+	return m
+}
+
+// styles the tui with a theme
+func (m *Model) setTheme(t theme.Theme) {
+	st := newStyles(t)
+	m.styles = st
+
+	for _, input := range []*textinput.Model{&m.titleInput, &m.slugInput, &m.descriptionInput, &m.variablesInput, &m.tagsInput, &m.collectionInput} {
+		input.TextStyle = st.input
+	}
+	m.titleInput.PromptStyle = st.focusedPrompt
+	m.searchInput.PromptStyle = st.focusedPrompt
+	m.searchInput.TextStyle = st.input
+	m.contentInput.FocusedStyle.Base = lipgloss.NewStyle().Foreground(st.Text)
 
-	// Initialize List with clean delegate styling
-	items := []list.Item{}
 	delegate := list.NewDefaultDelegate()
 
 	// Selected item - no background, just color and border
 	delegate.Styles.SelectedTitle = delegate.Styles.SelectedTitle.
-		Foreground(primaryColor).
+		Foreground(st.Primary).
 		BorderStyle(lipgloss.NormalBorder()).
-		BorderForeground(primaryColor).
+		BorderForeground(st.Primary).
 		BorderLeft(true).
 		Padding(0, 0, 0, 2).
 		Bold(true)
 
 	delegate.Styles.SelectedDesc = delegate.Styles.SelectedDesc.
-		Foreground(subtleColor).
+		Foreground(st.Subtle).
 		Padding(0, 0, 0, 2)
 
 	// Normal items
 	delegate.Styles.NormalTitle = delegate.Styles.NormalTitle.
-		Foreground(textColor).
+		Foreground(st.Text).
 		Padding(0, 0, 0, 1)
 
 	delegate.Styles.NormalDesc = delegate.Styles.NormalDesc.
-		Foreground(mutedColor).
+		Foreground(st.Muted).
 		Padding(0, 0, 0, 1)
 
-	l := list.New(items, delegate, 0, 0)
-	l.Title = "Prompt Vault"
-	l.Styles.Title = listTitleStyle
-	l.Styles.FilterPrompt = lipgloss.NewStyle().Foreground(primaryColor).Bold(true)
-	l.Styles.FilterCursor = lipgloss.NewStyle().Foreground(accentColor)
-
-	l.AdditionalShortHelpKeys = func() []key.Binding {
-		return []key.Binding{
-			key.NewBinding(
-				key.WithKeys("a"),
-				key.WithHelp("a", "new"),
-			),
-			key.NewBinding(
-				key.WithKeys("e"),
-				key.WithHelp("e", "edit"),
-			),
-			key.NewBinding(
-				key.WithKeys("d"),
-				key.WithHelp("d", "delete"),
-			),
-			key.NewBinding(
-				key.WithKeys("enter"),
-				key.WithHelp("↵", "copy"),
-			),
-		}
-	}
-	l.AdditionalFullHelpKeys = l.AdditionalShortHelpKeys
-
-	return Model{
-		state:            stateList,
-		service:          service,
-		list:             l,
-		titleInput:       ti,
-		descriptionInput: desc,
-		contentInput:     cont,
-		focusIndex:       0,
-	}
+	// Items left out while the filter is typed
+	delegate.Styles.DimmedTitle = delegate.Styles.DimmedTitle.
+		Foreground(st.Muted)
+
+	delegate.Styles.DimmedDesc = delegate.Styles.DimmedDesc.
+		Foreground(st.Muted)
+
+	delegate.Styles.FilterMatch = lipgloss.NewStyle().
+		Foreground(st.Secondary).
+		Underline(true)
+
+	m.list.SetDelegate(newPromptDelegate(delegate))
+
+	l := &m.list
+	l.Styles.Title = st.listTitle
+	l.Styles.FilterPrompt = lipgloss.NewStyle().Foreground(st.Primary).Bold(true)
+	l.Styles.FilterCursor = lipgloss.NewStyle().Foreground(st.Accent)
+	l.Styles.StatusBar = l.Styles.StatusBar.Foreground(st.Subtle)
+	l.Styles.NoItems = l.Styles.NoItems.Foreground(st.Muted)
+	l.Help.Styles.ShortKey = l.Help.Styles.ShortKey.Foreground(st.Subtle)
+	l.Help.Styles.FullKey = l.Help.Styles.FullKey.Foreground(st.Subtle)
+	l.Help.Styles.ShortDesc = l.Help.Styles.ShortDesc.Foreground(st.Muted)
+	l.Help.Styles.FullDesc = l.Help.Styles.FullDesc.Foreground(st.Muted)
 }
 
 func (m Model) Init() tea.Cmd {
-	return tea.Batch(
+	cmds := []tea.Cmd{
 		m.fetchPrompts,
 		textinput.Blink,
 		tea.EnableMouseCellMotion,
-	)
+	}
+	for _, w := range m.warnings {
+		cmds = append(cmds, func() tea.Msg { return errMsg{err: errors.New(w), quiet: true} })
+	}
+	return tea.Batch(cmds...)
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -144,145 +377,457 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
-		h, v := appStyle.GetFrameSize()
+		h, v := m.styles.app.GetFrameSize()
 		m.list.SetSize(msg.Width-h, msg.Height-v)
 		return m, nil
 
 	case tea.KeyMsg:
-		if m.state == stateList {
-			switch msg.String() {
-			case "ctrl+c", "q":
-				if m.list.FilterState() == list.Filtering {
-					break
-				}
+		keys := m.keys
+
+		// a fatal error blocks everything until it is dealt with
+		if m.notifier.fatal != nil {
+			switch {
+			case key.Matches(msg, keys.Global.Quit, keys.Fatal.Quit):
 				return m, tea.Quit
-			case "a":
-				if m.list.FilterState() == list.Filtering {
-					break
-				}
-				m.state = stateCreate
-				m.resetForm()
+			case key.Matches(msg, keys.Fatal.Retry):
+				m.notifier.dismissFatal()
+				return m, m.fetchPrompts
+			case key.Matches(msg, keys.Fatal.Dismiss):
+				m.notifier.dismissFatal()
+			}
+			return m, nil
+		}
+
+		if key.Matches(msg, keys.Global.Quit) {
+			return m, tea.Quit
+		}
+		if key.Matches(msg, keys.Global.Dismiss) {
+			m.notifier.dismissToasts()
+			return m, nil
+		}
+
+		// the palette takes the keys while it is open, over any screen
+		if key.Matches(msg, keys.Global.Palette) {
+			if m.palette.open {
+				m.palette.open = false
 				return m, nil
-			case "e":
-				if m.list.FilterState() == list.Filtering {
-					break
-				}
-				if i, ok := m.list.SelectedItem().(item); ok {
-					m.state = stateCreate
-					m.activePrompt = &i.prompt
-					m.setForm(i.prompt)
-				}
+			}
+			return m.openPalette()
+		}
+		if m.palette.open {
+			switch {
+			case key.Matches(msg, keys.Palette.Close):
+				m.palette.open = false
 				return m, nil
-			case "enter":
-				if m.list.FilterState() == list.Filtering {
-					break
-				}
-				if i, ok := m.list.SelectedItem().(item); ok {
-					err := vault.CopyToClipboard(&i.prompt)
-					if err != nil {
-						m.err = err
-						return m, nil
-					}
-					return m, m.list.NewStatusMessage(statusMessageStyle.Render("✓ Copied to clipboard!"))
-				}
+			case key.Matches(msg, keys.Palette.Up):
+				m.palette.move(-1)
 				return m, nil
-			case "d":
-				if m.list.FilterState() == list.Filtering {
-					break
+			case key.Matches(msg, keys.Palette.Down):
+				m.palette.move(1)
+				return m, nil
+			case key.Matches(msg, keys.Palette.Run):
+				return m.runCommand()
+			}
+			query := m.palette.input.Value()
+			m.palette.input, cmd = m.palette.input.Update(msg)
+			if m.palette.input.Value() != query {
+				m.palette.search(m.recent)
+			}
+			return m, cmd
+		}
+
+		// while filtering, keys are typed into the filter
+		if m.state == stateList && m.list.FilterState() != list.Filtering {
+			switch {
+			case key.Matches(msg, keys.List.Quit):
+				return m, tea.Quit
+			case key.Matches(msg, keys.List.New):
+				return m.newPrompt()
+			case key.Matches(msg, keys.List.Edit):
+				return m.editPrompt()
+			case key.Matches(msg, keys.List.Clone):
+				return m.clonePrompt()
+			case key.Matches(msg, keys.List.Copy):
+				return m.copySelected()
+			case key.Matches(msg, keys.List.Preview):
+				return m.previewSelected()
+			case key.Matches(msg, keys.List.Pin):
+				return m.pinSelected()
+			case key.Matches(msg, keys.List.Delete):
+				return m.deleteSelected()
+			case key.Matches(msg, keys.List.Errors):
+				return m.openErrors()
+			case key.Matches(msg, keys.List.Logs):
+				return m.openLogs()
+			case key.Matches(msg, keys.List.Sync):
+				return m.sync()
+			case key.Matches(msg, keys.List.Duplicates):
+				return m, m.findDuplicates
+			case key.Matches(msg, keys.List.Search):
+				return m, m.startSearch()
+			case key.Matches(msg, keys.List.Sort):
+				return m.cycleSort()
+			case key.Matches(msg, keys.List.Export):
+				return m.exportVault()
+			}
+		} else if m.state == stateSearch {
+			if keys.Modal && m.insert {
+				if key.Matches(msg, keys.SearchInsert.Normal) {
+					m.insert = false
+					m.searchInput.Blur()
+					return m, nil
+				}
+			} else {
+				switch {
+				case key.Matches(msg, keys.Search.Back):
+					return m.leaveSearch()
+				case key.Matches(msg, keys.Search.Copy):
+					return m.copySelected()
+				case key.Matches(msg, keys.Search.Up):
+					m.list.CursorUp()
+					return m, nil
+				case key.Matches(msg, keys.Search.Down):
+					m.list.CursorDown()
+					return m, nil
+				case key.Matches(msg, keys.Search.PrevPage):
+					m.list.PrevPage()
+					return m, nil
+				case key.Matches(msg, keys.Search.NextPage):
+					m.list.NextPage()
+					return m, nil
+				case keys.Modal && key.Matches(msg, keys.Search.Insert):
+					m.insert = true
+					return m, m.searchInput.Focus()
 				}
-				if i, ok := m.list.SelectedItem().(item); ok {
-					m.activePrompt = &i.prompt
-					m.state = stateDeleteConfirm
+				// normal mode, nothing is typed
+				if keys.Modal {
+					return m, nil
 				}
+			}
+			query := m.searchInput.Value()
+			m.searchInput, cmd = m.searchInput.Update(msg)
+			cmds = append(cmds, cmd)
+			if m.searchInput.Value() != query {
+				cmds = append(cmds, m.rankPrompts(m.searchInput.Value()))
+			}
+			return m, tea.Batch(cmds...)
+		} else if m.state == stateDuplicates {
+			switch {
+			case key.Matches(msg, keys.Duplicates.Back):
+				m.state = stateList
 				return m, nil
+			case key.Matches(msg, keys.Duplicates.Up):
+				m.dedupe.moveCursor(-1)
+			case key.Matches(msg, keys.Duplicates.Down):
+				m.dedupe.moveCursor(1)
+			case key.Matches(msg, keys.Duplicates.Keep):
+				m.dedupe.keepCursor()
+			case key.Matches(msg, keys.Duplicates.Toggle):
+				m.dedupe.toggleCursor()
+			case key.Matches(msg, keys.Duplicates.Next):
+				m.dedupe.move(1)
+			case key.Matches(msg, keys.Duplicates.Prev):
+				m.dedupe.move(-1)
+			case key.Matches(msg, keys.Duplicates.Merge):
+				return m.mergeGroup()
 			}
+			return m, nil
+		} else if m.state == stateMerge {
+			switch {
+			case key.Matches(msg, keys.Merge.Back):
+				// nothing was written yet
+				m.state = stateList
+				return m, nil
+			case key.Matches(msg, keys.Merge.Local):
+				m.merge.resolve(gitsync.KeepLocal)
+			case key.Matches(msg, keys.Merge.Remote):
+				m.merge.resolve(gitsync.KeepRemote)
+			case key.Matches(msg, keys.Merge.Next):
+				m.merge.move(1)
+			case key.Matches(msg, keys.Merge.Prev):
+				m.merge.move(-1)
+			case key.Matches(msg, keys.Merge.Apply):
+				return m.applyMerge()
+			}
+			return m, nil
+		} else if m.state == statePreview {
+			switch {
+			case key.Matches(msg, keys.Preview.Back):
+				m.state = stateList
+				return m, nil
+			case key.Matches(msg, keys.Preview.Copy):
+				return m.copyPreview()
+			}
+			m.preview.viewport, cmd = m.preview.viewport.Update(msg)
+			return m, cmd
+		} else if m.state == stateErrorLog {
+			if key.Matches(msg, keys.ErrorLog.Back) {
+				m.state = stateList
+			}
+			return m, nil
+		} else if m.state == stateLogs {
+			switch {
+			case key.Matches(msg, keys.Logs.Back):
+				m.state = stateList
+				return m, nil
+			case key.Matches(msg, keys.Logs.Top):
+				m.logs.viewport.GotoTop()
+				return m, nil
+			case key.Matches(msg, keys.Logs.Bottom):
+				m.logs.viewport.GotoBottom()
+				return m, nil
+			case key.Matches(msg, keys.Logs.Level):
+				m.logs.cycleLevel(m.styles)
+				return m, nil
+			}
+			m.logs.viewport, cmd = m.logs.viewport.Update(msg)
+			return m, cmd
 		} else if m.state == stateDeleteConfirm {
-			switch msg.String() {
-			case "y", "Y", "enter":
+			switch {
+			case key.Matches(msg, keys.Confirm.Yes):
 				if m.activePrompt != nil {
 					return m, m.deletePrompt
 				}
-			case "n", "N", "esc", "q":
+			case key.Matches(msg, keys.Confirm.No):
+				return m.cancelDelete()
+			}
+		} else if m.state == stateCreate && !m.typing() {
+			// normal mode, keys are actions and nothing is typed
+			switch {
+			case key.Matches(msg, keys.FormNormal.Cancel):
 				m.state = stateList
-				m.activePrompt = nil
-				return m, nil
+			case key.Matches(msg, keys.FormNormal.Insert):
+				if m.focusIndex != focusSubmit {
+					m.insert = true
+					return m, m.updateFocus()
+				}
+			case key.Matches(msg, keys.FormNormal.Redact):
+				if m.hasSecretIssues() {
+					return m, m.redactForm()
+				}
+			case key.Matches(msg, keys.FormNormal.Submit) && m.focusIndex == focusSubmit:
+				return m, m.submitForm()
+			case key.Matches(msg, keys.FormNormal.Next, keys.FormNormal.Submit):
+				m.lintPending = false
+				return m, m.moveFocus(1)
+			case key.Matches(msg, keys.FormNormal.Prev):
+				m.lintPending = false
+				return m, m.moveFocus(-1)
 			}
+			return m, nil
 		} else if m.state == stateCreate {
-			switch msg.String() {
-			case "ctrl+c":
-				return m, tea.Quit
-			case "esc":
+			switch {
+			case key.Matches(msg, keys.Form.Cancel):
 				m.state = stateList
 				return m, nil
-			case "tab", "shift+tab", "enter", "up", "down":
-				s := msg.String()
+			case keys.Modal && key.Matches(msg, keys.Form.Normal):
+				m.insert = false
+				return m, m.updateFocus()
+			}
 
-				// Prioritize Submit if Enter is pressed on Submit button
-				if s == "enter" && m.focusIndex == 3 {
-					return m, m.createPrompt
-				}
+			// the last check found secrets, offer to redact them in place.
+			// the prompt is checked again and saved if nothing else is left.
+			if key.Matches(msg, keys.Form.Redact) && m.hasSecretIssues() {
+				return m, m.redactForm()
+			}
 
-				// If in textarea (content input), enter should add new line unless ctrl+enter or moved away
-				if m.focusIndex == 2 {
-					if s == "enter" {
-						break // Textarea handles enter natively
-					}
-					if s == "up" || s == "down" {
-						break // Textarea handles up/down natively
-					}
-				}
+			if key.Matches(msg, keys.Form.Submit) && m.focusIndex == focusSubmit {
+				return m, m.submitForm()
+			}
 
-				// Navigation logic
-				if s == "up" || s == "shift+tab" {
-					m.focusIndex--
-				} else if s == "down" || s == "tab" || (s == "enter" && m.focusIndex != 2) {
-					m.focusIndex++
-				}
+			// anything else means the user is still working on the prompt
+			m.lintPending = false
 
-				if m.focusIndex > 3 { // 0: Title, 1: Desc, 2: Content, 3: Submit
-					m.focusIndex = 0
-				} else if m.focusIndex < 0 {
-					m.focusIndex = 3
-				}
+			// the content takes enter and the arrows itself, for new lines
+			// and moving between them
+			s := msg.String()
+			if m.focusIndex == focusContent && (s == "enter" || s == "up" || s == "down") {
+				break
+			}
 
-				// Update focus
-				cmds = append(cmds, m.updateFocus())
-				return m, tea.Batch(cmds...)
+			switch {
+			case key.Matches(msg, keys.Form.Prev):
+				return m, m.moveFocus(-1)
+			case key.Matches(msg, keys.Form.Next, keys.Form.Submit):
+				return m, m.moveFocus(1)
 			}
 		}
 
 	case promptsMsg:
-		items := make([]list.Item, len(msg))
-		for i, p := range msg {
-			items[i] = item{prompt: p}
+		// the search shows its own results until it is left
+		if m.state == stateSearch {
+			break
+		}
+		m.next, m.loadingMore = msg.next, false
+		cmds = append(cmds, m.setItems(m.items(msg.prompts)))
+		cmds = append(cmds, m.warnSkipped(msg.skipped))
+
+	case morePromptsMsg:
+		// a refresh since it was asked for makes this page stale
+		if !m.loadingMore || msg.cursor != m.next {
+			break
+		}
+		m.next, m.loadingMore = msg.next, false
+		cmds = append(cmds, m.setItems(append(m.list.Items(), m.items(msg.prompts)...)))
+		cmds = append(cmds, m.warnSkipped(msg.skipped))
+
+	case rankedMsg:
+		// results of an older query are already out of date
+		if m.state != stateSearch || msg.query != m.searchInput.Value() {
+			break
 		}
-		cmds = append(cmds, m.list.SetItems(items))
+		cmds = append(cmds, m.setItems(m.rankedItems(msg.query, msg.results)))
+
+	case pinnedMsg:
+		status := "✓ Unpinned"
+		if msg.pinned {
+			status = "✓ Pinned"
+		}
+		cmds = append(cmds, m.fetchPrompts)
+		cmds = append(cmds, m.list.NewStatusMessage(m.styles.statusMessage.Render(status)))
+
+	case lintResultMsg:
+		if len(msg) == 0 {
+			return m, m.createPrompt
+		}
+		m.lintIssues = msg
+		m.lintPending = true
+		return m, nil
 
 	case promptCreatedMsg:
 		m.state = stateList
 		m.resetForm()
 		cmds = append(cmds, m.fetchPrompts) // Refresh list
 
+	case previewMsg:
+		m.preview = newPreview(msg, m.width, m.height, m.keys.Preview, m.styles)
+		m.state = statePreview
+		return m, nil
+
+	case syncPlanMsg:
+		if len(msg.plan.Conflicts) == 0 {
+			return m, m.applySync(msg.plan)
+		}
+		m.merge = merge{plan: msg.plan}
+		m.state = stateMerge
+		return m, nil
+
+	case duplicatesMsg:
+		if len(msg) == 0 {
+			return m, m.list.NewStatusMessage(m.styles.statusMessage.Render("✓ No duplicates found"))
+		}
+		m.dedupe = newDedupe(msg)
+		m.state = stateDuplicates
+		return m, nil
+
+	case mergedMsg:
+		cmds = append(cmds, m.fetchPrompts)
+		if !m.dedupe.done() {
+			m.state = stateList
+		}
+		status := fmt.Sprintf("✓ Merged %d prompt(s) into %s, the others are in pvt trash", msg.merged, msg.kept.Title)
+		cmds = append(cmds, m.list.NewStatusMessage(m.styles.statusMessage.Render(status)))
+
+	case syncedMsg:
+		cmds = append(cmds, m.fetchPrompts)
+		cmds = append(cmds, m.list.NewStatusMessage(m.styles.statusMessage.Render("✓ Synced: "+msg.result.String())))
+
+	case exportedMsg:
+		status := fmt.Sprintf("✓ Exported %d prompt(s) to %s", msg.prompts, msg.path)
+		if msg.redacted > 0 {
+			status += fmt.Sprintf(", %d secret(s) redacted", msg.redacted)
+		}
+		return m, m.list.NewStatusMessage(m.styles.statusMessage.Render(status))
+
+	case vaultOpenedMsg:
+		return m.showVault(msg)
+
+	case copiedMsg:
+		return m, m.list.NewStatusMessage(m.styles.statusMessage.Render("✓ Copied to clipboard!"))
+
+	case dependentsMsg:
+		m.dependents = msg
+		m.state = stateDeleteConfirm
+		return m, nil
+
 	case promptDeletedMsg:
 		m.state = stateList
 		m.activePrompt = nil
+		m.dependents = nil
 		cmds = append(cmds, m.fetchPrompts)
-		cmds = append(cmds, m.list.NewStatusMessage(statusMessageStyle.Render("✓ Prompt deleted")))
+		cmds = append(cmds, m.list.NewStatusMessage(m.styles.statusMessage.Render("✓ Prompt deleted")))
 
 	case errMsg:
-		m.err = msg
+		// validation problems are shown next to the offending inputs
+		// instead of replacing the whole form
+		if fields := vault.FieldErrors(msg.err); fields != nil && m.state == stateCreate {
+			m.fieldErrors = fields
+			return m, nil
+		}
+
+		// anything else is a toast, or a modal if we cannot carry on.
+		// the form is left as it is so that nothing typed is lost.
+		if !msg.quiet {
+			logger := m.logger
+			if msg.id != 0 {
+				logger = logger.With("id", msg.id)
+			}
+			logger.Error("action failed", "error", msg.err, "fatal", msg.fatal)
+		}
+		level := levelError
+		if msg.fatal {
+			level = levelFatal
+		}
+		if m.state == stateDeleteConfirm {
+			m.state = stateList
+			m.activePrompt = nil
+			m.dependents = nil
+		}
+		return m, m.notifier.push(msg.err, level)
+
+	case logTickMsg:
+		// the ticks stop once the panel is closed
+		if m.state != stateLogs {
+			return m, nil
+		}
+		m.logs.refresh(m.styles)
+		return m, tickLogs()
+
+	case toastExpiredMsg:
+		m.notifier.expire(msg.id)
 		return m, nil
 	}
 
 	// Update children based on state
+	if m.palette.open {
+		m.palette.input, cmd = m.palette.input.Update(msg)
+		cmds = append(cmds, cmd)
+	}
 	if m.state == stateList {
+		m.list, cmd = m.list.Update(msg)
+		cmds = append(cmds, cmd, m.checkFilter())
+		if m.wantsMore() {
+			m.loadingMore = true
+			cmds = append(cmds, m.fetchMore(m.next))
+		}
+	} else if m.state == stateSearch {
 		m.list, cmd = m.list.Update(msg)
 		cmds = append(cmds, cmd)
+		m.searchInput, cmd = m.searchInput.Update(msg)
+		cmds = append(cmds, cmd)
 	} else {
 		m.titleInput, cmd = m.titleInput.Update(msg)
 		cmds = append(cmds, cmd)
+		m.slugInput, cmd = m.slugInput.Update(msg)
+		cmds = append(cmds, cmd)
 		m.descriptionInput, cmd = m.descriptionInput.Update(msg)
 		cmds = append(cmds, cmd)
+		m.variablesInput, cmd = m.variablesInput.Update(msg)
+		cmds = append(cmds, cmd)
+		m.tagsInput, cmd = m.tagsInput.Update(msg)
+		cmds = append(cmds, cmd)
+		m.collectionInput, cmd = m.collectionInput.Update(msg)
+		cmds = append(cmds, cmd)
 		m.contentInput, cmd = m.contentInput.Update(msg)
 		cmds = append(cmds, cmd)
 	}
@@ -291,42 +836,98 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m Model) View() string {
-	if m.err != nil {
-		return errorMessageStyle.Render("⚠ Error: " + m.err.Error())
+	if m.notifier.fatal != nil {
+		return m.styles.app.Render("\n" + m.notifier.fatalView(m.keys.Fatal, m.styles))
+	}
+
+	toasts := m.notifier.toastView(m.keys.Global.Dismiss, m.styles)
+	if toasts == "" {
+		return m.stateView()
+	}
+
+	// make room for the toasts so that they stay inside the window
+	toasts = m.styles.app.Render(toasts)
+	if m.state == stateList || m.state == stateSearch {
+		m.list.SetHeight(max(m.list.Height()-lipgloss.Height(toasts), 0))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, m.stateView(), toasts)
+}
+
+// renders the screen of the current state, without notifications
+func (m Model) stateView() string {
+	if m.palette.open {
+		h, _ := m.styles.app.GetFrameSize()
+		return m.styles.app.Render(m.palette.view(m.width-h, m.keys.Palette, m.styles))
 	}
 
 	if m.state == stateList {
-		return appStyle.Render(m.list.View())
+		return m.styles.app.Render(m.list.View())
+	}
+
+	if m.state == statePreview {
+		return m.styles.app.Render(m.preview.view(m.keys.Preview, m.styles))
+	}
+
+	if m.state == stateMerge {
+		return m.styles.app.Render(m.merge.view(m.width, m.height, m.keys.Merge, m.styles))
+	}
+
+	if m.state == stateDuplicates {
+		return m.styles.app.Render(m.dedupe.view(m.width, m.height, m.keys.Duplicates, m.styles))
+	}
+
+	if m.state == stateSearch {
+		return m.styles.app.Render(m.searchView())
+	}
+
+	if m.state == stateErrorLog {
+		_, v := m.styles.app.GetFrameSize()
+		return m.styles.app.Render(m.notifier.logView(m.height-v-8, m.keys.ErrorLog.Back, m.styles))
+	}
+
+	if m.state == stateLogs {
+		return m.styles.app.Render(m.logs.view(m.keys.Logs, m.styles))
 	}
 
 	if m.state == stateDeleteConfirm {
 		// Clean confirmation dialog
 		confirmBox := lipgloss.NewStyle().
 			Border(lipgloss.RoundedBorder()).
-			BorderForeground(dangerColor).
+			BorderForeground(m.styles.Danger).
 			Padding(2, 4).
 			Width(60)
 
 		titleStyle := lipgloss.NewStyle().
-			Foreground(dangerColor).
+			Foreground(m.styles.Danger).
 			Bold(true).
 			MarginBottom(1)
 
 		promptStyle := lipgloss.NewStyle().
-			Foreground(primaryColor).
+			Foreground(m.styles.Primary).
 			Padding(1, 0).
 			MarginTop(1).
 			MarginBottom(2).
 			Italic(true)
 
 		helpText := lipgloss.NewStyle().
-			Foreground(subtleColor)
+			Foreground(m.styles.Subtle)
 
 		content := titleStyle.Render("⚠ Delete Prompt?") + "\n\n" +
-			promptStyle.Render(m.activePrompt.Title) + "\n" +
-			helpText.Render("y/↵ confirm  •  n/esc cancel")
+			promptStyle.Render(m.activePrompt.Title) + "\n"
+
+		// deleting a prompt others include breaks them
+		if len(m.dependents) > 0 {
+			content += lipgloss.NewStyle().Foreground(m.styles.Danger).
+				Render(fmt.Sprintf("Included by %d prompt(s), they will fail to render:", len(m.dependents))) + "\n"
+			for _, p := range m.dependents {
+				content += helpText.Render(fmt.Sprintf("  • #%d %s", p.ID, p.Title)) + "\n"
+			}
+			content += "\n"
+		}
 
-		return appStyle.Render("\n" + confirmBox.Render(content))
+		content += helpText.Render(helpLine(m.keys.Confirm.Yes, m.keys.Confirm.No))
+
+		return m.styles.app.Render("\n" + confirmBox.Render(content))
 	}
 
 	// Create Form View
@@ -337,20 +938,28 @@ func (m Model) View() string {
 	if m.activePrompt != nil {
 		title = "Edit Prompt"
 	}
-	b.WriteString(formTitleStyle.Render(title))
+	b.WriteString(m.styles.formTitle.Render(title))
 	b.WriteString("\n\n")
 
 	// Form fields
-	b.WriteString(m.inputView("Title", m.titleInput, m.focusIndex == 0))
+	b.WriteString(m.inputView("Title", m.titleInput, m.focusIndex == focusTitle, m.fieldErrors[vault.FieldTitle]))
+	b.WriteString("\n")
+	b.WriteString(m.inputView("Slug", m.slugInput, m.focusIndex == focusSlug, m.fieldErrors[vault.FieldSlug]))
+	b.WriteString("\n")
+	b.WriteString(m.inputView("Description", m.descriptionInput, m.focusIndex == focusDescription, m.fieldErrors[vault.FieldDescription]))
 	b.WriteString("\n")
-	b.WriteString(m.inputView("Description", m.descriptionInput, m.focusIndex == 1))
+	b.WriteString(m.inputView("Variables", m.variablesInput, m.focusIndex == focusVariables, m.fieldErrors[vault.FieldVariables]))
+	b.WriteString("\n")
+	b.WriteString(m.inputView("Tags", m.tagsInput, m.focusIndex == focusTags, m.fieldErrors[vault.FieldTags]))
+	b.WriteString("\n")
+	b.WriteString(m.inputView("Collection", m.collectionInput, m.focusIndex == focusCollection, m.fieldErrors[vault.FieldCollection]))
 	b.WriteString("\n")
 
 	// Content textarea
 	label := "Content"
-	labelStyle := blurredPromptStyle
-	if m.focusIndex == 2 {
-		labelStyle = focusedPromptStyle
+	labelStyle := m.styles.blurredPrompt
+	if m.focusIndex == focusContent {
+		labelStyle = m.styles.focusedPrompt
 		label = "▸ " + label
 	} else {
 		label = "  " + label
@@ -360,51 +969,138 @@ func (m Model) View() string {
 	// Content box with subtle border
 	contentBorder := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(borderColor).
+		BorderForeground(m.styles.Border).
 		Padding(0, 1)
 
-	if m.focusIndex == 2 {
-		contentBorder = contentBorder.BorderForeground(primaryColor)
+	if m.focusIndex == focusContent {
+		contentBorder = contentBorder.BorderForeground(m.styles.Primary)
 	}
 
-	b.WriteString(contentBorder.Render(m.contentInput.View()))
+	if msg, ok := m.fieldErrors[vault.FieldContent]; ok {
+		contentBorder = contentBorder.BorderForeground(m.styles.Danger)
+		b.WriteString(contentBorder.Render(m.contentInput.View()))
+		b.WriteString("\n" + m.styles.fieldError.Render("✗ Content "+msg))
+	} else {
+		b.WriteString(contentBorder.Render(m.contentInput.View()))
+	}
+	b.WriteString("\n" + m.tokenView())
 	b.WriteString("\n\n")
 
+	// Lint issues of the last submit
+	if len(m.lintIssues) > 0 {
+		b.WriteString(m.lintView())
+		b.WriteString("\n")
+	}
+
 	// Submit button
-	btn := blurredButtonStyle.Render("  Submit  ")
-	if m.focusIndex == 3 {
-		btn = focusedButtonStyle.Render("▸ Submit ◂")
+	btn := m.styles.blurredButton.Render("  Submit  ")
+	if m.focusIndex == focusSubmit {
+		btn = m.styles.focusedButton.Render("▸ Submit ◂")
 	}
 	b.WriteString(btn)
 	b.WriteString("\n")
 
-	// Help text
-	b.WriteString(helpStyle.Render("esc cancel  •  tab/shift+tab navigate  •  ↵ submit"))
+	// Help text, for the mode the editor is in
+	form := m.keys.Form
+	help := []key.Binding{form.Cancel, form.Normal, form.Next, form.Prev, form.Submit, form.Redact}
+	mode := ""
+	if m.keys.Modal {
+		mode = "-- INSERT --  "
+	}
+	if !m.typing() {
+		normal := m.keys.FormNormal
+		help = []key.Binding{normal.Cancel, normal.Insert, normal.Next, normal.Prev, normal.Submit, normal.Redact}
+		mode = "-- NORMAL --  "
+	}
+	if m.lintPending {
+		help[4] = withHelp(help[4], "save anyway")
+	}
+	if !m.hasSecretIssues() {
+		help = help[:5]
+	}
+	b.WriteString(m.styles.help.Render(mode + helpLine(help...)))
+
+	return m.styles.app.Render(b.String())
+}
+
+// renders the live token estimate of the content being edited
+func (m Model) tokenView() string {
+	estimate := m.budget.Estimate(m.contentInput.Value())
+
+	text := fmt.Sprintf("%s (%s)", estimate, m.budget.Family.Name)
+	if m.budget.Limit > 0 {
+		text = fmt.Sprintf("%s of %s (%s)", estimate, tokenizer.FormatCount(m.budget.Limit), m.budget.Family.Name)
+	}
+
+	if estimate.OverBudget() {
+		return m.styles.fieldError.Render("⚠ " + text + ", over budget")
+	}
+	return m.styles.blurredPrompt.PaddingLeft(2).Render(text)
+}
 
-	return appStyle.Render(b.String())
+// reports whether the last check found secrets in the content
+func (m Model) hasSecretIssues() bool {
+	for _, issue := range m.lintIssues {
+		if issue.Rule == vault.RulePossibleSecret {
+			return true
+		}
+	}
+	return false
 }
 
-func (m Model) inputView(label string, input textinput.Model, focused bool) string {
-	labelStyle := blurredPromptStyle
+// renders the lint issues found when the prompt was submitted
+func (m Model) lintView() string {
+	var b strings.Builder
+	for _, issue := range m.lintIssues {
+		style := m.styles.lintInfo
+		switch issue.Severity {
+		case vault.SeverityWarning:
+			style = m.styles.lintWarning
+		case vault.SeverityError:
+			style = m.styles.lintError
+		}
+		location := issue.Field
+		if issue.Line > 0 {
+			location = fmt.Sprintf("%s:%d", issue.Field, issue.Line)
+		}
+		b.WriteString(style.Render(fmt.Sprintf("%-7s", issue.Severity)))
+		b.WriteString(" " + m.styles.input.Render(issue.Message))
+		b.WriteString(" " + m.styles.blurredPrompt.Render(location))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func (m Model) inputView(label string, input textinput.Model, focused bool, errText string) string {
+	name := label
+	labelStyle := m.styles.blurredPrompt
 	if focused {
-		labelStyle = focusedPromptStyle
+		labelStyle = m.styles.focusedPrompt
 		label = "▸ " + label
-		input.PromptStyle = focusedPromptStyle
-		input.TextStyle = inputStyle
+		input.PromptStyle = m.styles.focusedPrompt
+		input.TextStyle = m.styles.input
 	} else {
 		label = "  " + label
-		input.PromptStyle = blurredPromptStyle
-		input.TextStyle = lipgloss.NewStyle().Foreground(mutedColor)
+		input.PromptStyle = m.styles.blurredPrompt
+		input.TextStyle = lipgloss.NewStyle().Foreground(m.styles.Muted)
 	}
 
 	// Input with clean border
 	inputBorder := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(borderColor).
+		BorderForeground(m.styles.Border).
 		Padding(0, 1)
 
 	if focused {
-		inputBorder = inputBorder.BorderForeground(primaryColor)
+		inputBorder = inputBorder.BorderForeground(m.styles.Primary)
+	}
+
+	if errText != "" {
+		inputBorder = inputBorder.BorderForeground(m.styles.Danger)
+		return fmt.Sprintf("%s\n%s\n%s\n",
+			labelStyle.Render(label),
+			inputBorder.Render(input.View()),
+			m.styles.fieldError.Render("✗ "+name+" "+errText))
 	}
 
 	return fmt.Sprintf("%s\n%s\n",
@@ -414,84 +1110,404 @@ func (m Model) inputView(label string, input textinput.Model, focused bool) stri
 
 func (m *Model) updateFocus() tea.Cmd {
 	m.titleInput.Blur()
+	m.slugInput.Blur()
 	m.descriptionInput.Blur()
+	m.variablesInput.Blur()
+	m.tagsInput.Blur()
+	m.collectionInput.Blur()
 	m.contentInput.Blur()
 
+	// in normal mode nothing takes the keys
+	if !m.typing() {
+		return nil
+	}
+
 	switch m.focusIndex {
-	case 0:
+	case focusTitle:
 		return m.titleInput.Focus()
-	case 1:
+	case focusSlug:
+		return m.slugInput.Focus()
+	case focusDescription:
 		return m.descriptionInput.Focus()
-	case 2:
+	case focusVariables:
+		return m.variablesInput.Focus()
+	case focusTags:
+		return m.tagsInput.Focus()
+	case focusCollection:
+		return m.collectionInput.Focus()
+	case focusContent:
 		return m.contentInput.Focus()
 	}
 	return nil
 }
 
+// moves the focus of the form to the next (or previous) field, wrapping around
+func (m *Model) moveFocus(delta int) tea.Cmd {
+	m.focusIndex += delta
+	if m.focusIndex > focusSubmit {
+		m.focusIndex = focusTitle
+	} else if m.focusIndex < focusTitle {
+		m.focusIndex = focusSubmit
+	}
+	return m.updateFocus()
+}
+
+// the first submit lints the prompt, and if there is anything to report
+// the second one saves it anyway
+func (m *Model) submitForm() tea.Cmd {
+	if m.lintPending {
+		m.lintPending = false
+		return m.createPrompt
+	}
+	return m.lintPrompt
+}
+
+// redacts the secrets the last check found, and checks the prompt again
+func (m *Model) redactForm() tea.Cmd {
+	content, _ := vault.RedactSecrets(m.contentInput.Value())
+	m.contentInput.SetValue(content)
+	m.lintPending = false
+	return m.lintPrompt
+}
+
 func (m *Model) resetForm() {
 	m.titleInput.SetValue("")
+	m.slugInput.SetValue("")
 	m.descriptionInput.SetValue("")
+	m.variablesInput.SetValue("")
+	m.tagsInput.SetValue("")
+	m.collectionInput.SetValue("")
 	m.contentInput.SetValue("")
 	m.activePrompt = nil
-	m.focusIndex = 0
-	m.titleInput.Focus()
+	m.fieldErrors = nil
+	m.lintIssues = nil
+	m.lintPending = false
+	m.focusIndex = focusTitle
+	m.updateFocus()
 }
 
 func (m *Model) setForm(p vault.Prompt) {
 	m.titleInput.SetValue(p.Title)
+	m.slugInput.SetValue(p.Slug)
 	m.descriptionInput.SetValue(p.Description)
+	m.variablesInput.SetValue(strings.Join(p.Variables, ", "))
+	m.tagsInput.SetValue(strings.Join(p.Tags, ", "))
+	m.collectionInput.SetValue(p.Collection)
 	m.contentInput.SetValue(p.PromptContent)
-	m.focusIndex = 0
-	m.titleInput.Focus()
-}
-
-// -- Commands --
-
-type promptsMsg []vault.Prompt
-type promptCreatedMsg struct{}
-type promptDeletedMsg struct{}
-type errMsg error
-
-func (m Model) fetchPrompts() tea.Msg {
-	prompts, err := m.service.GetAllPrompts()
-	if err != nil {
-		return errMsg(err)
-	}
-	return promptsMsg(prompts)
+	m.fieldErrors = nil
+	m.lintIssues = nil
+	m.lintPending = false
+	m.focusIndex = focusTitle
+	m.updateFocus()
 }
 
-func (m Model) createPrompt() tea.Msg {
+// builds the prompt described by the form
+func (m Model) formPrompt() *vault.Prompt {
 	id := 0
 	var createdAt time.Time
+	pinned := false
 	if m.activePrompt != nil {
 		id = m.activePrompt.ID
 		createdAt = m.activePrompt.CreatedAt
+		pinned = m.activePrompt.Pinned
 	}
 
-	p := &vault.Prompt{
+	return &vault.Prompt{
 		ID:            id,
 		CreatedAt:     createdAt,
 		Title:         m.titleInput.Value(),
+		Slug:          strings.TrimSpace(m.slugInput.Value()),
 		Description:   m.descriptionInput.Value(),
+		Variables:     parseVariables(m.variablesInput.Value()),
+		Tags:          vault.NormalizeTags(parseVariables(m.tagsInput.Value())),
+		Collection:    m.collectionInput.Value(),
+		Pinned:        pinned,
 		PromptContent: m.contentInput.Value(),
 	}
+}
+
+// splits the variables input on commas and spaces, dropping duplicates
+func parseVariables(value string) []string {
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+
+	seen := map[string]bool{}
+	names := []string{}
+	for _, name := range fields {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// -- Commands --
+
+// the first prompts of the vault, and the cursor of the ones after them
+type promptsMsg struct {
+	prompts []vault.Prompt
+	next    string
+	skipped []int
+}
+
+// the page of prompts starting at cursor
+type morePromptsMsg struct {
+	cursor  string
+	prompts []vault.Prompt
+	next    string
+	skipped []int
+}
+
+type pinnedMsg struct{ pinned bool }
+type lintResultMsg []vault.LintIssue
+type copiedMsg struct{}
+type dependentsMsg []vault.Prompt
+type promptCreatedMsg struct{}
+type promptDeletedMsg struct{}
+
+// an error returned by a command. fatal errors are the ones that
+// leave nothing to work with, like failing to load the vault.
+type errMsg struct {
+	err   error
+	fatal bool
+	id    int  // of the prompt it is about, 0 for none
+	quiet bool // logged already, only to be toasted
+}
+
+// derives a bounded context for a single storage command
+func (m Model) commandContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(m.ctx, m.timeout)
+}
+
+// loads the first page of prompts. a refresh loads as many as the list
+// already shows, so that it does not shrink under the user.
+func (m Model) fetchPrompts() tea.Msg {
+	ctx, cancel := m.commandContext()
+	defer cancel()
+
+	limit := max(m.pageSize, len(m.list.Items()))
+	page, err := m.service.QueryPrompts(ctx, vault.Query{Sort: m.sort, Limit: limit})
+	if err != nil {
+		return errMsg{err: fmt.Errorf("could not load prompts: %w", err), fatal: true}
+	}
+	return promptsMsg{prompts: page.Prompts, next: page.Next, skipped: page.Skipped}
+}
+
+// loads the page of prompts following cursor
+func (m Model) fetchMore(cursor string) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := m.commandContext()
+		defer cancel()
+
+		page, err := m.service.QueryPrompts(ctx, vault.Query{Sort: m.sort, Limit: m.pageSize, Cursor: cursor})
+		if err != nil {
+			return errMsg{err: fmt.Errorf("could not load more prompts: %w", err)}
+		}
+		return morePromptsMsg{cursor: cursor, prompts: page.Prompts, next: page.Next, skipped: page.Skipped}
+	}
+}
 
-	_, err := m.service.CreateOrUpdatePrompt(p)
+// toasts the records left out of a page for being unreadable, the
+// ones not toasted before
+func (m *Model) warnSkipped(ids []int) tea.Cmd {
+	if m.skipped == nil {
+		m.skipped = map[int]bool{}
+	}
+	fresh := 0
+	for _, id := range ids {
+		if !m.skipped[id] {
+			m.skipped[id] = true
+			fresh++
+		}
+	}
+	if fresh == 0 {
+		return nil
+	}
+	return m.notifier.push(fmt.Errorf("skipped %d unreadable prompt(s), run pvt doctor to repair the vault", fresh), levelError)
+}
+
+// reports whether the next page should be loaded: the selection is
+// getting close to the end, or the list is filtered, which only
+// searches the prompts already loaded
+func (m Model) wantsMore() bool {
+	if m.next == "" || m.loadingMore {
+		return false
+	}
+	return m.list.FilterState() != list.Unfiltered || m.list.Index() >= len(m.list.Items())-m.pageSize/4
+}
+
+// wraps prompts as list items
+func (m Model) items(prompts []vault.Prompt) []list.Item {
+	items := make([]list.Item, len(prompts))
+	for i, p := range prompts {
+		items[i] = item{prompt: p, estimate: m.budget.Estimate(p.PromptContent)}
+	}
+	return items
+}
+
+func (m Model) lintPrompt() tea.Msg {
+	ctx, cancel := m.commandContext()
+	defer cancel()
+
+	issues, err := m.service.LintPrompt(ctx, m.formPrompt())
 	if err != nil {
-		return errMsg(err)
+		return errMsg{err: fmt.Errorf("could not check prompt: %w", err)}
 	}
+	return lintResultMsg(issues)
+}
+
+func (m Model) createPrompt() tea.Msg {
+	p := m.formPrompt()
+
+	ctx, cancel := m.commandContext()
+	defer cancel()
+
+	saved, err := m.service.CreateOrUpdatePrompt(ctx, p)
+	if err != nil {
+		return errMsg{err: fmt.Errorf("could not save prompt: %w", err), id: p.ID}
+	}
+	m.logger.Info("saved prompt", "id", saved.ID, "new", p.ID == 0)
 	return promptCreatedMsg{}
 }
 
+// copies the prompt with its includes expanded, counting it as a use
+func (m Model) copyPrompt(prompt vault.Prompt) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := m.commandContext()
+		defer cancel()
+
+		content, err := m.service.RenderPrompt(ctx, prompt.ID)
+		if err != nil {
+			return errMsg{err: fmt.Errorf("could not render prompt: %w", err), id: prompt.ID}
+		}
+		if err := vault.CopyTextToClipboard(content); err != nil {
+			return errMsg{err: fmt.Errorf("copy failed: %w", err), id: prompt.ID}
+		}
+		if _, err := m.service.RecordUse(ctx, prompt.ID); err != nil {
+			return errMsg{err: fmt.Errorf("copied, but could not count the use: %w", err), id: prompt.ID}
+		}
+		m.logger.Info("copied prompt", "id", prompt.ID)
+		return copiedMsg{}
+	}
+}
+
+// renders the prompt for the preview. a prompt that fails to render
+// is still previewed, as is, with the error on top.
+func (m Model) renderPreview(prompt vault.Prompt) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := m.commandContext()
+		defer cancel()
+
+		content, err := m.service.RenderPrompt(ctx, prompt.ID)
+		if err != nil {
+			m.logger.Warn("previewing prompt unexpanded", "id", prompt.ID, "error", err)
+			content = prompt.PromptContent
+		}
+		return previewMsg{prompt: prompt, content: content, err: err}
+	}
+}
+
+// fetches the remote and merges it with the vault
+func (m Model) planSync() tea.Msg {
+	if m.syncer == nil {
+		return errMsg{err: errors.New("sync is not available")}
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, m.syncTimeout)
+	defer cancel()
+
+	plan, err := m.syncer.Plan(ctx)
+	if err != nil {
+		return errMsg{err: fmt.Errorf("could not sync: %w", err)}
+	}
+	return syncPlanMsg{plan: plan}
+}
+
+// writes a merged sync to the vault and pushes it
+func (m Model) applySync(plan *gitsync.Plan) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(m.ctx, m.syncTimeout)
+		defer cancel()
+
+		result, err := m.syncer.Apply(ctx, plan)
+		if err != nil {
+			return errMsg{err: fmt.Errorf("could not sync: %w", err)}
+		}
+		m.logger.Info("synced", "result", result.String())
+		return syncedMsg{result: result}
+	}
+}
+
+// pins the prompt, or unpins it if it already is
+func (m Model) togglePin(prompt vault.Prompt) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := m.commandContext()
+		defer cancel()
+
+		prompt.Pinned = !prompt.Pinned
+		if _, err := m.service.CreateOrUpdatePrompt(ctx, &prompt); err != nil {
+			return errMsg{err: fmt.Errorf("could not pin prompt: %w", err), id: prompt.ID}
+		}
+		m.logger.Info("pinned prompt", "id", prompt.ID, "pinned", prompt.Pinned)
+		return pinnedMsg{pinned: prompt.Pinned}
+	}
+}
+
+func (m Model) findDuplicates() tea.Msg {
+	ctx, cancel := m.commandContext()
+	defer cancel()
+
+	clusters, err := m.service.FindDuplicates(ctx, vault.DefaultSimilarity)
+	if err != nil {
+		return errMsg{err: fmt.Errorf("could not look for duplicates: %w", err)}
+	}
+	return duplicatesMsg(clusters)
+}
+
+func (m Model) mergeDuplicates(keepID int, ids []int) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := m.commandContext()
+		defer cancel()
+
+		kept, err := m.service.MergePrompts(ctx, keepID, ids)
+		if err != nil {
+			return errMsg{err: fmt.Errorf("could not merge prompts: %w", err), id: keepID}
+		}
+		m.logger.Info("merged prompts", "id", keepID, "merged", ids)
+		return mergedMsg{kept: kept, merged: len(ids)}
+	}
+}
+
+func (m Model) loadDependents() tea.Msg {
+	if m.activePrompt == nil {
+		return nil
+	}
+
+	ctx, cancel := m.commandContext()
+	defer cancel()
+
+	dependents, err := m.service.GetDependents(ctx, m.activePrompt.ID)
+	if err != nil {
+		return errMsg{err: fmt.Errorf("could not check what includes this prompt: %w", err), id: m.activePrompt.ID}
+	}
+	return dependentsMsg(dependents)
+}
+
 func (m Model) deletePrompt() tea.Msg {
 	if m.activePrompt == nil {
 		return nil
 	}
 
-	err := m.service.DeletePrompt(m.activePrompt.ID)
+	ctx, cancel := m.commandContext()
+	defer cancel()
+
+	err := m.service.DeletePrompt(ctx, m.activePrompt.ID)
 	if err != nil {
-		return errMsg(err)
+		return errMsg{err: fmt.Errorf("could not delete prompt: %w", err), id: m.activePrompt.ID}
 	}
+	m.logger.Info("deleted prompt", "id", m.activePrompt.ID)
 
 	return promptDeletedMsg{}
 }
@@ -499,9 +1515,49 @@ func (m Model) deletePrompt() tea.Msg {
 // -- List Item Adapter --
 
 type item struct {
-	prompt vault.Prompt
+	prompt   vault.Prompt
+	estimate tokenizer.Estimate
+	score    float64  // relevance to the search, 0 outside of it
+	terms    []string // words of the search to highlight, nil outside of it
+}
+
+// pinned prompts are starred
+func (i item) Title() string {
+	if i.prompt.Pinned {
+		return "★ " + i.prompt.Title
+	}
+	return i.prompt.Title
 }
 
-func (i item) Title() string       { return i.prompt.Title }
-func (i item) Description() string { return i.prompt.Description }
-func (i item) FilterValue() string { return i.prompt.Title }
+// the description line also carries the size and the tags of the prompt
+func (i item) Description() string {
+	size := i.estimate.String()
+	if i.estimate.OverBudget() {
+		size = "⚠ " + size + " (over budget)"
+	}
+	parts := []string{}
+	if i.score > 0 {
+		parts = append(parts, fmt.Sprintf("score %.2f", i.score))
+	}
+	if i.prompt.Description != "" {
+		parts = append(parts, i.prompt.Description)
+	}
+	parts = append(parts, size)
+	if len(i.prompt.Tags) > 0 {
+		parts = append(parts, "#"+strings.Join(i.prompt.Tags, " #"))
+	}
+	return strings.Join(parts, "  ·  ")
+}
+
+// the filter matches tags and the collection as well as the title,
+// queries match the whole prompt
+func (i item) FilterValue() string {
+	value := i.prompt.Title
+	for _, tag := range i.prompt.Tags {
+		value += " #" + tag
+	}
+	if i.prompt.Collection != "" {
+		value += " " + i.prompt.Collection
+	}
+	return value
+}
@@ -0,0 +1,157 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/keymap"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// how long a toast stays on screen before it dismisses itself
+const toastDuration = 5 * time.Second
+
+// how many errors the error log panel keeps around
+const maxLoggedErrors = 50
+
+type notificationLevel int
+
+const (
+	levelError notificationLevel = iota
+	levelFatal
+)
+
+type notification struct {
+	id      int
+	level   notificationLevel
+	message string
+	at      time.Time
+}
+
+// Notifications shown by the tui.
+// Transient errors become toasts that expire on their own, fatal ones
+// open a modal, and every error is kept in a log the user can browse.
+type notifier struct {
+	nextID int
+	toasts []notification
+	fatal  *notification
+	log    []notification // newest last
+}
+
+// expires a single toast
+type toastExpiredMsg struct {
+	id int
+}
+
+// records err and returns the command that expires its toast, if any
+func (n *notifier) push(err error, level notificationLevel) tea.Cmd {
+	n.nextID++
+	note := notification{
+		id:      n.nextID,
+		level:   level,
+		message: err.Error(),
+		at:      time.Now(),
+	}
+
+	n.log = append(n.log, note)
+	if len(n.log) > maxLoggedErrors {
+		n.log = n.log[len(n.log)-maxLoggedErrors:]
+	}
+
+	if level == levelFatal {
+		n.fatal = &note
+		return nil
+	}
+
+	n.toasts = append(n.toasts, note)
+	id := note.id
+	return tea.Tick(toastDuration, func(time.Time) tea.Msg {
+		return toastExpiredMsg{id: id}
+	})
+}
+
+// removes the toast with the given id
+func (n *notifier) expire(id int) {
+	for i, t := range n.toasts {
+		if t.id == id {
+			n.toasts = append(n.toasts[:i], n.toasts[i+1:]...)
+			return
+		}
+	}
+}
+
+// removes every visible toast
+func (n *notifier) dismissToasts() {
+	n.toasts = nil
+}
+
+// closes the fatal error modal
+func (n *notifier) dismissFatal() {
+	n.fatal = nil
+}
+
+// renders the visible toasts, newest at the bottom
+func (n notifier) toastView(dismiss key.Binding, st styles) string {
+	if len(n.toasts) == 0 {
+		return ""
+	}
+
+	views := make([]string, 0, len(n.toasts))
+	for _, t := range n.toasts {
+		views = append(views, st.toast.Render("⚠ "+t.message))
+	}
+	views = append(views, st.blurredPrompt.Render(helpLine(dismiss)))
+
+	return lipgloss.JoinVertical(lipgloss.Left, views...)
+}
+
+// renders the fatal error modal
+func (n notifier) fatalView(keys keymap.FatalKeys, st styles) string {
+	titleStyle := lipgloss.NewStyle().
+		Foreground(st.Danger).
+		Bold(true)
+
+	helpText := lipgloss.NewStyle().
+		Foreground(st.Subtle)
+
+	content := titleStyle.Render("⚠ Something went wrong") + "\n\n" +
+		lipgloss.NewStyle().Foreground(st.Text).Render(n.fatal.message) + "\n\n" +
+		helpText.Render(helpLine(keys.Retry, keys.Dismiss, keys.Quit))
+
+	return st.errorMessage.Width(60).Render(content)
+}
+
+// renders the error log panel with the most recent errors first
+func (n notifier) logView(height int, back key.Binding, st styles) string {
+	var b strings.Builder
+	b.WriteString(st.formTitle.Render("Error Log"))
+	b.WriteString("\n\n")
+
+	if len(n.log) == 0 {
+		b.WriteString(st.blurredPrompt.Render("No errors so far."))
+	}
+
+	// keep the panel inside the window
+	limit := len(n.log)
+	if height > 0 && limit > height {
+		limit = height
+	}
+
+	for i := len(n.log) - 1; i >= len(n.log)-limit; i-- {
+		entry := n.log[i]
+		level := "error"
+		if entry.level == levelFatal {
+			level = "fatal"
+		}
+		b.WriteString(fmt.Sprintf("%s  %s  %s\n",
+			st.blurredPrompt.Render(entry.at.Format("15:04:05")),
+			st.fieldError.UnsetPaddingLeft().Render(level),
+			st.input.Render(entry.message)))
+	}
+
+	b.WriteString(st.help.Render(helpLine(back)))
+	return b.String()
+}
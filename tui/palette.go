@@ -0,0 +1,329 @@
+package tui
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/gitsync"
+	"github.com/Dima-salang/proompt-vault-tui/internal/keymap"
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/sahilm/fuzzy"
+)
+
+// how many commands are remembered as recent
+const recentCommands = 10
+
+// how many commands the palette shows at a time
+const paletteRows = 12
+
+// A command of the palette: an action of the screen it was opened on.
+type command struct {
+	name string      // shown and matched, as in "New prompt"
+	keys key.Binding // shown next to the name, if it has keys
+	run  func(Model) (Model, tea.Cmd)
+}
+
+// The command palette, searching the commands of the current screen.
+type palette struct {
+	open     bool
+	input    textinput.Model
+	commands []command
+	matches  []paletteMatch // best first
+	cursor   int            // in matches
+}
+
+// a command matching the search of the palette
+type paletteMatch struct {
+	command int   // index in the commands
+	matched []int // runes of the name that matched, to be highlighted
+	recent  bool
+}
+
+func newPalette(commands []command, st styles) palette {
+	input := textinput.New()
+	input.Placeholder = "Type a command..."
+	input.Prompt = "› "
+	input.Width = 50
+	input.PromptStyle = st.focusedPrompt
+	input.TextStyle = st.input
+	input.Focus()
+	return palette{open: true, input: input, commands: commands}
+}
+
+// ranks the commands for a search: the recent ones first, the latest
+// first, then the others by how well they match. an empty search
+// matches every command, in order.
+func rankCommands(commands []command, query string, recent []string) []paletteMatch {
+	matches := []paletteMatch{}
+	if query == "" {
+		for i := range commands {
+			matches = append(matches, paletteMatch{command: i})
+		}
+	} else {
+		names := make([]string, len(commands))
+		for i, c := range commands {
+			names[i] = c.name
+		}
+		for _, f := range fuzzy.Find(query, names) {
+			matches = append(matches, paletteMatch{command: f.Index, matched: f.MatchedIndexes})
+		}
+	}
+
+	rank := func(m paletteMatch) int {
+		if i := slices.Index(recent, commands[m.command].name); i >= 0 {
+			return i
+		}
+		return len(recent)
+	}
+	slices.SortStableFunc(matches, func(a, b paletteMatch) int {
+		return rank(a) - rank(b)
+	})
+	for i := range matches {
+		matches[i].recent = rank(matches[i]) < len(recent)
+	}
+	return matches
+}
+
+// matches the commands to the search again, back on the best one
+func (p *palette) search(recent []string) {
+	p.matches = rankCommands(p.commands, p.input.Value(), recent)
+	p.cursor = 0
+}
+
+// moves the cursor, wrapping around
+func (p *palette) move(delta int) {
+	if len(p.matches) == 0 {
+		return
+	}
+	p.cursor = (p.cursor + delta + len(p.matches)) % len(p.matches)
+}
+
+// the command under the cursor, nil if nothing matches
+func (p palette) selected() *command {
+	if p.cursor >= len(p.matches) {
+		return nil
+	}
+	return &p.commands[p.matches[p.cursor].command]
+}
+
+func (p palette) view(width int, keys keymap.PaletteKeys, st styles) string {
+	var b strings.Builder
+	b.WriteString(st.formTitle.Render("Commands"))
+	b.WriteString("\n")
+	b.WriteString(p.input.View())
+	b.WriteString("\n\n")
+
+	if len(p.matches) == 0 {
+		b.WriteString(st.blurredPrompt.Render("No matching command."))
+		b.WriteString("\n")
+	}
+
+	// scroll the cursor into view
+	first := max(p.cursor-paletteRows+1, 0)
+	last := min(first+paletteRows, len(p.matches))
+	width = min(max(width, 40), 72)
+	for i := first; i < last; i++ {
+		match := p.matches[i]
+		c := p.commands[match.command]
+
+		style, prefix := st.input, "  "
+		if i == p.cursor {
+			style, prefix = st.focusedPrompt, st.focusedPrompt.Render("▸ ")
+		}
+		name := highlight(c.name, match.matched, style, lipgloss.NewStyle().Foreground(st.Secondary).Underline(true))
+		right := ""
+		if match.recent {
+			right = st.blurredPrompt.Render("recent  ")
+		}
+		if len(c.keys.Keys()) > 0 {
+			right += lipgloss.NewStyle().Foreground(st.Subtle).Render(c.keys.Help().Key)
+		}
+		gap := max(width-lipgloss.Width(prefix+name)-lipgloss.Width(right), 2)
+		b.WriteString(prefix + name + strings.Repeat(" ", gap) + right + "\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(st.help.Render(helpLine(keys.Up, keys.Down, keys.Run, keys.Close)))
+	return b.String()
+}
+
+// opens the palette on the commands of the current screen
+func (m Model) openPalette() (Model, tea.Cmd) {
+	m.palette = newPalette(m.commands(), m.styles)
+	m.palette.search(m.recent)
+	return m, textinput.Blink
+}
+
+// closes the palette and runs the selected command, remembering it
+func (m Model) runCommand() (Model, tea.Cmd) {
+	c := m.palette.selected()
+	m.palette.open = false
+	if c == nil {
+		return m, nil
+	}
+
+	m.recent = slices.DeleteFunc(slices.Clone(m.recent), func(name string) bool { return name == c.name })
+	m.recent = append([]string{c.name}, m.recent...)
+	m.recent = m.recent[:min(len(m.recent), recentCommands)]
+
+	m, cmd := c.run(m)
+	return m, tea.Batch(cmd, m.saveHistory(m.recent))
+}
+
+// Returns the commands of the current screen, then the ones of every
+// screen.
+func (m Model) commands() []command {
+	k := m.keys
+	commands := []command{}
+	add := func(name string, keys key.Binding, run func(Model) (Model, tea.Cmd)) {
+		commands = append(commands, command{name: name, keys: keys, run: run})
+	}
+	none := key.Binding{}
+	i, selected := m.list.SelectedItem().(item)
+
+	switch m.state {
+	case stateList:
+		add("New prompt", k.List.New, Model.newPrompt)
+		if selected {
+			add("Copy prompt", k.List.Copy, Model.copySelected)
+			add("Edit prompt", k.List.Edit, Model.editPrompt)
+			add("Duplicate prompt", k.List.Clone, Model.clonePrompt)
+			add("Preview prompt", k.List.Preview, Model.previewSelected)
+			if i.prompt.Pinned {
+				add("Unpin prompt", k.List.Pin, Model.pinSelected)
+			} else {
+				add("Pin prompt", k.List.Pin, Model.pinSelected)
+			}
+			add("Delete prompt", k.List.Delete, Model.deleteSelected)
+		}
+		add("Filter prompts", k.List.Filter, Model.startFilter)
+		add("Find by relevance", k.List.Search, func(m Model) (Model, tea.Cmd) { return m, m.startSearch() })
+		for _, sort := range vault.SortKeys {
+			if sort == m.sort {
+				continue
+			}
+			keys := none
+			if sort == m.nextSort() {
+				keys = k.List.Sort
+			}
+			add("Sort by "+string(sort), keys, func(m Model) (Model, tea.Cmd) { return m.sortBy(sort) })
+		}
+		add("Find duplicates", k.List.Duplicates, func(m Model) (Model, tea.Cmd) { return m, m.findDuplicates })
+		if m.syncer != nil {
+			add("Sync", k.List.Sync, Model.sync)
+		}
+		add("Export the vault", k.List.Export, Model.exportVault)
+		if m.openVault != nil {
+			for _, backend := range vault.Backends {
+				if backend != m.backend {
+					add("Switch to the "+backend+" vault", none, func(m Model) (Model, tea.Cmd) { return m.switchVault(backend) })
+				}
+			}
+		}
+		add("Show error log", k.List.Errors, Model.openErrors)
+		add("Show log", k.List.Logs, Model.openLogs)
+
+	case stateSearch:
+		if selected {
+			add("Copy prompt", k.Search.Copy, Model.copySelected)
+		}
+		add("Back to every prompt", k.Search.Back, Model.leaveSearch)
+
+	case statePreview:
+		add("Copy prompt", k.Preview.Copy, Model.copyPreview)
+		add("Close preview", k.Preview.Back, Model.backToList)
+
+	case stateCreate:
+		cancel, redact := k.Form.Cancel, k.Form.Redact
+		if !m.typing() {
+			cancel, redact = k.FormNormal.Cancel, k.FormNormal.Redact
+		}
+		// submitting only saves from the submit button, so it has no key here
+		add("Save prompt", none, func(m Model) (Model, tea.Cmd) { return m, m.submitForm() })
+		if m.hasSecretIssues() {
+			add("Redact secrets and save", redact, func(m Model) (Model, tea.Cmd) { return m, m.redactForm() })
+		}
+		add("Cancel editing", cancel, Model.backToList)
+
+	case stateDeleteConfirm:
+		add("Delete prompt", k.Confirm.Yes, func(m Model) (Model, tea.Cmd) { return m, m.deletePrompt })
+		add("Keep prompt", k.Confirm.No, Model.cancelDelete)
+
+	case stateDuplicates:
+		add("Merge the group", k.Duplicates.Merge, Model.mergeGroup)
+		add("Next group", k.Duplicates.Next, func(m Model) (Model, tea.Cmd) { m.dedupe.move(1); return m, nil })
+		add("Previous group", k.Duplicates.Prev, func(m Model) (Model, tea.Cmd) { m.dedupe.move(-1); return m, nil })
+		add("Back to the list", k.Duplicates.Back, Model.backToList)
+
+	case stateMerge:
+		add("Keep local", k.Merge.Local, func(m Model) (Model, tea.Cmd) { m.merge.resolve(gitsync.KeepLocal); return m, nil })
+		add("Keep remote", k.Merge.Remote, func(m Model) (Model, tea.Cmd) { m.merge.resolve(gitsync.KeepRemote); return m, nil })
+		add("Apply the sync", k.Merge.Apply, Model.applyMerge)
+		add("Cancel the sync", k.Merge.Back, Model.backToList)
+
+	case stateLogs:
+		add("Cycle log level", k.Logs.Level, func(m Model) (Model, tea.Cmd) { m.logs.cycleLevel(m.styles); return m, nil })
+		add("Follow the log", k.Logs.Bottom, func(m Model) (Model, tea.Cmd) { m.logs.viewport.GotoBottom(); return m, nil })
+		add("Close log", k.Logs.Back, Model.backToList)
+
+	case stateErrorLog:
+		add("Close error log", k.ErrorLog.Back, Model.backToList)
+	}
+
+	for _, t := range m.themes {
+		if t.Name != m.styles.Name {
+			add("Change theme to "+t.Name, none, func(m Model) (Model, tea.Cmd) { return m.switchTheme(t) })
+		}
+	}
+	if len(m.notifier.toasts) > 0 {
+		add("Dismiss notifications", k.Global.Dismiss, func(m Model) (Model, tea.Cmd) { m.notifier.dismissToasts(); return m, nil })
+	}
+	add("Quit", k.Global.Quit, func(m Model) (Model, tea.Cmd) { return m, tea.Quit })
+	return commands
+}
+
+// reads the commands run before, the latest first. a missing or
+// unreadable file is only an empty history.
+func loadHistory(path string) []string {
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	recent := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() && len(recent) < recentCommands {
+		if name := strings.TrimSpace(scanner.Text()); name != "" {
+			recent = append(recent, name)
+		}
+	}
+	return recent
+}
+
+// writes the commands run, one a line
+func (m Model) saveHistory(recent []string) tea.Cmd {
+	if m.historyPath == "" {
+		return nil
+	}
+	return func() tea.Msg {
+		err := os.MkdirAll(filepath.Dir(m.historyPath), 0755)
+		if err == nil {
+			err = os.WriteFile(m.historyPath, []byte(strings.Join(recent, "\n")+"\n"), 0644)
+		}
+		if err != nil {
+			m.logger.Warn("could not save the recent commands", "path", m.historyPath, "error", err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,62 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/keymap"
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+	"github.com/charmbracelet/bubbles/viewport"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// a rendered prompt, ready to be previewed
+type previewMsg struct {
+	prompt  vault.Prompt
+	content string
+	err     error // set if the includes could not be expanded
+}
+
+// Read-only view of a prompt with its includes expanded,
+// exactly as it would be copied.
+type preview struct {
+	prompt   vault.Prompt
+	err      error
+	viewport viewport.Model
+}
+
+func newPreview(msg previewMsg, width, height int, keys keymap.PreviewKeys, st styles) preview {
+	h, v := st.app.GetFrameSize()
+
+	// leave room for the header and the help line
+	vp := viewport.New(max(width-h, 20), max(height-v-8, 5))
+	vp.KeyMap.Up = keys.Up
+	vp.KeyMap.Down = keys.Down
+	vp.KeyMap.PageUp = keys.PageUp
+	vp.KeyMap.PageDown = keys.PageDown
+	vp.KeyMap.HalfPageUp = keys.HalfPageUp
+	vp.KeyMap.HalfPageDown = keys.HalfPageDown
+	vp.SetContent(lipgloss.NewStyle().Foreground(st.Text).Width(max(width-h-2, 20)).Render(msg.content))
+
+	return preview{prompt: msg.prompt, err: msg.err, viewport: vp}
+}
+
+func (p preview) view(keys keymap.PreviewKeys, st styles) string {
+	var b strings.Builder
+	b.WriteString(st.formTitle.Render(p.prompt.Title))
+	if p.prompt.Slug != "" {
+		b.WriteString(st.blurredPrompt.Render("  {{> " + p.prompt.Slug + "}}"))
+	}
+	b.WriteString("\n")
+
+	if p.err != nil {
+		b.WriteString(st.fieldError.Render("⚠ " + p.err.Error() + ", showing it unexpanded"))
+	} else if len(vault.Includes(p.prompt.PromptContent)) > 0 {
+		b.WriteString(st.blurredPrompt.PaddingLeft(2).Render("includes expanded"))
+	}
+	b.WriteString("\n\n")
+
+	b.WriteString(p.viewport.View())
+	b.WriteString("\n")
+	b.WriteString(st.help.Render(helpLine(keys.Up, keys.Down, keys.Copy, keys.Back)))
+	return b.String()
+}
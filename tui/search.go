@@ -0,0 +1,65 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Dima-salang/proompt-vault-tui/internal/vault"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// the prompts most relevant to query, best first
+type rankedMsg struct {
+	query   string
+	results []vault.SearchResult
+}
+
+// ranks the vault against what was typed into the search
+func (m Model) rankPrompts(query string) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := m.commandContext()
+		defer cancel()
+
+		results, err := m.service.RankPrompts(ctx, query, m.pageSize)
+		if err != nil {
+			return errMsg{err: fmt.Errorf("could not search prompts: %w", err)}
+		}
+		return rankedMsg{query: query, results: results}
+	}
+}
+
+// wraps search results as list items showing their scores, and the
+// words of the query highlighted
+func (m Model) rankedItems(query string, results []vault.SearchResult) []list.Item {
+	items := make([]list.Item, len(results))
+	terms := strings.Fields(query)
+	for i, r := range results {
+		items[i] = item{prompt: r.Prompt, estimate: m.budget.Estimate(r.Prompt.PromptContent), score: r.Score, terms: terms}
+	}
+	return items
+}
+
+// switches the list to relevance search, empty until something is typed
+func (m *Model) startSearch() tea.Cmd {
+	m.state = stateSearch
+	m.insert = true
+	m.next, m.loadingMore = "", false
+	m.searchInput.SetValue("")
+	m.list.ResetFilter()
+	return tea.Batch(m.setItems(nil), m.searchInput.Focus())
+}
+
+// the search input above the ranked list
+func (m Model) searchView() string {
+	input := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(m.styles.Primary).
+		Padding(0, 1).
+		Render(m.searchInput.View())
+
+	l := m.list
+	l.SetHeight(max(l.Height()-lipgloss.Height(input), 0))
+	return lipgloss.JoinVertical(lipgloss.Left, input, l.View())
+}
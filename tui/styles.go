@@ -1,82 +1,124 @@
 package tui
 
-import "github.com/charmbracelet/lipgloss"
-
-var (
-	// Modern color palette - no backgrounds, works with any terminal theme
-	primaryColor   = lipgloss.Color("#00E5FF") // Electric Cyan
-	secondaryColor = lipgloss.Color("#B388FF") // Soft Purple
-	accentColor    = lipgloss.Color("#69F0AE") // Mint Green
-	dangerColor    = lipgloss.Color("#FF5370") // Coral Red
-	textColor      = lipgloss.Color("#E4E4E7") // Cool white
-	subtleColor    = lipgloss.Color("#94A3B8") // Slate
-	mutedColor     = lipgloss.Color("#64748B") // Medium slate
-	borderColor    = lipgloss.Color("#475569") // Visible border
-
-	appStyle = lipgloss.NewStyle().
-			Padding(1, 2)
-
-	listTitleStyle = lipgloss.NewStyle().
-			Foreground(primaryColor).
+import (
+	"github.com/Dima-salang/proompt-vault-tui/internal/theme"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// the styles of the tui, all built from the colors of its theme. No
+// style sets a background, so they work with any terminal.
+type styles struct {
+	theme.Theme
+
+	app           lipgloss.Style
+	listTitle     lipgloss.Style
+	listStatus    lipgloss.Style
+	formTitle     lipgloss.Style
+	focusedPrompt lipgloss.Style
+	blurredPrompt lipgloss.Style
+	input         lipgloss.Style
+	focusedButton lipgloss.Style
+	blurredButton lipgloss.Style
+	errorMessage  lipgloss.Style
+	toast         lipgloss.Style
+	fieldError    lipgloss.Style
+	lintInfo      lipgloss.Style
+	lintWarning   lipgloss.Style
+	lintError     lipgloss.Style
+	statusMessage lipgloss.Style
+	help          lipgloss.Style
+}
+
+func newStyles(t theme.Theme) styles {
+	return styles{
+		Theme: t,
+
+		app: lipgloss.NewStyle().
+			Padding(1, 2),
+
+		listTitle: lipgloss.NewStyle().
+			Foreground(t.Primary).
 			BorderStyle(lipgloss.ThickBorder()).
-			BorderForeground(primaryColor).
+			BorderForeground(t.Primary).
 			BorderBottom(true).
 			Padding(0, 1, 1, 1).
 			Bold(true).
-			MarginBottom(1)
+			MarginBottom(1),
 
-	listStatusStyle = lipgloss.NewStyle().
-			Foreground(accentColor).
-			Bold(true)
+		listStatus: lipgloss.NewStyle().
+			Foreground(t.Accent).
+			Bold(true),
 
-	formTitleStyle = lipgloss.NewStyle().
-			Foreground(primaryColor).
+		formTitle: lipgloss.NewStyle().
+			Foreground(t.Primary).
 			Bold(true).
 			MarginBottom(1).
 			PaddingBottom(1).
 			PaddingLeft(1).
 			BorderStyle(lipgloss.NormalBorder()).
-			BorderForeground(primaryColor).
-			BorderLeft(true)
-
-	focusedPromptStyle = lipgloss.NewStyle().
-				Foreground(primaryColor).
-				Bold(true)
-
-	blurredPromptStyle = lipgloss.NewStyle().
-				Foreground(mutedColor)
-
-	inputStyle = lipgloss.NewStyle().
-			Foreground(textColor)
-
-	focusedButtonStyle = lipgloss.NewStyle().
-				Foreground(primaryColor).
-				Padding(0, 3).
-				Bold(true).
-				BorderStyle(lipgloss.RoundedBorder()).
-				BorderForeground(primaryColor)
-
-	blurredButtonStyle = lipgloss.NewStyle().
-				Foreground(mutedColor).
-				BorderStyle(lipgloss.RoundedBorder()).
-				BorderForeground(borderColor).
-				Padding(0, 3)
-
-	errorMessageStyle = lipgloss.NewStyle().
-				Foreground(dangerColor).
-				Bold(true).
-				Padding(1, 2).
-				Border(lipgloss.RoundedBorder()).
-				BorderForeground(dangerColor)
-
-	statusMessageStyle = lipgloss.NewStyle().
-				Foreground(accentColor).
-				Bold(true)
-
-	helpStyle = lipgloss.NewStyle().
-			Foreground(subtleColor).
+			BorderForeground(t.Primary).
+			BorderLeft(true),
+
+		focusedPrompt: lipgloss.NewStyle().
+			Foreground(t.Primary).
+			Bold(true),
+
+		blurredPrompt: lipgloss.NewStyle().
+			Foreground(t.Muted),
+
+		input: lipgloss.NewStyle().
+			Foreground(t.Text),
+
+		focusedButton: lipgloss.NewStyle().
+			Foreground(t.Primary).
+			Padding(0, 3).
+			Bold(true).
+			BorderStyle(lipgloss.RoundedBorder()).
+			BorderForeground(t.Primary),
+
+		blurredButton: lipgloss.NewStyle().
+			Foreground(t.Muted).
+			BorderStyle(lipgloss.RoundedBorder()).
+			BorderForeground(t.Border).
+			Padding(0, 3),
+
+		errorMessage: lipgloss.NewStyle().
+			Foreground(t.Danger).
+			Bold(true).
+			Padding(1, 2).
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(t.Danger),
+
+		toast: lipgloss.NewStyle().
+			Foreground(t.Danger).
+			Padding(0, 1).
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(t.Danger),
+
+		fieldError: lipgloss.NewStyle().
+			Foreground(t.Danger).
+			PaddingLeft(2),
+
+		lintInfo: lipgloss.NewStyle().
+			Foreground(t.Subtle),
+
+		lintWarning: lipgloss.NewStyle().
+			Foreground(t.Secondary).
+			Bold(true),
+
+		lintError: lipgloss.NewStyle().
+			Foreground(t.Danger).
+			Bold(true),
+
+		statusMessage: lipgloss.NewStyle().
+			Foreground(t.Accent).
+			Bold(true),
+
+		help: lipgloss.NewStyle().
+			Foreground(t.Subtle).
 			MarginTop(1).
 			Padding(1, 2).
 			Border(lipgloss.RoundedBorder()).
-			BorderForeground(borderColor)
-)
\ No newline at end of file
+			BorderForeground(t.Border),
+	}
+}